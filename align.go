@@ -0,0 +1,128 @@
+package convolver
+
+import (
+	"fmt"
+	"github.com/mandykoh/go-parallel"
+	"image"
+	"math"
+)
+
+// Alignment describes how one image is best aligned with a reference.
+type Alignment struct {
+	// DX and DY are the offset by which the image should be moved, such as
+	// with Shift, to line it up with the reference.
+	DX, DY float64
+
+	// Correlation is the normalised cross-correlation of the images' linear
+	// luminance at the best whole pixel offset, ranging from -1 to 1.
+	Correlation float64
+}
+
+// Align finds the offset which best aligns img with reference by maximising
+// the normalised cross-correlation of their linear luminance, searching
+// offsets of up to maxOffset pixels in each direction. Images are compared by
+// position relative to their bounds, so they need not share an origin.
+//
+// When subPixel is true, the offset is refined to a fraction of a pixel by
+// fitting a parabola through the correlation either side of the best whole
+// pixel offset in each direction.
+//
+// The correlation is computed directly over the overlap of the images at each
+// offset, so the cost grows with the square of maxOffset; large misalignments
+// are best found on downsampled images first.
+func Align(reference, img image.Image, maxOffset int, subPixel bool, parallelism int) Alignment {
+	if maxOffset < 0 {
+		panic(fmt.Sprintf("maximum offset must be non-negative but was %d", maxOffset))
+	}
+
+	ref := LuminancePlane(reference, parallelism)
+	moving := LuminancePlane(img, parallelism)
+
+	side := 2*maxOffset + 1
+	scores := make([]float64, side*side)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := workerNum; i < len(scores); i += workerCount {
+			scores[i] = normalisedCrossCorrelation(ref, moving, i%side-maxOffset, i/side-maxOffset)
+		}
+	})
+
+	best := 0
+	for i, score := range scores {
+		if score > scores[best] {
+			best = i
+		}
+	}
+
+	bestX, bestY := best%side, best/side
+	result := Alignment{
+		DX:          float64(bestX - maxOffset),
+		DY:          float64(bestY - maxOffset),
+		Correlation: scores[best],
+	}
+
+	if subPixel {
+		if bestX > 0 && bestX < side-1 {
+			result.DX += parabolicPeak(scores[best-1], scores[best], scores[best+1])
+		}
+		if bestY > 0 && bestY < side-1 {
+			result.DY += parabolicPeak(scores[best-side], scores[best], scores[best+side])
+		}
+	}
+
+	return result
+}
+
+// normalisedCrossCorrelation returns the correlation between reference values
+// and moving values displaced by dx and dy, over the region where both planes
+// overlap. Positions are relative to each plane's bounds.
+func normalisedCrossCorrelation(ref, moving *Plane, dx, dy int) float64 {
+	overlap := image.Rect(0, 0, ref.Rect.Dx(), ref.Rect.Dy()).Intersect(
+		image.Rect(dx, dy, moving.Rect.Dx()+dx, moving.Rect.Dy()+dy))
+	minX, maxX := overlap.Min.X, overlap.Max.X
+	minY, maxY := overlap.Min.Y, overlap.Max.Y
+
+	if minX >= maxX || minY >= maxY {
+		return -1
+	}
+
+	count := float64((maxX - minX) * (maxY - minY))
+	var refSum, movingSum float64
+
+	for i := minY; i < maxY; i++ {
+		for j := minX; j < maxX; j++ {
+			refSum += float64(ref.Pix[i*ref.Stride+j])
+			movingSum += float64(moving.Pix[(i-dy)*moving.Stride+(j-dx)])
+		}
+	}
+
+	refMean, movingMean := refSum/count, movingSum/count
+	var product, refSquares, movingSquares float64
+
+	for i := minY; i < maxY; i++ {
+		for j := minX; j < maxX; j++ {
+			a := float64(ref.Pix[i*ref.Stride+j]) - refMean
+			b := float64(moving.Pix[(i-dy)*moving.Stride+(j-dx)]) - movingMean
+			product += a * b
+			refSquares += a * a
+			movingSquares += b * b
+		}
+	}
+
+	if refSquares == 0 || movingSquares == 0 {
+		return 0
+	}
+
+	return product / math.Sqrt(refSquares*movingSquares)
+}
+
+// parabolicPeak returns the offset from the centre sample of the vertex of the
+// parabola through three equally spaced samples, limited to half a sample.
+func parabolicPeak(before, centre, after float64) float64 {
+	curvature := before - 2*centre + after
+	if curvature >= 0 {
+		return 0
+	}
+
+	return math.Max(-0.5, math.Min(0.5, 0.5*(before-after)/curvature))
+}
@@ -0,0 +1,57 @@
+package convolver
+
+import (
+	"image"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func TestAlign(t *testing.T) {
+	source := FastGaussian(randomImage(64, 64), 1.5, 3, runtime.NumCPU())
+	reference := source.SubImage(image.Rect(8, 8, 56, 56))
+
+	t.Run("finds whole pixel offsets", func(t *testing.T) {
+		img := source.SubImage(image.Rect(10, 5, 58, 53))
+
+		result := Align(reference, img, 4, false, runtime.NumCPU())
+
+		if result.DX != 2 || result.DY != -3 {
+			t.Errorf("Expected offset to be 2,-3 but was %v,%v", result.DX, result.DY)
+		}
+		if result.Correlation < 0.999 {
+			t.Errorf("Expected correlation to be close to 1 but was %v", result.Correlation)
+		}
+	})
+
+	t.Run("aligns images with Shift", func(t *testing.T) {
+		img := source.SubImage(image.Rect(5, 9, 53, 57))
+
+		result := Align(reference, img, 4, false, runtime.NumCPU())
+		aligned := Shift(img, result.DX, result.DY, runtime.NumCPU())
+
+		check := Align(reference, aligned, 2, false, runtime.NumCPU())
+		if check.DX != 0 || check.DY != 0 {
+			t.Errorf("Expected shifted image to be aligned but was offset by %v,%v", check.DX, check.DY)
+		}
+	})
+
+	t.Run("refines sub-pixel offsets", func(t *testing.T) {
+		img := Shift(reference, 0.4, -0.3, runtime.NumCPU())
+
+		result := Align(reference, img, 2, true, runtime.NumCPU())
+
+		if math.Abs(result.DX+0.4) > 0.15 || math.Abs(result.DY-0.3) > 0.15 {
+			t.Errorf("Expected offset to be close to -0.4,0.3 but was %v,%v", result.DX, result.DY)
+		}
+	})
+
+	t.Run("panics for negative maximum offsets", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic")
+			}
+		}()
+		Align(reference, reference, -1, false, runtime.NumCPU())
+	})
+}
@@ -0,0 +1,45 @@
+package convolver
+
+import (
+	"fmt"
+	"image"
+)
+
+// KernelWithAnchor returns a kernel with the given weights, in row-major
+// order, for a neighbourhood width by height pixels in size which need not be
+// odd or square, such as the 2×2 Roberts cross operators. The anchor is the
+// position within the neighbourhood, from its top left, of the pixel being
+// computed.
+//
+// The weights are placed in the smallest square kernel centred on the anchor,
+// with zero weights elsewhere. Zero weights contribute nothing to any of the
+// built-in operations, so the result is the same as for a kernel of exactly
+// the given shape, at the cost of visiting the extra taps.
+func KernelWithAnchor(width, height int, anchor image.Point, weights []float32) Kernel {
+	if width < 1 || height < 1 {
+		panic(fmt.Sprintf("kernel dimensions must be positive but were %dx%d", width, height))
+	}
+	if !anchor.In(image.Rect(0, 0, width, height)) {
+		panic(fmt.Sprintf("anchor %v must lie within the %dx%d kernel", anchor, width, height))
+	}
+	if len(weights) != width*height {
+		panic(fmt.Sprintf("expected %d weights for a %dx%d kernel but got %d", width*height, width, height, len(weights)))
+	}
+
+	radius := 0
+	for _, d := range []int{anchor.X, anchor.Y, width - 1 - anchor.X, height - 1 - anchor.Y} {
+		if d > radius {
+			radius = d
+		}
+	}
+
+	k := KernelWithRadius(radius)
+	for i := 0; i < height; i++ {
+		for j := 0; j < width; j++ {
+			s, t := i-anchor.Y+radius, j-anchor.X+radius
+			k.setWeight(s*k.sideLength+t, uniformWeight(weights[i*width+j]))
+		}
+	}
+
+	return k
+}
@@ -0,0 +1,46 @@
+package convolver
+
+import (
+	"image"
+	"runtime"
+	"testing"
+)
+
+func TestKernelWithAnchor(t *testing.T) {
+
+	plane := NewPlane(image.Rect(0, 0, 4, 1))
+	copy(plane.Pix, []float32{1, 3, 7, 15})
+
+	t.Run("places the anchor at the pixel being processed", func(t *testing.T) {
+		forward := KernelWithAnchor(2, 1, image.Pt(0, 0), []float32{-1, 1})
+		backward := KernelWithAnchor(2, 1, image.Pt(1, 0), []float32{-1, 1})
+
+		f := forward.convolvePlane(plane, runtime.NumCPU())
+		b := backward.convolvePlane(plane, runtime.NumCPU())
+
+		if expected, actual := float32(3-1), f.ValueAt(0, 0); expected != actual {
+			t.Errorf("Expected forward difference to be %v but was %v", expected, actual)
+		}
+		if expected, actual := float32(7-3), b.ValueAt(2, 0); expected != actual {
+			t.Errorf("Expected backward difference to be %v but was %v", expected, actual)
+		}
+	})
+
+	t.Run("uses the smallest square kernel containing the neighbourhood", func(t *testing.T) {
+		k := KernelWithAnchor(3, 1, image.Pt(0, 0), []float32{1, 1, 1})
+
+		if expected, actual := 2, k.radius; expected != actual {
+			t.Errorf("Expected radius to be %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("panics for an anchor outside the neighbourhood", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic")
+			}
+		}()
+
+		KernelWithAnchor(2, 2, image.Pt(2, 0), []float32{1, 1, 1, 1})
+	})
+}
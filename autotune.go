@@ -0,0 +1,153 @@
+package convolver
+
+import (
+	"fmt"
+	"image"
+	"sync"
+	"time"
+)
+
+// autoTuneTileSizes are the tile sizes compared by ApplyAutoTuned, in addition
+// to untiled application. Zero means untiled.
+var autoTuneTileSizes = []int{0, 32, 64, 128, 256}
+
+// TuningChoice is a configuration chosen by ApplyAutoTuned: either the named
+// registered backend, or the built-in implementation in tiles of TileSize,
+// where zero means untiled.
+type TuningChoice struct {
+	Backend  string
+	TileSize int
+}
+
+// TuningStore records the configurations chosen by ApplyAutoTuned, so that
+// tuning happens once per machine rather than once per process if the store
+// is persistent. Implementations must be safe for concurrent use.
+type TuningStore interface {
+	Get(key string) (choice TuningChoice, ok bool)
+	Put(key string, choice TuningChoice)
+}
+
+// ApplyAutoTuned applies an operation such as k.Avg to an image using the
+// fastest of several strategies on the current machine for images of similar
+// size, kernel radius and parallelism. The id identifies the operation, as
+// for CachedStage; if it is "avg", the registered name of Avg, every
+// registered backend is also a candidate, since backends replace only the
+// average.
+//
+// The first time a combination is seen, the operation is applied once with
+// each strategy and the fastest is recorded in the store; later calls use the
+// recorded strategy directly, falling back to the built-in implementation if
+// a recorded backend is no longer registered or declines the work. The
+// built-in strategies give identical results, and backends are expected to
+// match them.
+func (k *Kernel) ApplyAutoTuned(img image.Image, op OpFunc, id string, store TuningStore, parallelism int) *image.NRGBA {
+	key := k.tuningKey(id, img.Bounds(), parallelism)
+
+	if choice, ok := store.Get(key); ok {
+		if result, ok := k.applyWithChoice(img, op, choice, parallelism); ok {
+			return result
+		}
+		return k.applyImage(img, op, parallelism)
+	}
+
+	var result *image.NRGBA
+	var best TuningChoice
+	var bestDuration time.Duration
+
+	for _, choice := range autoTuneCandidates(id) {
+		start := time.Now()
+		candidate, ok := k.applyWithChoice(img, op, choice, parallelism)
+		if !ok {
+			continue
+		}
+
+		if duration := time.Since(start); result == nil || duration < bestDuration {
+			best, bestDuration = choice, duration
+		}
+		result = candidate
+	}
+
+	store.Put(key, best)
+
+	return result
+}
+
+// applyWithChoice applies an operation with the configuration described by
+// choice, returning false if its backend isn't registered or declines the
+// work.
+func (k *Kernel) applyWithChoice(img image.Image, op OpFunc, choice TuningChoice, parallelism int) (*image.NRGBA, bool) {
+	switch {
+	case choice.Backend != "":
+		b, ok := lookupBackend(choice.Backend)
+		if !ok {
+			return nil, false
+		}
+		return b.ApplyAvg(k, img, parallelism)
+
+	case choice.TileSize == 0:
+		return k.applyImage(img, op, parallelism), true
+	}
+
+	return k.ApplyTiled(img, op, choice.TileSize, nil, parallelism), true
+}
+
+// autoTuneCandidates returns the configurations compared by ApplyAutoTuned
+// for the operation with the given id. The untiled built-in implementation
+// comes first, so that it is always available.
+func autoTuneCandidates(id string) []TuningChoice {
+	var candidates []TuningChoice
+
+	for _, tileSize := range autoTuneTileSizes {
+		candidates = append(candidates, TuningChoice{TileSize: tileSize})
+	}
+	if id == "avg" {
+		for _, name := range BackendNames() {
+			candidates = append(candidates, TuningChoice{Backend: name})
+		}
+	}
+
+	return candidates
+}
+
+// tuningKey identifies the class of work for which a tuning choice applies.
+// Image dimensions are rounded up to powers of two, so that images of similar
+// size share a choice.
+func (k *Kernel) tuningKey(id string, bounds image.Rectangle, parallelism int) string {
+	return fmt.Sprintf("%s r=%d size=%dx%d p=%d", id, k.radius, nextPowerOfTwo(bounds.Dx()), nextPowerOfTwo(bounds.Dy()), parallelism)
+}
+
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p *= 2
+	}
+	return p
+}
+
+// MemoryTuningStore is a TuningStore which holds choices in memory for the
+// life of the process.
+type MemoryTuningStore struct {
+	mutex   sync.RWMutex
+	choices map[string]TuningChoice
+}
+
+func (s *MemoryTuningStore) Get(key string) (TuningChoice, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	choice, ok := s.choices[key]
+	return choice, ok
+}
+
+func (s *MemoryTuningStore) Put(key string, choice TuningChoice) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.choices[key] = choice
+}
+
+func NewMemoryTuningStore() *MemoryTuningStore {
+	return &MemoryTuningStore{
+		choices: map[string]TuningChoice{},
+	}
+}
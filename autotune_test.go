@@ -0,0 +1,131 @@
+package convolver
+
+import (
+	"image"
+	"runtime"
+	"testing"
+)
+
+type countingTuningStore struct {
+	*MemoryTuningStore
+	puts int
+}
+
+func (s *countingTuningStore) Put(key string, choice TuningChoice) {
+	s.puts++
+	s.MemoryTuningStore.Put(key, choice)
+}
+
+type builtInBackend struct {
+	calls int
+}
+
+func (b *builtInBackend) Accepts(k *Kernel, bounds image.Rectangle) bool {
+	return true
+}
+
+func (b *builtInBackend) ApplyAvg(k *Kernel, img image.Image, parallelism int) (*image.NRGBA, bool) {
+	b.calls++
+	return k.applyImage(img, k.Avg, parallelism), true
+}
+
+func TestApplyAutoTuned(t *testing.T) {
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{1, 2, 1, 2, 4, 2, 1, 2, 1})
+
+	img := randomImage(70, 50)
+	expected := kernel.ApplyAvg(img, runtime.NumCPU())
+
+	checkResult := func(t *testing.T, actual *image.NRGBA) {
+		for i := range expected.Pix {
+			if expected.Pix[i] != actual.Pix[i] {
+				t.Fatalf("Expected tuned result to match at offset %d", i)
+			}
+		}
+	}
+
+	t.Run("tunes once and reuses the recorded choice", func(t *testing.T) {
+		store := &countingTuningStore{MemoryTuningStore: NewMemoryTuningStore()}
+
+		checkResult(t, kernel.ApplyAutoTuned(img, kernel.Avg, "weighted", store, runtime.NumCPU()))
+		checkResult(t, kernel.ApplyAutoTuned(img, kernel.Avg, "weighted", store, runtime.NumCPU()))
+
+		other := randomImage(80, 60)
+		kernel.ApplyAutoTuned(other, kernel.Avg, "weighted", store, runtime.NumCPU())
+
+		if expected, actual := 1, store.puts; expected != actual {
+			t.Errorf("Expected %d tuning run but there were %d", expected, actual)
+		}
+	})
+
+	t.Run("uses a recorded tile size", func(t *testing.T) {
+		store := NewMemoryTuningStore()
+
+		for _, tileSize := range autoTuneTileSizes {
+			store.Put(kernel.tuningKey("weighted", img.Rect, 2), TuningChoice{TileSize: tileSize})
+			checkResult(t, kernel.ApplyAutoTuned(img, kernel.Avg, "weighted", store, 2))
+		}
+	})
+
+	t.Run("untiled application honours the edge mode", func(t *testing.T) {
+		k := kernel
+		k.SetEdgeMode(EdgeWrap)
+		expected := k.ApplyAvg(img, 2)
+
+		store := NewMemoryTuningStore()
+		store.Put(k.tuningKey("weighted", img.Rect, 2), TuningChoice{})
+		actual := k.ApplyAutoTuned(img, k.Avg, "weighted", store, 2)
+
+		for i := range expected.Pix {
+			if expected.Pix[i] != actual.Pix[i] {
+				t.Fatalf("Expected wrapped result to match at offset %d", i)
+			}
+		}
+	})
+
+	t.Run("registered backends are candidates for the average", func(t *testing.T) {
+		backend := &builtInBackend{}
+		RegisterBackend("test-autotuned", backend)
+
+		store := NewMemoryTuningStore()
+		checkResult(t, kernel.ApplyAutoTuned(img, kernel.Avg, "avg", store, 2))
+
+		if backend.calls != 1 {
+			t.Errorf("Expected backend to be tried once but was called %d times", backend.calls)
+		}
+
+		store.Put(kernel.tuningKey("avg", img.Rect, 2), TuningChoice{Backend: "test-autotuned"})
+		checkResult(t, kernel.ApplyAutoTuned(img, kernel.Avg, "avg", store, 2))
+
+		if backend.calls != 2 {
+			t.Errorf("Expected recorded backend to be used but was called %d times", backend.calls)
+		}
+
+		kernel.ApplyAutoTuned(img, kernel.Avg, "weighted", NewMemoryTuningStore(), 2)
+
+		if backend.calls != 2 {
+			t.Errorf("Expected backend not to be tried for other ops but was called %d times", backend.calls)
+		}
+	})
+
+	t.Run("falls back to the built-in implementation for an unknown recorded backend", func(t *testing.T) {
+		store := NewMemoryTuningStore()
+		store.Put(kernel.tuningKey("avg", img.Rect, 2), TuningChoice{Backend: "nonexistent"})
+
+		checkResult(t, kernel.ApplyAutoTuned(img, kernel.Avg, "avg", store, 2))
+	})
+
+	t.Run("keys choices by size class, radius and parallelism", func(t *testing.T) {
+		key := kernel.tuningKey("avg", image.Rect(0, 0, 70, 50), 4)
+
+		if expected := "avg r=1 size=128x64 p=4"; key != expected {
+			t.Errorf("Expected key %q but was %q", expected, key)
+		}
+		if other := kernel.tuningKey("avg", image.Rect(0, 0, 100, 60), 4); other != key {
+			t.Errorf("Expected similar sizes to share a key but got %q", other)
+		}
+		if other := kernel.tuningKey("avg", image.Rect(0, 0, 70, 50), 2); other == key {
+			t.Errorf("Expected different parallelism to have a different key")
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package convolver
+
+import (
+	"github.com/mandykoh/go-parallel"
+	"image"
+	"math"
+)
+
+// Bloom adds a glow around the bright areas of an image, as produced by light
+// scattering in a camera lens or the eye.
+//
+// Light above the threshold luminance is extracted and blurred at a number of
+// scales, starting with a Gaussian of standard deviation sigma and doubling
+// at each level of a half-resolution pyramid. The blurred levels are averaged
+// and added back onto the image, scaled by intensity. All of this is done in
+// linear light without clamping, so HDR inputs should be given as FloatImage;
+// other images are linearised first.
+func Bloom(img image.Image, threshold float32, sigma float64, levels int, intensity float32, parallelism int) *FloatImage {
+	if levels < 1 {
+		levels = 1
+	}
+
+	input := FloatImageFromImage(img, parallelism)
+	bright := brightPass(input, threshold, parallelism)
+	blur := GaussianKernel(sigma)
+
+	glow := NewFloatImage(input.Rect)
+	level := bright
+
+	for l := 0; l < levels; l++ {
+		if l > 0 {
+			if level.Rect.Dx() < 2 || level.Rect.Dy() < 2 {
+				break
+			}
+			level = downsampleFloat(level, parallelism)
+		}
+
+		addUpsampled(glow, blur.ApplyAvgFloat(level, parallelism), intensity/float32(levels), parallelism)
+	}
+
+	result := NewFloatImage(input.Rect)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := workerNum * 4; i < len(result.Pix); i += workerCount * 4 {
+			result.Pix[i] = input.Pix[i] + glow.Pix[i]
+			result.Pix[i+1] = input.Pix[i+1] + glow.Pix[i+1]
+			result.Pix[i+2] = input.Pix[i+2] + glow.Pix[i+2]
+			result.Pix[i+3] = input.Pix[i+3]
+		}
+	})
+
+	return result
+}
+
+// addUpsampled bilinearly resamples src to the bounds of dst and adds it on,
+// scaled by amount. Alpha is left unchanged.
+func addUpsampled(dst, src *FloatImage, amount float32, parallelism int) {
+	scaleX := float64(src.Rect.Dx()) / float64(dst.Rect.Dx())
+	scaleY := float64(src.Rect.Dy()) / float64(dst.Rect.Dy())
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := dst.Rect.Min.Y + workerNum; i < dst.Rect.Max.Y; i += workerCount {
+			y := (float64(i-dst.Rect.Min.Y)+0.5)*scaleY - 0.5
+
+			for j := dst.Rect.Min.X; j < dst.Rect.Max.X; j++ {
+				x := (float64(j-dst.Rect.Min.X)+0.5)*scaleX - 0.5
+
+				r, g, b := sampleBilinear(src, x, y)
+				offset := dst.offset(j, i)
+				dst.Pix[offset] += r * amount
+				dst.Pix[offset+1] += g * amount
+				dst.Pix[offset+2] += b * amount
+			}
+		}
+	})
+}
+
+// brightPass returns the part of an image brighter than the threshold
+// luminance, scaling each pixel's colour by the fraction of its luminance
+// which exceeds the threshold so that hues are preserved.
+func brightPass(img *FloatImage, threshold float32, parallelism int) *FloatImage {
+	result := NewFloatImage(img.Rect)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := workerNum * 4; i < len(img.Pix); i += workerCount * 4 {
+			r, g, b, a := img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3]
+			luminance := 0.2126*r + 0.7152*g + 0.0722*b
+
+			if luminance > threshold && luminance > 0 {
+				scale := (luminance - threshold) / luminance
+				result.Pix[i] = r * scale
+				result.Pix[i+1] = g * scale
+				result.Pix[i+2] = b * scale
+			}
+			result.Pix[i+3] = a
+		}
+	})
+
+	return result
+}
+
+// downsampleFloat halves the resolution of an image by averaging 2x2 blocks of
+// pixels. An odd final row or column is averaged with itself.
+func downsampleFloat(img *FloatImage, parallelism int) *FloatImage {
+	bounds := image.Rect(0, 0, (img.Rect.Dx()+1)/2, (img.Rect.Dy()+1)/2)
+	result := NewFloatImage(bounds)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := bounds.Min.Y + workerNum; i < bounds.Max.Y; i += workerCount {
+			y0 := img.Rect.Min.Y + i*2
+			y1 := clampInt(y0+1, img.Rect.Min.Y, img.Rect.Max.Y-1)
+
+			for j := bounds.Min.X; j < bounds.Max.X; j++ {
+				x0 := img.Rect.Min.X + j*2
+				x1 := clampInt(x0+1, img.Rect.Min.X, img.Rect.Max.X-1)
+
+				offset := result.offset(j, i)
+				for c := 0; c < 4; c++ {
+					result.Pix[offset+c] = (img.Pix[img.offset(x0, y0)+c] +
+						img.Pix[img.offset(x1, y0)+c] +
+						img.Pix[img.offset(x0, y1)+c] +
+						img.Pix[img.offset(x1, y1)+c]) / 4
+				}
+			}
+		}
+	})
+
+	return result
+}
+
+// sampleBilinear returns the bilinearly interpolated colour of an image at a
+// point given relative to its top left pixel, replicating edge pixels.
+func sampleBilinear(img *FloatImage, x, y float64) (r, g, b float32) {
+	maxX, maxY := img.Rect.Dx()-1, img.Rect.Dy()-1
+
+	floorX, floorY := math.Floor(x), math.Floor(y)
+
+	x0 := clampInt(int(floorX), 0, maxX)
+	y0 := clampInt(int(floorY), 0, maxY)
+	x1 := clampInt(int(floorX)+1, 0, maxX)
+	y1 := clampInt(int(floorY)+1, 0, maxY)
+
+	fx := float32(x - floorX)
+	fy := float32(y - floorY)
+
+	at := func(x, y int) (float32, float32, float32) {
+		r, g, b, _ := img.RGBAAt(img.Rect.Min.X+x, img.Rect.Min.Y+y)
+		return r, g, b
+	}
+
+	r00, g00, b00 := at(x0, y0)
+	r10, g10, b10 := at(x1, y0)
+	r01, g01, b01 := at(x0, y1)
+	r11, g11, b11 := at(x1, y1)
+
+	lerp := func(a, b, t float32) float32 { return a + (b-a)*t }
+
+	r = lerp(lerp(r00, r10, fx), lerp(r01, r11, fx), fy)
+	g = lerp(lerp(g00, g10, fx), lerp(g01, g11, fx), fy)
+	b = lerp(lerp(b00, b10, fx), lerp(b01, b11, fx), fy)
+
+	return r, g, b
+}
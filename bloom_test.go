@@ -0,0 +1,67 @@
+package convolver
+
+import (
+	"image"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func TestBloom(t *testing.T) {
+	img := NewFloatImage(image.Rect(0, 0, 64, 64))
+	for i := range img.Pix {
+		img.Pix[i] = 0.2
+	}
+	img.SetRGBA(32, 32, 50, 40, 30, 1)
+
+	t.Run("spreads light from bright areas", func(t *testing.T) {
+		result := Bloom(img, 1, 2, 3, 1, runtime.NumCPU())
+
+		r, g, b, _ := result.RGBAAt(36, 32)
+		if r <= 0.2 || g <= 0.2 || b <= 0.2 {
+			t.Errorf("Expected glow near the bright pixel but got %v, %v, %v", r, g, b)
+		}
+		if r <= b {
+			t.Errorf("Expected glow to preserve the hue of the source but got %v, %v, %v", r, g, b)
+		}
+
+		if r, _, _, _ := result.RGBAAt(32, 32); r < 50 {
+			t.Errorf("Expected bright pixel to keep its value but was %v", r)
+		}
+		if r, _, _, a := result.RGBAAt(0, 0); math.Abs(float64(r)-0.2) > 1e-3 || a != 0.2 {
+			t.Errorf("Expected distant pixels and alpha to be unaffected but got %v with alpha %v", r, a)
+		}
+	})
+
+	t.Run("adds energy in proportion to intensity", func(t *testing.T) {
+		total := func(f *FloatImage) float64 {
+			sum := 0.0
+			for i := 0; i < len(f.Pix); i += 4 {
+				sum += float64(f.Pix[i])
+			}
+			return sum
+		}
+
+		base := total(img)
+		added := total(Bloom(img, 1, 1, 1, 0.5, runtime.NumCPU())) - base
+
+		// The bright pass keeps (L - 1) / L of the pixel's light, where L is
+		// its luminance
+		luminance := 0.2126*50 + 0.7152*40 + 0.0722*30
+		expected := 0.5 * 50 * (luminance - 1) / luminance
+
+		if math.Abs(added-expected) > expected*0.01 {
+			t.Errorf("Expected bloom to add %v but added %v", expected, added)
+		}
+	})
+
+	t.Run("has no effect below the threshold", func(t *testing.T) {
+		result := Bloom(img, 100, 2, 3, 1, runtime.NumCPU())
+
+		for i := range img.Pix {
+			if img.Pix[i] != result.Pix[i] {
+				t.Fatalf("Expected results to match but differ at index %d", i)
+			}
+		}
+	})
+}
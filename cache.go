@@ -0,0 +1,187 @@
+package convolver
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"image"
+	"image/color"
+	"math"
+	"sync"
+)
+
+// StageCache stores the results of stages for reuse by CachedStage.
+// Implementations must be safe for concurrent use, and may evict entries at
+// any time.
+type StageCache interface {
+	Get(key string) (*image.NRGBA, bool)
+	Put(key string, img *image.NRGBA)
+}
+
+// CachedStage returns a stage which reuses earlier results of the given stage
+// from a cache, keyed by a hash of the input image's content along with id.
+// The id must uniquely identify the stage's definition, including any
+// parameters (for example, "gaussian sigma=2.5"), since stages themselves
+// cannot be compared.
+//
+// This lets interactive applications re-run a pipeline in which only later
+// stages have changed without recomputing the earlier ones. Images are copied
+// into and out of the cache, so results may be freely modified.
+//
+// The input is passed to the stage as it is, so stages which read a
+// FloatImage or 16-bit image at full precision still can, and it is hashed
+// in its own representation, so inputs differing only beyond 8 bits or
+// outside the range 0 to 1 are cached separately.
+func CachedStage(stage Stage, id string, cache StageCache) Stage {
+	return func(img image.Image, parallelism int) *image.NRGBA {
+		key := stageCacheKey(id, img)
+
+		if cached, ok := cache.Get(key); ok {
+			return cloneNRGBA(cached)
+		}
+
+		result := stage(img, parallelism)
+		cache.Put(key, cloneNRGBA(result))
+
+		return result
+	}
+}
+
+// MemoryStageCache is a StageCache which holds up to a fixed number of results
+// in memory, evicting the least recently used when full.
+type MemoryStageCache struct {
+	mutex      sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List
+}
+
+type memoryStageCacheEntry struct {
+	key string
+	img *image.NRGBA
+}
+
+func (c *MemoryStageCache) Get(key string) (*image.NRGBA, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	return element.Value.(*memoryStageCacheEntry).img, true
+}
+
+func (c *MemoryStageCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.order.Len()
+}
+
+func (c *MemoryStageCache) Put(key string, img *image.NRGBA) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*memoryStageCacheEntry).img = img
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&memoryStageCacheEntry{key: key, img: img})
+
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryStageCacheEntry).key)
+	}
+}
+
+func NewMemoryStageCache(maxEntries int) *MemoryStageCache {
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+
+	return &MemoryStageCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+func cloneNRGBA(img *image.NRGBA) *image.NRGBA {
+	clone := image.NewNRGBA(img.Rect)
+	for i := img.Rect.Min.Y; i < img.Rect.Max.Y; i++ {
+		copy(clone.Pix[clone.PixOffset(img.Rect.Min.X, i):clone.PixOffset(img.Rect.Max.X, i)], img.Pix[img.PixOffset(img.Rect.Min.X, i):img.PixOffset(img.Rect.Max.X, i)])
+	}
+	return clone
+}
+
+func stageCacheKey(id string, img image.Image) string {
+	h := sha256.New()
+	bounds := img.Bounds()
+
+	fmt.Fprintf(h, "%T", img)
+
+	var header [4 * 8]byte
+	binary.BigEndian.PutUint64(header[0:], uint64(bounds.Min.X))
+	binary.BigEndian.PutUint64(header[8:], uint64(bounds.Min.Y))
+	binary.BigEndian.PutUint64(header[16:], uint64(bounds.Max.X))
+	binary.BigEndian.PutUint64(header[24:], uint64(bounds.Max.Y))
+	h.Write(header[:])
+
+	switch src := img.(type) {
+	case *image.NRGBA:
+		hashPixRows(h, src.Pix, src.Stride, bounds, 4)
+	case *image.RGBA:
+		hashPixRows(h, src.Pix, src.Stride, bounds, 4)
+	case *image.NRGBA64:
+		hashPixRows(h, src.Pix, src.Stride, bounds, 8)
+	case *image.RGBA64:
+		hashPixRows(h, src.Pix, src.Stride, bounds, 8)
+	case *image.Gray:
+		hashPixRows(h, src.Pix, src.Stride, bounds, 1)
+	case *image.Gray16:
+		hashPixRows(h, src.Pix, src.Stride, bounds, 2)
+
+	case *FloatImage:
+		row := make([]byte, bounds.Dx()*4*4)
+		for i := 0; i < bounds.Dy(); i++ {
+			for j, v := range src.Pix[i*src.Stride:][:bounds.Dx()*4] {
+				binary.BigEndian.PutUint32(row[j*4:], math.Float32bits(v))
+			}
+			h.Write(row)
+		}
+
+	default:
+		var pixel [8]byte
+		for i := bounds.Min.Y; i < bounds.Max.Y; i++ {
+			for j := bounds.Min.X; j < bounds.Max.X; j++ {
+				c := color.NRGBA64Model.Convert(img.At(j, i)).(color.NRGBA64)
+				binary.BigEndian.PutUint16(pixel[0:], c.R)
+				binary.BigEndian.PutUint16(pixel[2:], c.G)
+				binary.BigEndian.PutUint16(pixel[4:], c.B)
+				binary.BigEndian.PutUint16(pixel[6:], c.A)
+				h.Write(pixel[:])
+			}
+		}
+	}
+
+	h.Write([]byte(id))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashPixRows writes the bytes of each row of pixels within bounds, for an
+// image whose Pix begins at the top left of the bounds.
+func hashPixRows(h hash.Hash, pix []uint8, stride int, bounds image.Rectangle, bytesPerPixel int) {
+	for i := 0; i < bounds.Dy(); i++ {
+		h.Write(pix[i*stride:][:bounds.Dx()*bytesPerPixel])
+	}
+}
@@ -0,0 +1,128 @@
+package convolver
+
+import (
+	"image"
+	"runtime"
+	"testing"
+)
+
+func TestCachedStage(t *testing.T) {
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{
+		1, 2, 1,
+		2, 4, 2,
+		1, 2, 1,
+	})
+
+	calls := 0
+	countingStage := func(img image.Image, parallelism int) *image.NRGBA {
+		calls++
+		return kernel.ApplyAvg(img, parallelism)
+	}
+
+	cache := NewMemoryStageCache(4)
+	stage := CachedStage(countingStage, "blur", cache)
+
+	img := randomImage(20, 20)
+	expectedImg := kernel.ApplyAvg(img, runtime.NumCPU())
+
+	t.Run("reuses results for identical input", func(t *testing.T) {
+		first := stage(img, runtime.NumCPU())
+		first.Pix[0] ^= 0xff
+
+		second := stage(img, runtime.NumCPU())
+
+		if expected, actual := 1, calls; expected != actual {
+			t.Errorf("Expected stage to be computed %d time but was computed %d times", expected, actual)
+		}
+		for i := range expectedImg.Pix {
+			if expectedImg.Pix[i] != second.Pix[i] {
+				t.Fatalf("Expected cached result to match but differs at byte %d", i)
+			}
+		}
+	})
+
+	t.Run("recomputes for different input content", func(t *testing.T) {
+		other := randomImage(20, 20)
+		stage(other, runtime.NumCPU())
+
+		if expected, actual := 2, calls; expected != actual {
+			t.Errorf("Expected stage to be computed %d times but was computed %d times", expected, actual)
+		}
+	})
+
+	t.Run("distinguishes stages by id", func(t *testing.T) {
+		CachedStage(countingStage, "blur v2", cache)(img, runtime.NumCPU())
+
+		if expected, actual := 3, calls; expected != actual {
+			t.Errorf("Expected stage to be computed %d times but was computed %d times", expected, actual)
+		}
+	})
+
+	t.Run("skips unchanged earlier stages of a pipeline", func(t *testing.T) {
+		before := calls
+
+		NewPipeline(stage, Exposure(1)).Apply(img, runtime.NumCPU())
+		NewPipeline(stage, Exposure(2)).Apply(img, runtime.NumCPU())
+
+		if expected, actual := before, calls; expected != actual {
+			t.Errorf("Expected cached stage not to be recomputed but it was computed %d more times", actual-expected)
+		}
+	})
+
+	t.Run("passes the original image to the stage", func(t *testing.T) {
+		var received image.Image
+		passthrough := CachedStage(func(img image.Image, parallelism int) *image.NRGBA {
+			received = img
+			return image.NewNRGBA(img.Bounds())
+		}, "passthrough", NewMemoryStageCache(4))
+
+		hdr := NewFloatImage(image.Rect(0, 0, 2, 2))
+		passthrough(hdr, runtime.NumCPU())
+
+		if received != image.Image(hdr) {
+			t.Errorf("Expected stage to receive the original %T but got %T", hdr, received)
+		}
+	})
+
+	t.Run("distinguishes inputs differing only beyond 8 bits", func(t *testing.T) {
+		before := calls
+
+		for _, v := range []float32{2, 4} {
+			hdr := NewFloatImage(image.Rect(0, 0, 4, 4))
+			for i := range hdr.Pix {
+				hdr.Pix[i] = v
+			}
+			stage(hdr, runtime.NumCPU())
+		}
+
+		deep := image.NewNRGBA64(image.Rect(0, 0, 4, 4))
+		stage(deep, runtime.NumCPU())
+		deep.Pix[1] = 1
+		stage(deep, runtime.NumCPU())
+
+		if expected, actual := before+4, calls; expected != actual {
+			t.Errorf("Expected stage to be computed %d times but was computed %d times", expected, actual)
+		}
+	})
+}
+
+func TestMemoryStageCache(t *testing.T) {
+	cache := NewMemoryStageCache(2)
+	img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+
+	cache.Put("a", img)
+	cache.Put("b", img)
+	cache.Get("a")
+	cache.Put("c", img)
+
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("Expected least recently used entry to be evicted")
+	}
+	if _, ok := cache.Get("a"); !ok {
+		t.Errorf("Expected recently used entry to be retained")
+	}
+	if expected, actual := 2, cache.Len(); expected != actual {
+		t.Errorf("Expected %d entries but got %d", expected, actual)
+	}
+}
@@ -0,0 +1,52 @@
+package convolver
+
+import (
+	"image"
+)
+
+// WithChannelOffsets returns a copy of the kernel in which the taps of each
+// channel, in R, G, B, A order, are translated so that the channel is
+// displaced by the given offset in the result. An offset of (2, 0) for red
+// moves the red channel two pixels to the right, as for an RGB split effect
+// or to correct lateral chromatic aberration, in the same single pass as the
+// kernel's own filtering, and with any operation such as Avg or Max.
+//
+// The radius grows by the largest offset in either direction, so that every
+// translated tap still fits. Settings such as the edge mode are copied.
+func (k *Kernel) WithChannelOffsets(offsets [4]image.Point) Kernel {
+	extra := 0
+	for _, o := range offsets {
+		for _, v := range []int{o.X, -o.X, o.Y, -o.Y} {
+			if v > extra {
+				extra = v
+			}
+		}
+	}
+
+	result := *k
+	result.radius = k.radius + extra
+	result.sideLength = result.radius*2 + 1
+	result.weights = make([]kernelWeight, result.sideLength*result.sideLength)
+	result.asymmetricPairs = 0
+
+	weights := make([][4]float32, len(result.weights))
+
+	for i := 0; i < k.sideLength; i++ {
+		for j := 0; j < k.sideLength; j++ {
+			w := k.weights[i*k.sideLength+j]
+
+			// A channel displaced by o samples each tap from o earlier.
+			for c, v := range [4]float32{w.R, w.G, w.B, w.A} {
+				s := i + extra - offsets[c].Y
+				t := j + extra - offsets[c].X
+				weights[s*result.sideLength+t][c] = v
+			}
+		}
+	}
+
+	for i, w := range weights {
+		result.setWeight(i, kernelWeight{R: w[0], G: w[1], B: w[2], A: w[3]})
+	}
+
+	return result
+}
@@ -0,0 +1,72 @@
+package convolver
+
+import (
+	"image"
+	"runtime"
+	"testing"
+)
+
+func TestWithChannelOffsets(t *testing.T) {
+	img := randomImage(40, 30)
+
+	identity := KernelWithRadius(0)
+	identity.SetWeightsUniform([]float32{1})
+
+	t.Run("displaces each channel by its offset", func(t *testing.T) {
+		kernel := identity.WithChannelOffsets([4]image.Point{{X: 2}, {}, {Y: -1}, {}})
+
+		if expected, actual := 5, kernel.SideLength(); expected != actual {
+			t.Errorf("Expected side length to be %d but was %d", expected, actual)
+		}
+
+		result := kernel.ApplyMax(img, runtime.NumCPU())
+
+		// Values pass through linear light, which quantises dark tones by up
+		// to 3 levels
+
+		for i := 2; i < 28; i++ {
+			for j := 2; j < 38; j++ {
+				c := result.NRGBAAt(j, i)
+
+				if expected := img.NRGBAAt(j-2, i).R; absDiff(c.R, expected) > 3 {
+					t.Fatalf("Expected red at %d, %d to be %d but was %d", j, i, expected, c.R)
+				}
+				if expected := img.NRGBAAt(j, i).G; absDiff(c.G, expected) > 3 {
+					t.Fatalf("Expected green at %d, %d to be %d but was %d", j, i, expected, c.G)
+				}
+				if expected := img.NRGBAAt(j, i+1).B; absDiff(c.B, expected) > 3 {
+					t.Fatalf("Expected blue at %d, %d to be %d but was %d", j, i, expected, c.B)
+				}
+			}
+		}
+	})
+
+	t.Run("filters and displaces in a single pass", func(t *testing.T) {
+		blur := GaussianKernel(1)
+		offsets := [4]image.Point{{X: -3, Y: 1}, {X: -3, Y: 1}, {X: -3, Y: 1}, {X: -3, Y: 1}}
+		shifted := blur.WithChannelOffsets(offsets)
+
+		expected := blur.ApplyAvg(img, runtime.NumCPU())
+		actual := shifted.ApplyAvg(img, runtime.NumCPU())
+
+		// Away from the edges, where clipping differs, the result is the
+		// blurred image moved left and down
+		for i := 8; i < 22; i++ {
+			for j := 8; j < 32; j++ {
+				e, a := expected.NRGBAAt(j+3, i-1), actual.NRGBAAt(j, i)
+				if absDiff(e.R, a.R) > 1 || absDiff(e.G, a.G) > 1 || absDiff(e.B, a.B) > 1 || absDiff(e.A, a.A) > 1 {
+					t.Fatalf("Expected %v but was %v at %d, %d", e, a, j, i)
+				}
+			}
+		}
+	})
+
+	t.Run("leaves the kernel unchanged with no offsets", func(t *testing.T) {
+		blur := GaussianKernel(1)
+		same := blur.WithChannelOffsets([4]image.Point{})
+
+		if same.SideLength() != blur.SideLength() || !same.pointSymmetric() {
+			t.Errorf("Expected an identical symmetric kernel")
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/mandykoh/convolver"
+	"image"
+	"io"
+	"math/rand"
+	"time"
+)
+
+// runBench implements the bench subcommand, which times kernel application to
+// a random image at each level of parallelism up to -max-parallelism:
+//
+//	convolve bench --size 4096 --radius 2 --op avg
+func runBench(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("convolve bench", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	size := flags.Int("size", 2048, "width and height in pixels of the test image")
+	radius := flags.Int("radius", 1, "radius in pixels of the uniform kernel")
+	opName := flags.String("op", "avg", "operation to apply: avg, max, min")
+	iterations := flags.Int("iterations", 3, "number of timed runs per parallelism level, of which the fastest is reported")
+	maxParallelism := flags.Int("max-parallelism", convolver.DefaultParallelism(), "highest parallelism level to measure")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	switch {
+	case *size < 1:
+		return fmt.Errorf("size must be at least 1")
+	case *radius < 0:
+		return fmt.Errorf("radius must not be negative")
+	case *iterations < 1:
+		return fmt.Errorf("iterations must be at least 1")
+	case *maxParallelism < 1:
+		return fmt.Errorf("max-parallelism must be at least 1")
+	}
+
+	kernel := uniformKernel(*radius)
+
+	var apply func(img image.Image, parallelism int) *image.NRGBA
+	switch *opName {
+	case "avg":
+		apply = kernel.ApplyAvg
+	case "max":
+		apply = kernel.ApplyMax
+	case "min":
+		apply = kernel.ApplyMin
+	default:
+		return fmt.Errorf("unknown op %q", *opName)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, *size, *size))
+	rand.New(rand.NewSource(1)).Read(img.Pix)
+
+	megapixels := float64(*size) * float64(*size) / 1e6
+
+	fmt.Fprintf(stdout, "%dx%d image, radius %d, op %s\n", *size, *size, *radius, *opName)
+	fmt.Fprintf(stdout, "%11s %12s %10s\n", "parallelism", "time", "MP/s")
+
+	for parallelism := 1; parallelism <= *maxParallelism; parallelism++ {
+		fastest := time.Duration(0)
+
+		for i := 0; i < *iterations; i++ {
+			start := time.Now()
+			apply(img, parallelism)
+			if elapsed := time.Since(start); i == 0 || elapsed < fastest {
+				fastest = elapsed
+			}
+		}
+
+		fmt.Fprintf(stdout, "%11d %12s %10.2f\n", parallelism, fastest.Round(time.Microsecond), megapixels/fastest.Seconds())
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestRunBench(t *testing.T) {
+
+	t.Run("reports throughput for each parallelism level", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+
+		err := run([]string{"bench", "--size", "64", "--radius", "2", "--op", "max", "--iterations", "1", "--max-parallelism", "3"}, stdout, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+		if expected, actual := 5, len(lines); expected != actual {
+			t.Fatalf("Expected %d lines of output but got %d:\n%s", expected, actual, stdout.String())
+		}
+		if expected, actual := "64x64 image, radius 2, op max", lines[0]; expected != actual {
+			t.Errorf("Expected heading %q but was %q", expected, actual)
+		}
+		for i, line := range lines[2:] {
+			if fields := strings.Fields(line); len(fields) != 3 || fields[0] != string(rune('1'+i)) {
+				t.Errorf("Expected result line for parallelism %d but was %q", i+1, line)
+			}
+		}
+	})
+
+	t.Run("rejects unknown ops", func(t *testing.T) {
+		if err := run([]string{"bench", "--op", "median"}, ioutil.Discard, ioutil.Discard); err == nil {
+			t.Errorf("Expected an error but got none")
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mandykoh/convolver"
+	"github.com/mandykoh/prism"
+	"image"
+	"image/color"
+)
+
+type filterParams struct {
+	Sigma      float64
+	Radius     int
+	Amount     float64
+	Passes     int
+	Edge       convolver.EdgeMode
+	EdgeColour color.NRGBA
+}
+
+// withEdge returns a copy of a filter's kernel which treats the image's edges
+// as the parameters ask.
+func (p filterParams) withEdge(kernel convolver.Kernel) *convolver.Kernel {
+	kernel.SetEdgeColour(p.EdgeColour)
+	return kernel.WithEdgeMode(p.Edge)
+}
+
+// requireClip returns an error if the parameters ask for an edge mode, for
+// filters which aren't built from a single kernel and so can't honour one.
+func (p filterParams) requireClip() error {
+	if p.Edge != convolver.EdgeClip {
+		return fmt.Errorf("edge modes are not supported")
+	}
+	return nil
+}
+
+type filterFactory func(params filterParams) (convolver.Stage, error)
+
+var filters = map[string]filterFactory{
+	"auto-sharpen": func(params filterParams) (convolver.Stage, error) {
+		if params.Amount < 0 {
+			return nil, fmt.Errorf("amount must not be negative")
+		}
+		if err := params.requireClip(); err != nil {
+			return nil, err
+		}
+		return convolver.AutoSharpen(params.Amount), nil
+	},
+
+	"box": func(params filterParams) (convolver.Stage, error) {
+		if params.Radius < 0 {
+			return nil, fmt.Errorf("radius must not be negative")
+		}
+		kernel := params.withEdge(uniformKernel(params.Radius))
+		return kernel.ApplyAvg, nil
+	},
+
+	"dilate": func(params filterParams) (convolver.Stage, error) {
+		if params.Radius < 0 {
+			return nil, fmt.Errorf("radius must not be negative")
+		}
+		kernel := params.withEdge(uniformKernel(params.Radius))
+		return kernel.ApplyMax, nil
+	},
+
+	"edge-detect": func(params filterParams) (convolver.Stage, error) {
+		kernel := params.withEdge(edgeDetectKernel())
+		return kernel.ApplyAvg, nil
+	},
+
+	"erode": func(params filterParams) (convolver.Stage, error) {
+		if params.Radius < 0 {
+			return nil, fmt.Errorf("radius must not be negative")
+		}
+		kernel := params.withEdge(uniformKernel(params.Radius))
+		return kernel.ApplyMin, nil
+	},
+
+	"flow-smooth": func(params filterParams) (convolver.Stage, error) {
+		if params.Sigma <= 0 {
+			return nil, fmt.Errorf("sigma must be positive")
+		}
+		if err := params.requireClip(); err != nil {
+			return nil, err
+		}
+		return convolver.FlowSmooth(params.Sigma, 1, 3), nil
+	},
+
+	"gaussian": func(params filterParams) (convolver.Stage, error) {
+		if params.Sigma <= 0 {
+			return nil, fmt.Errorf("sigma must be positive")
+		}
+		kernel := params.withEdge(convolver.GaussianKernel(params.Sigma))
+		return kernel.ApplyAvg, nil
+	},
+
+	"sharpen": func(params filterParams) (convolver.Stage, error) {
+		kernel := params.withEdge(sharpenKernel())
+		return kernel.ApplyAvg, nil
+	},
+
+	"sobel": func(params filterParams) (convolver.Stage, error) {
+		if err := params.requireClip(); err != nil {
+			return nil, err
+		}
+		return func(img image.Image, parallelism int) *image.NRGBA {
+			return prism.ConvertImageToNRGBA(convolver.Sobel(img, parallelism), parallelism)
+		}, nil
+	},
+
+	"unsharp": func(params filterParams) (convolver.Stage, error) {
+		if params.Radius < 1 {
+			return nil, fmt.Errorf("radius must be at least 1")
+		}
+		if err := params.requireClip(); err != nil {
+			return nil, err
+		}
+		return convolver.UnsharpMask(float64(params.Radius)/3, params.Amount), nil
+	},
+}
+
+func buildFilter(name string, params filterParams) (convolver.Stage, error) {
+	factory, ok := filters[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown filter %q", name)
+	}
+
+	stage, err := factory(params)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parameters for filter %s: %v", name, err)
+	}
+
+	if params.Passes > 1 {
+		stage = convolver.Repeat(stage, params.Passes)
+	}
+
+	return stage, nil
+}
+
+func edgeDetectKernel() convolver.Kernel {
+	kernel := convolver.KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{
+		-1, -1, -1,
+		-1, 8, -1,
+		-1, -1, -1,
+	})
+	return kernel
+}
+
+func sharpenKernel() convolver.Kernel {
+	kernel := convolver.KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{
+		0, -1, 0,
+		-1, 5, -1,
+		0, -1, 0,
+	})
+	return kernel
+}
+
+func uniformKernel(radius int) convolver.Kernel {
+	kernel := convolver.KernelWithRadius(radius)
+	for i := 0; i < kernel.SideLength(); i++ {
+		for j := 0; j < kernel.SideLength(); j++ {
+			kernel.SetWeightUniform(j, i, 1)
+		}
+	}
+	return kernel
+}
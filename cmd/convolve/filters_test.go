@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestBuildFilter(t *testing.T) {
+	defaults := filterParams{Sigma: 1, Radius: 1, Amount: 1, Passes: 1}
+
+	t.Run("builds every named preset", func(t *testing.T) {
+		for _, name := range filterNames() {
+			if stage, err := buildFilter(name, defaults); err != nil || stage == nil {
+				t.Errorf("Expected filter %s to be built but got error %v", name, err)
+			}
+		}
+	})
+
+	t.Run("validates parameters", func(t *testing.T) {
+		cases := []struct {
+			Filter string
+			Params filterParams
+		}{
+			{Filter: "gaussian", Params: filterParams{Sigma: 0}},
+			{Filter: "unsharp", Params: filterParams{Radius: 0, Amount: 1}},
+			{Filter: "dilate", Params: filterParams{Radius: -1}},
+			{Filter: "auto-sharpen", Params: filterParams{Amount: -1}},
+			{Filter: "flow-smooth", Params: filterParams{Sigma: 0}},
+		}
+
+		for _, c := range cases {
+			if _, err := buildFilter(c.Filter, c.Params); err == nil {
+				t.Errorf("Expected an error for filter %s with parameters %+v", c.Filter, c.Params)
+			}
+		}
+	})
+}
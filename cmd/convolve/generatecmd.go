@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/mandykoh/convolver"
+	"go/format"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// runKernelGenerate implements the kernel generate subcommand, which writes Go
+// source for a function applying a filter's kernel with its weights unrolled
+// into constants, for use with go:generate:
+//
+//	//go:generate convolve kernel generate gaussian --sigma 1 -package filters -func Gaussian -o gaussian.go
+func runKernelGenerate(name string, args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("convolve kernel generate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	output := flags.String("o", "", "Go file to write (standard output if omitted)")
+	pkg := flags.String("package", "main", "package name of the generated file")
+	funcName := flags.String("func", "", "name of the generated function (required)")
+	params := filterParams{}
+	flags.Float64Var(&params.Sigma, "sigma", 1, "standard deviation in pixels (gaussian)")
+	flags.IntVar(&params.Radius, "radius", 1, "radius in pixels (dilate, erode, box)")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if !token.IsIdentifier(*funcName) {
+		return fmt.Errorf("-func must be a valid Go identifier but was %q", *funcName)
+	}
+	if !token.IsIdentifier(*pkg) {
+		return fmt.Errorf("-package must be a valid Go identifier but was %q", *pkg)
+	}
+
+	factory, ok := kernelPresets[name]
+	if !ok {
+		return fmt.Errorf("no kernel for filter %q; available: %s", name, strings.Join(kernelPresetNames(), ", "))
+	}
+
+	kernels, err := factory(params)
+	if err != nil {
+		return fmt.Errorf("invalid parameters for filter %s: %v", name, err)
+	}
+
+	command := append([]string{"convolve", "kernel", "generate", name}, args...)
+	src, err := generateKernelSource(*pkg, *funcName, strings.Join(command, " "), kernels)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = stdout.Write(src)
+		return err
+	}
+
+	return ioutil.WriteFile(*output, src, 0644)
+}
+
+// generateKernelSource returns formatted Go source declaring a function for
+// each kernel which applies it as Kernel.ApplyAvg does, giving the same
+// results to within rounding. Functions for filters with several kernels are suffixed with each
+// kernel's name.
+//
+// Pixels far enough from the edges for the whole kernel to fit are computed
+// with one statement per non-zero weight and channel, with no loops or
+// look-ups of weights; the remaining pixels use a loop over the weights
+// clipped to the image.
+func generateKernelSource(pkg, funcName, command string, kernels []namedKernel) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	fmt.Fprintf(buf, "// Code generated by %s; DO NOT EDIT.\n\n", command)
+	fmt.Fprintf(buf, "package %s\n\n", pkg)
+	fmt.Fprintf(buf, "import (\n")
+	for _, path := range []string{"github.com/mandykoh/go-parallel", "github.com/mandykoh/prism", "github.com/mandykoh/prism/srgb", "image", "image/color"} {
+		fmt.Fprintf(buf, "\t%q\n", path)
+	}
+	fmt.Fprintf(buf, ")\n")
+
+	for _, nk := range kernels {
+		name := funcName + exportedSuffix(nk.Name)
+		writeKernelFunc(buf, name, &nk.Kernel)
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("error formatting generated source: %v", err)
+	}
+
+	return src, nil
+}
+
+func writeKernelFunc(w io.Writer, name string, k *convolver.Kernel) {
+	side := k.SideLength()
+	radius := side / 2
+	edgeName := unexportedName(name) + "Edge"
+	weightsName := unexportedName(name) + "Weights"
+
+	var total [4]float32
+	for i := 0; i < side; i++ {
+		for j := 0; j < side; j++ {
+			r, g, b, a := k.WeightRGBA(j, i)
+			total[0] += r
+			total[1] += g
+			total[2] += b
+			total[3] += a
+		}
+	}
+
+	fmt.Fprintf(w, "\n// %s applies a %dx%d kernel to an image, averaging colours in linear\n", name, side, side)
+	fmt.Fprintf(w, "// light with the kernel clipped at the image's edges.\n")
+	fmt.Fprintf(w, "func %s(img image.Image, parallelism int) *image.NRGBA {\n", name)
+	fmt.Fprintf(w, "src := prism.ConvertImageToNRGBA(img, parallelism)\n")
+	fmt.Fprintf(w, "bounds := src.Rect\n")
+	fmt.Fprintf(w, "result := image.NewNRGBA(bounds)\n\n")
+	fmt.Fprintf(w, "parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {\n")
+	fmt.Fprintf(w, "for i := bounds.Min.Y + workerNum; i < bounds.Max.Y; i += workerCount {\n")
+	fmt.Fprintf(w, "for j := bounds.Min.X; j < bounds.Max.X; j++ {\n")
+	fmt.Fprintf(w, "if i < bounds.Min.Y+%d || i >= bounds.Max.Y-%d || j < bounds.Min.X+%d || j >= bounds.Max.X-%d {\n", radius, radius, radius, radius)
+	fmt.Fprintf(w, "result.SetNRGBA(j, i, %s(src, j, i))\n", edgeName)
+	fmt.Fprintf(w, "continue\n}\n\n")
+	fmt.Fprintf(w, "o := src.PixOffset(j, i)\n")
+	fmt.Fprintf(w, "s := src.Stride\n")
+	fmt.Fprintf(w, "var r, g, b, a float32\n")
+
+	channelVars := [4]string{"r", "g", "b", "a"}
+
+	for i := 0; i < side; i++ {
+		for j := 0; j < side; j++ {
+			weights := [4]float32{}
+			weights[0], weights[1], weights[2], weights[3] = k.WeightRGBA(j, i)
+
+			offset := pixOffsetExpr(j-radius, i-radius)
+			for c, v := range channelVars {
+				if weights[c] == 0 {
+					continue
+				}
+				index := offset
+				if c > 0 {
+					index += fmt.Sprintf("+%d", c)
+				}
+				if c == 3 {
+					fmt.Fprintf(w, "%s += float32(src.Pix[%s]) / 255 * %s\n", v, index, floatLiteral(weights[c]))
+				} else {
+					fmt.Fprintf(w, "%s += srgb.From8Bit(src.Pix[%s]) * %s\n", v, index, floatLiteral(weights[c]))
+				}
+			}
+		}
+	}
+
+	for c, v := range channelVars {
+		if total[c] > 0 {
+			fmt.Fprintf(w, "%s /= %s\n", v, floatLiteral(total[c]))
+		}
+	}
+
+	fmt.Fprintf(w, "result.SetNRGBA(j, i, srgb.ColorFromLinear(r, g, b).ToNRGBA(a))\n")
+	fmt.Fprintf(w, "}\n}\n})\n\n")
+	fmt.Fprintf(w, "return result\n}\n")
+
+	fmt.Fprintf(w, "\nvar %s = [%d][4]float32{\n", weightsName, side*side)
+	for i := 0; i < side; i++ {
+		for j := 0; j < side; j++ {
+			r, g, b, a := k.WeightRGBA(j, i)
+			fmt.Fprintf(w, "{%s, %s, %s, %s},\n", floatLiteral(r), floatLiteral(g), floatLiteral(b), floatLiteral(a))
+		}
+	}
+	fmt.Fprintf(w, "}\n")
+
+	fmt.Fprintf(w, "\nfunc %s(img *image.NRGBA, x, y int) color.NRGBA {\n", edgeName)
+	fmt.Fprintf(w, "var total, sum [4]float32\n\n")
+	fmt.Fprintf(w, "for s := 0; s < %d; s++ {\n", side)
+	fmt.Fprintf(w, "for t := 0; t < %d; t++ {\n", side)
+	fmt.Fprintf(w, "p := image.Pt(x+t-%d, y+s-%d)\n", radius, radius)
+	fmt.Fprintf(w, "if !p.In(img.Rect) {\ncontinue\n}\n\n")
+	fmt.Fprintf(w, "w := %s[s*%d+t]\n", weightsName, side)
+	fmt.Fprintf(w, "c, alpha := srgb.ColorFromNRGBA(img.NRGBAAt(p.X, p.Y))\n")
+	fmt.Fprintf(w, "for i, v := range [4]float32{c.R, c.G, c.B, alpha} {\n")
+	fmt.Fprintf(w, "total[i] += w[i]\n")
+	fmt.Fprintf(w, "sum[i] += v * w[i]\n")
+	fmt.Fprintf(w, "}\n}\n}\n\n")
+	fmt.Fprintf(w, "for i := range sum {\nif total[i] > 0 {\nsum[i] /= total[i]\n}\n}\n\n")
+	fmt.Fprintf(w, "return srgb.ColorFromLinear(sum[0], sum[1], sum[2]).ToNRGBA(sum[3])\n}\n")
+}
+
+// pixOffsetExpr returns an expression for the offset of the pixel at dx, dy
+// relative to the one at offset o, in an image with stride s.
+func pixOffsetExpr(dx, dy int) string {
+	expr := "o"
+	switch {
+	case dy == 1:
+		expr += "+s"
+	case dy == -1:
+		expr += "-s"
+	case dy != 0:
+		expr += fmt.Sprintf("%+d*s", dy)
+	}
+	if dx != 0 {
+		expr += fmt.Sprintf("%+d", dx*4)
+	}
+	return expr
+}
+
+// floatLiteral formats a float32 so that it parses back to exactly the same
+// value.
+func floatLiteral(v float32) string {
+	s := strconv.FormatFloat(float64(v), 'g', -1, 32)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+func exportedSuffix(name string) string {
+	if name == "" {
+		return ""
+	}
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+func unexportedName(name string) string {
+	r := []rune(name)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
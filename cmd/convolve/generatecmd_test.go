@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunKernelGenerate(t *testing.T) {
+	t.Run("writes valid Go source for a kernel", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+
+		err := run([]string{"kernel", "generate", "sharpen", "-package", "filters", "-func", "Sharpen"}, stdout, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		src := stdout.String()
+
+		file, err := parser.ParseFile(token.NewFileSet(), "sharpen.go", src, 0)
+		if err != nil {
+			t.Fatalf("Expected generated source to parse but got %v:\n%s", err, src)
+		}
+
+		if expected, actual := "filters", file.Name.Name; expected != actual {
+			t.Errorf("Expected package to be %s but was %s", expected, actual)
+		}
+		if !strings.HasPrefix(src, "// Code generated by convolve kernel generate sharpen") {
+			t.Errorf("Expected generated code header but source began:\n%s", src[:80])
+		}
+		if !strings.Contains(src, "func Sharpen(img image.Image, parallelism int) *image.NRGBA") {
+			t.Errorf("Expected Sharpen function in source:\n%s", src)
+		}
+
+		// Only the five non-zero taps of the sharpen kernel are unrolled
+		if expected, actual := 5, strings.Count(src, "r += srgb.From8Bit"); expected != actual {
+			t.Errorf("Expected %d unrolled red taps but found %d", expected, actual)
+		}
+	})
+
+	t.Run("suffixes functions for each kernel of a multi-kernel filter", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "convolve-generate")
+		if err != nil {
+			t.Fatalf("Error creating temporary directory: %v", err)
+		}
+		defer os.RemoveAll(dir)
+
+		output := filepath.Join(dir, "sobel.go")
+
+		err = run([]string{"kernel", "generate", "sobel", "-func", "Sobel", "-o", output}, ioutil.Discard, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		src, err := ioutil.ReadFile(output)
+		if err != nil {
+			t.Fatalf("Error reading generated source: %v", err)
+		}
+
+		for _, decl := range []string{"func SobelX(", "func SobelY(", "func sobelXEdge(", "func sobelYEdge("} {
+			if !strings.Contains(string(src), decl) {
+				t.Errorf("Expected source to contain %q", decl)
+			}
+		}
+	})
+
+	t.Run("rejects invalid function names", func(t *testing.T) {
+		if err := run([]string{"kernel", "generate", "box", "-func", "not valid"}, ioutil.Discard, ioutil.Discard); err == nil {
+			t.Errorf("Expected an error but got none")
+		}
+	})
+}
+
+func TestFloatLiteral(t *testing.T) {
+	for _, v := range []float32{1, -1, 0.1, 1.964128e-05, 1234567} {
+		if s := floatLiteral(v); !strings.ContainsAny(s, ".e") {
+			t.Errorf("Expected %q to be a floating point literal", s)
+		}
+	}
+}
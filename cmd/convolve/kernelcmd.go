@@ -0,0 +1,257 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/mandykoh/convolver"
+	"github.com/mandykoh/convolver/imageio"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+type namedKernel struct {
+	Name   string
+	Kernel convolver.Kernel
+}
+
+type kernelFactory func(params filterParams) ([]namedKernel, error)
+
+// kernelPresets gives the kernels underlying each filter which is a plain
+// kernel application, for inspection with the kernel subcommand.
+var kernelPresets = map[string]kernelFactory{
+	"box":    uniformKernelPreset,
+	"dilate": uniformKernelPreset,
+	"edge-detect": func(params filterParams) ([]namedKernel, error) {
+		return []namedKernel{{Kernel: edgeDetectKernel()}}, nil
+	},
+	"erode": uniformKernelPreset,
+
+	"gaussian": func(params filterParams) ([]namedKernel, error) {
+		if params.Sigma <= 0 {
+			return nil, fmt.Errorf("sigma must be positive")
+		}
+		return []namedKernel{{Kernel: convolver.GaussianKernel(params.Sigma)}}, nil
+	},
+
+	"sharpen": func(params filterParams) ([]namedKernel, error) { return []namedKernel{{Kernel: sharpenKernel()}}, nil },
+
+	"sobel": func(params filterParams) ([]namedKernel, error) {
+		gx, gy := convolver.SobelKernels()
+		return []namedKernel{{Name: "x", Kernel: gx}, {Name: "y", Kernel: gy}}, nil
+	},
+}
+
+func uniformKernelPreset(params filterParams) ([]namedKernel, error) {
+	if params.Radius < 0 {
+		return nil, fmt.Errorf("radius must not be negative")
+	}
+	return []namedKernel{{Kernel: uniformKernel(params.Radius)}}, nil
+}
+
+// runKernel implements the kernel subcommand:
+//
+//	convolve kernel show gaussian --sigma 2 [-o visualisation.png]
+//	convolve kernel generate gaussian --sigma 2 -func Gaussian [-package filters] [-o gaussian.go]
+func runKernel(args []string, stdout, stderr io.Writer) error {
+	if len(args) < 2 || (args[0] != "show" && args[0] != "generate") {
+		return fmt.Errorf("usage: convolve kernel show|generate <%s> [flags]", strings.Join(kernelPresetNames(), "|"))
+	}
+
+	name := args[1]
+
+	if args[0] == "generate" {
+		return runKernelGenerate(name, args[2:], stdout, stderr)
+	}
+
+	flags := flag.NewFlagSet("convolve kernel show", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	output := flags.String("o", "", "optional PNG file to write a visualisation of the kernel to")
+	scale := flags.Int("scale", 16, "size in pixels of each tap in the visualisation")
+	params := filterParams{}
+	flags.Float64Var(&params.Sigma, "sigma", 1, "standard deviation in pixels (gaussian)")
+	flags.IntVar(&params.Radius, "radius", 1, "radius in pixels (dilate, erode, box)")
+
+	if err := flags.Parse(args[2:]); err != nil {
+		return err
+	}
+	if *scale < 1 {
+		return fmt.Errorf("scale must be at least 1")
+	}
+
+	factory, ok := kernelPresets[name]
+	if !ok {
+		return fmt.Errorf("no kernel for filter %q; available: %s", name, strings.Join(kernelPresetNames(), ", "))
+	}
+
+	kernels, err := factory(params)
+	if err != nil {
+		return fmt.Errorf("invalid parameters for filter %s: %v", name, err)
+	}
+
+	for i, k := range kernels {
+		if i > 0 {
+			fmt.Fprintln(stdout)
+		}
+		title := name
+		if k.Name != "" {
+			title += " " + k.Name
+		}
+		printKernel(stdout, title, &k.Kernel)
+	}
+
+	if *output != "" {
+		return imageio.Save(*output, visualiseKernels(kernels, *scale))
+	}
+
+	return nil
+}
+
+func printKernel(w io.Writer, title string, k *convolver.Kernel) {
+	side := k.SideLength()
+	fmt.Fprintf(w, "%s (%dx%d)\n", title, side, side)
+
+	sum := float32(0)
+	uniform := true
+
+	for i := 0; i < side; i++ {
+		for j := 0; j < side; j++ {
+			r, g, b, a := k.WeightRGBA(j, i)
+			if r != g || r != b || r != a {
+				uniform = false
+			}
+			sum += r
+			fmt.Fprintf(w, " %9.4f", r)
+		}
+		fmt.Fprintln(w)
+	}
+
+	if !uniform {
+		fmt.Fprintln(w, "note: channel weights differ; red channel weights shown")
+	}
+	fmt.Fprintf(w, "sum: %.4f\n", sum)
+
+	if isSeparable(k) {
+		fmt.Fprintln(w, "separable: yes")
+	} else {
+		fmt.Fprintln(w, "separable: no")
+	}
+}
+
+// isSeparable reports whether the red channel weights of a kernel form a
+// rank one matrix, and so can be expressed as the product of a column and a
+// row vector.
+func isSeparable(k *convolver.Kernel) bool {
+	side := k.SideLength()
+
+	weight := func(x, y int) float64 {
+		r, _, _, _ := k.WeightRGBA(x, y)
+		return float64(r)
+	}
+
+	// Find the largest weight to use as the pivot for the factorisation
+	pivotX, pivotY, largest := 0, 0, 0.0
+	for i := 0; i < side; i++ {
+		for j := 0; j < side; j++ {
+			if v := math.Abs(weight(j, i)); v > largest {
+				pivotX, pivotY, largest = j, i, v
+			}
+		}
+	}
+
+	if largest == 0 {
+		return true
+	}
+
+	pivot := weight(pivotX, pivotY)
+	tolerance := largest * 1e-5
+
+	for i := 0; i < side; i++ {
+		for j := 0; j < side; j++ {
+			if math.Abs(weight(j, i)-weight(j, pivotY)*weight(pivotX, i)/pivot) > tolerance {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// visualiseKernels draws kernels side by side, with each tap drawn as a block
+// whose brightness gives the magnitude of its weight relative to the largest
+// in the kernel. Positive weights are drawn in white and negative in red.
+func visualiseKernels(kernels []namedKernel, scale int) *image.NRGBA {
+	width, height := 0, 0
+	for i, k := range kernels {
+		if i > 0 {
+			width += scale
+		}
+		width += k.Kernel.SideLength() * scale
+		if h := k.Kernel.SideLength() * scale; h > height {
+			height = h
+		}
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+
+	left := 0
+	for _, nk := range kernels {
+		k := &nk.Kernel
+		side := k.SideLength()
+
+		largest := float32(0)
+		for i := 0; i < side; i++ {
+			for j := 0; j < side; j++ {
+				r, _, _, _ := k.WeightRGBA(j, i)
+				if r < 0 {
+					r = -r
+				}
+				if r > largest {
+					largest = r
+				}
+			}
+		}
+
+		for i := 0; i < side; i++ {
+			for j := 0; j < side; j++ {
+				r, _, _, _ := k.WeightRGBA(j, i)
+
+				c := color.NRGBA{A: 255}
+				if largest > 0 {
+					v := uint8(math.Round(float64(r/largest) * 255))
+					if r >= 0 {
+						c.R, c.G, c.B = v, v, v
+					} else {
+						c.R = uint8(math.Round(float64(-r/largest) * 255))
+					}
+				}
+
+				for y := 0; y < scale; y++ {
+					for x := 0; x < scale; x++ {
+						img.SetNRGBA(left+j*scale+x, i*scale+y, c)
+					}
+				}
+			}
+		}
+
+		left += (side + 1) * scale
+	}
+
+	return img
+}
+
+func kernelPresetNames() []string {
+	names := make([]string, 0, len(kernelPresets))
+	for name := range kernelPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
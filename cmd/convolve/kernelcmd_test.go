@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"github.com/mandykoh/convolver"
+	"github.com/mandykoh/convolver/imageio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunKernel(t *testing.T) {
+
+	t.Run("prints weights, sum and separability", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+
+		err := run([]string{"kernel", "show", "sharpen"}, stdout, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		expected := "sharpen (3x3)\n" +
+			"    0.0000   -1.0000    0.0000\n" +
+			"   -1.0000    5.0000   -1.0000\n" +
+			"    0.0000   -1.0000    0.0000\n" +
+			"sum: 1.0000\n" +
+			"separable: no\n"
+
+		if actual := stdout.String(); expected != actual {
+			t.Errorf("Expected output:\n%s\nbut was:\n%s", expected, actual)
+		}
+	})
+
+	t.Run("shows each kernel of a multi-kernel filter", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+
+		err := run([]string{"kernel", "show", "sobel"}, stdout, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		for _, title := range []string{"sobel x (3x3)", "sobel y (3x3)"} {
+			if !strings.Contains(stdout.String(), title) {
+				t.Errorf("Expected output to contain %q but was:\n%s", title, stdout.String())
+			}
+		}
+	})
+
+	t.Run("writes a visualisation", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "convolve-kernel")
+		if err != nil {
+			t.Fatalf("Error creating temporary directory: %v", err)
+		}
+		defer os.RemoveAll(dir)
+
+		output := filepath.Join(dir, "kernel.png")
+
+		err = run([]string{"kernel", "show", "edge-detect", "-scale", "4", "-o", output}, ioutil.Discard, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		img, err := imageio.Load(output)
+		if err != nil {
+			t.Fatalf("Error loading visualisation: %v", err)
+		}
+
+		if expected, actual := 12, img.Bounds().Dx(); expected != actual {
+			t.Errorf("Expected visualisation to be %d pixels wide but was %d", expected, actual)
+		}
+		if r, g, b, _ := img.At(5, 5).RGBA(); r != 0xffff || g != 0xffff || b != 0xffff {
+			t.Errorf("Expected centre tap to be white but was %v", img.At(5, 5))
+		}
+		if r, g, _, _ := img.At(0, 0).RGBA(); r>>8 != 32 || g != 0 {
+			t.Errorf("Expected corner tap to be dark red but was %v", img.At(0, 0))
+		}
+	})
+
+	t.Run("rejects filters without a kernel", func(t *testing.T) {
+		if err := run([]string{"kernel", "show", "unsharp"}, ioutil.Discard, ioutil.Discard); err == nil {
+			t.Errorf("Expected an error but got none")
+		}
+	})
+}
+
+func TestIsSeparable(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Kernel   convolver.Kernel
+		Expected bool
+	}{
+		{Name: "gaussian", Kernel: convolver.GaussianKernel(2), Expected: true},
+		{Name: "box", Kernel: uniformKernel(2), Expected: true},
+		{Name: "sharpen", Kernel: sharpenKernel(), Expected: false},
+		{Name: "edge-detect", Kernel: edgeDetectKernel(), Expected: false},
+		{Name: "empty", Kernel: convolver.KernelWithRadius(1), Expected: true},
+	}
+
+	for _, c := range cases {
+		if expected, actual := c.Expected, isSeparable(&c.Kernel); expected != actual {
+			t.Errorf("Expected separability of %s kernel to be %v but was %v", c.Name, expected, actual)
+		}
+	}
+
+	gx, _ := convolver.SobelKernels()
+	if !isSeparable(&gx) {
+		t.Errorf("Expected Sobel kernel to be separable")
+	}
+}
@@ -0,0 +1,231 @@
+// Command convolve applies convolver filters to image files from the command
+// line.
+//
+// Usage:
+//
+//	convolve --filter gaussian --sigma 3.5 -o output.png input.png
+//	convolve --filter unsharp --amount 0.8 --radius 2 -o output.jpg input.jpg
+//	convolve --filter sobel -o edges.png input.png
+//	convolve --pipeline pipeline.json -o output-dir input1.png input2.png
+//	convolve --watch --filter sharpen -o output-dir input-dir
+//	convolve --filter dilate --passes 5 --snapshots passes-dir -o output.png input.png
+//	convolve kernel show gaussian --sigma 2 -o kernel.png
+//	convolve kernel generate gaussian --sigma 2 -package filters -func Gaussian -o gaussian.go
+//	convolve bench --size 4096 --radius 2 --op avg
+//	convolve pipeline migrate old.json -o pipeline.json
+//
+// When more than one input is given, -o names a directory into which results
+// are written using the input file names.
+//
+// In watch mode, the single input is a directory which is monitored until the
+// command is interrupted; new and changed images in it are processed into the
+// output directory.
+//
+// With -snapshots, the intermediate result after each pass of a filter (or each
+// stage of a pipeline) is also written to the given directory as a numbered
+// PNG, so the progression can be seen when tuning the number of passes.
+//
+// Embedded ICC profiles are carried through from each input to its output.
+//
+// The kernel show subcommand prints the weights of a filter's kernel along with
+// its sum and whether it is separable, and can write a visualisation of it.
+//
+// The kernel generate subcommand writes Go source for a function which applies
+// a filter's kernel with its weights unrolled into constants, giving the same
+// results as Kernel.ApplyAvg considerably faster. It is intended for use with
+// go:generate by programs which only need one or two fixed filters.
+//
+// The pipeline validate subcommand checks that pipeline files can be loaded,
+// and pipeline migrate rewrites a pipeline file written for an earlier release
+// in the current format version. Older versions are also migrated
+// automatically when loaded with -pipeline.
+//
+// The bench subcommand measures throughput in megapixels per second at each
+// level of parallelism, to help choose a parallelism setting for a machine.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/mandykoh/convolver"
+	"github.com/mandykoh/convolver/imageio"
+	"image"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "convolve: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "bench":
+			return runBench(args[1:], stdout, stderr)
+		case "kernel":
+			return runKernel(args[1:], stdout, stderr)
+		case "pipeline":
+			return runPipeline(args[1:], stdout, stderr)
+		}
+	}
+
+	flags := flag.NewFlagSet("convolve", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	filterName := flags.String("filter", "", "filter to apply: "+strings.Join(filterNames(), ", "))
+	pipelinePath := flags.String("pipeline", "", "JSON pipeline file to apply instead of a single filter")
+	output := flags.String("o", "", "output file path (format chosen by extension)")
+	parallelism := flags.Int("parallelism", convolver.DefaultParallelism(), "number of threads to use")
+	params := filterParams{}
+	flags.Float64Var(&params.Sigma, "sigma", 1, "standard deviation in pixels (gaussian, flow-smooth)")
+	flags.IntVar(&params.Radius, "radius", 1, "radius in pixels (unsharp, dilate, erode, box)")
+	flags.Float64Var(&params.Amount, "amount", 1, "strength of the effect (unsharp, auto-sharpen)")
+	flags.IntVar(&params.Passes, "passes", 1, "number of times to apply the filter")
+	watch := flags.Bool("watch", false, "monitor the input directory and process new or changed images")
+	interval := flags.Duration("interval", time.Second, "how often to check for changes in watch mode")
+	snapshotDir := flags.String("snapshots", "", "directory to write the result of each pass or stage to")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		return fmt.Errorf("no input files specified")
+	}
+	if *output == "" {
+		return fmt.Errorf("an output path must be specified with -o")
+	}
+
+	if *snapshotDir != "" {
+		if *watch || flags.NArg() != 1 {
+			return fmt.Errorf("snapshots can only be written when processing a single input")
+		}
+		if info, err := os.Stat(*snapshotDir); err != nil || !info.IsDir() {
+			return fmt.Errorf("snapshot output %s must be an existing directory", *snapshotDir)
+		}
+	}
+
+	snapshots := newSnapshotWriter(*snapshotDir, flags.Arg(0))
+
+	var stage convolver.Stage
+
+	switch {
+	case *pipelinePath != "" && *filterName != "":
+		return fmt.Errorf("only one of -filter or -pipeline may be specified")
+
+	case *pipelinePath != "":
+		pipeline, err := loadPipelineFile(*pipelinePath)
+		if err != nil {
+			return err
+		}
+		stage = pipeline.Apply
+
+		if *snapshotDir != "" {
+			stage = func(img image.Image, parallelism int) *image.NRGBA {
+				return pipeline.ApplyWithSnapshots(img, snapshots.write, parallelism)
+			}
+		}
+
+	default:
+		var err error
+
+		if *snapshotDir != "" {
+			passes := params.Passes
+			params.Passes = 1
+			if stage, err = buildFilter(*filterName, params); err != nil {
+				return err
+			}
+			stage = convolver.RepeatWithSnapshots(stage, passes, snapshots.write)
+
+		} else if stage, err = buildFilter(*filterName, params); err != nil {
+			return err
+		}
+	}
+
+	if *watch {
+		return watchDirectory(flags.Args(), *output, stage, *parallelism, *interval, stderr)
+	}
+
+	if flags.NArg() == 1 {
+		if err := processFile(flags.Arg(0), *output, stage, *parallelism); err != nil {
+			return err
+		}
+		return snapshots.err
+	}
+
+	if info, err := os.Stat(*output); err != nil || !info.IsDir() {
+		return fmt.Errorf("output %s must be an existing directory when processing multiple inputs", *output)
+	}
+
+	for _, input := range flags.Args() {
+		if err := processFile(input, filepath.Join(*output, filepath.Base(input)), stage, *parallelism); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func processFile(input, output string, stage convolver.Stage, parallelism int) error {
+	img, md, err := imageio.LoadWithMetadata(input)
+	if err != nil {
+		return err
+	}
+
+	return imageio.SaveWithMetadata(output, stage(img, parallelism), md)
+}
+
+func watchDirectory(inputs []string, output string, stage convolver.Stage, parallelism int, interval time.Duration, log io.Writer) error {
+	if len(inputs) != 1 {
+		return fmt.Errorf("watch mode requires exactly one input directory")
+	}
+	if interval <= 0 {
+		return fmt.Errorf("watch interval must be positive")
+	}
+	for _, dir := range []string{inputs[0], output} {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("%s must be an existing directory in watch mode", dir)
+		}
+	}
+
+	w, err := newWatcher(inputs[0], output, stage, parallelism, log)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	go func() {
+		select {
+		case <-interrupts:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return w.Run(ctx, interval)
+}
+
+func filterNames() []string {
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
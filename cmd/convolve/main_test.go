@@ -0,0 +1,136 @@
+package main
+
+import (
+	"github.com/mandykoh/convolver"
+	"github.com/mandykoh/convolver/imageio"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "convolve")
+	if err != nil {
+		t.Fatalf("Error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join("..", "..", "test-images", "avocado.png")
+	img, err := imageio.Load(input)
+	if err != nil {
+		t.Fatalf("Error loading test image: %v", err)
+	}
+
+	t.Run("applies a named preset with parameters", func(t *testing.T) {
+		output := filepath.Join(dir, "gaussian.png")
+
+		err := run([]string{"--filter", "gaussian", "--sigma", "1.5", "-o", output, input}, ioutil.Discard, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		result, err := imageio.Load(output)
+		if err != nil {
+			t.Fatalf("Error loading output: %v", err)
+		}
+
+		kernel := convolver.GaussianKernel(1.5)
+		expectedImg := kernel.ApplyAvg(img, runtime.NumCPU())
+		checkImagesMatch(t, expectedImg, result)
+	})
+
+	t.Run("applies a pipeline file to multiple inputs", func(t *testing.T) {
+		pipelinePath := filepath.Join(dir, "pipeline.json")
+		err := ioutil.WriteFile(pipelinePath, []byte(`{"stages": [{"filter": "dilate", "radius": 1}]}`), 0644)
+		if err != nil {
+			t.Fatalf("Error writing pipeline file: %v", err)
+		}
+
+		inputDir := filepath.Join(dir, "inputs")
+		outputDir := filepath.Join(dir, "outputs")
+		_ = os.Mkdir(inputDir, 0755)
+		_ = os.Mkdir(outputDir, 0755)
+
+		inputs := []string{filepath.Join(inputDir, "a.png"), filepath.Join(inputDir, "b.png")}
+		for _, path := range inputs {
+			if err := imageio.Save(path, img); err != nil {
+				t.Fatalf("Error writing input: %v", err)
+			}
+		}
+
+		err = run(append([]string{"--pipeline", pipelinePath, "-o", outputDir}, inputs...), ioutil.Discard, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		kernel := convolver.KernelWithRadius(1)
+		kernel.SetWeightsUniform([]float32{1, 1, 1, 1, 1, 1, 1, 1, 1})
+		expectedImg := kernel.ApplyMax(img, runtime.NumCPU())
+
+		for _, name := range []string{"a.png", "b.png"} {
+			result, err := imageio.Load(filepath.Join(outputDir, name))
+			if err != nil {
+				t.Fatalf("Error loading output %s: %v", name, err)
+			}
+			checkImagesMatch(t, expectedImg, result)
+		}
+	})
+
+	t.Run("requires an output directory for multiple inputs", func(t *testing.T) {
+		err := run([]string{"--filter", "sobel", "-o", filepath.Join(dir, "out.png"), input, input}, ioutil.Discard, ioutil.Discard)
+		if err == nil {
+			t.Errorf("Expected an error but got none")
+		}
+	})
+
+	t.Run("rejects unknown filters", func(t *testing.T) {
+		err := run([]string{"--filter", "nonexistent", "-o", filepath.Join(dir, "out.png"), input}, ioutil.Discard, ioutil.Discard)
+		if err == nil {
+			t.Errorf("Expected an error but got none")
+		}
+	})
+
+	t.Run("rejects unsupported output formats", func(t *testing.T) {
+		err := run([]string{"--filter", "sobel", "-o", filepath.Join(dir, "out.bmp"), input}, ioutil.Discard, ioutil.Discard)
+		if err == nil {
+			t.Errorf("Expected an error but got none")
+		}
+	})
+
+	t.Run("requires an output path", func(t *testing.T) {
+		err := run([]string{"--filter", "sobel", input}, ioutil.Discard, ioutil.Discard)
+		if err == nil {
+			t.Errorf("Expected an error but got none")
+		}
+	})
+}
+
+func loadTestImage() image.Image {
+	img, err := imageio.Load(filepath.Join("..", "..", "test-images", "avocado.png"))
+	if err != nil {
+		panic(err)
+	}
+	return img
+}
+
+func checkImagesMatch(t *testing.T, expected *image.NRGBA, actual image.Image) {
+	t.Helper()
+
+	if expected.Rect != actual.Bounds() {
+		t.Fatalf("Expected bounds %v but got %v", expected.Rect, actual.Bounds())
+	}
+
+	for i := expected.Rect.Min.Y; i < expected.Rect.Max.Y; i++ {
+		for j := expected.Rect.Min.X; j < expected.Rect.Max.X; j++ {
+			er, eg, eb, ea := expected.At(j, i).RGBA()
+			ar, ag, ab, aa := actual.At(j, i).RGBA()
+
+			if er != ar || eg != ag || eb != ab || ea != aa {
+				t.Fatalf("Expected pixel %d,%d to be %+v but was %+v", j, i, expected.At(j, i), actual.At(j, i))
+			}
+		}
+	}
+}
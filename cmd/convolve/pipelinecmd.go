@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// runPipeline implements the pipeline subcommand:
+//
+//	convolve pipeline validate pipeline.json [more.json ...]
+//	convolve pipeline migrate pipeline.json [-o upgraded.json]
+func runPipeline(args []string, stdout, stderr io.Writer) error {
+	if len(args) < 2 || (args[0] != "validate" && args[0] != "migrate") {
+		return fmt.Errorf("usage: convolve pipeline validate|migrate <file> [flags]")
+	}
+
+	if args[0] == "validate" {
+		for _, path := range args[1:] {
+			if _, err := loadPipelineFile(path); err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, "%s: ok\n", path)
+		}
+		return nil
+	}
+
+	path := args[1]
+
+	flags := flag.NewFlagSet("convolve pipeline migrate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	output := flags.String("o", "", "file to write the migrated pipeline to (standard output if omitted)")
+
+	if err := flags.Parse(args[2:]); err != nil {
+		return err
+	}
+
+	src, err := migratePipelineFile(path)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = stdout.Write(src)
+		return err
+	}
+
+	return ioutil.WriteFile(*output, src, 0644)
+}
+
+// migratePipelineFile returns the pipeline file at the given path upgraded to
+// the current format version, after checking that every stage is valid.
+func migratePipelineFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	file, err := decodePipelineFile(f)
+	if err == nil {
+		_, err = file.build()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading pipeline %s: %v", path, err)
+	}
+
+	src, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(src, '\n'), nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunPipeline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "convolve-pipeline")
+	if err != nil {
+		t.Fatalf("Error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatalf("Error writing %s: %v", name, err)
+		}
+		return path
+	}
+
+	legacy := writeFile("legacy.json", `{"stages": [{"filter": "gaussian", "sigma": 2}, {"kernel": {"radius": 0, "weights": [1]}, "op": "max"}]}`)
+	invalid := writeFile("invalid.json", `{"stages": [{"filter": "nonexistent"}]}`)
+
+	t.Run("validate reports each valid file", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+
+		if err := run([]string{"pipeline", "validate", legacy}, stdout, ioutil.Discard); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if expected := legacy + ": ok\n"; stdout.String() != expected {
+			t.Errorf("Expected output %q but was %q", expected, stdout.String())
+		}
+	})
+
+	t.Run("validate fails for an invalid file", func(t *testing.T) {
+		err := run([]string{"pipeline", "validate", legacy, invalid}, ioutil.Discard, ioutil.Discard)
+		if err == nil {
+			t.Fatalf("Expected an error but got none")
+		}
+		if !strings.Contains(err.Error(), invalid) {
+			t.Errorf("Expected error to name %s but was %q", invalid, err)
+		}
+	})
+
+	t.Run("migrate writes the current version", func(t *testing.T) {
+		output := filepath.Join(dir, "migrated.json")
+
+		if err := run([]string{"pipeline", "migrate", legacy, "-o", output}, ioutil.Discard, ioutil.Discard); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		src, err := ioutil.ReadFile(output)
+		if err != nil {
+			t.Fatalf("Error reading migrated file: %v", err)
+		}
+
+		var file pipelineFile
+		if err := json.Unmarshal(src, &file); err != nil {
+			t.Fatalf("Error decoding migrated file: %v", err)
+		}
+		if file.Version != pipelineFileVersion {
+			t.Errorf("Expected version to be %d but was %d", pipelineFileVersion, file.Version)
+		}
+		if len(file.Stages) != 2 || file.Stages[0].Filter != "gaussian" || file.Stages[1].Op != "max" {
+			t.Errorf("Expected stages to be preserved but were %+v", file.Stages)
+		}
+
+		original, err := loadPipelineFile(legacy)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		migrated, err := loadPipelineFile(output)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		img := loadTestImage()
+		checkImagesMatch(t, original.Apply(img, 1), migrated.Apply(img, 1))
+	})
+
+	t.Run("migrate rejects an invalid file", func(t *testing.T) {
+		if err := run([]string{"pipeline", "migrate", invalid}, ioutil.Discard, ioutil.Discard); err == nil {
+			t.Errorf("Expected an error but got none")
+		}
+	})
+}
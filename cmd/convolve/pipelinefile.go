@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/mandykoh/convolver"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"strings"
+)
+
+// pipelineFile is the JSON representation of a pipeline. Each stage either
+// names a preset filter along with its parameters, or gives an explicit
+// kernel and the name of an operation registered with convolver.RegisterOp
+// to apply it with. For example:
+//
+//	{
+//	  "version": 2,
+//	  "stages": [
+//	    {"filter": "gaussian", "sigma": 2, "edge": "mirror"},
+//	    {"kernel": {"radius": 1, "weights": [0, 1, 0, 1, 1, 1, 0, 1, 0]}, "op": "max", "passes": 3}
+//	  ]
+//	}
+//
+// A stage may set "edge" to the name of an edge mode ("clip", "zero",
+// "extend", "mirror", "wrap" or "constant"), and with the constant mode give
+// an "edgeColour" as #rrggbb or #rrggbbaa; stages otherwise clip the kernel
+// at the image's edges.
+//
+// The version identifies the format of the file, so that files written for an
+// older release remain loadable after the format changes. Files without a
+// version predate versioning and are treated as version 0.
+type pipelineFile struct {
+	Version int                 `json:"version"`
+	Stages  []pipelineFileStage `json:"stages"`
+}
+
+// pipelineFileVersion is the current version of the pipeline file format,
+// which is written by the pipeline migrate subcommand.
+const pipelineFileVersion = 2
+
+// pipelineFileMigrations upgrades pipeline files from each format version to
+// the next, indexed by the version being upgraded from. Every change to the
+// format must increment pipelineFileVersion and add a migration here.
+var pipelineFileMigrations = []func(file *pipelineFile) error{
+	// Version 0 files are identical to version 1 apart from lacking the
+	// version field.
+	0: func(file *pipelineFile) error { return nil },
+
+	// Version 2 adds the optional edge and edgeColour stage fields, so version
+	// 1 files need no changes.
+	1: func(file *pipelineFile) error { return nil },
+}
+
+type pipelineFileStage struct {
+	Filter string              `json:"filter,omitempty"`
+	Sigma  *float64            `json:"sigma,omitempty"`
+	Radius *int                `json:"radius,omitempty"`
+	Amount *float64            `json:"amount,omitempty"`
+	Passes int                 `json:"passes,omitempty"`
+	Kernel *pipelineFileKernel `json:"kernel,omitempty"`
+	Op     string              `json:"op,omitempty"`
+
+	Edge       string `json:"edge,omitempty"`
+	EdgeColour string `json:"edgeColour,omitempty"`
+}
+
+type pipelineFileKernel struct {
+	Radius  int       `json:"radius"`
+	Weights []float32 `json:"weights"`
+}
+
+func loadPipelineFile(path string) (*convolver.Pipeline, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pipeline, err := parsePipeline(f)
+	if err != nil {
+		return nil, fmt.Errorf("error loading pipeline %s: %v", path, err)
+	}
+
+	return pipeline, nil
+}
+
+func parsePipeline(r io.Reader) (*convolver.Pipeline, error) {
+	file, err := decodePipelineFile(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return file.build()
+}
+
+// decodePipelineFile reads a pipeline file of any supported version, migrating
+// it to the current version.
+func decodePipelineFile(r io.Reader) (*pipelineFile, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	var file pipelineFile
+	if err := decoder.Decode(&file); err != nil {
+		return nil, err
+	}
+
+	if err := file.migrate(); err != nil {
+		return nil, err
+	}
+
+	return &file, nil
+}
+
+// migrate upgrades the file in place to the current format version. Files
+// from newer releases are rejected rather than guessed at, since they may
+// rely on stages or parameters this release does not understand.
+func (f *pipelineFile) migrate() error {
+	if f.Version < 0 {
+		return fmt.Errorf("invalid pipeline file version %d", f.Version)
+	}
+	if f.Version > pipelineFileVersion {
+		return fmt.Errorf("pipeline file version %d is newer than the supported version %d; a newer release of convolve is required", f.Version, pipelineFileVersion)
+	}
+
+	for ; f.Version < pipelineFileVersion; f.Version++ {
+		if err := pipelineFileMigrations[f.Version](f); err != nil {
+			return fmt.Errorf("error migrating pipeline file from version %d: %v", f.Version, err)
+		}
+	}
+
+	return nil
+}
+
+func (f *pipelineFile) build() (*convolver.Pipeline, error) {
+	stages := make([]convolver.Stage, 0, len(f.Stages))
+
+	for i, s := range f.Stages {
+		stage, err := s.build()
+		if err != nil {
+			return nil, fmt.Errorf("stage %d: %v", i+1, err)
+		}
+		stages = append(stages, stage)
+	}
+
+	return convolver.NewPipeline(stages...), nil
+}
+
+func (s *pipelineFileStage) build() (convolver.Stage, error) {
+	if (s.Filter == "") == (s.Kernel == nil) {
+		return nil, fmt.Errorf("exactly one of filter or kernel must be specified")
+	}
+
+	edge, edgeColour, err := s.edgeSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Filter != "" {
+		params := filterParams{Sigma: 1, Radius: 1, Amount: 1, Passes: s.Passes, Edge: edge, EdgeColour: edgeColour}
+		if s.Sigma != nil {
+			params.Sigma = *s.Sigma
+		}
+		if s.Radius != nil {
+			params.Radius = *s.Radius
+		}
+		if s.Amount != nil {
+			params.Amount = *s.Amount
+		}
+		return buildFilter(s.Filter, params)
+	}
+
+	if s.Kernel.Radius < 0 {
+		return nil, fmt.Errorf("kernel radius must not be negative")
+	}
+
+	kernel := convolver.KernelWithRadius(s.Kernel.Radius)
+	if expected := kernel.SideLength() * kernel.SideLength(); len(s.Kernel.Weights) != expected {
+		return nil, fmt.Errorf("kernel of radius %d requires exactly %d weights but %d provided", s.Kernel.Radius, expected, len(s.Kernel.Weights))
+	}
+	kernel.SetWeightsUniform(s.Kernel.Weights)
+	kernel.SetEdgeMode(edge)
+	kernel.SetEdgeColour(edgeColour)
+
+	var stage convolver.Stage
+	switch s.Op {
+	case "", "avg":
+		stage = kernel.ApplyAvg
+	default:
+		if _, ok := convolver.LookupOp(s.Op); !ok {
+			return nil, fmt.Errorf("unknown op %q; available: %s", s.Op, strings.Join(convolver.OpNames(), ", "))
+		}
+		op := s.Op
+		stage = func(img image.Image, parallelism int) *image.NRGBA {
+			return kernel.ApplyOp(img, op, parallelism)
+		}
+	}
+
+	if s.Passes > 1 {
+		stage = convolver.Repeat(stage, s.Passes)
+	}
+
+	return stage, nil
+}
+
+// edgeSettings returns the edge mode and colour given for the stage.
+func (s *pipelineFileStage) edgeSettings() (convolver.EdgeMode, color.NRGBA, error) {
+	mode := convolver.EdgeClip
+
+	if s.Edge != "" {
+		var names []string
+		found := false
+
+		for m := convolver.EdgeClip; m <= convolver.EdgeConstant; m++ {
+			names = append(names, m.String())
+			if m.String() == s.Edge {
+				mode, found = m, true
+			}
+		}
+
+		if !found {
+			return mode, color.NRGBA{}, fmt.Errorf("unknown edge mode %q; available: %s", s.Edge, strings.Join(names, ", "))
+		}
+	}
+
+	if s.EdgeColour == "" {
+		return mode, color.NRGBA{}, nil
+	}
+	if mode != convolver.EdgeConstant {
+		return mode, color.NRGBA{}, fmt.Errorf("edgeColour requires the constant edge mode")
+	}
+
+	c, err := parseHexColour(s.EdgeColour)
+	if err != nil {
+		return mode, color.NRGBA{}, fmt.Errorf("invalid edgeColour: %v", err)
+	}
+
+	return mode, c, nil
+}
+
+// parseHexColour parses a colour given as #rrggbb or #rrggbbaa, with the
+// alpha defaulting to opaque.
+func parseHexColour(s string) (color.NRGBA, error) {
+	digits := strings.TrimPrefix(s, "#")
+	if digits == s || (len(digits) != 6 && len(digits) != 8) {
+		return color.NRGBA{}, fmt.Errorf("expected #rrggbb or #rrggbbaa but got %q", s)
+	}
+
+	b, err := hex.DecodeString(digits)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("expected #rrggbb or #rrggbbaa but got %q", s)
+	}
+
+	c := color.NRGBA{R: b[0], G: b[1], B: b[2], A: 255}
+	if len(b) == 4 {
+		c.A = b[3]
+	}
+
+	return c, nil
+}
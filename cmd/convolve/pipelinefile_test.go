@@ -0,0 +1,129 @@
+package main
+
+import (
+	"github.com/mandykoh/convolver"
+	"image/color"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParsePipeline(t *testing.T) {
+	img := loadTestImage()
+
+	t.Run("builds filter and kernel stages in order", func(t *testing.T) {
+		pipeline, err := parsePipeline(strings.NewReader(`{
+			"stages": [
+				{"filter": "gaussian", "sigma": 1.5},
+				{"kernel": {"radius": 1, "weights": [0, 1, 0, 1, 1, 1, 0, 1, 0]}, "op": "max", "passes": 2}
+			]
+		}`))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		gaussian := convolver.GaussianKernel(1.5)
+		dilate := convolver.KernelWithRadius(1)
+		dilate.SetWeightsUniform([]float32{0, 1, 0, 1, 1, 1, 0, 1, 0})
+
+		expectedImg := convolver.NewPipeline(gaussian.ApplyAvg, dilate.ApplyMax, dilate.ApplyMax).Apply(img, runtime.NumCPU())
+
+		checkImagesMatch(t, expectedImg, pipeline.Apply(img, runtime.NumCPU()))
+	})
+
+	t.Run("rejects invalid definitions", func(t *testing.T) {
+		cases := []struct {
+			Name       string
+			Definition string
+		}{
+			{Name: "malformed JSON", Definition: `{"stages": [`},
+			{Name: "unknown field", Definition: `{"stages": [{"filter": "sobel", "colour": "red"}]}`},
+			{Name: "unknown filter", Definition: `{"stages": [{"filter": "nonexistent"}]}`},
+			{Name: "neither filter nor kernel", Definition: `{"stages": [{"passes": 2}]}`},
+			{Name: "both filter and kernel", Definition: `{"stages": [{"filter": "sobel", "kernel": {"radius": 0, "weights": [1]}}]}`},
+			{Name: "wrong weight count", Definition: `{"stages": [{"kernel": {"radius": 1, "weights": [1, 2]}}]}`},
+			{Name: "unknown op", Definition: `{"stages": [{"kernel": {"radius": 0, "weights": [1]}, "op": "median"}]}`},
+			{Name: "unknown edge mode", Definition: `{"stages": [{"filter": "box", "edge": "reflect"}]}`},
+			{Name: "edge colour without constant edge mode", Definition: `{"stages": [{"filter": "box", "edge": "wrap", "edgeColour": "#ffffff"}]}`},
+			{Name: "malformed edge colour", Definition: `{"stages": [{"filter": "box", "edge": "constant", "edgeColour": "white"}]}`},
+			{Name: "edge mode for a filter without a kernel", Definition: `{"stages": [{"filter": "sobel", "edge": "extend"}]}`},
+			{Name: "future version", Definition: `{"version": 99, "stages": [{"filter": "sobel"}]}`},
+			{Name: "negative version", Definition: `{"version": -1, "stages": [{"filter": "sobel"}]}`},
+		}
+
+		for _, c := range cases {
+			if _, err := parsePipeline(strings.NewReader(c.Definition)); err == nil {
+				t.Errorf("Expected an error for %s but got none", c.Name)
+			}
+		}
+	})
+	t.Run("applies edge modes to filter and kernel stages", func(t *testing.T) {
+		pipeline, err := parsePipeline(strings.NewReader(`{
+			"version": 2,
+			"stages": [
+				{"filter": "gaussian", "sigma": 1.5, "edge": "mirror"},
+				{"kernel": {"radius": 1, "weights": [0, 1, 0, 1, 1, 1, 0, 1, 0]}, "edge": "constant", "edgeColour": "#ff8000c0"}
+			]
+		}`))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		gaussian := convolver.GaussianKernel(1.5)
+		blur := convolver.KernelWithRadius(1)
+		blur.SetWeightsUniform([]float32{0, 1, 0, 1, 1, 1, 0, 1, 0})
+		blur.SetEdgeColour(color.NRGBA{R: 255, G: 128, B: 0, A: 192})
+
+		expectedImg := convolver.NewPipeline(
+			gaussian.WithEdgeMode(convolver.EdgeMirror).ApplyAvg,
+			blur.WithEdgeMode(convolver.EdgeConstant).ApplyAvg,
+		).Apply(img, runtime.NumCPU())
+
+		checkImagesMatch(t, expectedImg, pipeline.Apply(img, runtime.NumCPU()))
+	})
+
+	t.Run("accepts unversioned and current version files alike", func(t *testing.T) {
+		unversioned, err := parsePipeline(strings.NewReader(`{"stages": [{"filter": "gaussian", "sigma": 1.5}]}`))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		current, err := parsePipeline(strings.NewReader(`{"version": 1, "stages": [{"filter": "gaussian", "sigma": 1.5}]}`))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		checkImagesMatch(t, unversioned.Apply(img, runtime.NumCPU()), current.Apply(img, runtime.NumCPU()))
+	})
+}
+
+func TestPipelineFileMigrate(t *testing.T) {
+
+	t.Run("upgrades older files to the current version", func(t *testing.T) {
+		file := pipelineFile{}
+
+		if err := file.migrate(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if file.Version != pipelineFileVersion {
+			t.Errorf("Expected version to be %d but was %d", pipelineFileVersion, file.Version)
+		}
+	})
+
+	t.Run("has a migration from every earlier version", func(t *testing.T) {
+		if len(pipelineFileMigrations) != pipelineFileVersion {
+			t.Errorf("Expected %d migrations but there were %d", pipelineFileVersion, len(pipelineFileMigrations))
+		}
+	})
+
+	t.Run("rejects files from newer releases", func(t *testing.T) {
+		file := pipelineFile{Version: pipelineFileVersion + 1}
+
+		err := file.migrate()
+		if err == nil {
+			t.Fatalf("Expected an error but got none")
+		}
+		if !strings.Contains(err.Error(), "newer") {
+			t.Errorf("Expected error to mention a newer version but was %q", err)
+		}
+	})
+}
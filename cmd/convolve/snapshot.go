@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"github.com/mandykoh/convolver/imageio"
+	"image"
+	"path/filepath"
+	"strings"
+)
+
+// snapshotWriter writes each intermediate result of processing an input to a
+// directory, naming them after the input and the step number. The first error
+// encountered is kept, and later snapshots are skipped.
+type snapshotWriter struct {
+	dir  string
+	base string
+	err  error
+}
+
+func (w *snapshotWriter) write(step int, img *image.NRGBA) {
+	if w.err != nil {
+		return
+	}
+
+	path := filepath.Join(w.dir, fmt.Sprintf("%s-%03d.png", w.base, step))
+	if err := imageio.Save(path, img); err != nil {
+		w.err = fmt.Errorf("error writing snapshot: %v", err)
+	}
+}
+
+func newSnapshotWriter(dir, input string) *snapshotWriter {
+	base := filepath.Base(input)
+
+	return &snapshotWriter{
+		dir:  dir,
+		base: strings.TrimSuffix(base, filepath.Ext(base)),
+	}
+}
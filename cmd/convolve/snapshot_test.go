@@ -0,0 +1,76 @@
+package main
+
+import (
+	"github.com/mandykoh/convolver"
+	"github.com/mandykoh/convolver/imageio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSnapshots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "convolve")
+	if err != nil {
+		t.Fatalf("Error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join("..", "..", "test-images", "avocado.png")
+	img := loadTestImage()
+
+	kernel := convolver.KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{1, 1, 1, 1, 1, 1, 1, 1, 1})
+
+	t.Run("writes the result of each pass of a filter", func(t *testing.T) {
+		snapshotDir := filepath.Join(dir, "passes")
+		_ = os.Mkdir(snapshotDir, 0755)
+
+		err := run([]string{"--filter", "dilate", "--passes", "3", "--snapshots", snapshotDir, "-o", filepath.Join(dir, "out.png"), input}, ioutil.Discard, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		expectedImg := kernel.ApplyMax(img, runtime.NumCPU())
+
+		for _, name := range []string{"avocado-001.png", "avocado-002.png", "avocado-003.png"} {
+			result, err := imageio.Load(filepath.Join(snapshotDir, name))
+			if err != nil {
+				t.Fatalf("Error loading snapshot %s: %v", name, err)
+			}
+			checkImagesMatch(t, expectedImg, result)
+
+			expectedImg = kernel.ApplyMax(expectedImg, runtime.NumCPU())
+		}
+	})
+
+	t.Run("writes the result of each stage of a pipeline", func(t *testing.T) {
+		snapshotDir := filepath.Join(dir, "stages")
+		_ = os.Mkdir(snapshotDir, 0755)
+
+		pipelinePath := filepath.Join(dir, "pipeline.json")
+		err := ioutil.WriteFile(pipelinePath, []byte(`{"stages": [{"filter": "dilate"}, {"filter": "erode"}]}`), 0644)
+		if err != nil {
+			t.Fatalf("Error writing pipeline file: %v", err)
+		}
+
+		err = run([]string{"--pipeline", pipelinePath, "--snapshots", snapshotDir, "-o", filepath.Join(dir, "out.png"), input}, ioutil.Discard, ioutil.Discard)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		for _, name := range []string{"avocado-001.png", "avocado-002.png"} {
+			if _, err := os.Stat(filepath.Join(snapshotDir, name)); err != nil {
+				t.Errorf("Expected snapshot %s to be written but got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("requires a single input", func(t *testing.T) {
+		err := run([]string{"--filter", "dilate", "--snapshots", dir, "-o", dir, input, input}, ioutil.Discard, ioutil.Discard)
+		if err == nil {
+			t.Errorf("Expected an error but got none")
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/mandykoh/convolver"
+	"github.com/mandykoh/convolver/imageio"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type fileState struct {
+	ModTime time.Time
+	Size    int64
+}
+
+// watcher processes images in an input directory into an output directory
+// whenever they are added or changed. A file is only processed once its
+// modification time and size are unchanged between two consecutive scans, so
+// that files are not read while still being written.
+type watcher struct {
+	InputDir    string
+	OutputDir   string
+	Stage       convolver.Stage
+	Parallelism int
+	Log         io.Writer
+
+	seen      map[string]fileState
+	processed map[string]fileState
+}
+
+// newWatcher returns a watcher for inputDir. It returns an error if outputDir
+// is inputDir or lies within it, since results would then be picked up as new
+// inputs.
+func newWatcher(inputDir, outputDir string, stage convolver.Stage, parallelism int, log io.Writer) (*watcher, error) {
+	inside, err := isWithinDir(inputDir, outputDir)
+	if err != nil {
+		return nil, err
+	}
+	if inside {
+		return nil, fmt.Errorf("output directory %s must not be the input directory or within it", outputDir)
+	}
+
+	return &watcher{
+		InputDir:    inputDir,
+		OutputDir:   outputDir,
+		Stage:       stage,
+		Parallelism: parallelism,
+		Log:         log,
+		seen:        make(map[string]fileState),
+		processed:   make(map[string]fileState),
+	}, nil
+}
+
+// Run scans the input directory every interval until the context is done.
+func (w *watcher) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.scan(); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// scan checks the input directory once, processing any files which have
+// settled since they were last processed. Failures to process individual
+// files are logged rather than returned, so that a bad file doesn't stop the
+// watch.
+func (w *watcher) scan() error {
+	entries, err := ioutil.ReadDir(w.InputDir)
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string]fileState, len(entries))
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !isWatchableFile(name) {
+			continue
+		}
+
+		state := fileState{ModTime: entry.ModTime(), Size: entry.Size()}
+		current[name] = state
+
+		if previous, ok := w.seen[name]; !ok || previous != state {
+			continue
+		}
+		if processed, ok := w.processed[name]; ok && processed == state {
+			continue
+		}
+
+		w.processed[name] = state
+
+		if err := processFile(filepath.Join(w.InputDir, name), filepath.Join(w.OutputDir, name), w.Stage, w.Parallelism); err != nil {
+			fmt.Fprintf(w.Log, "convolve: %v\n", err)
+		} else {
+			fmt.Fprintf(w.Log, "processed %s\n", name)
+		}
+	}
+
+	for name := range w.processed {
+		if _, ok := current[name]; !ok {
+			delete(w.processed, name)
+		}
+	}
+	w.seen = current
+
+	return nil
+}
+
+// isWatchableFile returns whether a file is an image which can be both read
+// and written back in the same format, as results keep their input's name.
+func isWatchableFile(name string) bool {
+	format, ok := imageio.FormatForPath(name)
+	return ok && imageio.CanEncode(format)
+}
+
+// isWithinDir returns whether path is dir or a descendant of it, after
+// resolving both to absolute paths without symbolic links.
+func isWithinDir(dir, path string) (bool, error) {
+	resolve := func(p string) (string, error) {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return "", err
+		}
+		if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+			return resolved, nil
+		}
+		return abs, nil
+	}
+
+	dir, err := resolve(dir)
+	if err != nil {
+		return false, err
+	}
+	path, err = resolve(path)
+	if err != nil {
+		return false, err
+	}
+
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false, nil
+	}
+	return rel == "." || rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)), nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"github.com/mandykoh/convolver"
+	"github.com/mandykoh/convolver/imageio"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "convolve-watch")
+	if err != nil {
+		t.Fatalf("Error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	inputDir := filepath.Join(dir, "in")
+	outputDir := filepath.Join(dir, "out")
+	_ = os.Mkdir(inputDir, 0755)
+	_ = os.Mkdir(outputDir, 0755)
+
+	img := loadTestImage()
+	kernel := convolver.KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{1, 1, 1, 1, 1, 1, 1, 1, 1})
+
+	processed := 0
+	stage := func(img image.Image, parallelism int) *image.NRGBA {
+		processed++
+		return kernel.ApplyMin(img, parallelism)
+	}
+
+	log := &bytes.Buffer{}
+	w, err := newWatcher(inputDir, outputDir, stage, runtime.NumCPU(), log)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	scan := func() {
+		if err := w.scan(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	inputPath := filepath.Join(inputDir, "a.png")
+	if err := imageio.Save(inputPath, img); err != nil {
+		t.Fatalf("Error writing input: %v", err)
+	}
+
+	t.Run("processes a new file once it has settled", func(t *testing.T) {
+		scan()
+		if processed != 0 {
+			t.Fatalf("Expected file not to be processed on first sighting")
+		}
+
+		scan()
+		if expected, actual := 1, processed; expected != actual {
+			t.Fatalf("Expected %d file to be processed but got %d", expected, actual)
+		}
+
+		result, err := imageio.Load(filepath.Join(outputDir, "a.png"))
+		if err != nil {
+			t.Fatalf("Error loading output: %v", err)
+		}
+		checkImagesMatch(t, kernel.ApplyMin(img, runtime.NumCPU()), result)
+	})
+
+	t.Run("does not reprocess an unchanged file", func(t *testing.T) {
+		scan()
+		scan()
+		if expected, actual := 1, processed; expected != actual {
+			t.Errorf("Expected %d file to be processed but got %d", expected, actual)
+		}
+	})
+
+	t.Run("reprocesses a changed file", func(t *testing.T) {
+		later := time.Now().Add(time.Minute)
+		if err := os.Chtimes(inputPath, later, later); err != nil {
+			t.Fatalf("Error touching input: %v", err)
+		}
+
+		scan()
+		scan()
+		if expected, actual := 2, processed; expected != actual {
+			t.Errorf("Expected %d files to be processed but got %d", expected, actual)
+		}
+	})
+
+	t.Run("logs and skips files which fail to decode", func(t *testing.T) {
+		if err := ioutil.WriteFile(filepath.Join(inputDir, "broken.png"), []byte("not an image"), 0644); err != nil {
+			t.Fatalf("Error writing input: %v", err)
+		}
+
+		scan()
+		scan()
+		if log.Len() == 0 || !bytes.Contains(log.Bytes(), []byte("broken.png")) {
+			t.Errorf("Expected failure to be logged but log was %q", log.String())
+		}
+	})
+	t.Run("skips files whose format can't be written back", func(t *testing.T) {
+		before := processed
+
+		if err := ioutil.WriteFile(filepath.Join(inputDir, "c.gif"), []byte("GIF89a"), 0644); err != nil {
+			t.Fatalf("Error writing input: %v", err)
+		}
+
+		log.Reset()
+		scan()
+		scan()
+		if expected, actual := before, processed; expected != actual {
+			t.Errorf("Expected %d files to be processed but got %d", expected, actual)
+		}
+		if bytes.Contains(log.Bytes(), []byte("c.gif")) {
+			t.Errorf("Expected GIF to be skipped silently but log was %q", log.String())
+		}
+	})
+
+	t.Run("rejects an output directory within the input directory", func(t *testing.T) {
+		nestedDir := filepath.Join(inputDir, "out")
+		_ = os.Mkdir(nestedDir, 0755)
+
+		for _, output := range []string{inputDir, inputDir + string(filepath.Separator), nestedDir} {
+			if _, err := newWatcher(inputDir, output, stage, 1, log); err == nil {
+				t.Errorf("Expected an error for output directory %s", output)
+			}
+		}
+
+		if _, err := newWatcher(inputDir, filepath.Join(dir, "in-other"), stage, 1, log); err != nil {
+			t.Errorf("Expected a sibling with a common prefix to be accepted but got %v", err)
+		}
+	})
+}
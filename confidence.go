@@ -0,0 +1,64 @@
+package convolver
+
+import (
+	"github.com/mandykoh/go-parallel"
+	"github.com/mandykoh/prism"
+	"image"
+)
+
+// ApplyAvgWithWeight applies the kernel as ApplyAvg does, and also returns
+// the effective weight used for each pixel as a fraction of the kernel's
+// total weight, scaled so that 255 means the whole kernel was used. This is
+// less than 255 near the edges of the image, where the kernel is clipped and
+// Avg renormalises by the weight remaining, so that downstream code can
+// compensate for or ignore results with less support.
+//
+// Weights are compared by magnitude averaged over the channels, so that
+// negative weights count towards the support as much as positive ones. With
+// edge modes other than EdgeClip, the kernel is never clipped and the weight
+// is always 255.
+func (k *Kernel) ApplyAvgWithWeight(img image.Image, parallelism int) (*image.NRGBA, *image.Gray) {
+	input := prism.ConvertImageToNRGBA(img, parallelism)
+	result := k.applyImage(input, k.Avg, parallelism)
+
+	return result, k.weightImage(input.Rect, parallelism)
+}
+
+// weightImage returns the fraction of the kernel's weight which falls within
+// bounds at each pixel.
+func (k *Kernel) weightImage(bounds image.Rectangle, parallelism int) *image.Gray {
+	fullWeight := float32(0)
+	for i := range k.weights {
+		fullWeight += k.weights[i].magnitude()
+	}
+
+	weights := image.NewGray(bounds)
+	if fullWeight == 0 {
+		return weights
+	}
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := bounds.Min.Y + workerNum; i < bounds.Max.Y; i += workerCount {
+			for j := bounds.Min.X; j < bounds.Max.X; j++ {
+				clip := kernelClip{}
+				if k.edgeMode == EdgeClip {
+					clip = k.clipToBounds(bounds, j, i)
+				}
+
+				weight := fullWeight
+				if clip != (kernelClip{}) {
+					weight = 0
+					for s := clip.Top; s < k.sideLength-clip.Bottom; s++ {
+						for t := clip.Left; t < k.sideLength-clip.Right; t++ {
+							weight += k.weights[s*k.sideLength+t].magnitude()
+						}
+					}
+				}
+
+				weights.Pix[weights.PixOffset(j, i)] = maskValue(float64(weight / fullWeight))
+			}
+		}
+	})
+
+	return weights
+}
@@ -0,0 +1,65 @@
+package convolver
+
+import (
+	"testing"
+)
+
+func TestApplyAvgWithWeight(t *testing.T) {
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{
+		1, 1, 1,
+		1, 1, 1,
+		1, 1, 1,
+	})
+
+	img := randomImage(7, 5)
+
+	t.Run("matches normal application", func(t *testing.T) {
+		expected := kernel.ApplyAvg(img, 2)
+
+		result, _ := kernel.ApplyAvgWithWeight(img, 2)
+
+		for i := range expected.Pix {
+			if expected.Pix[i] != result.Pix[i] {
+				t.Fatalf("Expected results to match but differ at byte %d", i)
+			}
+		}
+	})
+
+	t.Run("reports the fraction of the kernel within the image", func(t *testing.T) {
+		_, weights := kernel.ApplyAvgWithWeight(img, 2)
+
+		cases := []struct {
+			x, y     int
+			expected uint8
+		}{
+			{3, 2, 255},
+			{0, 2, 170},
+			{6, 2, 170},
+			{3, 0, 170},
+			{0, 0, 113},
+			{6, 4, 113},
+		}
+
+		for _, c := range cases {
+			if actual := weights.GrayAt(c.x, c.y).Y; c.expected != actual {
+				t.Errorf("Expected weight at %d, %d to be %d but was %d", c.x, c.y, c.expected, actual)
+			}
+		}
+	})
+
+	t.Run("counts negative weights by magnitude", func(t *testing.T) {
+		sharpen := KernelWithRadius(1)
+		sharpen.SetWeightsUniform([]float32{
+			0, -1, 0,
+			-1, 4, -1,
+			0, -1, 0,
+		})
+
+		_, weights := sharpen.ApplyAvgWithWeight(img, 2)
+
+		if expected, actual := uint8(223), weights.GrayAt(0, 2).Y; expected != actual {
+			t.Errorf("Expected weight at edge to be %d but was %d", expected, actual)
+		}
+	})
+}
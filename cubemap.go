@@ -0,0 +1,165 @@
+package convolver
+
+import (
+	"fmt"
+	"github.com/mandykoh/go-parallel"
+	"image"
+	"math"
+	"time"
+)
+
+// CubeFace identifies a face of a cubemap, in the order used by OpenGL and
+// most other graphics APIs.
+type CubeFace int
+
+const (
+	CubePositiveX CubeFace = iota
+	CubeNegativeX
+	CubePositiveY
+	CubeNegativeY
+	CubePositiveZ
+	CubeNegativeZ
+)
+
+// ApplyAvgCubemap applies the kernel to the six faces of a cubemap, indexed
+// by CubeFace, as when prefiltering an environment map. Where the kernel
+// extends beyond the edge of a face, its taps are projected onto the
+// adjacent faces, so the result is continuous across the edges of the cube.
+// Faces are oriented as for OpenGL's cube map textures, with the top row of
+// each image at t = 0, and must be square and all the same size.
+//
+// The kernel's edge mode is ignored, since a cubemap has no edges.
+func (k *Kernel) ApplyAvgCubemap(faces [6]image.Image, parallelism int) [6]*image.NRGBA {
+	defer observeImage(currentMetrics(), time.Now())
+
+	size := faces[0].Bounds().Dx()
+	for f, face := range faces {
+		if b := face.Bounds(); b.Dx() != size || b.Dy() != size {
+			panic(fmt.Sprintf("cubemap faces must be square and the same size but face %d was %dx%d", f, b.Dx(), b.Dy()))
+		}
+	}
+
+	parallelism = k.powerMode.workers(parallelism)
+
+	var inputs [6]*FloatImage
+	var results [6]*image.NRGBA
+	for f := range faces {
+		inputs[f] = FloatImageFromImage(faces[f], parallelism)
+		results[f] = image.NewNRGBA(faces[f].Bounds())
+	}
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for row := workerNum; row < 6*size; row += workerCount {
+			f, i := CubeFace(row/size), row%size
+
+			for j := 0; j < size; j++ {
+				totalWeight := kernelWeight{}
+				sum := kernelWeight{}
+
+				for s := 0; s < k.sideLength; s++ {
+					for t := 0; t < k.sideLength; t++ {
+						weight := k.weights[s*k.sideLength+t]
+						if weight == (kernelWeight{}) {
+							continue
+						}
+
+						sf, x, y := cubemapSource(f, j+t-k.radius, i+s-k.radius, size)
+						input := inputs[sf]
+						p := input.Pix[y*input.Stride+x*4:]
+
+						totalWeight.R += weight.R
+						totalWeight.G += weight.G
+						totalWeight.B += weight.B
+						totalWeight.A += weight.A
+
+						sum.R += p[0] * weight.R
+						sum.G += p[1] * weight.G
+						sum.B += p[2] * weight.B
+						sum.A += p[3] * weight.A
+					}
+				}
+
+				if totalWeight.R > 0 {
+					sum.R /= totalWeight.R
+				}
+				if totalWeight.G > 0 {
+					sum.G /= totalWeight.G
+				}
+				if totalWeight.B > 0 {
+					sum.B /= totalWeight.B
+				}
+				if totalWeight.A > 0 {
+					sum.A /= totalWeight.A
+				}
+
+				result := results[f]
+				rx, ry := result.Rect.Min.X+j, result.Rect.Min.Y+i
+				result.SetNRGBA(rx, ry, k.averageToNRGBA(rx, ry, sum))
+			}
+		}
+	})
+
+	return results
+}
+
+// cubemapSource returns the face and pixel, relative to its top-left, seen in
+// the direction of pixel x, y of the given face. Pixels beyond the face's
+// edges lie on the extension of its plane, and are projected onto whichever
+// face the direction meets.
+func cubemapSource(f CubeFace, x, y, size int) (CubeFace, int, int) {
+	if x >= 0 && y >= 0 && x < size && y < size {
+		return f, x, y
+	}
+
+	sc := (float64(x)+0.5)/float64(size)*2 - 1
+	tc := (float64(y)+0.5)/float64(size)*2 - 1
+
+	var dx, dy, dz float64
+	switch f {
+	case CubePositiveX:
+		dx, dy, dz = 1, -tc, -sc
+	case CubeNegativeX:
+		dx, dy, dz = -1, -tc, sc
+	case CubePositiveY:
+		dx, dy, dz = sc, 1, tc
+	case CubeNegativeY:
+		dx, dy, dz = sc, -1, -tc
+	case CubePositiveZ:
+		dx, dy, dz = sc, -tc, 1
+	case CubeNegativeZ:
+		dx, dy, dz = -sc, -tc, -1
+	}
+
+	ax, ay, az := math.Abs(dx), math.Abs(dy), math.Abs(dz)
+
+	var major float64
+	switch {
+	case ax >= ay && ax >= az:
+		major = ax
+		if dx > 0 {
+			f, sc, tc = CubePositiveX, -dz, -dy
+		} else {
+			f, sc, tc = CubeNegativeX, dz, -dy
+		}
+	case ay >= az:
+		major = ay
+		if dy > 0 {
+			f, sc, tc = CubePositiveY, dx, dz
+		} else {
+			f, sc, tc = CubeNegativeY, dx, -dz
+		}
+	default:
+		major = az
+		if dz > 0 {
+			f, sc, tc = CubePositiveZ, dx, -dy
+		} else {
+			f, sc, tc = CubeNegativeZ, -dx, -dy
+		}
+	}
+
+	toPixel := func(c float64) int {
+		return clampInt(int(math.Floor((c/major+1)/2*float64(size))), 0, size-1)
+	}
+
+	return f, toPixel(sc), toPixel(tc)
+}
@@ -0,0 +1,121 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestApplyAvgCubemap(t *testing.T) {
+	const size = 8
+
+	box := func(radius int) Kernel {
+		kernel := KernelWithRadius(radius)
+		weights := make([]float32, kernel.sideLength*kernel.sideLength)
+		for i := range weights {
+			weights[i] = 1
+		}
+		kernel.SetWeightsUniform(weights)
+		return kernel
+	}
+
+	flatFace := func(c color.NRGBA) *image.NRGBA {
+		img := image.NewNRGBA(image.Rect(0, 0, size, size))
+		for i := 0; i < size; i++ {
+			for j := 0; j < size; j++ {
+				img.SetNRGBA(j, i, c)
+			}
+		}
+		return img
+	}
+
+	t.Run("keeps a uniform cubemap uniform", func(t *testing.T) {
+		c := color.NRGBA{R: 200, G: 100, B: 50, A: 255}
+		var faces [6]image.Image
+		for f := range faces {
+			faces[f] = flatFace(c)
+		}
+
+		kernel := box(2)
+		results := kernel.ApplyAvgCubemap(faces, 2)
+
+		for f, result := range results {
+			for i := 0; i < size; i++ {
+				for j := 0; j < size; j++ {
+					if actual := result.NRGBAAt(j, i); actual != c {
+						t.Fatalf("Expected face %d at %d,%d to be %v but was %v", f, j, i, c, actual)
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("blends across face edges", func(t *testing.T) {
+		var faces [6]image.Image
+		for f := range faces {
+			faces[f] = flatFace(color.NRGBA{A: 255})
+		}
+		faces[CubePositiveZ] = flatFace(color.NRGBA{R: 255, A: 255})
+		faces[CubePositiveX] = flatFace(color.NRGBA{B: 255, A: 255})
+
+		kernel := box(1)
+		results := kernel.ApplyAvgCubemap(faces, 2)
+
+		if c := results[CubePositiveZ].NRGBAAt(size-1, size/2); c.B == 0 || c.R == 0 {
+			t.Errorf("Expected right edge of +Z to blend with +X but was %v", c)
+		}
+		if c := results[CubePositiveX].NRGBAAt(0, size/2); c.B == 0 || c.R == 0 {
+			t.Errorf("Expected left edge of +X to blend with +Z but was %v", c)
+		}
+		if expected, actual := (color.NRGBA{R: 255, A: 255}), results[CubePositiveZ].NRGBAAt(size/2, size/2); expected != actual {
+			t.Errorf("Expected centre of +Z to be %v but was %v", expected, actual)
+		}
+	})
+
+	t.Run("panics for faces of different sizes", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic but none occurred")
+			}
+		}()
+
+		var faces [6]image.Image
+		for f := range faces {
+			faces[f] = flatFace(color.NRGBA{})
+		}
+		faces[CubeNegativeY] = image.NewNRGBA(image.Rect(0, 0, size, size+1))
+
+		kernel := box(1)
+		kernel.ApplyAvgCubemap(faces, 2)
+	})
+}
+
+func TestCubemapSource(t *testing.T) {
+	const size = 8
+
+	cases := []struct {
+		Name     string
+		Face     CubeFace
+		X, Y     int
+		Expected CubeFace
+		EX, EY   int
+	}{
+		{Name: "within a face", Face: CubeNegativeY, X: 3, Y: 5, Expected: CubeNegativeY, EX: 3, EY: 5},
+		{Name: "right of +Z", Face: CubePositiveZ, X: size, Y: 4, Expected: CubePositiveX, EX: 0, EY: 4},
+		{Name: "left of +Z", Face: CubePositiveZ, X: -1, Y: 4, Expected: CubeNegativeX, EX: size - 1, EY: 4},
+		{Name: "above +Z", Face: CubePositiveZ, X: 4, Y: -1, Expected: CubePositiveY, EX: 4, EY: size - 1},
+		{Name: "below +Z", Face: CubePositiveZ, X: 4, Y: size, Expected: CubeNegativeY, EX: 4, EY: 0},
+		{Name: "right of +X", Face: CubePositiveX, X: size, Y: 4, Expected: CubeNegativeZ, EX: 0, EY: 4},
+		{Name: "above +X", Face: CubePositiveX, X: 4, Y: -1, Expected: CubePositiveY, EX: size - 1, EY: 3},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			f, x, y := cubemapSource(c.Face, c.X, c.Y, size)
+
+			if f != c.Expected || x != c.EX || y != c.EY {
+				t.Errorf("Expected face %d at %d,%d but was face %d at %d,%d", c.Expected, c.EX, c.EY, f, x, y)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package convolver
+
+import (
+	"fmt"
+	"github.com/mandykoh/go-parallel"
+	"github.com/mandykoh/prism"
+	"image"
+	"image/color"
+	"math"
+)
+
+// ApplyWithHeatmap applies an operation such as k.Avg to an image, and also
+// returns a heatmap of the operation's effect as produced by ResponseHeatmap.
+// This is intended for debugging, to show what a kernel is actually doing
+// across an image.
+func (k *Kernel) ApplyWithHeatmap(img image.Image, op OpFunc, parallelism int) (*image.NRGBA, *image.Gray) {
+	input := prism.ConvertImageToNRGBA(img, parallelism)
+	result := k.applyImage(input, op, parallelism)
+
+	return result, ResponseHeatmap(input, result, parallelism)
+}
+
+// ResponseHeatmap returns the magnitude of the difference between the input
+// and output of an operation at each pixel, taken over the encoded RGBA
+// channels. Magnitudes are normalised so that the largest difference is 255;
+// an operation with no effect gives an entirely black heatmap. Both images
+// must have the same bounds.
+func ResponseHeatmap(input, output image.Image, parallelism int) *image.Gray {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	in := prism.ConvertImageToNRGBA(input, parallelism)
+	out := prism.ConvertImageToNRGBA(output, parallelism)
+
+	magnitudes := NewPlane(in.Rect)
+	maxMagnitudes := make([]float32, parallelism)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := in.Rect.Min.Y + workerNum; i < in.Rect.Max.Y; i += workerCount {
+			for j := in.Rect.Min.X; j < in.Rect.Max.X; j++ {
+				a, b := in.NRGBAAt(j, i), out.NRGBAAt(j, i)
+
+				dr := float64(a.R) - float64(b.R)
+				dg := float64(a.G) - float64(b.G)
+				db := float64(a.B) - float64(b.B)
+				da := float64(a.A) - float64(b.A)
+				m := float32(math.Sqrt(dr*dr + dg*dg + db*db + da*da))
+
+				magnitudes.Pix[magnitudes.offset(j, i)] = m
+				if m > maxMagnitudes[workerNum] {
+					maxMagnitudes[workerNum] = m
+				}
+			}
+		}
+	})
+
+	maxMagnitude := float32(0)
+	for _, m := range maxMagnitudes {
+		if m > maxMagnitude {
+			maxMagnitude = m
+		}
+	}
+
+	heatmap := image.NewGray(in.Rect)
+	if maxMagnitude == 0 {
+		return heatmap
+	}
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := in.Rect.Min.Y + workerNum; i < in.Rect.Max.Y; i += workerCount {
+			for j := in.Rect.Min.X; j < in.Rect.Max.X; j++ {
+				m := magnitudes.Pix[magnitudes.offset(j, i)]
+				heatmap.Pix[heatmap.PixOffset(j, i)] = uint8(m/maxMagnitude*255 + 0.5)
+			}
+		}
+	})
+
+	return heatmap
+}
+
+// DiffImage returns a false colour image of the differences between two
+// images with the same bounds, such as the results of two backends or of a
+// kernel before and after tuning. Differences are taken over the encoded RGBA
+// channels and multiplied by gain, so that a gain of 16 makes a difference of
+// one level in a channel clearly visible.
+//
+// Identical pixels are black. Pixels where b is brighter than a, by the sum of
+// the channel differences, run from red to yellow as the largest amplified
+// channel difference grows, and those where b is darker run from blue to
+// cyan. Differences only in alpha are shown in grey.
+func DiffImage(a, b image.Image, gain float64, parallelism int) *image.NRGBA {
+	if gain <= 0 {
+		panic(fmt.Sprintf("gain must be positive but was %v", gain))
+	}
+	if a.Bounds() != b.Bounds() {
+		panic(fmt.Sprintf("images to compare must have the same bounds but were %v and %v", a.Bounds(), b.Bounds()))
+	}
+
+	in := prism.ConvertImageToNRGBA(a, parallelism)
+	out := prism.ConvertImageToNRGBA(b, parallelism)
+	result := image.NewNRGBA(in.Rect)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := in.Rect.Min.Y + workerNum; i < in.Rect.Max.Y; i += workerCount {
+			for j := in.Rect.Min.X; j < in.Rect.Max.X; j++ {
+				result.SetNRGBA(j, i, diffColour(in.NRGBAAt(j, i), out.NRGBAAt(j, i), gain))
+			}
+		}
+	})
+
+	return result
+}
+
+// diffColour returns the false colour for the difference between a pair of
+// pixels, as described for DiffImage.
+func diffColour(a, b color.NRGBA, gain float64) color.NRGBA {
+	var sum, largest float64
+	for c, d := range [4]float64{
+		float64(b.R) - float64(a.R),
+		float64(b.G) - float64(a.G),
+		float64(b.B) - float64(a.B),
+		float64(b.A) - float64(a.A),
+	} {
+		if c < 3 {
+			sum += d
+		}
+		largest = math.Max(largest, math.Abs(d))
+	}
+
+	// Intensity rises to full over the first half of the scale, then the
+	// second channel rises over the remainder, so that large differences
+	// stand out from small ones.
+	m := math.Min(largest*gain/255, 1)
+	primary := uint8(math.Min(m*2, 1)*255 + 0.5)
+	secondary := uint8(math.Max(m*2-1, 0)*255 + 0.5)
+
+	switch {
+	case sum > 0:
+		return color.NRGBA{R: primary, G: secondary, A: 255}
+	case sum < 0:
+		return color.NRGBA{G: secondary, B: primary, A: 255}
+	default:
+		grey := uint8(m*255 + 0.5)
+		return color.NRGBA{R: grey, G: grey, B: grey, A: 255}
+	}
+}
@@ -0,0 +1,126 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+)
+
+func TestApplyWithHeatmap(t *testing.T) {
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{
+		1, 1, 1,
+		1, 1, 1,
+		1, 1, 1,
+	})
+
+	t.Run("matches normal application", func(t *testing.T) {
+		img := randomImage(16, 16)
+		expectedImg := kernel.ApplyAvg(img, runtime.NumCPU())
+
+		result, _ := kernel.ApplyWithHeatmap(img, kernel.Avg, runtime.NumCPU())
+
+		for i := range expectedImg.Pix {
+			if expectedImg.Pix[i] != result.Pix[i] {
+				t.Fatalf("Expected results to match but differ at byte %d", i)
+			}
+		}
+	})
+
+	t.Run("highlights where the operation has an effect", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 9, 9))
+		for i := range img.Pix {
+			img.Pix[i] = 255
+		}
+		img.SetNRGBA(4, 4, color.NRGBA{A: 255})
+
+		_, heatmap := kernel.ApplyWithHeatmap(img, kernel.Avg, runtime.NumCPU())
+
+		if expected, actual := uint8(255), heatmap.GrayAt(4, 4).Y; expected != actual {
+			t.Errorf("Expected heatmap at changed centre to be %d but was %d", expected, actual)
+		}
+		if actual := heatmap.GrayAt(3, 4).Y; actual == 0 || actual == 255 {
+			t.Errorf("Expected heatmap next to centre to be partial but was %d", actual)
+		}
+		if expected, actual := uint8(0), heatmap.GrayAt(0, 0).Y; expected != actual {
+			t.Errorf("Expected heatmap away from centre to be %d but was %d", expected, actual)
+		}
+	})
+}
+
+func TestResponseHeatmap(t *testing.T) {
+
+	t.Run("is black for identical images", func(t *testing.T) {
+		img := randomImage(8, 8)
+
+		heatmap := ResponseHeatmap(img, img, runtime.NumCPU())
+
+		for i, v := range heatmap.Pix {
+			if v != 0 {
+				t.Fatalf("Expected heatmap to be black but was %d at index %d", v, i)
+			}
+		}
+	})
+
+	t.Run("treats parallelism below one as a single worker", func(t *testing.T) {
+		input, output := randomImage(8, 8), randomImage(8, 8)
+		expected := ResponseHeatmap(input, output, 1)
+
+		for _, parallelism := range []int{0, -2} {
+			heatmap := ResponseHeatmap(input, output, parallelism)
+
+			for i := range expected.Pix {
+				if expected.Pix[i] != heatmap.Pix[i] {
+					t.Fatalf("Expected heatmaps with parallelism %d to match but differ at index %d", parallelism, i)
+				}
+			}
+		}
+	})
+}
+
+func TestDiffImage(t *testing.T) {
+	a := image.NewNRGBA(image.Rect(0, 0, 5, 1))
+	for i := range a.Pix {
+		a.Pix[i] = 100
+	}
+
+	b := image.NewNRGBA(a.Rect)
+	copy(b.Pix, a.Pix)
+	b.SetNRGBA(1, 0, color.NRGBA{R: 101, G: 100, B: 100, A: 100})
+	b.SetNRGBA(2, 0, color.NRGBA{R: 99, G: 100, B: 100, A: 100})
+	b.SetNRGBA(3, 0, color.NRGBA{R: 200, G: 200, B: 200, A: 100})
+	b.SetNRGBA(4, 0, color.NRGBA{R: 100, G: 100, B: 100, A: 90})
+
+	diff := DiffImage(a, b, 64, runtime.NumCPU())
+
+	cases := []struct {
+		Name     string
+		X        int
+		Expected color.NRGBA
+	}{
+		{Name: "identical pixel", X: 0, Expected: color.NRGBA{A: 255}},
+		{Name: "slightly brighter pixel", X: 1, Expected: color.NRGBA{R: 128, A: 255}},
+		{Name: "slightly darker pixel", X: 2, Expected: color.NRGBA{B: 128, A: 255}},
+		{Name: "much brighter pixel", X: 3, Expected: color.NRGBA{R: 255, G: 255, A: 255}},
+		{Name: "alpha only difference", X: 4, Expected: color.NRGBA{R: 255, G: 255, B: 255, A: 255}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			if actual := diff.NRGBAAt(c.X, 0); actual != c.Expected {
+				t.Errorf("Expected %v but was %v", c.Expected, actual)
+			}
+		})
+	}
+
+	t.Run("panics for images of different bounds", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic but there was none")
+			}
+		}()
+
+		DiffImage(a, image.NewNRGBA(image.Rect(0, 0, 4, 1)), 1, 1)
+	})
+}
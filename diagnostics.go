@@ -0,0 +1,162 @@
+package convolver
+
+import (
+	"fmt"
+	"github.com/mandykoh/go-parallel"
+	"image"
+	"math"
+	"strings"
+	"sync"
+)
+
+// Diagnostics collects problems with the values computed while applying a
+// kernel, for debugging custom kernels and operations. It is safe for
+// concurrent use, so one Diagnostics can be shared by several kernels.
+type Diagnostics struct {
+	mutex  sync.Mutex
+	report DiagnosticReport
+}
+
+// DiagnosticIssue summarises occurrences of one kind of problem.
+type DiagnosticIssue struct {
+	// Count is the number of pixels with the problem in any channel.
+	Count int
+
+	// First is the position of the first affected pixel in raster order,
+	// and Value the offending channel value there. They are only meaningful
+	// if Count is non-zero.
+	First image.Point
+	Value float32
+}
+
+// DiagnosticReport summarises the problems found by a Diagnostics.
+type DiagnosticReport struct {
+	// NaN counts pixels with a channel which is not a number, such as from
+	// weights which are NaN or sum to infinity.
+	NaN DiagnosticIssue
+
+	// Inf counts pixels with an infinite channel.
+	Inf DiagnosticIssue
+
+	// OutOfRange counts pixels with a finite channel outside the range 0 to
+	// 1, which are clipped on output to 8 bits. This is expected of HDR input
+	// or kernels with negative weights such as for sharpening, but can
+	// otherwise indicate mistaken weights.
+	OutOfRange DiagnosticIssue
+}
+
+// Clean returns whether no problems were found.
+func (r DiagnosticReport) Clean() bool {
+	return r.NaN.Count == 0 && r.Inf.Count == 0 && r.OutOfRange.Count == 0
+}
+
+func (r DiagnosticReport) String() string {
+	if r.Clean() {
+		return "no problems found"
+	}
+
+	var problems []string
+	for _, issue := range []struct {
+		name  string
+		issue DiagnosticIssue
+	}{
+		{"NaN", r.NaN},
+		{"infinite", r.Inf},
+		{"out of range", r.OutOfRange},
+	} {
+		if issue.issue.Count > 0 {
+			problems = append(problems, fmt.Sprintf("%d %s (first %v at %v)", issue.issue.Count, issue.name, issue.issue.Value, issue.issue.First))
+		}
+	}
+
+	return strings.Join(problems, ", ")
+}
+
+// SetDiagnostics sets d to collect problems with the values computed by this
+// kernel's averaging operations, such as Avg and ApplyAvgFloat, before they
+// are clamped or encoded. Checking values slows application, so this is
+// intended only for debugging. A nil Diagnostics, the default, disables
+// checking.
+func (k *Kernel) SetDiagnostics(d *Diagnostics) {
+	k.diagnostics = d
+}
+
+// CheckFloatImage records problems with the values of a FloatImage, such as
+// one produced by a custom operation.
+func (d *Diagnostics) CheckFloatImage(img *FloatImage, parallelism int) {
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := img.Rect.Min.Y + workerNum; i < img.Rect.Max.Y; i += workerCount {
+			for j := img.Rect.Min.X; j < img.Rect.Max.X; j++ {
+				r, g, b, a := img.RGBAAt(j, i)
+				d.check(j, i, kernelWeight{R: r, G: g, B: b, A: a})
+			}
+		}
+	})
+}
+
+// Report returns a summary of the problems found so far.
+func (d *Diagnostics) Report() DiagnosticReport {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.report
+}
+
+// Reset discards the problems found so far.
+func (d *Diagnostics) Reset() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.report = DiagnosticReport{}
+}
+
+// check records any problems with the channel values of the pixel at x, y.
+func (d *Diagnostics) check(x, y int, c kernelWeight) {
+	var nan, inf, outOfRange *float32
+
+	for _, v := range [4]*float32{&c.R, &c.G, &c.B, &c.A} {
+		switch {
+		case math.IsNaN(float64(*v)):
+			if nan == nil {
+				nan = v
+			}
+		case math.IsInf(float64(*v), 0):
+			if inf == nil {
+				inf = v
+			}
+		case *v < 0 || *v > 1:
+			if outOfRange == nil {
+				outOfRange = v
+			}
+		}
+	}
+
+	if nan == nil && inf == nil && outOfRange == nil {
+		return
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	p := image.Pt(x, y)
+	d.report.NaN.record(p, nan)
+	d.report.Inf.record(p, inf)
+	d.report.OutOfRange.record(p, outOfRange)
+}
+
+func (i *DiagnosticIssue) record(p image.Point, value *float32) {
+	if value == nil {
+		return
+	}
+
+	if i.Count == 0 || p.Y < i.First.Y || p.Y == i.First.Y && p.X < i.First.X {
+		i.First = p
+		i.Value = *value
+	}
+	i.Count++
+}
+
+// NewDiagnostics returns a Diagnostics which has found no problems.
+func NewDiagnostics() *Diagnostics {
+	return &Diagnostics{}
+}
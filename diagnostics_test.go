@@ -0,0 +1,112 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestDiagnostics(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 6, 5))
+	for i := range img.Pix {
+		img.Pix[i] = 128
+	}
+
+	t.Run("finds no problems with well behaved kernels", func(t *testing.T) {
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightsUniform([]float32{1, 2, 1, 2, 4, 2, 1, 2, 1})
+
+		d := NewDiagnostics()
+		kernel.SetDiagnostics(d)
+		kernel.ApplyAvg(randomImage(12, 9), 2)
+
+		if report := d.Report(); !report.Clean() {
+			t.Errorf("Expected no problems but found %v", report)
+		}
+	})
+
+	t.Run("reports out of range values with the first offending pixel", func(t *testing.T) {
+		bright := image.NewNRGBA(img.Rect)
+		for i := range bright.Pix {
+			bright.Pix[i] = 200
+		}
+		bright.SetNRGBA(4, 3, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+		bright.SetNRGBA(2, 1, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightsUniform([]float32{
+			0, -1, 0,
+			-1, 5, -1,
+			0, -1, 0,
+		})
+
+		d := NewDiagnostics()
+		kernel.SetDiagnostics(d)
+		kernel.ApplyAvg(bright, 3)
+
+		report := d.Report()
+		if expected, actual := 2, report.OutOfRange.Count; expected != actual {
+			t.Errorf("Expected %d out of range pixels but was %d", expected, actual)
+		}
+		if expected, actual := image.Pt(2, 1), report.OutOfRange.First; expected != actual {
+			t.Errorf("Expected first out of range pixel to be %v but was %v", expected, actual)
+		}
+		if report.NaN.Count != 0 || report.Inf.Count != 0 {
+			t.Errorf("Expected no NaN or infinite values but found %v", report)
+		}
+	})
+
+	t.Run("reports NaN and infinite values", func(t *testing.T) {
+		f := FloatImageFromImage(img, 1)
+		f.SetRGBA(3, 3, float32(math.NaN()), 0.5, 0.5, 1)
+		f.SetRGBA(4, 1, 0.5, float32(math.Inf(1)), 0.5, 1)
+		f.SetRGBA(1, 2, 0.5, float32(math.Inf(-1)), 0.5, 1)
+
+		kernel := KernelWithRadius(0)
+		kernel.SetWeightsUniform([]float32{1})
+
+		d := NewDiagnostics()
+		kernel.SetDiagnostics(d)
+		kernel.ApplyAvgFloat(f, 2)
+
+		report := d.Report()
+		if expected, actual := 1, report.NaN.Count; expected != actual {
+			t.Errorf("Expected %d NaN pixels but was %d", expected, actual)
+		}
+		if expected, actual := image.Pt(3, 3), report.NaN.First; expected != actual {
+			t.Errorf("Expected first NaN pixel to be %v but was %v", expected, actual)
+		}
+		if expected, actual := 2, report.Inf.Count; expected != actual {
+			t.Errorf("Expected %d infinite pixels but was %d", expected, actual)
+		}
+		if expected, actual := image.Pt(4, 1), report.Inf.First; expected != actual {
+			t.Errorf("Expected first infinite pixel to be %v but was %v", expected, actual)
+		}
+
+		d.Reset()
+
+		if report := d.Report(); !report.Clean() {
+			t.Errorf("Expected no problems after reset but found %v", report)
+		}
+	})
+
+	t.Run("checks float images", func(t *testing.T) {
+		f := FloatImageFromImage(img, 1)
+		f.SetRGBA(5, 4, 0.5, -0.25, 0.5, 1)
+
+		d := NewDiagnostics()
+		d.CheckFloatImage(f, 2)
+
+		report := d.Report()
+		if expected, actual := 1, report.OutOfRange.Count; expected != actual {
+			t.Errorf("Expected %d out of range pixels but was %d", expected, actual)
+		}
+		if expected, actual := float32(-0.25), report.OutOfRange.Value; expected != actual {
+			t.Errorf("Expected out of range value to be %v but was %v", expected, actual)
+		}
+		if expected, actual := "1 out of range (first -0.25 at (5,4))", report.String(); expected != actual {
+			t.Errorf("Expected report to be %q but was %q", expected, actual)
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package convolver
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Drawer returns a draw.Drawer which applies an operation such as k.Avg to
+// the source image, so that convolution can be used wherever compositing code
+// accepts a draw.Drawer. Only the part of the source needed for the
+// destination rectangle (plus the kernel's apron) is processed, honouring the
+// kernel's edge mode, power mode and memory budget as ApplyAvg does.
+func (k *Kernel) Drawer(op OpFunc, parallelism int) draw.Drawer {
+	return kernelDrawer{kernel: k, op: op, parallelism: parallelism}
+}
+
+type kernelDrawer struct {
+	kernel      *Kernel
+	op          OpFunc
+	parallelism int
+}
+
+// Draw aligns r.Min in dst with sp in src, and replaces the rectangle r in dst
+// with the result of applying the operation to src.
+func (d kernelDrawer) Draw(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point) {
+	// Clip the destination rectangle to both images, as draw.Draw does
+	origin := r.Min
+	r = r.Intersect(dst.Bounds())
+	r = r.Intersect(src.Bounds().Add(origin.Sub(sp)))
+	if r.Empty() {
+		return
+	}
+	sp = sp.Add(r.Min.Sub(origin))
+
+	k := d.kernel
+	parallelism := k.powerMode.workers(d.parallelism)
+
+	srcRect := image.Rectangle{Min: sp, Max: sp.Add(r.Size())}
+	apronRect := srcRect.Inset(-k.radius)
+
+	// Pixels beyond the source come from its edges unless the edge mode
+	// clips the kernel instead
+	if k.edgeMode == EdgeClip {
+		apronRect = apronRect.Intersect(src.Bounds())
+	} else {
+		src = edgePaddedImage{img: src, rect: src.Bounds().Inset(-k.radius), mode: k.edgeMode, fill: k.edgeFill()}
+	}
+
+	var result *image.NRGBA
+	if k.exceedsMemoryBudget(apronRect) {
+		result = k.applyBounded(src, srcRect, k.radius, d.op, parallelism)
+	} else {
+		apron := image.NewNRGBA(apronRect)
+		draw.Draw(apron, apronRect, src, apronRect.Min, draw.Src)
+		result = k.applyWithin(apron, srcRect, d.op, parallelism)
+	}
+
+	draw.Draw(dst, r, result, srcRect.Min, draw.Src)
+}
@@ -0,0 +1,113 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"runtime"
+	"testing"
+)
+
+func TestKernelDrawer(t *testing.T) {
+	src := randomImage(40, 30)
+
+	kernel := KernelWithRadius(2)
+	for i := 0; i < kernel.SideLength(); i++ {
+		for j := 0; j < kernel.SideLength(); j++ {
+			kernel.SetWeightUniform(j, i, 1)
+		}
+	}
+
+	expectedImg := kernel.ApplyMin(src, runtime.NumCPU())
+	background := color.NRGBA{R: 1, G: 2, B: 3, A: 255}
+
+	newDestination := func() *image.NRGBA {
+		dst := image.NewNRGBA(image.Rect(100, 100, 130, 120))
+		draw.Draw(dst, dst.Rect, image.NewUniform(background), image.Point{}, draw.Src)
+		return dst
+	}
+
+	t.Run("draws the operation's result into the destination rectangle", func(t *testing.T) {
+		dst := newDestination()
+		r := image.Rect(105, 103, 120, 115)
+		sp := image.Pt(10, 12)
+
+		var drawer draw.Drawer = kernel.Drawer(kernel.Min, runtime.NumCPU())
+		drawer.Draw(dst, r, src, sp)
+
+		for i := dst.Rect.Min.Y; i < dst.Rect.Max.Y; i++ {
+			for j := dst.Rect.Min.X; j < dst.Rect.Max.X; j++ {
+				expected := background
+				if image.Pt(j, i).In(r) {
+					expected = expectedImg.NRGBAAt(j-r.Min.X+sp.X, i-r.Min.Y+sp.Y)
+				}
+
+				if actual := dst.NRGBAAt(j, i); expected != actual {
+					t.Fatalf("Expected pixel %d,%d to be %+v but was %+v", j, i, expected, actual)
+				}
+			}
+		}
+	})
+
+	t.Run("clips against the source bounds", func(t *testing.T) {
+		dst := newDestination()
+		r := image.Rect(100, 100, 130, 120)
+		sp := image.Pt(25, 20)
+
+		kernel.Drawer(kernel.Min, 1).Draw(dst, r, src, sp)
+
+		for i := dst.Rect.Min.Y; i < dst.Rect.Max.Y; i++ {
+			for j := dst.Rect.Min.X; j < dst.Rect.Max.X; j++ {
+				expected := background
+				if sx, sy := j-r.Min.X+sp.X, i-r.Min.Y+sp.Y; image.Pt(sx, sy).In(src.Rect) {
+					expected = expectedImg.NRGBAAt(sx, sy)
+				}
+
+				if actual := dst.NRGBAAt(j, i); expected != actual {
+					t.Fatalf("Expected pixel %d,%d to be %+v but was %+v", j, i, expected, actual)
+				}
+			}
+		}
+	})
+	t.Run("pads the source according to the edge mode", func(t *testing.T) {
+		wrapped := kernel.WithEdgeMode(EdgeWrap)
+		expectedImg := wrapped.ApplyAvg(src, runtime.NumCPU())
+
+		dst := image.NewNRGBA(src.Rect)
+		wrapped.Drawer(wrapped.Avg, runtime.NumCPU()).Draw(dst, dst.Rect, src, src.Rect.Min)
+
+		for i := range expectedImg.Pix {
+			if expectedImg.Pix[i] != dst.Pix[i] {
+				t.Fatalf("Expected results to match but differ at byte %d", i)
+			}
+		}
+	})
+
+	t.Run("draws with parallelism of zero", func(t *testing.T) {
+		dst := newDestination()
+		r := image.Rect(105, 103, 120, 115)
+		sp := image.Pt(10, 12)
+
+		kernel.Drawer(kernel.Min, 0).Draw(dst, r, src, sp)
+
+		expected := expectedImg.NRGBAAt(sp.X, sp.Y)
+		if actual := dst.NRGBAAt(r.Min.X, r.Min.Y); expected != actual {
+			t.Errorf("Expected pixel to be %+v but was %+v", expected, actual)
+		}
+	})
+
+	t.Run("tiles within the memory budget", func(t *testing.T) {
+		bounded := kernel.WithEdgeMode(EdgeMirror)
+		bounded.SetMaxMemoryBytes(1024)
+		expectedImg := kernel.WithEdgeMode(EdgeMirror).ApplyAvg(src, runtime.NumCPU())
+
+		dst := image.NewNRGBA(src.Rect)
+		bounded.Drawer(bounded.Avg, runtime.NumCPU()).Draw(dst, dst.Rect, src, src.Rect.Min)
+
+		for i := range expectedImg.Pix {
+			if expectedImg.Pix[i] != dst.Pix[i] {
+				t.Fatalf("Expected results to match but differ at byte %d", i)
+			}
+		}
+	})
+}
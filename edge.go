@@ -0,0 +1,374 @@
+package convolver
+
+import (
+	"fmt"
+	"github.com/mandykoh/go-parallel"
+	"github.com/mandykoh/prism"
+	"github.com/mandykoh/prism/srgb"
+	"image"
+	"image/color"
+)
+
+// EdgeMode selects how a kernel treats pixels beyond the edges of an image.
+type EdgeMode int
+
+const (
+	// EdgeClip clips the kernel to the image, so that only pixels within it
+	// contribute. Avg renormalises by the weight remaining, while operations
+	// which don't normalise see less of the kernel near the edges. This is the
+	// default.
+	EdgeClip EdgeMode = iota
+
+	// EdgeZero treats pixels beyond the edges as transparent black without
+	// renormalising, so that averages fade towards transparent black at the
+	// edges as they would for an image on an empty canvas.
+	EdgeZero
+
+	// EdgeExtend replicates the nearest edge pixel outwards, clamping each
+	// coordinate to the image, so the whole kernel is always used and flat
+	// regions stay flat up to the edges. Unlike EdgeZero, blurs don't darken
+	// or fade towards the edges. This matches the default border handling of
+	// most other image libraries, such as OpenCV's BORDER_REPLICATE.
+	EdgeExtend
+
+	// EdgeMirror reflects the image across its edges, about the centres of
+	// the edge pixels, so that the pixel beyond an edge repeats the one just
+	// inside it. Like EdgeExtend, the whole kernel is always used, but
+	// gradients and texture continue smoothly across the edges rather than
+	// being flattened, which keeps blurs and morphology stable along borders.
+	// Kernels larger than the image are reflected repeatedly.
+	EdgeMirror
+
+	// EdgeWrap treats the image as tiling the plane, so that pixels beyond
+	// the right edge come from the left edge and those beyond the bottom
+	// from the top. Results are then seamlessly tileable, as needed when
+	// filtering textures.
+	EdgeWrap
+
+	// EdgeConstant treats pixels beyond the edges as a constant colour, set
+	// with SetEdgeColour, without renormalising. With the default of
+	// transparent black it behaves as EdgeZero, but unlike EdgeClip the
+	// pixels beyond the edges are seen by every operation, so that eroding
+	// an alpha mask with Min, for example, treats the area outside the
+	// canvas as fully transparent rather than ignoring it.
+	EdgeConstant
+)
+
+func (m EdgeMode) String() string {
+	switch m {
+	case EdgeClip:
+		return "clip"
+	case EdgeZero:
+		return "zero"
+	case EdgeExtend:
+		return "extend"
+	case EdgeMirror:
+		return "mirror"
+	case EdgeWrap:
+		return "wrap"
+	case EdgeConstant:
+		return "constant"
+	}
+	return fmt.Sprintf("EdgeMode(%d)", int(m))
+}
+
+// SetEdgeMode sets how pixels beyond the edges of an image are treated when
+// applying this kernel to a whole image, whether with ApplyAvg, ApplyMax,
+// ApplyMin and the like, or a tile or row at a time with ApplyTiled,
+// ApplyResumable, ApplyPooled, ApplyFused, ApplyAutoTuned, ApplyRows and
+// ApplyFallible, through Drawer, or to linear values with ApplyAvgFloat and
+// ApplyAvgSource.
+// Modes other than EdgeClip are implemented by padding the input, so every
+// operation sees the same pixels beyond the edges; the input is padded as a
+// whole only if it fits within the memory budget, and otherwise one tile at a
+// time.
+//
+// Use WithEdgeMode to choose a mode for a single application.
+func (k *Kernel) SetEdgeMode(mode EdgeMode) {
+	if mode < EdgeClip || mode > EdgeConstant {
+		panic(fmt.Sprintf("unknown edge mode %d", int(mode)))
+	}
+
+	k.edgeMode = mode
+}
+
+// WithEdgeMode returns a copy of the kernel which treats pixels beyond the
+// edges of an image according to mode, leaving this kernel unchanged, so that
+// different applications of the same weights can use different modes:
+//
+//	wrapped := k.WithEdgeMode(EdgeWrap).ApplyAvg(img, parallelism)
+func (k *Kernel) WithEdgeMode(mode EdgeMode) *Kernel {
+	c := *k
+	c.weights = append([]kernelWeight(nil), k.weights...)
+	c.SetEdgeMode(mode)
+
+	return &c
+}
+
+// SetEdgeColour sets the colour of pixels beyond the edges of an image when
+// the edge mode is EdgeConstant.
+func (k *Kernel) SetEdgeColour(c color.NRGBA) {
+	k.edgeColour = c
+}
+
+// EdgeMode returns how pixels beyond the edges of an image are treated, as
+// set with SetEdgeMode.
+func (k *Kernel) EdgeMode() EdgeMode {
+	return k.edgeMode
+}
+
+// EdgeColour returns the colour of pixels beyond the edges of an image when
+// the edge mode is EdgeConstant, as set with SetEdgeColour.
+func (k *Kernel) EdgeColour() color.NRGBA {
+	return k.edgeColour
+}
+
+// edgeFill returns the colour of pixels beyond the edges of an image which
+// don't come from within it.
+func (k *Kernel) edgeFill() color.NRGBA {
+	if k.edgeMode == EdgeConstant {
+		return k.edgeColour
+	}
+	return color.NRGBA{}
+}
+
+// sourceCoord returns the coordinate sampled for v in a dimension spanning
+// min to max (exclusive), or false if the sample is the fill colour.
+func (m EdgeMode) sourceCoord(v, min, max int) (int, bool) {
+	if v >= min && v < max {
+		return v, true
+	}
+
+	switch m {
+	case EdgeExtend:
+		if v < min {
+			return min, true
+		}
+		return max - 1, true
+
+	case EdgeMirror:
+		n := max - min
+		if n == 1 {
+			return min, true
+		}
+
+		period := 2 * (n - 1)
+		offset := (v - min) % period
+		if offset < 0 {
+			offset += period
+		}
+		if offset >= n {
+			offset = period - offset
+		}
+		return min + offset, true
+
+	case EdgeWrap:
+		offset := (v - min) % (max - min)
+		if offset < 0 {
+			offset += max - min
+		}
+		return min + offset, true
+	}
+
+	return 0, false
+}
+
+// applyPadded applies an operation which reads pixels up to reach away to an
+// image padded by that much according to the kernel's edge mode, producing a
+// result with the image's bounds.
+func (k *Kernel) applyPadded(img image.Image, op OpFunc, reach int, parallelism int) *image.NRGBA {
+	bounds := img.Bounds()
+
+	if k.exceedsMemoryBudget(bounds.Inset(-reach)) {
+		return k.applyBounded(edgePaddedImage{img: img, rect: bounds.Inset(-reach), mode: k.edgeMode, fill: k.edgeFill()}, bounds, reach, op, parallelism)
+	}
+
+	padded := padNRGBA(prism.ConvertImageToNRGBA(img, parallelism), reach, k.edgeMode, k.edgeFill(), parallelism)
+	return k.applyWithin(padded, bounds, op, parallelism)
+}
+
+// padNRGBA returns a copy of img with a border of the given width filled
+// according to the edge mode, using the fill colour for pixels which don't
+// come from within the image.
+func padNRGBA(img *image.NRGBA, border int, mode EdgeMode, fill color.NRGBA, parallelism int) *image.NRGBA {
+	bounds := img.Rect
+	result := image.NewNRGBA(bounds.Inset(-border))
+	width := bounds.Dx() * 4
+	filled := fill != color.NRGBA{}
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := result.Rect.Min.Y + workerNum; i < result.Rect.Max.Y; i += workerCount {
+			sy, ok := mode.sourceCoord(i, bounds.Min.Y, bounds.Max.Y)
+			if !ok {
+				if filled {
+					for j := result.Rect.Min.X; j < result.Rect.Max.X; j++ {
+						result.SetNRGBA(j, i, fill)
+					}
+				}
+				continue
+			}
+
+			copy(result.Pix[result.PixOffset(bounds.Min.X, i):][:width], img.Pix[img.PixOffset(bounds.Min.X, sy):][:width])
+
+			for j := result.Rect.Min.X; j < result.Rect.Max.X; j++ {
+				if j == bounds.Min.X {
+					j = bounds.Max.X
+				}
+				if sx, ok := mode.sourceCoord(j, bounds.Min.X, bounds.Max.X); ok {
+					copy(result.Pix[result.PixOffset(j, i):][:4], img.Pix[img.PixOffset(sx, sy):][:4])
+				} else if filled {
+					result.SetNRGBA(j, i, fill)
+				}
+			}
+		}
+	})
+
+	return result
+}
+
+// edgePaddedImage presents an image extended to a larger rectangle according
+// to an edge mode, without copying it.
+type edgePaddedImage struct {
+	img  image.Image
+	rect image.Rectangle
+	mode EdgeMode
+	fill color.NRGBA
+}
+
+func (e edgePaddedImage) ColorModel() color.Model {
+	return e.img.ColorModel()
+}
+
+func (e edgePaddedImage) Bounds() image.Rectangle {
+	return e.rect
+}
+
+func (e edgePaddedImage) At(x, y int) color.Color {
+	bounds := e.img.Bounds()
+
+	sx, okX := e.mode.sourceCoord(x, bounds.Min.X, bounds.Max.X)
+	sy, okY := e.mode.sourceCoord(y, bounds.Min.Y, bounds.Max.Y)
+	if !okX || !okY {
+		return e.fill
+	}
+
+	return e.img.At(sx, sy)
+}
+
+// edgePaddedSource presents a pixel source extended to a larger rectangle
+// according to an edge mode, reading only the parts of the source which each
+// region needs.
+type edgePaddedSource struct {
+	src  PixelSource
+	rect image.Rectangle
+	mode EdgeMode
+	fill [4]float32
+}
+
+// paddedSource returns src padded by the kernel's radius according to its
+// edge mode.
+func (k *Kernel) paddedSource(src PixelSource) edgePaddedSource {
+	c, a := srgb.ColorFromNRGBA(k.edgeFill())
+
+	return edgePaddedSource{
+		src:  src,
+		rect: src.Bounds().Inset(-k.radius),
+		mode: k.edgeMode,
+		fill: [4]float32{c.R, c.G, c.B, a},
+	}
+}
+
+func (e edgePaddedSource) Bounds() image.Rectangle {
+	return e.rect
+}
+
+func (e edgePaddedSource) ReadRegion(dst *FloatImage) error {
+	bounds := e.src.Bounds()
+
+	for _, rows := range edgeSpans(dst.Rect.Min.Y, dst.Rect.Max.Y, bounds.Min.Y, bounds.Max.Y) {
+		for _, cols := range edgeSpans(dst.Rect.Min.X, dst.Rect.Max.X, bounds.Min.X, bounds.Max.X) {
+			if err := e.readPart(dst, image.Rect(cols[0], rows[0], cols[1], rows[1])); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// readPart fills the part of dst within r, which lies either wholly within
+// the source or wholly beyond one or two of its edges.
+func (e edgePaddedSource) readPart(dst *FloatImage, r image.Rectangle) error {
+	bounds := e.src.Bounds()
+
+	if r.In(bounds) {
+		return e.src.ReadRegion(&FloatImage{Pix: dst.Pix[dst.offset(r.Min.X, r.Min.Y):], Stride: dst.Stride, Rect: r})
+	}
+
+	minX, maxX, okX := e.mode.sourceSpan(r.Min.X, r.Max.X, bounds.Min.X, bounds.Max.X)
+	minY, maxY, okY := e.mode.sourceSpan(r.Min.Y, r.Max.Y, bounds.Min.Y, bounds.Max.Y)
+
+	if !okX || !okY {
+		for i := r.Min.Y; i < r.Max.Y; i++ {
+			for j := r.Min.X; j < r.Max.X; j++ {
+				dst.SetRGBA(j, i, e.fill[0], e.fill[1], e.fill[2], e.fill[3])
+			}
+		}
+		return nil
+	}
+
+	region := NewFloatImage(image.Rect(minX, minY, maxX, maxY))
+	if err := e.src.ReadRegion(region); err != nil {
+		return err
+	}
+
+	for i := r.Min.Y; i < r.Max.Y; i++ {
+		sy, _ := e.mode.sourceCoord(i, bounds.Min.Y, bounds.Max.Y)
+
+		for j := r.Min.X; j < r.Max.X; j++ {
+			sx, _ := e.mode.sourceCoord(j, bounds.Min.X, bounds.Max.X)
+			r, g, b, a := region.RGBAAt(sx, sy)
+			dst.SetRGBA(j, i, r, g, b, a)
+		}
+	}
+
+	return nil
+}
+
+// sourceSpan returns the range of coordinates sampled for lo to hi
+// (exclusive) in a dimension spanning min to max, or false if the samples are
+// the fill colour.
+func (m EdgeMode) sourceSpan(lo, hi, min, max int) (int, int, bool) {
+	first, ok := m.sourceCoord(lo, min, max)
+	if !ok {
+		return 0, 0, false
+	}
+
+	spanMin, spanMax := first, first+1
+	for v := lo + 1; v < hi; v++ {
+		s, _ := m.sourceCoord(v, min, max)
+		if s < spanMin {
+			spanMin = s
+		}
+		if s >= spanMax {
+			spanMax = s + 1
+		}
+	}
+
+	return spanMin, spanMax, true
+}
+
+// edgeSpans splits lo to hi (exclusive) at min and max into the non-empty
+// spans before, within and after them.
+func edgeSpans(lo, hi, min, max int) [][2]int {
+	var spans [][2]int
+
+	for _, span := range [][2]int{{lo, min}, {min, max}, {max, hi}} {
+		start, end := clampInt(span[0], lo, hi), clampInt(span[1], lo, hi)
+		if start < end {
+			spans = append(spans, [2]int{start, end})
+		}
+	}
+
+	return spans
+}
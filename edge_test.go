@@ -0,0 +1,420 @@
+package convolver
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestEdgeMode(t *testing.T) {
+	flat := image.NewNRGBA(image.Rect(2, 3, 9, 8))
+	for i := flat.Rect.Min.Y; i < flat.Rect.Max.Y; i++ {
+		for j := flat.Rect.Min.X; j < flat.Rect.Max.X; j++ {
+			flat.SetNRGBA(j, i, color.NRGBA{R: 200, G: 100, B: 50, A: 255})
+		}
+	}
+
+	sum := func() Kernel {
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightsUniform([]float32{
+			1, 1, 1,
+			1, 1, 1,
+			1, 1, 1,
+		})
+		return kernel
+	}
+
+	t.Run("clip renormalises at the edges by default", func(t *testing.T) {
+		kernel := sum()
+		result := kernel.ApplyAvg(flat, 2)
+
+		if expected, actual := flat.NRGBAAt(2, 3), result.NRGBAAt(2, 3); expected != actual {
+			t.Errorf("Expected corner to be %v but was %v", expected, actual)
+		}
+	})
+
+	t.Run("zero fades towards transparent black at the edges", func(t *testing.T) {
+		kernel := sum()
+		kernel.SetEdgeMode(EdgeZero)
+		result := kernel.ApplyAvg(flat, 2)
+
+		if expected, actual := flat.NRGBAAt(5, 5), result.NRGBAAt(5, 5); expected != actual {
+			t.Errorf("Expected interior to be %v but was %v", expected, actual)
+		}
+		if expected, actual := uint8(113), result.NRGBAAt(2, 3).A; expected != actual {
+			t.Errorf("Expected corner alpha to be %d but was %d", expected, actual)
+		}
+		if expected, actual := uint8(170), result.NRGBAAt(5, 7).A; expected != actual {
+			t.Errorf("Expected edge alpha to be %d but was %d", expected, actual)
+		}
+		if expected, actual := result.Rect, flat.Rect; expected != actual {
+			t.Errorf("Expected result bounds to be %v but were %v", expected, actual)
+		}
+	})
+
+	t.Run("extend replicates the edge pixels", func(t *testing.T) {
+		img := randomImage(6, 5)
+
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightsUniform([]float32{
+			0, 0, 0,
+			1, 0, 0,
+			0, 0, 0,
+		})
+		clipped := kernel.ApplyAvg(img, 2)
+
+		kernel.SetEdgeMode(EdgeExtend)
+		result := kernel.ApplyAvg(img, 2)
+
+		for i := img.Rect.Min.Y; i < img.Rect.Max.Y; i++ {
+			if expected, actual := result.NRGBAAt(1, i), result.NRGBAAt(0, i); expected != actual {
+				t.Errorf("Expected left edge at row %d to repeat the edge pixel %v but was %v", i, expected, actual)
+			}
+			if expected, actual := clipped.NRGBAAt(3, i), result.NRGBAAt(3, i); expected != actual {
+				t.Errorf("Expected interior at row %d to be %v but was %v", i, expected, actual)
+			}
+		}
+	})
+
+	t.Run("extend keeps flat images flat without edge darkening", func(t *testing.T) {
+		kernel := GaussianKernel(3)
+		kernel.SetEdgeMode(EdgeExtend)
+		result := kernel.ApplyAvg(flat, 2)
+
+		for i := flat.Rect.Min.Y; i < flat.Rect.Max.Y; i++ {
+			for j := flat.Rect.Min.X; j < flat.Rect.Max.X; j++ {
+				if expected, actual := flat.NRGBAAt(j, i), result.NRGBAAt(j, i); expected != actual {
+					t.Fatalf("Expected pixel at %d,%d to be %v but was %v", j, i, expected, actual)
+				}
+			}
+		}
+	})
+
+	t.Run("mirror reflects about the edge pixels", func(t *testing.T) {
+		img := randomImage(6, 5)
+
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightsUniform([]float32{
+			0, 0, 0,
+			1, 0, 0,
+			0, 0, 0,
+		})
+		clipped := kernel.ApplyAvg(img, 2)
+
+		kernel.SetEdgeMode(EdgeMirror)
+		result := kernel.ApplyAvg(img, 2)
+
+		for i := img.Rect.Min.Y; i < img.Rect.Max.Y; i++ {
+			if expected, actual := result.NRGBAAt(2, i), result.NRGBAAt(0, i); expected != actual {
+				t.Errorf("Expected left edge at row %d to repeat the pixel inside it %v but was %v", i, expected, actual)
+			}
+			if expected, actual := clipped.NRGBAAt(3, i), result.NRGBAAt(3, i); expected != actual {
+				t.Errorf("Expected interior at row %d to be %v but was %v", i, expected, actual)
+			}
+		}
+	})
+
+	t.Run("mirror reflects repeatedly for kernels larger than the image", func(t *testing.T) {
+		cases := []struct {
+			V, Min, Max, Expected int
+		}{
+			{V: -1, Min: 0, Max: 3, Expected: 1},
+			{V: -2, Min: 0, Max: 3, Expected: 2},
+			{V: -3, Min: 0, Max: 3, Expected: 1},
+			{V: -4, Min: 0, Max: 3, Expected: 0},
+			{V: 3, Min: 0, Max: 3, Expected: 1},
+			{V: 5, Min: 0, Max: 3, Expected: 1},
+			{V: 6, Min: 0, Max: 3, Expected: 2},
+			{V: 12, Min: 10, Max: 13, Expected: 12},
+			{V: 8, Min: 10, Max: 13, Expected: 12},
+			{V: -5, Min: 4, Max: 5, Expected: 4},
+		}
+
+		for _, c := range cases {
+			actual, ok := EdgeMirror.sourceCoord(c.V, c.Min, c.Max)
+			if !ok || actual != c.Expected {
+				t.Errorf("Expected %d in %d to %d to mirror to %d but was %d", c.V, c.Min, c.Max, c.Expected, actual)
+			}
+		}
+	})
+
+	t.Run("wrap samples the opposite edge", func(t *testing.T) {
+		img := randomImage(6, 5)
+
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightsUniform([]float32{
+			0, 0, 0,
+			1, 0, 0,
+			0, 0, 0,
+		})
+		kernel.SetEdgeMode(EdgeWrap)
+		result := kernel.ApplyAvg(img, 2)
+
+		identity := KernelWithRadius(0)
+		identity.SetWeightsUniform([]float32{1})
+		roundTripped := identity.ApplyAvg(img, 2)
+
+		for i := img.Rect.Min.Y; i < img.Rect.Max.Y; i++ {
+			if expected, actual := roundTripped.NRGBAAt(5, i), result.NRGBAAt(0, i); expected != actual {
+				t.Errorf("Expected left edge at row %d to take the right edge pixel %v but was %v", i, expected, actual)
+			}
+		}
+
+		for _, c := range []struct{ V, Expected int }{{-1, 5}, {-7, 5}, {6, 0}, {13, 1}} {
+			if actual, ok := EdgeWrap.sourceCoord(c.V, 0, 6); !ok || actual != c.Expected {
+				t.Errorf("Expected %d to wrap to %d but was %d", c.V, c.Expected, actual)
+			}
+		}
+	})
+
+	t.Run("wrap makes results tileable", func(t *testing.T) {
+		img := randomImage(16, 12)
+		tiled := image.NewNRGBA(image.Rect(0, 0, 48, 36))
+		for i := 0; i < 36; i++ {
+			for j := 0; j < 48; j++ {
+				tiled.SetNRGBA(j, i, img.NRGBAAt(j%16, i%12))
+			}
+		}
+
+		kernel := GaussianKernel(1.5)
+		kernel.SetEdgeMode(EdgeWrap)
+		result := kernel.ApplyAvg(img, 2)
+
+		kernel.SetEdgeMode(EdgeClip)
+		expected := kernel.ApplyAvg(tiled, 2)
+
+		for i := 0; i < 12; i++ {
+			for j := 0; j < 16; j++ {
+				if e, a := expected.NRGBAAt(16+j, 12+i), result.NRGBAAt(j, i); e != a {
+					t.Fatalf("Expected %v but was %v at %d, %d", e, a, j, i)
+				}
+			}
+		}
+	})
+
+	t.Run("constant erodes an alpha mask from outside the canvas", func(t *testing.T) {
+		kernel := sum()
+		clipped := kernel.ApplyMin(flat, 2)
+
+		kernel.SetEdgeMode(EdgeConstant)
+		kernel.SetEdgeColour(color.NRGBA{})
+		result := kernel.ApplyMin(flat, 2)
+
+		if expected, actual := uint8(255), clipped.NRGBAAt(2, 5).A; expected != actual {
+			t.Errorf("Expected clipped edge alpha to be %d but was %d", expected, actual)
+		}
+		if expected, actual := uint8(0), result.NRGBAAt(2, 5).A; expected != actual {
+			t.Errorf("Expected edge alpha to be %d but was %d", expected, actual)
+		}
+		if expected, actual := flat.NRGBAAt(5, 5), result.NRGBAAt(5, 5); expected != actual {
+			t.Errorf("Expected interior to be %v but was %v", expected, actual)
+		}
+	})
+
+	t.Run("constant blends towards the edge colour", func(t *testing.T) {
+		kernel := sum()
+		kernel.SetEdgeMode(EdgeConstant)
+		kernel.SetEdgeColour(color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+		result := kernel.ApplyAvg(flat, 2)
+
+		if expected, actual := flat.NRGBAAt(5, 5), result.NRGBAAt(5, 5); expected != actual {
+			t.Errorf("Expected interior to be %v but was %v", expected, actual)
+		}
+
+		edge := result.NRGBAAt(5, 3)
+		corner := result.NRGBAAt(2, 3)
+		if edge.G <= 100 || corner.G <= edge.G {
+			t.Errorf("Expected green to increase towards the corner but was %d at the edge and %d at the corner", edge.G, corner.G)
+		}
+		if expected, actual := uint8(255), corner.A; expected != actual {
+			t.Errorf("Expected corner alpha to be %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("edge colour is ignored by other modes", func(t *testing.T) {
+		kernel := sum()
+		kernel.SetEdgeMode(EdgeZero)
+		expected := kernel.ApplyAvg(flat, 2)
+
+		kernel.SetEdgeColour(color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+		actual := kernel.ApplyAvg(flat, 2)
+
+		for i := range expected.Pix {
+			if expected.Pix[i] != actual.Pix[i] {
+				t.Fatalf("Expected result to be unaffected by the edge colour but differed at offset %d", i)
+			}
+		}
+	})
+
+	t.Run("applies to every operation", func(t *testing.T) {
+		img := randomImage(9, 7)
+
+		kernel := sum()
+		kernel.SetEdgeMode(EdgeZero)
+		result := kernel.ApplyMin(img, 2)
+
+		for j := img.Rect.Min.X; j < img.Rect.Max.X; j++ {
+			if expected, actual := (color.NRGBA{}), result.NRGBAAt(j, 0); expected != actual {
+				t.Errorf("Expected minimum at top edge to be %v but was %v", expected, actual)
+			}
+		}
+	})
+
+	t.Run("produces the same results with a memory budget", func(t *testing.T) {
+		img := randomImage(23, 19)
+
+		for _, mode := range []EdgeMode{EdgeZero, EdgeExtend, EdgeMirror, EdgeWrap, EdgeConstant} {
+			kernel := sum()
+			kernel.SetEdgeMode(mode)
+			kernel.SetEdgeColour(color.NRGBA{R: 10, G: 20, B: 30, A: 128})
+			expected := kernel.ApplyAvg(img, 3)
+
+			kernel.SetMaxMemoryBytes(1024)
+			actual := kernel.ApplyAvg(img.SubImage(img.Rect), 3)
+
+			for i := range expected.Pix {
+				if expected.Pix[i] != actual.Pix[i] {
+					t.Fatalf("Expected memory bounded result with edge mode %v to match at offset %d", mode, i)
+				}
+			}
+		}
+	})
+
+	t.Run("is honoured by tiled and streaming applications", func(t *testing.T) {
+		img := randomImage(23, 19)
+		rgba := image.NewRGBA(img.Rect)
+		draw.Draw(rgba, img.Rect, img, img.Rect.Min, draw.Src)
+
+		for _, mode := range []EdgeMode{EdgeClip, EdgeZero, EdgeExtend, EdgeMirror, EdgeWrap, EdgeConstant} {
+			for _, budget := range []int{0, 1024} {
+				kernel := sum()
+				kernel.SetEdgeMode(mode)
+				kernel.SetEdgeColour(color.NRGBA{R: 10, G: 20, B: 30, A: 128})
+				expected := kernel.ApplyAvg(rgba, 3)
+
+				kernel.SetMaxMemoryBytes(budget)
+
+				pool := NewPool(3, 5)
+				defer pool.Close()
+
+				resumed, _ := kernel.ApplyResumable(context.Background(), rgba, kernel.Avg, NewCheckpoint(rgba.Rect, 5), 3)
+				fallible, _ := kernel.ApplyFallible(context.Background(), rgba, Fallible(kernel.Avg), 3)
+
+				rows := image.NewNRGBA(img.Rect)
+				kernel.ApplyRows(rgba, kernel.Avg, func(y int, row *image.NRGBA) error {
+					copy(rows.Pix[rows.PixOffset(rows.Rect.Min.X, y):], row.Pix)
+					return nil
+				}, 3)
+
+				results := map[string]*image.NRGBA{
+					"ApplyTiled":     kernel.ApplyTiled(rgba, kernel.Avg, 5, nil, 3),
+					"ApplyResumable": resumed,
+					"ApplyPooled":    kernel.ApplyPooled(rgba, kernel.Avg, pool, PriorityBatch),
+					"ApplyFused":     ApplyFused(rgba, []FusedStep{{Kernel: &kernel, Op: kernel.Avg}}, 5, 3),
+					"ApplyAutoTuned": kernel.ApplyAutoTuned(rgba, kernel.Avg, "edge", NewMemoryTuningStore(), 3),
+					"ApplyRows":      rows,
+					"ApplyFallible":  fallible,
+				}
+
+				for name, actual := range results {
+					for i := range expected.Pix {
+						if expected.Pix[i] != actual.Pix[i] {
+							t.Fatalf("Expected %s with edge mode %v and budget %d to match at offset %d", name, mode, budget, i)
+						}
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("is honoured by linear applications", func(t *testing.T) {
+		img := randomImage(7, 5)
+
+		kernel := KernelWithRadius(4)
+		for i := 0; i < kernel.SideLength(); i++ {
+			for j := 0; j < kernel.SideLength(); j++ {
+				kernel.SetWeightUniform(j, i, float32(i*3+j+1))
+			}
+		}
+		fill := color.NRGBA{R: 10, G: 20, B: 30, A: 128}
+
+		for _, mode := range []EdgeMode{EdgeZero, EdgeExtend, EdgeMirror, EdgeWrap, EdgeConstant} {
+			k := kernel.WithEdgeMode(mode)
+			k.SetEdgeColour(fill)
+
+			padded := FloatImageFromImage(padNRGBA(img, 4, mode, k.edgeFill(), 1), 1)
+			expected := kernel.ApplyAvgFloat(padded, 1)
+
+			sourced, err := k.ApplyAvgSource(context.Background(), FloatImageFromImage(img, 1), 3, 2)
+			if err != nil {
+				t.Fatalf("Expected no error but got %v", err)
+			}
+
+			results := map[string]*FloatImage{
+				"ApplyAvgFloat":  k.ApplyAvgFloat(FloatImageFromImage(img, 1), 2),
+				"ApplyAvgSource": sourced,
+			}
+
+			for name, actual := range results {
+				if actual.Rect != img.Rect {
+					t.Fatalf("Expected %s bounds to be %v but were %v", name, img.Rect, actual.Rect)
+				}
+				for i := img.Rect.Min.Y; i < img.Rect.Max.Y; i++ {
+					for j := img.Rect.Min.X; j < img.Rect.Max.X; j++ {
+						er, eg, eb, ea := expected.RGBAAt(j, i)
+						ar, ag, ab, aa := actual.RGBAAt(j, i)
+						if er != ar || eg != ag || eb != ab || ea != aa {
+							t.Fatalf("Expected %s with edge mode %v at %d,%d to be %v but was %v", name, mode, j, i, []float32{er, eg, eb, ea}, []float32{ar, ag, ab, aa})
+						}
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("can be chosen for a single application", func(t *testing.T) {
+		img := randomImage(9, 7)
+
+		kernel := sum()
+		clipped := kernel.ApplyAvg(img, 2)
+		wrapped := kernel.WithEdgeMode(EdgeWrap).ApplyAvg(img, 2)
+
+		expectedKernel := sum()
+		expectedKernel.SetEdgeMode(EdgeWrap)
+		expected := expectedKernel.ApplyAvg(img, 2)
+
+		for i := range expected.Pix {
+			if expected.Pix[i] != wrapped.Pix[i] {
+				t.Fatalf("Expected wrapped result to match at offset %d", i)
+			}
+		}
+		if kernel.edgeMode != EdgeClip {
+			t.Errorf("Expected original kernel's edge mode to be unchanged but was %v", kernel.edgeMode)
+		}
+		if again := kernel.ApplyAvg(img, 2); again.Pix[0] != clipped.Pix[0] {
+			t.Errorf("Expected original kernel to still clip")
+		}
+	})
+
+	t.Run("panics for an unknown mode", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic")
+			}
+		}()
+
+		kernel := sum()
+		kernel.SetEdgeMode(EdgeMode(-1))
+	})
+
+	t.Run("names each mode", func(t *testing.T) {
+		if expected, actual := "mirror", EdgeMirror.String(); expected != actual {
+			t.Errorf("Expected name %q but was %q", expected, actual)
+		}
+		if expected, actual := "constant", EdgeConstant.String(); expected != actual {
+			t.Errorf("Expected name %q but was %q", expected, actual)
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// maxIntegerKernelWeight bounds the total magnitude of integer weights for
+// which sums of 8-bit values are guaranteed to fit in 32 bits.
+const maxIntegerKernelWeight = math.MaxInt32 / 255
+
+// ApplyAvgEncoded applies the kernel like ApplyAvg, but averages sRGB encoded
+// values directly instead of converting them to linear light first. This is
+// less accurate, darkening fine detail and the edges between bright and dark
+// areas, but matches classic 8-bit convolvers and is considerably faster.
+//
+// When every weight is a whole number, as with typical 3×3 and 5×5 blur,
+// sharpen and edge kernels, sums are computed entirely in integer arithmetic.
+func (k *Kernel) ApplyAvgEncoded(img image.Image, parallelism int) *image.NRGBA {
+	if weights, ok := k.integerWeights(); ok {
+		return k.applyImage(img, k.avgEncodedInteger(weights), parallelism)
+	}
+	return k.applyImage(img, k.AvgEncoded, parallelism)
+}
+
+// AvgEncoded is like Avg, but averages sRGB encoded values directly.
+func (k *Kernel) AvgEncoded(img *image.NRGBA, x, y int) color.NRGBA {
+	clip := k.clipToBounds(img.Rect, x, y)
+
+	totalWeight := kernelWeight{}
+	sum := kernelWeight{}
+
+	for s := clip.Top; s < k.sideLength-clip.Bottom; s++ {
+		for t := clip.Left; t < k.sideLength-clip.Right; t++ {
+			weight := k.weights[s*k.sideLength+t]
+			totalWeight.R += weight.R
+			totalWeight.G += weight.G
+			totalWeight.B += weight.B
+			totalWeight.A += weight.A
+
+			c := img.NRGBAAt(x+t-k.radius, y+s-k.radius)
+			sum.R += float32(c.R) * weight.R
+			sum.G += float32(c.G) * weight.G
+			sum.B += float32(c.B) * weight.B
+			sum.A += float32(c.A) * weight.A
+		}
+	}
+
+	if totalWeight.R > 0 {
+		sum.R /= totalWeight.R
+	}
+	if totalWeight.G > 0 {
+		sum.G /= totalWeight.G
+	}
+	if totalWeight.B > 0 {
+		sum.B /= totalWeight.B
+	}
+	if totalWeight.A > 0 {
+		sum.A /= totalWeight.A
+	}
+
+	return color.NRGBA{
+		R: clampEncoded(int32(math.Round(float64(sum.R)))),
+		G: clampEncoded(int32(math.Round(float64(sum.G)))),
+		B: clampEncoded(int32(math.Round(float64(sum.B)))),
+		A: clampEncoded(int32(math.Round(float64(sum.A)))),
+	}
+}
+
+// avgEncodedInteger returns an operation equivalent to AvgEncoded for a kernel
+// with the given integer weights, as returned by integerWeights, using only
+// integer arithmetic.
+func (k *Kernel) avgEncodedInteger(weights [][4]int32) OpFunc {
+	var taps []integerTap
+	var fullWeight [4]int32
+
+	for s := 0; s < k.sideLength; s++ {
+		for t := 0; t < k.sideLength; t++ {
+			w := weights[s*k.sideLength+t]
+			for c := range fullWeight {
+				fullWeight[c] += w[c]
+			}
+			if w != ([4]int32{}) {
+				taps = append(taps, integerTap{dx: t - k.radius, dy: s - k.radius, weight: w})
+			}
+		}
+	}
+
+	return func(img *image.NRGBA, x, y int) color.NRGBA {
+		clip := k.clipToBounds(img.Rect, x, y)
+
+		var totalWeight, sum [4]int32
+
+		if clip == (kernelClip{}) {
+			// Away from the edges, every tap is within the image, so only
+			// taps with non-zero weights need be visited.
+			totalWeight = fullWeight
+			base := img.PixOffset(x, y)
+
+			for _, tap := range taps {
+				offset := base + tap.dy*img.Stride + tap.dx*4
+				p := img.Pix[offset : offset+4 : offset+4]
+
+				sum[0] += int32(p[0]) * tap.weight[0]
+				sum[1] += int32(p[1]) * tap.weight[1]
+				sum[2] += int32(p[2]) * tap.weight[2]
+				sum[3] += int32(p[3]) * tap.weight[3]
+			}
+		} else {
+			for s := clip.Top; s < k.sideLength-clip.Bottom; s++ {
+				offset := img.PixOffset(x+clip.Left-k.radius, y+s-k.radius)
+
+				for t := clip.Left; t < k.sideLength-clip.Right; t++ {
+					w := &weights[s*k.sideLength+t]
+					p := img.Pix[offset : offset+4 : offset+4]
+
+					for c := 0; c < 4; c++ {
+						totalWeight[c] += w[c]
+						sum[c] += int32(p[c]) * w[c]
+					}
+					offset += 4
+				}
+			}
+		}
+
+		var result [4]uint8
+		for c := 0; c < 4; c++ {
+			v := sum[c]
+			if total := totalWeight[c]; total > 0 {
+				v = roundedQuotient(v, total)
+			}
+			result[c] = clampEncoded(v)
+		}
+
+		return color.NRGBA{R: result[0], G: result[1], B: result[2], A: result[3]}
+	}
+}
+
+// integerTap is a kernel tap with non-zero integer weights, positioned
+// relative to the kernel's centre.
+type integerTap struct {
+	dx, dy int
+	weight [4]int32
+}
+
+// integerWeights returns the kernel's weights as integers, or false if any
+// weight is not a whole number or the weights are too large for sums to fit
+// in 32 bits.
+func (k *Kernel) integerWeights() ([][4]int32, bool) {
+	weights := make([][4]int32, len(k.weights))
+	var total [4]float64
+
+	for i, w := range k.weights {
+		for c, v := range [4]float32{w.R, w.G, w.B, w.A} {
+			if v != float32(math.Trunc(float64(v))) {
+				return nil, false
+			}
+
+			total[c] += math.Abs(float64(v))
+			if total[c] > maxIntegerKernelWeight {
+				return nil, false
+			}
+
+			weights[i][c] = int32(v)
+		}
+	}
+
+	return weights, true
+}
+
+// roundedQuotient divides a by a positive b, rounding halves away from zero.
+func roundedQuotient(a, b int32) int32 {
+	if a < 0 {
+		return -((-a + b/2) / b)
+	}
+	return (a + b/2) / b
+}
+
+func clampEncoded(v int32) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
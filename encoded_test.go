@@ -0,0 +1,95 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+)
+
+func TestApplyAvgEncoded(t *testing.T) {
+	t.Run("averages encoded values without linearising", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+		img.SetNRGBA(0, 0, color.NRGBA{A: 255})
+		img.SetNRGBA(1, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightsUniform([]float32{0, 0, 0, 1, 1, 1, 0, 0, 0})
+
+		result := kernel.ApplyAvgEncoded(img, runtime.NumCPU())
+
+		if expected, actual := (color.NRGBA{R: 128, G: 128, B: 128, A: 255}), result.NRGBAAt(0, 0); expected != actual {
+			t.Errorf("Expected encoded average to be %+v but was %+v", expected, actual)
+		}
+	})
+
+	t.Run("integer path matches the float path", func(t *testing.T) {
+		img := randomImage(40, 30)
+
+		for _, weights := range [][]float32{
+			{1, 2, 1, 2, 4, 2, 1, 2, 1},
+			{0, -1, 0, -1, 5, -1, 0, -1, 0},
+			{1, 4, 6, 4, 1, 4, 16, 24, 16, 4, 6, 24, 36, 24, 6, 4, 16, 24, 16, 4, 1, 4, 6, 4, 1},
+		} {
+			radius := 1
+			if len(weights) == 25 {
+				radius = 2
+			}
+			kernel := KernelWithRadius(radius)
+			kernel.SetWeightsUniform(weights)
+
+			if _, ok := kernel.integerWeights(); !ok {
+				t.Fatalf("Expected weights %v to use the integer path", weights)
+			}
+
+			expected := kernel.applyImage(img, kernel.AvgEncoded, runtime.NumCPU())
+			actual := kernel.ApplyAvgEncoded(img, runtime.NumCPU())
+
+			for i := range expected.Pix {
+				if expected.Pix[i] != actual.Pix[i] {
+					t.Fatalf("Expected integer result for weights %v to match at offset %d: %d vs %d", weights, i, expected.Pix[i], actual.Pix[i])
+				}
+			}
+		}
+	})
+
+	t.Run("uses the float path for fractional weights", func(t *testing.T) {
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightsUniform([]float32{0.5, 1, 0.5, 1, 2, 1, 0.5, 1, 0.5})
+
+		if _, ok := kernel.integerWeights(); ok {
+			t.Fatalf("Expected fractional weights not to use the integer path")
+		}
+
+		img := randomImage(10, 10)
+		doubled := KernelWithRadius(1)
+		doubled.SetWeightsUniform([]float32{1, 2, 1, 2, 4, 2, 1, 2, 1})
+
+		expected := doubled.ApplyAvgEncoded(img, runtime.NumCPU())
+		actual := kernel.ApplyAvgEncoded(img, runtime.NumCPU())
+
+		for i := range expected.Pix {
+			if absDiff(expected.Pix[i], actual.Pix[i]) > 1 {
+				t.Fatalf("Expected scaled weights to give the same result at offset %d: %d vs %d", i, expected.Pix[i], actual.Pix[i])
+			}
+		}
+	})
+}
+
+func BenchmarkApplyAvgEncoded(b *testing.B) {
+	img := randomImage(1024, 1024)
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{1, 2, 1, 2, 4, 2, 1, 2, 1})
+
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			kernel.ApplyAvg(img, 1)
+		}
+	})
+
+	b.Run("encoded integer", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			kernel.ApplyAvgEncoded(img, 1)
+		}
+	})
+}
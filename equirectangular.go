@@ -0,0 +1,128 @@
+package convolver
+
+import (
+	"github.com/mandykoh/go-parallel"
+	"image"
+	"math"
+	"time"
+)
+
+// ApplyAvgEquirectangular applies the kernel to an equirectangular (360° by
+// 180°) panorama, as produced by most 360° cameras, so that the kernel covers
+// the same area of the sphere everywhere. Rows are taken to span latitudes
+// from 90° at the top to -90° at the bottom, and columns a full turn of
+// longitude, so the kernel wraps around between the left and right edges
+// without leaving a seam. Rows closer to the poles cover less of the sphere,
+// so the kernel is stretched horizontally by the inverse cosine of each row's
+// latitude, up to the width of the panorama. Taps beyond the top or bottom
+// edge continue over the pole, on the opposite side of the sphere.
+//
+// Stretched taps fall between pixels and are interpolated linearly, so near
+// the poles, where a row's few taps span many pixels, the kernel should be
+// large enough not to alias. The kernel's edge mode is ignored.
+func (k *Kernel) ApplyAvgEquirectangular(img image.Image, parallelism int) *image.NRGBA {
+	defer observeImage(currentMetrics(), time.Now())
+
+	parallelism = k.powerMode.workers(parallelism)
+	input := FloatImageFromImage(img, parallelism)
+	bounds := input.Rect
+	result := image.NewNRGBA(bounds)
+
+	if bounds.Empty() {
+		return result
+	}
+
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// Stretching further than half the width would wrap taps past each other.
+	maxStretch := math.Inf(1)
+	if k.radius > 0 {
+		maxStretch = math.Max(1, float64(width)/float64(2*k.radius))
+	}
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := workerNum; i < height; i += workerCount {
+			latitude := math.Pi * (0.5 - (float64(i)+0.5)/float64(height))
+			stretch := math.Min(1/math.Cos(latitude), maxStretch)
+
+			for j := 0; j < width; j++ {
+				totalWeight := kernelWeight{}
+				sum := kernelWeight{}
+
+				for s := 0; s < k.sideLength; s++ {
+					for t := 0; t < k.sideLength; t++ {
+						weight := k.weights[s*k.sideLength+t]
+						if weight == (kernelWeight{}) {
+							continue
+						}
+
+						c := sampleEquirectangular(input, float64(j)+float64(t-k.radius)*stretch, i+s-k.radius)
+
+						totalWeight.R += weight.R
+						totalWeight.G += weight.G
+						totalWeight.B += weight.B
+						totalWeight.A += weight.A
+
+						sum.R += c.R * weight.R
+						sum.G += c.G * weight.G
+						sum.B += c.B * weight.B
+						sum.A += c.A * weight.A
+					}
+				}
+
+				if totalWeight.R > 0 {
+					sum.R /= totalWeight.R
+				}
+				if totalWeight.G > 0 {
+					sum.G /= totalWeight.G
+				}
+				if totalWeight.B > 0 {
+					sum.B /= totalWeight.B
+				}
+				if totalWeight.A > 0 {
+					sum.A /= totalWeight.A
+				}
+
+				x, y := bounds.Min.X+j, bounds.Min.Y+i
+				result.SetNRGBA(x, y, k.averageToNRGBA(x, y, sum))
+			}
+		}
+	})
+
+	return result
+}
+
+// sampleEquirectangular returns the linear colour and alpha of a panorama at
+// column x, interpolated linearly, and row y, both relative to its top-left.
+// Columns wrap around, and rows beyond the top or bottom continue over the
+// pole, half a turn around.
+func sampleEquirectangular(img *FloatImage, x float64, y int) kernelWeight {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+
+	if y < 0 {
+		y = -1 - y
+		x += float64(width) / 2
+	} else if y >= height {
+		y = 2*height - 1 - y
+		x += float64(width) / 2
+	}
+	y = clampInt(y, 0, height-1)
+
+	floorX := math.Floor(x)
+	fx := float32(x - floorX)
+	x0 := int(floorX) % width
+	if x0 < 0 {
+		x0 += width
+	}
+	x1 := (x0 + 1) % width
+
+	row := img.Pix[y*img.Stride:]
+	p0, p1 := row[x0*4:x0*4+4], row[x1*4:x1*4+4]
+
+	return kernelWeight{
+		R: p0[0] + (p1[0]-p0[0])*fx,
+		G: p0[1] + (p1[1]-p0[1])*fx,
+		B: p0[2] + (p1[2]-p0[2])*fx,
+		A: p0[3] + (p1[3]-p0[3])*fx,
+	}
+}
@@ -0,0 +1,113 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestApplyAvgEquirectangular(t *testing.T) {
+	box := func(radius int) Kernel {
+		kernel := KernelWithRadius(radius)
+		weights := make([]float32, kernel.sideLength*kernel.sideLength)
+		for i := range weights {
+			weights[i] = 1
+		}
+		kernel.SetWeightsUniform(weights)
+		return kernel
+	}
+
+	t.Run("wraps around without a seam", func(t *testing.T) {
+		img := randomImage(32, 16)
+		rolled := image.NewNRGBA(img.Rect)
+		for i := 0; i < 16; i++ {
+			for j := 0; j < 32; j++ {
+				rolled.SetNRGBA((j+5)%32, i, img.NRGBAAt(j, i))
+			}
+		}
+
+		kernel := box(2)
+		result := kernel.ApplyAvgEquirectangular(img, 2)
+		rolledResult := kernel.ApplyAvgEquirectangular(rolled, 2)
+
+		for i := 0; i < 16; i++ {
+			for j := 0; j < 32; j++ {
+				if expected, actual := result.NRGBAAt(j, i), rolledResult.NRGBAAt((j+5)%32, i); expected != actual {
+					t.Fatalf("Expected rolled result at %d,%d to be %v but was %v", (j+5)%32, i, expected, actual)
+				}
+			}
+		}
+	})
+
+	t.Run("keeps flat images flat", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 24, 12))
+		for i := 0; i < 12; i++ {
+			for j := 0; j < 24; j++ {
+				img.SetNRGBA(j, i, color.NRGBA{R: 200, G: 100, B: 50, A: 255})
+			}
+		}
+
+		kernel := box(3)
+		result := kernel.ApplyAvgEquirectangular(img, 2)
+
+		for i := range img.Pix {
+			if d := absDiff(img.Pix[i], result.Pix[i]); d > 1 {
+				t.Fatalf("Expected byte %d to be %d but was %d", i, img.Pix[i], result.Pix[i])
+			}
+		}
+	})
+
+	t.Run("stretches the kernel towards the poles", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 64, 32))
+		for i := 0; i < 32; i++ {
+			for j := 0; j < 64; j++ {
+				v := uint8(0)
+				if j%8 < 4 {
+					v = 255
+				}
+				img.SetNRGBA(j, i, color.NRGBA{R: v, G: v, B: v, A: 255})
+			}
+		}
+
+		kernel := box(1)
+		result := kernel.ApplyAvgEquirectangular(img, 2)
+
+		contrast := func(y int) int {
+			min, max := 255, 0
+			for j := 0; j < 64; j++ {
+				v := int(result.NRGBAAt(j, y).G)
+				if v < min {
+					min = v
+				}
+				if v > max {
+					max = v
+				}
+			}
+			return max - min
+		}
+
+		if equator, pole := contrast(16), contrast(0); pole >= equator/4 {
+			t.Errorf("Expected contrast at the pole to be much less than %d at the equator but was %d", equator, pole)
+		}
+	})
+
+	t.Run("continues over the poles", func(t *testing.T) {
+		img := FloatImageFromImage(randomImage(16, 8), 1)
+
+		for j := 0; j < 16; j++ {
+			r, g, b, a := img.RGBAAt((j+8)%16, 0)
+			expected := kernelWeight{R: r, G: g, B: b, A: a}
+
+			if actual := sampleEquirectangular(img, float64(j), -1); expected != actual {
+				t.Errorf("Expected sample over the north pole at %d to be %v but was %v", j, expected, actual)
+			}
+
+			r, g, b, a = img.RGBAAt((j+8)%16, 7)
+			expected = kernelWeight{R: r, G: g, B: b, A: a}
+
+			if actual := sampleEquirectangular(img, float64(j), 8); expected != actual {
+				t.Errorf("Expected sample over the south pole at %d to be %v but was %v", j, expected, actual)
+			}
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package convolver
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// estimatedTapTime is the rough time taken for each unit of a kernel's tap
+// cost at each pixel by a single worker, as measured for Avg on a typical
+// desktop CPU.
+const estimatedTapTime = 5 * time.Nanosecond
+
+// PlanFunc describes how a stage will process an image with the given bounds.
+type PlanFunc func(bounds image.Rectangle, parallelism int) StagePlan
+
+// PlannedStage is a stage along with a description of how it executes, for
+// use with NewPlannedPipeline.
+type PlannedStage struct {
+	Stage Stage
+	Plan  PlanFunc
+}
+
+// StagePlan describes how a stage of a pipeline will be executed.
+type StagePlan struct {
+	// Name identifies the stage, such as by its operation.
+	Name string
+
+	// Backend is how the stage is computed: "direct" for visiting every tap,
+	// "folded" for adding mirrored taps of symmetric kernels together,
+	// "fused" for a repeated kernel convolved with itself into one, or
+	// "unknown" for stages which don't describe themselves.
+	Backend string
+
+	// Passes is the number of times the stage traverses the image.
+	Passes int
+
+	// Taps is the number of non-zero kernel taps read for each pixel in each
+	// pass.
+	Taps int
+
+	// BufferBytes is the size of the buffers allocated by each pass,
+	// including its result but not any conversion of the stage's input.
+	BufferBytes int
+
+	// EstimatedTime is a rough estimate of the time the stage will take,
+	// based on its tap cost. It is only meant for comparing plans.
+	EstimatedTime time.Duration
+}
+
+// PipelinePlan describes how each stage of a pipeline will be executed.
+type PipelinePlan []StagePlan
+
+// EstimatedTime returns the total estimated time of all stages.
+func (p PipelinePlan) EstimatedTime() time.Duration {
+	total := time.Duration(0)
+	for _, s := range p {
+		total += s.EstimatedTime
+	}
+	return total
+}
+
+func (p PipelinePlan) String() string {
+	b := &strings.Builder{}
+	w := tabwriter.NewWriter(b, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "STAGE\tNAME\tBACKEND\tPASSES\tTAPS\tBUFFERS\tESTIMATE")
+	for i, s := range p {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%d\t%v\n", i+1, s.Name, s.Backend, s.Passes, s.Taps, s.BufferBytes, s.EstimatedTime)
+	}
+	fmt.Fprintf(w, "total\t\t\t\t\t\t%v\n", p.EstimatedTime())
+
+	w.Flush()
+	return b.String()
+}
+
+// Explain returns how each stage of the pipeline would be executed for an
+// image with the given bounds, without running it. Stages added without a
+// plan, such as by NewPipeline, are reported with an unknown backend.
+func (p *Pipeline) Explain(bounds image.Rectangle, parallelism int) PipelinePlan {
+	plan := make(PipelinePlan, len(p.stages))
+
+	for i := range p.stages {
+		if p.plans[i] == nil {
+			plan[i] = StagePlan{Name: "stage", Backend: "unknown", Passes: 1}
+			continue
+		}
+		plan[i] = p.plans[i](bounds, parallelism)
+	}
+
+	return plan
+}
+
+// PlannedAvg returns ApplyAvg as a stage which describes its execution.
+func (k *Kernel) PlannedAvg() PlannedStage {
+	return PlannedStage{
+		Stage: k.ApplyAvg,
+		Plan: func(bounds image.Rectangle, parallelism int) StagePlan {
+			return k.avgPlan("avg", 1, bounds, parallelism)
+		},
+	}
+}
+
+// PlannedRepeatAvg returns RepeatAvg(passes) as a stage which describes its
+// execution, including whether the kernel is fused.
+func (k *Kernel) PlannedRepeatAvg(passes int) PlannedStage {
+	return PlannedStage{
+		Stage: k.RepeatAvg(passes),
+		Plan: func(bounds image.Rectangle, parallelism int) StagePlan {
+			name := fmt.Sprintf("avg ×%d", passes)
+
+			if fused := k.fusedRepeat(passes); fused != nil {
+				plan := fused.avgPlan(name, 1, bounds, parallelism)
+				plan.Backend = "fused"
+				return plan
+			}
+			return k.avgPlan(name, passes, bounds, parallelism)
+		},
+	}
+}
+
+// avgPlan describes applying Avg with the kernel the given number of times.
+func (k *Kernel) avgPlan(name string, passes int, bounds image.Rectangle, parallelism int) StagePlan {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	parallelism = k.powerMode.workers(parallelism)
+
+	taps := 0
+	for _, w := range k.weights {
+		if w != (kernelWeight{}) {
+			taps++
+		}
+	}
+
+	backend := "direct"
+	if accepting := k.acceptingBackend(bounds); accepting != "" {
+		backend = accepting
+	} else if k.pointSymmetric() {
+		backend = "folded"
+	}
+
+	pixels := bounds.Dx() * bounds.Dy()
+
+	buffers := bufferLength(bounds, 4)
+	if k.edgeMode != EdgeClip {
+		buffers += bufferLength(bounds.Inset(-k.radius), 4)
+	}
+
+	cost := passes * pixels * (k.tapCost() + repeatPassCost)
+
+	return StagePlan{
+		Name:          name,
+		Backend:       backend,
+		Passes:        passes,
+		Taps:          taps,
+		BufferBytes:   buffers,
+		EstimatedTime: time.Duration(cost) * estimatedTapTime / time.Duration(parallelism),
+	}
+}
@@ -0,0 +1,104 @@
+package convolver
+
+import (
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestPipelineExplain(t *testing.T) {
+	bounds := image.Rect(0, 0, 100, 50)
+
+	plus := KernelWithRadius(1)
+	plus.SetWeightsUniform([]float32{
+		0, 1, 0,
+		1, 1, 1,
+		0, 1, 0,
+	})
+
+	sobel := KernelWithRadius(1)
+	sobel.SetWeightsUniform([]float32{
+		1, 0, -1,
+		2, 0, -2,
+		1, 0, -1,
+	})
+
+	pipeline := NewPlannedPipeline(
+		plus.PlannedRepeatAvg(2),
+		sobel.PlannedAvg(),
+		PlannedStage{Stage: Gamma(2.2)},
+	)
+
+	plan := pipeline.Explain(bounds, 2)
+
+	t.Run("describes each stage", func(t *testing.T) {
+		expected := []StagePlan{
+			{Name: "avg ×2", Backend: "fused", Passes: 1, Taps: 13, BufferBytes: 20000},
+			{Name: "avg", Backend: "direct", Passes: 1, Taps: 6, BufferBytes: 20000},
+			{Name: "stage", Backend: "unknown", Passes: 1},
+		}
+
+		if len(plan) != len(expected) {
+			t.Fatalf("Expected %d stages but was %d", len(expected), len(plan))
+		}
+
+		for i, e := range expected {
+			actual := plan[i]
+			actual.EstimatedTime = 0
+
+			if e != actual {
+				t.Errorf("Expected stage %d to be %+v but was %+v", i+1, e, actual)
+			}
+		}
+	})
+
+	t.Run("estimates time from the tap cost", func(t *testing.T) {
+		if expected, actual := 5000*(7+repeatPassCost)*estimatedTapTime/2, plan[0].EstimatedTime; expected != actual {
+			t.Errorf("Expected estimated time to be %v but was %v", expected, actual)
+		}
+		if expected, actual := plan[0].EstimatedTime+plan[1].EstimatedTime, plan.EstimatedTime(); expected != actual {
+			t.Errorf("Expected total estimated time to be %v but was %v", expected, actual)
+		}
+	})
+
+	t.Run("reports repeated application when it is cheaper", func(t *testing.T) {
+		dense := KernelWithRadius(2)
+		dense.SetWeightsUniform([]float32{
+			1, 4, 6, 4, 1,
+			4, 16, 24, 16, 4,
+			6, 24, 36, 24, 6,
+			4, 16, 24, 16, 4,
+			1, 4, 6, 4, 1,
+		})
+
+		plan := NewPlannedPipeline(dense.PlannedRepeatAvg(3)).Explain(bounds, 1)
+
+		if expected, actual := "folded", plan[0].Backend; expected != actual {
+			t.Errorf("Expected backend to be %q but was %q", expected, actual)
+		}
+		if expected, actual := 3, plan[0].Passes; expected != actual {
+			t.Errorf("Expected %d passes but was %d", expected, actual)
+		}
+	})
+
+	t.Run("formats a table", func(t *testing.T) {
+		s := plan.String()
+
+		if !strings.Contains(s, "avg ×2") || !strings.Contains(s, "fused") || !strings.Contains(s, "total") {
+			t.Errorf("Expected table to describe the stages but was:\n%s", s)
+		}
+	})
+
+	t.Run("runs the planned stages", func(t *testing.T) {
+		img := randomImage(20, 10)
+
+		expected := NewPipeline(plus.RepeatAvg(2), sobel.ApplyAvg, Gamma(2.2)).Apply(img, 2)
+		actual := pipeline.Apply(img, 2)
+
+		for i := range expected.Pix {
+			if expected.Pix[i] != actual.Pix[i] {
+				t.Fatalf("Expected results to match but differ at byte %d", i)
+			}
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package convolver
+
+import (
+	"context"
+	"github.com/mandykoh/go-parallel"
+	"image"
+	"image/color"
+	"sync"
+	"sync/atomic"
+)
+
+// FallibleOpFunc is an operation like OpFunc which may fail, such as a custom
+// operation which fetches data it depends on from elsewhere.
+type FallibleOpFunc func(img *image.NRGBA, x, y int) (color.NRGBA, error)
+
+// ApplyFallible applies an operation which may fail to an image. If the
+// operation returns an error, the remaining workers are stopped as soon as
+// they reach their next pixel, and the first error encountered is returned
+// without a result. Workers also stop if the context is cancelled, in which
+// case the context's error is returned.
+func (k *Kernel) ApplyFallible(ctx context.Context, img image.Image, op FallibleOpFunc, parallelism int) (*image.NRGBA, error) {
+	parallelism = k.powerMode.workers(parallelism)
+
+	input := k.newTileInput(img, parallelism)
+	result := image.NewNRGBA(img.Bounds())
+	bounds := result.Rect
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var failed int32
+	var firstErr error
+	var errOnce sync.Once
+
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			atomic.StoreInt32(&failed, 1)
+			cancel()
+		})
+	}
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		var buffer []uint8
+
+		for i := bounds.Min.Y + workerNum; i < bounds.Max.Y; i += workerCount {
+			if err := ctx.Err(); err != nil {
+				fail(err)
+				return
+			}
+
+			row := input.around(image.Rect(bounds.Min.X, i, bounds.Max.X, i+1), &buffer)
+
+			for j := bounds.Min.X; j < bounds.Max.X; j++ {
+				if atomic.LoadInt32(&failed) != 0 {
+					return
+				}
+
+				c, err := op(row, j, i)
+				if err != nil {
+					fail(err)
+					return
+				}
+				result.SetNRGBA(j, i, c)
+			}
+		}
+	})
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return result, nil
+}
+
+// Fallible adapts an operation which cannot fail for use with ApplyFallible.
+func Fallible(op OpFunc) FallibleOpFunc {
+	return func(img *image.NRGBA, x, y int) (color.NRGBA, error) {
+		return op(img, x, y), nil
+	}
+}
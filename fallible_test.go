@@ -0,0 +1,85 @@
+package convolver
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"runtime"
+	"sync/atomic"
+	"testing"
+)
+
+func TestApplyFallible(t *testing.T) {
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{1, 2, 1, 2, 4, 2, 1, 2, 1})
+
+	img := randomImage(32, 32)
+
+	t.Run("produces the same result as ApplyAvg when no errors occur", func(t *testing.T) {
+		result, err := kernel.ApplyFallible(context.Background(), img, Fallible(kernel.Avg), runtime.NumCPU())
+		if err != nil {
+			t.Fatalf("Expected no error but got %v", err)
+		}
+
+		expected := kernel.ApplyAvg(img, runtime.NumCPU())
+		for i := range expected.Pix {
+			if expected.Pix[i] != result.Pix[i] {
+				t.Fatalf("Expected result to match at offset %d", i)
+			}
+		}
+	})
+
+	t.Run("treats parallelism below one as a single worker", func(t *testing.T) {
+		expected := kernel.ApplyAvg(img, runtime.NumCPU())
+
+		for _, parallelism := range []int{0, -2} {
+			result, err := kernel.ApplyFallible(context.Background(), img, Fallible(kernel.Avg), parallelism)
+			if err != nil {
+				t.Fatalf("Expected no error with parallelism %d but got %v", parallelism, err)
+			}
+
+			for i := range expected.Pix {
+				if expected.Pix[i] != result.Pix[i] {
+					t.Fatalf("Expected result with parallelism %d to match at offset %d", parallelism, i)
+				}
+			}
+		}
+	})
+
+	t.Run("returns the first error and stops remaining work", func(t *testing.T) {
+		failure := errors.New("tile unavailable")
+		var calls int64
+
+		op := func(img *image.NRGBA, x, y int) (color.NRGBA, error) {
+			atomic.AddInt64(&calls, 1)
+			if x == 3 && y == 2 {
+				return color.NRGBA{}, failure
+			}
+			return img.NRGBAAt(x, y), nil
+		}
+
+		result, err := kernel.ApplyFallible(context.Background(), img, op, 2)
+
+		if err != failure {
+			t.Errorf("Expected error %v but got %v", failure, err)
+		}
+		if result != nil {
+			t.Errorf("Expected no result")
+		}
+		if total := int64(32 * 32); atomic.LoadInt64(&calls) >= total {
+			t.Errorf("Expected work to stop early but op was called %d times", calls)
+		}
+	})
+
+	t.Run("stops when the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := kernel.ApplyFallible(ctx, img, Fallible(kernel.Avg), runtime.NumCPU())
+
+		if err != context.Canceled {
+			t.Errorf("Expected cancellation error but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package convolver
+
+import (
+	"github.com/mandykoh/prism/srgb"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+func TestFastGaussian(t *testing.T) {
+
+	t.Run("approximates a true Gaussian blur", func(t *testing.T) {
+		const sigma = 2.0
+
+		// A locally seeded source keeps the input, and so the error of the
+		// approximation, the same from run to run.
+		rng := rand.New(rand.NewSource(1421))
+		img := image.NewNRGBA(image.Rect(0, 0, 48, 48))
+		for i := 0; i < len(img.Pix); i += 4 {
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255
+		}
+
+		gaussian := gaussianKernelFromFunc(sigma, func(_, _, g float64) float64 { return g })
+		expectedImg := gaussian.ApplyAvg(img, runtime.NumCPU())
+
+		result := FastGaussian(img, sigma, 4, runtime.NumCPU())
+
+		inner := img.Rect.Inset(gaussian.radius * 2)
+		for i := inner.Min.Y; i < inner.Max.Y; i++ {
+			for j := inner.Min.X; j < inner.Max.X; j++ {
+				expected, actual := expectedImg.NRGBAAt(j, i), result.NRGBAAt(j, i)
+
+				if absDiff(expected.R, actual.R) > 3 || absDiff(expected.G, actual.G) > 3 || absDiff(expected.B, actual.B) > 3 {
+					t.Fatalf("Expected pixel at %d,%d to be close to %+v but was %+v", j, i, expected, actual)
+				}
+			}
+		}
+	})
+
+	t.Run("leaves a flat image unchanged", func(t *testing.T) {
+		colour := color.NRGBA{R: 10, G: 100, B: 200, A: 128}
+
+		img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+		for i := img.Rect.Min.Y; i < img.Rect.Max.Y; i++ {
+			for j := img.Rect.Min.X; j < img.Rect.Max.X; j++ {
+				img.SetNRGBA(j, i, colour)
+			}
+		}
+
+		result := FastGaussian(img, 3, 3, runtime.NumCPU())
+
+		c, a := srgb.ColorFromNRGBA(colour)
+		expectedColour := c.ToNRGBA(a)
+
+		for i := img.Rect.Min.Y; i < img.Rect.Max.Y; i++ {
+			for j := img.Rect.Min.X; j < img.Rect.Max.X; j++ {
+				if expected, actual := expectedColour, result.NRGBAAt(j, i); expected != actual {
+					t.Fatalf("Expected pixel at %d,%d to be %+v but was %+v", j, i, expected, actual)
+				}
+			}
+		}
+	})
+}
+
+func TestGaussianBoxRadii(t *testing.T) {
+	for _, sigma := range []float64{0.8, 1, 2.5, 5, 12} {
+		for passes := 1; passes <= 5; passes++ {
+			variance := 0.0
+			for _, r := range gaussianBoxRadii(sigma, passes) {
+				width := float64(r*2 + 1)
+				variance += (width*width - 1) / 12
+			}
+
+			if expected, actual := sigma, math.Sqrt(variance); math.Abs(expected-actual) > 0.5 {
+				t.Errorf("Expected %d passes to give standard deviation close to %v but was %v", passes, expected, actual)
+			}
+		}
+	}
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
@@ -0,0 +1,164 @@
+package convolver
+
+import (
+	"context"
+	"github.com/mandykoh/go-parallel"
+	"github.com/mandykoh/prism"
+	"github.com/mandykoh/prism/srgb"
+	"image"
+	"image/color"
+)
+
+// FloatImage is an image of linear, non-premultiplied float32 RGBA values.
+// Unlike 8-bit images, values are unbounded, so high dynamic range results
+// can be carried between stages without clamping. Values are stored
+// interleaved in R, G, B, A order, row by row in Pix, in the same layout as
+// image.NRGBA.
+//
+// When used as an image.Image, colours are sRGB encoded and clipped to
+// 0.0–1.0.
+type FloatImage struct {
+	Pix    []float32
+	Stride int
+	Rect   image.Rectangle
+}
+
+func (f *FloatImage) At(x, y int) color.Color {
+	r, g, b, a := f.RGBAAt(x, y)
+	return srgb.ColorFromLinear(r, g, b).ToNRGBA(a)
+}
+
+func (f *FloatImage) Bounds() image.Rectangle {
+	return f.Rect
+}
+
+func (f *FloatImage) ColorModel() color.Model {
+	return color.NRGBAModel
+}
+
+func (f *FloatImage) RGBAAt(x, y int) (r, g, b, a float32) {
+	if !(image.Point{X: x, Y: y}.In(f.Rect)) {
+		return 0, 0, 0, 0
+	}
+	i := f.offset(x, y)
+	return f.Pix[i], f.Pix[i+1], f.Pix[i+2], f.Pix[i+3]
+}
+
+func (f *FloatImage) SetRGBA(x, y int, r, g, b, a float32) {
+	if !(image.Point{X: x, Y: y}.In(f.Rect)) {
+		return
+	}
+	i := f.offset(x, y)
+	f.Pix[i], f.Pix[i+1], f.Pix[i+2], f.Pix[i+3] = r, g, b, a
+}
+
+func (f *FloatImage) offset(x, y int) int {
+	return (y-f.Rect.Min.Y)*f.Stride + (x-f.Rect.Min.X)*4
+}
+
+// paddedFloatTileSize is the tile size with which ApplyAvgFloat reads an image
+// padded according to an edge mode.
+const paddedFloatTileSize = 64
+
+// ApplyAvgFloat applies the kernel to a FloatImage in the same way as
+// ApplyAvg, but without encoding or clamping the results. Edge modes other
+// than EdgeClip are applied a tile at a time, as by ApplyAvgSource, so that
+// the image is never copied as a whole to pad it.
+func (k *Kernel) ApplyAvgFloat(img *FloatImage, parallelism int) *FloatImage {
+	if k.edgeMode != EdgeClip && k.radius > 0 && !img.Rect.Empty() {
+		// Reading regions of a FloatImage can't fail, nor can the
+		// background context be cancelled.
+		result, _ := k.ApplyAvgSource(context.Background(), img, paddedFloatTileSize, parallelism)
+		return result
+	}
+
+	result := NewFloatImage(img.Rect)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := img.Rect.Min.Y + workerNum; i < img.Rect.Max.Y; i += workerCount {
+			for j := img.Rect.Min.X; j < img.Rect.Max.X; j++ {
+				r, g, b, a := k.avgFloat(img, img.Rect, j, i)
+				result.SetRGBA(j, i, r, g, b, a)
+			}
+		}
+	})
+
+	return result
+}
+
+// avgFloat returns the weighted average of linear values around x, y, clipping
+// the kernel to bounds. img must hold every pixel within bounds which the
+// kernel covers, but may be smaller than bounds.
+func (k *Kernel) avgFloat(img *FloatImage, bounds image.Rectangle, x, y int) (r, g, b, a float32) {
+	clip := k.clipToBounds(bounds, x, y)
+
+	var totalWeight, sum kernelWeight
+
+	if clip == (kernelClip{}) && k.pointSymmetric() {
+		sum, totalWeight = k.foldedSumFloat(img, x, y)
+	} else {
+		for s := clip.Top; s < k.sideLength-clip.Bottom; s++ {
+			for t := clip.Left; t < k.sideLength-clip.Right; t++ {
+				weight := k.weights[s*k.sideLength+t]
+				totalWeight.R += weight.R
+				totalWeight.G += weight.G
+				totalWeight.B += weight.B
+				totalWeight.A += weight.A
+
+				r, g, b, a := img.RGBAAt(x+t-k.radius, y+s-k.radius)
+				sum.R += r * weight.R
+				sum.G += g * weight.G
+				sum.B += b * weight.B
+				sum.A += a * weight.A
+			}
+		}
+	}
+
+	if totalWeight.R > 0 {
+		sum.R /= totalWeight.R
+	}
+	if totalWeight.G > 0 {
+		sum.G /= totalWeight.G
+	}
+	if totalWeight.B > 0 {
+		sum.B /= totalWeight.B
+	}
+	if totalWeight.A > 0 {
+		sum.A /= totalWeight.A
+	}
+
+	if k.diagnostics != nil {
+		k.diagnostics.check(x, y, sum)
+	}
+
+	return sum.R, sum.G, sum.B, sum.A
+}
+
+// FloatImageFromImage converts an sRGB encoded image to a FloatImage of linear
+// values.
+func FloatImageFromImage(img image.Image, parallelism int) *FloatImage {
+	if f, ok := img.(*FloatImage); ok {
+		return f
+	}
+
+	nrgba := prism.ConvertImageToNRGBA(img, parallelism)
+	result := NewFloatImage(nrgba.Rect)
+	linearise := vector().linearise
+	width := nrgba.Rect.Dx() * 4
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := workerNum; i < nrgba.Rect.Dy(); i += workerCount {
+			linearise(result.Pix[i*result.Stride:][:width], nrgba.Pix[nrgba.PixOffset(nrgba.Rect.Min.X, nrgba.Rect.Min.Y+i):][:width])
+		}
+	})
+
+	return result
+}
+
+func NewFloatImage(r image.Rectangle) *FloatImage {
+	return &FloatImage{
+		Pix:    make([]float32, bufferLength(r, 4)),
+		Stride: r.Dx() * 4,
+		Rect:   r,
+	}
+}
@@ -0,0 +1,99 @@
+package convolver
+
+import (
+	"github.com/mandykoh/prism/srgb"
+	"image"
+	"image/color"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func TestFloatImage(t *testing.T) {
+
+	t.Run("stores values outside 0-1 without clamping", func(t *testing.T) {
+		img := NewFloatImage(image.Rect(2, 3, 6, 7))
+		img.SetRGBA(4, 5, 12.5, -1, 0.25, 0.5)
+
+		if r, g, b, a := img.RGBAAt(4, 5); r != 12.5 || g != -1 || b != 0.25 || a != 0.5 {
+			t.Errorf("Expected stored values to be returned but got %v, %v, %v, %v", r, g, b, a)
+		}
+		if expected, actual := (color.NRGBA{R: 255, G: 0, B: 137, A: 128}), img.At(4, 5); expected != actual {
+			t.Errorf("Expected encoded colour to be %+v but was %+v", expected, actual)
+		}
+		if r, g, b, a := img.RGBAAt(0, 0); r != 0 || g != 0 || b != 0 || a != 0 {
+			t.Errorf("Expected out of bounds values to be zero")
+		}
+	})
+
+	t.Run("converts from encoded images", func(t *testing.T) {
+		src := randomImage(8, 8)
+		img := FloatImageFromImage(src, runtime.NumCPU())
+
+		for i := src.Rect.Min.Y; i < src.Rect.Max.Y; i++ {
+			for j := src.Rect.Min.X; j < src.Rect.Max.X; j++ {
+				c, a := srgb.ColorFromNRGBA(src.NRGBAAt(j, i))
+				if expected, actual := c.ToNRGBA(a), img.At(j, i); expected != actual {
+					t.Fatalf("Expected pixel %d,%d to round trip as %+v but was %+v", j, i, expected, actual)
+				}
+			}
+		}
+	})
+}
+
+func TestKernelApplyAvgFloat(t *testing.T) {
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{
+		1, 2, 1,
+		2, 4, 2,
+		1, 2, 1,
+	})
+
+	t.Run("matches ApplyAvg for in-range values", func(t *testing.T) {
+		src := randomImage(16, 16)
+		expectedImg := kernel.ApplyAvg(src, runtime.NumCPU())
+
+		result := kernel.ApplyAvgFloat(FloatImageFromImage(src, runtime.NumCPU()), runtime.NumCPU())
+
+		for i := src.Rect.Min.Y; i < src.Rect.Max.Y; i++ {
+			for j := src.Rect.Min.X; j < src.Rect.Max.X; j++ {
+				if expected, actual := expectedImg.NRGBAAt(j, i), color.NRGBAModel.Convert(result.At(j, i)); expected != actual {
+					t.Fatalf("Expected pixel %d,%d to be %+v but was %+v", j, i, expected, actual)
+				}
+			}
+		}
+	})
+
+	t.Run("preserves values above 1", func(t *testing.T) {
+		img := NewFloatImage(image.Rect(0, 0, 3, 3))
+		img.SetRGBA(1, 1, 16, 16, 16, 1)
+
+		result := kernel.ApplyAvgFloat(img, runtime.NumCPU())
+
+		if r, _, _, _ := result.RGBAAt(1, 1); math.Abs(float64(r)-4) > 1e-6 {
+			t.Errorf("Expected centre value to be 4 but was %v", r)
+		}
+		if r, _, _, _ := result.RGBAAt(0, 0); math.Abs(float64(r)-16.0/9) > 1e-6 {
+			t.Errorf("Expected corner value to be %v but was %v", 16.0/9, r)
+		}
+	})
+}
+
+func BenchmarkFloatImageConversion(b *testing.B) {
+	img := randomImage(1024, 1024)
+
+	b.Run("linearise", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			FloatImageFromImage(img, 1)
+		}
+	})
+
+	b.Run("quantise", func(b *testing.B) {
+		f := FloatImageFromImage(img, 1)
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			nrgbaFromFloatImage(f, 1)
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func stripesImage(width, height, period int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < height; i++ {
+		for j := 0; j < width; j++ {
+			v := uint8(64)
+			if (i/(period/2))%2 == 0 {
+				v = 192
+			}
+			img.SetNRGBA(j, i, color.NRGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+	return img
+}
+
+func TestFlowField(t *testing.T) {
+
+	t.Run("runs along horizontal stripes with high coherence", func(t *testing.T) {
+		img := stripesImage(32, 32, 8)
+
+		angle, coherence := FlowField(img, 1, 3, runtime.NumCPU())
+
+		for i := 10; i < 22; i++ {
+			for j := 10; j < 22; j++ {
+				a := float64(angle.Pix[angle.offset(j, i)])
+				if math.Abs(a) > 0.01 {
+					t.Fatalf("Expected angle at %d,%d to be 0 but was %v", j, i, a)
+				}
+				if c := coherence.Pix[coherence.offset(j, i)]; c < 0.99 {
+					t.Fatalf("Expected coherence at %d,%d to be nearly 1 but was %v", j, i, c)
+				}
+			}
+		}
+	})
+
+	t.Run("runs along vertical stripes", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+		stripes := stripesImage(32, 32, 8)
+		for i := 0; i < 32; i++ {
+			for j := 0; j < 32; j++ {
+				img.SetNRGBA(j, i, stripes.NRGBAAt(i, j))
+			}
+		}
+
+		angle, _ := FlowField(img, 1, 3, runtime.NumCPU())
+
+		if a := float64(angle.Pix[angle.offset(16, 16)]); math.Abs(math.Abs(a)-math.Pi/2) > 0.01 {
+			t.Errorf("Expected angle to be ±π/2 but was %v", a)
+		}
+	})
+
+	t.Run("has zero coherence in flat areas", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+		for i := range img.Pix {
+			img.Pix[i] = 128
+		}
+
+		_, coherence := FlowField(img, 1, 2, runtime.NumCPU())
+
+		for i, c := range coherence.Pix {
+			if c != 0 {
+				t.Fatalf("Expected coherence at %d to be 0 but was %v", i, c)
+			}
+		}
+	})
+}
+
+func TestFlowSmooth(t *testing.T) {
+	clean := stripesImage(48, 48, 12)
+	noisy := GaussianNoise(0.05, 3)(clean, runtime.NumCPU())
+
+	// Measures the error against the clean image over the interior, away from
+	// stripe boundaries where any blur leaves some residue.
+	errorFromClean := func(img *image.NRGBA) float64 {
+		total, n := 0.0, 0
+		for i := 8; i < 40; i++ {
+			if i%6 == 0 || i%6 == 5 {
+				continue
+			}
+			for j := 8; j < 40; j++ {
+				d := float64(img.NRGBAAt(j, i).G) - float64(clean.NRGBAAt(j, i).G)
+				total += d * d
+				n++
+			}
+		}
+		return math.Sqrt(total / float64(n))
+	}
+
+	t.Run("removes noise along stripes better than an isotropic blur", func(t *testing.T) {
+		smoothed := FlowSmooth(3, 1, 3)(noisy, runtime.NumCPU())
+
+		gaussian := GaussianKernel(3)
+		blurred := gaussian.ApplyAvg(noisy, runtime.NumCPU())
+
+		noisyError := errorFromClean(noisy)
+		smoothedError := errorFromClean(smoothed)
+		blurredError := errorFromClean(blurred)
+
+		if smoothedError > noisyError/2 {
+			t.Errorf("Expected error to be at most half of %v but was %v", noisyError, smoothedError)
+		}
+		if smoothedError >= blurredError {
+			t.Errorf("Expected error to be less than the isotropic blur's %v but was %v", blurredError, smoothedError)
+		}
+	})
+
+	t.Run("leaves alpha unchanged", func(t *testing.T) {
+		img := randomImage(16, 16)
+
+		result := FlowSmooth(2, 1, 2)(img, runtime.NumCPU())
+
+		for i := 0; i < 16; i++ {
+			for j := 0; j < 16; j++ {
+				if expected, actual := img.NRGBAAt(j, i).A, result.NRGBAAt(j, i).A; expected != actual {
+					t.Fatalf("Expected alpha at %d,%d to be %d but was %d", j, i, expected, actual)
+				}
+			}
+		}
+	})
+
+	t.Run("is the same regardless of parallelism", func(t *testing.T) {
+		a := FlowSmooth(2, 1, 2)(noisy, 1)
+		b := FlowSmooth(2, 1, 2)(noisy, runtime.NumCPU())
+
+		for i := range a.Pix {
+			if a.Pix[i] != b.Pix[i] {
+				t.Fatalf("Expected results to match but differed at byte %d", i)
+			}
+		}
+	})
+
+	t.Run("panics with non-positive sigma", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic but none occurred")
+			}
+		}()
+
+		FlowSmooth(0, 1, 2)
+	})
+}
@@ -0,0 +1,55 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+)
+
+func TestFrangi(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	for i := img.Rect.Min.Y; i < img.Rect.Max.Y; i++ {
+		for j := img.Rect.Min.X; j < img.Rect.Max.X; j++ {
+			img.SetNRGBA(j, i, color.NRGBA{A: 255})
+		}
+	}
+	for j := img.Rect.Min.X; j < img.Rect.Max.X; j++ {
+		img.SetNRGBA(j, 16, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+	}
+
+	sigmas := []float64{1, 2}
+
+	t.Run("responds strongly along bright ridges", func(t *testing.T) {
+		result := Frangi(img, sigmas, 0.5, 0, true, runtime.NumCPU())
+
+		onRidge := result.ValueAt(16, 16)
+		offRidge := result.ValueAt(16, 4)
+
+		if onRidge < 0.5 {
+			t.Errorf("Expected response on ridge to be at least 0.5 but was %v", onRidge)
+		}
+		if offRidge > 0.01 {
+			t.Errorf("Expected response away from ridge to be near zero but was %v", offRidge)
+		}
+	})
+
+	t.Run("ignores ridges of the opposite polarity", func(t *testing.T) {
+		result := Frangi(img, sigmas, 0.5, 0, false, runtime.NumCPU())
+
+		if actual := result.ValueAt(16, 16); actual != 0 {
+			t.Errorf("Expected no response on bright ridge but was %v", actual)
+		}
+	})
+
+	t.Run("produces no response for a flat image", func(t *testing.T) {
+		flat := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+		result := Frangi(flat, sigmas, 0.5, 0, true, runtime.NumCPU())
+
+		for i, v := range result.Pix {
+			if v != 0 {
+				t.Fatalf("Expected no response but found %v at index %d", v, i)
+			}
+		}
+	})
+}
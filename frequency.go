@@ -0,0 +1,93 @@
+package convolver
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// FrequencyResponse returns the magnitude of the kernel's 2D frequency
+// response (its modulation transfer function) sampled on a grid of the given
+// size. The plane's x and y coordinates map linearly onto horizontal and
+// vertical frequencies from -0.5 to 0.5 cycles per pixel, so that zero
+// frequency is at the centre when samples is odd.
+//
+// Responses are normalised by the kernel's total weight, as Avg normalises its
+// results, so a blur kernel has a response of 1 at zero frequency falling
+// towards 0 at higher frequencies. Kernels whose weights sum to zero or less
+// are not normalised. Only the red channel weights are used.
+func (k *Kernel) FrequencyResponse(samples int) *Plane {
+	if samples < 2 {
+		panic(fmt.Sprintf("at least 2 samples are required but %d requested", samples))
+	}
+
+	result := NewPlane(image.Rect(0, 0, samples, samples))
+
+	for i := 0; i < samples; i++ {
+		v := frequencySample(i, samples)*2 - 0.5
+		for j := 0; j < samples; j++ {
+			u := frequencySample(j, samples)*2 - 0.5
+			result.Pix[result.offset(j, i)] = float32(k.responseAt(u, v))
+		}
+	}
+
+	return result
+}
+
+// RadialFrequencyResponse returns the magnitude of the kernel's frequency
+// response averaged over all directions, at the given number of frequencies
+// spaced evenly from 0 to 0.5 cycles per pixel (the Nyquist frequency). This
+// allows blur kernels to be compared on a single curve. Responses are
+// normalised as for FrequencyResponse.
+func (k *Kernel) RadialFrequencyResponse(samples int) []float64 {
+	if samples < 2 {
+		panic(fmt.Sprintf("at least 2 samples are required but %d requested", samples))
+	}
+
+	const angles = 64
+
+	result := make([]float64, samples)
+
+	for i := range result {
+		f := frequencySample(i, samples)
+
+		sum := 0.0
+		for a := 0; a < angles; a++ {
+			theta := math.Pi * float64(a) / angles
+			sum += k.responseAt(f*math.Cos(theta), f*math.Sin(theta))
+		}
+		result[i] = sum / angles
+	}
+
+	return result
+}
+
+// responseAt returns the normalised magnitude of the kernel's response at the
+// horizontal and vertical frequencies u and v, in cycles per pixel.
+func (k *Kernel) responseAt(u, v float64) float64 {
+	re, im, total := 0.0, 0.0, 0.0
+
+	for s := 0; s < k.sideLength; s++ {
+		for t := 0; t < k.sideLength; t++ {
+			w := float64(k.weights[s*k.sideLength+t].R)
+			phase := -2 * math.Pi * (u*float64(t-k.radius) + v*float64(s-k.radius))
+
+			re += w * math.Cos(phase)
+			im += w * math.Sin(phase)
+			total += w
+		}
+	}
+
+	magnitude := math.Hypot(re, im)
+	if total > 0 {
+		magnitude /= total
+	}
+
+	return magnitude
+}
+
+// frequencySample returns the i-th of n frequencies spaced evenly from 0 to
+// 0.5 cycles per pixel.
+func frequencySample(i, n int) float64 {
+	return float64(i) / float64(n-1) / 2
+}
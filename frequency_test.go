@@ -0,0 +1,67 @@
+package convolver
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFrequencyResponse(t *testing.T) {
+
+	t.Run("is flat for an identity kernel", func(t *testing.T) {
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightUniform(1, 1, 1)
+
+		response := kernel.FrequencyResponse(9)
+
+		for i, v := range response.Pix {
+			if math.Abs(float64(v)-1) > 1e-6 {
+				t.Fatalf("Expected response to be 1 but was %v at index %d", v, i)
+			}
+		}
+	})
+
+	t.Run("falls off at high frequencies for a blur kernel", func(t *testing.T) {
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightsUniform([]float32{
+			1, 2, 1,
+			2, 4, 2,
+			1, 2, 1,
+		})
+
+		response := kernel.FrequencyResponse(9)
+
+		if expected, actual := float32(1), response.ValueAt(4, 4); math.Abs(float64(expected-actual)) > 1e-6 {
+			t.Errorf("Expected response at zero frequency to be %v but was %v", expected, actual)
+		}
+
+		// The [1 2 1] / 4 filter has a response of (1 + cos(2πf)) / 2, which is
+		// zero at the Nyquist frequency
+		if actual := response.ValueAt(0, 4); math.Abs(float64(actual)) > 1e-6 {
+			t.Errorf("Expected response at Nyquist frequency to be 0 but was %v", actual)
+		}
+		if expected, actual := 0.5, float64(response.ValueAt(6, 4)); math.Abs(expected-actual) > 1e-6 {
+			t.Errorf("Expected response at 0.25 cycles per pixel to be %v but was %v", expected, actual)
+		}
+	})
+}
+
+func TestRadialFrequencyResponse(t *testing.T) {
+	narrow := GaussianKernel(1)
+	wide := GaussianKernel(2)
+
+	narrowResponse := narrow.RadialFrequencyResponse(11)
+	wideResponse := wide.RadialFrequencyResponse(11)
+
+	if expected, actual := 1.0, narrowResponse[0]; math.Abs(expected-actual) > 1e-6 {
+		t.Errorf("Expected response at zero frequency to be %v but was %v", expected, actual)
+	}
+
+	for i := 1; i < len(narrowResponse); i++ {
+		if narrowResponse[i] > narrowResponse[i-1]+1e-6 {
+			t.Errorf("Expected response to fall with frequency but rose from %v to %v", narrowResponse[i-1], narrowResponse[i])
+		}
+		if wideResponse[i] > narrowResponse[i]+1e-6 {
+			t.Errorf("Expected wider blur to pass less at sample %d but was %v versus %v", i, wideResponse[i], narrowResponse[i])
+		}
+	}
+}
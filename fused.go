@@ -0,0 +1,107 @@
+package convolver
+
+import (
+	"github.com/mandykoh/go-parallel"
+	"github.com/mandykoh/prism"
+	"image"
+	"sync/atomic"
+)
+
+// FusedStep is one step of a fused application: an operation such as k.Avg,
+// along with the kernel whose radius determines how far around each pixel the
+// operation reads.
+type FusedStep struct {
+	Kernel *Kernel
+	Op     OpFunc
+}
+
+// ApplyFused applies a sequence of kernel operations to an image, producing the
+// same result as applying each in turn to the whole image, but working one
+// output tile at a time. For each tile, the first step is computed over the
+// tile plus the apron needed by all later steps, the second over the tile
+// plus the apron needed by the steps after it, and so on, so that
+// intermediate results stay small enough to remain in cache rather than being
+// materialised for the whole frame.
+//
+// Pixels within the aprons are computed once for each tile they border, so
+// larger tiles waste less work while smaller ones use less memory.
+//
+// Padding an intermediate result according to an edge mode other than
+// EdgeClip can need pixels from anywhere along its edges, so if any step's
+// kernel has such a mode, the steps are instead applied in turn to the whole
+// image, each with its kernel's edge mode and memory budget.
+func ApplyFused(img image.Image, steps []FusedStep, tileSize int, parallelism int) *image.NRGBA {
+	if len(steps) == 0 {
+		return prism.ConvertImageToNRGBA(img, parallelism)
+	}
+
+	for _, step := range steps {
+		if step.Kernel.edgeMode != EdgeClip {
+			return applyInTurn(img, steps, parallelism)
+		}
+	}
+
+	bounds := img.Bounds()
+	result := image.NewNRGBA(bounds)
+
+	// aprons[i] is the margin required around a tile for the input to step i
+	aprons := make([]int, len(steps)+1)
+	for i := len(steps) - 1; i >= 0; i-- {
+		aprons[i] = aprons[i+1] + steps[i].Kernel.radius
+	}
+
+	tiles := tilesCovering(bounds, tileSize)
+	nextTile := int64(-1)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		var buffers [2][]uint8
+
+		region := func(buffer int, rect image.Rectangle) *image.NRGBA {
+			size := bufferLength(rect, 4)
+			if cap(buffers[buffer]) < size {
+				buffers[buffer] = make([]uint8, size)
+			}
+			return &image.NRGBA{Pix: buffers[buffer][:size], Stride: rect.Dx() * 4, Rect: rect}
+		}
+
+		for {
+			index := int(atomic.AddInt64(&nextTile, 1))
+			if index >= len(tiles) {
+				return
+			}
+
+			tile := tiles[index]
+
+			// Clipping an intermediate region to its own bounds is equivalent
+			// to clipping to the image bounds, since each region includes all
+			// the pixels the next step reads except those outside the image.
+			current := nrgbaRegion(img, tile.Inset(-aprons[0]).Intersect(bounds), &buffers[0])
+
+			for i, step := range steps {
+				if i == len(steps)-1 {
+					applyToRect(current, result, tile, step.Op)
+					break
+				}
+
+				nextRect := tile.Inset(-aprons[i+1]).Intersect(bounds)
+				next := region((i+1)%2, nextRect)
+				applyToRect(current, next, nextRect, step.Op)
+				current = next
+			}
+		}
+	})
+
+	return result
+}
+
+// applyInTurn applies each step to the whole result of the one before it.
+func applyInTurn(img image.Image, steps []FusedStep, parallelism int) *image.NRGBA {
+	var result *image.NRGBA
+
+	for _, step := range steps {
+		result = step.Kernel.applyImage(img, step.Op, parallelism)
+		img = result
+	}
+
+	return result
+}
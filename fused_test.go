@@ -0,0 +1,62 @@
+package convolver
+
+import (
+	"image"
+	"runtime"
+	"testing"
+)
+
+func TestApplyFused(t *testing.T) {
+	img := randomImage(67, 45)
+
+	blur := KernelWithRadius(2)
+	for i := 0; i < blur.SideLength(); i++ {
+		for j := 0; j < blur.SideLength(); j++ {
+			blur.SetWeightUniform(j, i, float32(i+j+1))
+		}
+	}
+
+	dilate := KernelWithRadius(1)
+	dilate.SetWeightsUniform([]float32{
+		0, 1, 0,
+		1, 1, 1,
+		0, 1, 0,
+	})
+
+	steps := []FusedStep{
+		{Kernel: &blur, Op: blur.Avg},
+		{Kernel: &dilate, Op: dilate.Max},
+		{Kernel: &dilate, Op: dilate.Max},
+		{Kernel: &blur, Op: blur.Avg},
+	}
+
+	expectedImg := NewPipeline(blur.ApplyAvg, dilate.ApplyMax, dilate.ApplyMax, blur.ApplyAvg).Apply(img, runtime.NumCPU())
+
+	for _, tileSize := range []int{1, 7, 16, 100} {
+		result := ApplyFused(img, steps, tileSize, runtime.NumCPU())
+
+		for i := range expectedImg.Pix {
+			if expectedImg.Pix[i] != result.Pix[i] {
+				t.Fatalf("Expected fused result with tile size %d to match sequential application but differs at byte %d", tileSize, i)
+			}
+		}
+	}
+
+	t.Run("handles offset bounds", func(t *testing.T) {
+		offset := img.SubImage(image.Rect(10, 5, 50, 40)).(*image.NRGBA)
+		expectedImg := NewPipeline(blur.ApplyAvg, dilate.ApplyMax).Apply(offset, runtime.NumCPU())
+
+		result := ApplyFused(offset, steps[:2], 8, runtime.NumCPU())
+
+		if expected, actual := offset.Rect, result.Rect; expected != actual {
+			t.Fatalf("Expected bounds %v but were %v", expected, actual)
+		}
+		for i := offset.Rect.Min.Y; i < offset.Rect.Max.Y; i++ {
+			for j := offset.Rect.Min.X; j < offset.Rect.Max.X; j++ {
+				if expected, actual := expectedImg.NRGBAAt(j, i), result.NRGBAAt(j, i); expected != actual {
+					t.Fatalf("Expected pixel %d,%d to be %+v but was %+v", j, i, expected, actual)
+				}
+			}
+		}
+	})
+}
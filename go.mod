@@ -5,4 +5,5 @@ go 1.14
 require (
 	github.com/mandykoh/go-parallel v0.1.0
 	github.com/mandykoh/prism v0.32.0
+	golang.org/x/sys v0.0.0-20200930185726-fdedc70b468f
 )
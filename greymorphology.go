@@ -0,0 +1,90 @@
+package convolver
+
+import (
+	"github.com/mandykoh/prism/srgb"
+	"image"
+	"image/color"
+	"math"
+)
+
+// ApplyGreyDilate applies the GreyDilate operation to an image.
+func (k *Kernel) ApplyGreyDilate(img image.Image, parallelism int) *image.NRGBA {
+	return k.applyImage(img, k.GreyDilate, parallelism)
+}
+
+// ApplyGreyErode applies the GreyErode operation to an image.
+func (k *Kernel) ApplyGreyErode(img image.Image, parallelism int) *image.NRGBA {
+	return k.applyImage(img, k.GreyErode, parallelism)
+}
+
+// GreyDilate is greyscale dilation with the kernel as a non-flat structuring
+// element, following the standard definition of mathematical morphology (as
+// used by scipy.ndimage.grey_dilation): each channel's result is the maximum
+// over the element of the linear value plus the tap's weight, taken as the
+// element's height. Unlike Max, weights are added rather than multiplied, so a
+// kernel of zero weights is a flat square element.
+//
+// Taps with a weight of negative infinity are outside the element. As in the
+// standard definition, the element is reflected about its centre, which only
+// matters for asymmetric elements.
+func (k *Kernel) GreyDilate(img *image.NRGBA, x, y int) color.NRGBA {
+	return k.greyMorphology(img, x, y, true)
+}
+
+// GreyErode is greyscale erosion with the kernel as a non-flat structuring
+// element: each channel's result is the minimum over the element of the
+// linear value minus the tap's weight. Taps with a weight of negative
+// infinity are outside the element. See GreyDilate.
+func (k *Kernel) GreyErode(img *image.NRGBA, x, y int) color.NRGBA {
+	return k.greyMorphology(img, x, y, false)
+}
+
+func (k *Kernel) greyMorphology(img *image.NRGBA, x, y int, dilate bool) color.NRGBA {
+	inf := float32(math.Inf(1))
+
+	result := kernelWeight{inf, inf, inf, inf}
+	if dilate {
+		result = kernelWeight{-inf, -inf, -inf, -inf}
+	}
+
+	for s := 0; s < k.sideLength; s++ {
+		for t := 0; t < k.sideLength; t++ {
+			px, py := x+t-k.radius, y+s-k.radius
+			if dilate {
+				px, py = x-t+k.radius, y-s+k.radius
+			}
+			if !(image.Point{X: px, Y: py}.In(img.Rect)) {
+				continue
+			}
+
+			height := k.weights[s*k.sideLength+t]
+			c, a := srgb.ColorFromNRGBA(img.NRGBAAt(px, py))
+
+			if dilate {
+				result.R = float32(math.Max(float64(result.R), float64(c.R+height.R)))
+				result.G = float32(math.Max(float64(result.G), float64(c.G+height.G)))
+				result.B = float32(math.Max(float64(result.B), float64(c.B+height.B)))
+				result.A = float32(math.Max(float64(result.A), float64(a+height.A)))
+			} else {
+				result.R = float32(math.Min(float64(result.R), float64(c.R-height.R)))
+				result.G = float32(math.Min(float64(result.G), float64(c.G-height.G)))
+				result.B = float32(math.Min(float64(result.B), float64(c.B-height.B)))
+				result.A = float32(math.Min(float64(result.A), float64(a-height.A)))
+			}
+		}
+	}
+
+	// Channels with no taps inside the element and the image are left
+	// unchanged
+	original, originalAlpha := srgb.ColorFromNRGBA(img.NRGBAAt(x, y))
+	for _, ch := range []struct {
+		v    *float32
+		orig float32
+	}{{&result.R, original.R}, {&result.G, original.G}, {&result.B, original.B}, {&result.A, originalAlpha}} {
+		if math.IsInf(float64(*ch.v), 0) {
+			*ch.v = ch.orig
+		}
+	}
+
+	return result.toNRGBA()
+}
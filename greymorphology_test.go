@@ -0,0 +1,74 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func TestGreyMorphology(t *testing.T) {
+	img := randomImage(16, 16)
+
+	t.Run("matches Max and Min for a flat element", func(t *testing.T) {
+		outside := float32(math.Inf(-1))
+
+		flat := KernelWithRadius(1)
+		flat.SetWeightsUniform([]float32{
+			outside, 0, outside,
+			0, 0, 0,
+			outside, 0, outside,
+		})
+
+		gated := KernelWithRadius(1)
+		gated.SetWeightsUniform([]float32{
+			0, 1, 0,
+			1, 1, 1,
+			0, 1, 0,
+		})
+
+		for _, c := range []struct {
+			Name     string
+			Expected *image.NRGBA
+			Actual   *image.NRGBA
+		}{
+			{"dilation", gated.ApplyMax(img, runtime.NumCPU()), flat.ApplyGreyDilate(img, runtime.NumCPU())},
+			{"erosion", gated.ApplyMin(img, runtime.NumCPU()), flat.ApplyGreyErode(img, runtime.NumCPU())},
+		} {
+			for i := range c.Expected.Pix {
+				if c.Expected.Pix[i] != c.Actual.Pix[i] {
+					t.Fatalf("Expected flat %s to match but differs at byte %d", c.Name, i)
+				}
+			}
+		}
+	})
+
+	t.Run("adds element heights", func(t *testing.T) {
+		element := KernelWithRadius(1)
+		element.SetWeightsUniform([]float32{
+			0, 0, 0,
+			0.25, 0, 0,
+			0, 0, 0,
+		})
+
+		row := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+		row.SetNRGBA(0, 0, color.NRGBA{A: 255})
+		row.SetNRGBA(1, 0, color.NRGBA{A: 255})
+		row.SetNRGBA(2, 0, color.NRGBA{R: 137, G: 137, B: 137, A: 255})
+
+		dilated := element.ApplyGreyDilate(row, runtime.NumCPU())
+
+		// The element is reflected, so the raised tap to the left picks up the
+		// pixel to the right: linear 0.25 + 0.25 encodes to 188
+		if expected, actual := uint8(188), dilated.NRGBAAt(1, 0).R; expected != actual {
+			t.Errorf("Expected dilated value to be %d but was %d", expected, actual)
+		}
+
+		eroded := element.ApplyGreyErode(row, runtime.NumCPU())
+
+		if expected, actual := uint8(0), eroded.NRGBAAt(2, 0).R; expected != actual {
+			t.Errorf("Expected eroded value to be %d but was %d", expected, actual)
+		}
+	})
+}
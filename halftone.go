@@ -0,0 +1,37 @@
+package convolver
+
+import (
+	"fmt"
+	"math"
+)
+
+// Halftone returns a stage which renders each colour channel as a screen of
+// round dots, as in print, so that every channel value becomes either 0 or 1.
+// Dots are centred on a grid of cells of the given size in pixels, rotated by
+// angle radians, and grow with the darkness of the linear value so that the
+// proportion of each cell they cover matches it. This is an ordered dither,
+// and works well after a blur which removes detail finer than the cells.
+// Alpha is left unchanged.
+func Halftone(cellSize, angle float64) Stage {
+	if cellSize <= 0 {
+		panic(fmt.Sprintf("cell size must be positive but was %v", cellSize))
+	}
+
+	sin, cos := math.Sincos(angle)
+
+	return positionalStage(func(v float32, x, y, c int) float32 {
+		px, py := float64(x)+0.5, float64(y)+0.5
+		u := (px*cos + py*sin) / cellSize
+		w := (py*cos - px*sin) / cellSize
+
+		du, dw := u-math.Floor(u)-0.5, w-math.Floor(w)-0.5
+
+		// The fraction of the cell covered by a dot reaching this point
+		threshold := float32(math.Pi * (du*du + dw*dw))
+
+		if 1-v > threshold {
+			return 0
+		}
+		return 1
+	})
+}
@@ -0,0 +1,48 @@
+package convolver
+
+import (
+	"image"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func TestHalftone(t *testing.T) {
+
+	t.Run("produces only black and white", func(t *testing.T) {
+		img := randomImage(32, 32)
+
+		result := Halftone(6, math.Pi/4)(img, runtime.NumCPU())
+
+		for i, v := range result.Pix {
+			if i%4 != 3 && v != 0 && v != 255 {
+				t.Fatalf("Expected only 0 or 255 but found %d at byte %d", v, i)
+			}
+			if i%4 == 3 && v != img.Pix[i] {
+				t.Fatalf("Expected alpha to be unchanged but was %d at byte %d", v, i)
+			}
+		}
+	})
+
+	t.Run("covers a proportion of each cell matching the value", func(t *testing.T) {
+		for _, value := range []float32{0.2, 0.5, 0.8} {
+			img := NewFloatImage(image.Rect(0, 0, 120, 120))
+			for i := 0; i < len(img.Pix); i += 4 {
+				img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = value, value, value, 1
+			}
+
+			result := Halftone(8, 0.3)(img, runtime.NumCPU())
+
+			white := 0
+			for i := 0; i < len(result.Pix); i += 4 {
+				if result.Pix[i] == 255 {
+					white++
+				}
+			}
+
+			if actual := float32(white) / float32(len(result.Pix)/4); math.Abs(float64(actual-value)) > 0.05 {
+				t.Errorf("Expected proportion of white to be about %v but was %v", value, actual)
+			}
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package convolver
+
+import (
+	"fmt"
+	"github.com/mandykoh/go-parallel"
+	"github.com/mandykoh/prism"
+	"image"
+)
+
+// HistogramSpace selects the values counted by a histogram.
+type HistogramSpace int
+
+const (
+	// HistogramEncoded counts sRGB encoded values, as image editors usually
+	// show.
+	HistogramEncoded HistogramSpace = iota
+
+	// HistogramLinear counts linear light values, giving more resolution to
+	// highlights and less to shadows.
+	HistogramLinear
+)
+
+// Histogram counts the values of each channel of an image, in equally sized
+// bins spanning 0 to 1. Channels are ordered R, G, B and A.
+type Histogram struct {
+	Space HistogramSpace
+	Bins  [4][]uint64
+
+	// Pixels is the number of pixels counted.
+	Pixels uint64
+}
+
+// Percentile returns the value of a channel below which the given fraction of
+// pixels fall, between 0 and 1 in the histogram's space, interpolating within
+// the bin in which the fraction is reached.
+func (h *Histogram) Percentile(channel int, fraction float64) float32 {
+	bins := h.Bins[channel]
+	if h.Pixels == 0 {
+		return 0
+	}
+
+	target := fraction * float64(h.Pixels)
+	cumulative := 0.0
+
+	for i, count := range bins {
+		if count > 0 && cumulative+float64(count) >= target {
+			within := (target - cumulative) / float64(count)
+			return float32((float64(i) + within) / float64(len(bins)))
+		}
+		cumulative += float64(count)
+	}
+
+	return 1
+}
+
+// ComputeHistogram counts the values of each channel of an image into the
+// given number of bins. Values in linear space beyond the range 0 to 1, such
+// as from an HDR FloatImage, are counted in the first or last bin.
+func ComputeHistogram(img image.Image, bins int, space HistogramSpace, parallelism int) *Histogram {
+	if bins < 1 {
+		panic(fmt.Sprintf("number of bins must be positive but was %d", bins))
+	}
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	var rows int
+	var countRow func(row int, counts *[4][]uint64)
+
+	switch space {
+	case HistogramEncoded:
+		nrgba := prism.ConvertImageToNRGBA(img, parallelism)
+		rows = nrgba.Rect.Dy()
+		width := nrgba.Rect.Dx() * 4
+
+		countRow = func(row int, counts *[4][]uint64) {
+			pix := nrgba.Pix[nrgba.PixOffset(nrgba.Rect.Min.X, nrgba.Rect.Min.Y+row):][:width]
+			for i, v := range pix {
+				counts[i%4][int(v)*bins/256]++
+			}
+		}
+
+	case HistogramLinear:
+		f := FloatImageFromImage(img, parallelism)
+		rows = f.Rect.Dy()
+		width := f.Rect.Dx() * 4
+
+		countRow = func(row int, counts *[4][]uint64) {
+			pix := f.Pix[row*f.Stride:][:width]
+			for i, v := range pix {
+				bin := 0
+				if v >= 1 {
+					bin = bins - 1
+				} else if v > 0 {
+					bin = int(v * float32(bins))
+				}
+				counts[i%4][bin]++
+			}
+		}
+
+	default:
+		panic(fmt.Sprintf("unknown histogram space %d", int(space)))
+	}
+
+	workerCounts := make([][4][]uint64, parallelism)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		counts := &workerCounts[workerNum]
+		for c := range counts {
+			counts[c] = make([]uint64, bins)
+		}
+
+		for i := workerNum; i < rows; i += workerCount {
+			countRow(i, counts)
+		}
+	})
+
+	h := &Histogram{Space: space}
+	for c := range h.Bins {
+		h.Bins[c] = make([]uint64, bins)
+	}
+
+	for _, counts := range workerCounts {
+		for c := range counts {
+			for i, n := range counts[c] {
+				h.Bins[c][i] += n
+			}
+		}
+	}
+
+	bounds := img.Bounds()
+	h.Pixels = uint64(bounds.Dx()) * uint64(bounds.Dy())
+
+	return h
+}
+
+// HistogramStage returns a stage which computes the histogram of its input
+// as ComputeHistogram does and passes it to onHistogram, returning the input
+// unchanged. This allows the histogram of an intermediate result of a
+// Pipeline to be collected.
+func HistogramStage(bins int, space HistogramSpace, onHistogram func(h *Histogram)) Stage {
+	return func(img image.Image, parallelism int) *image.NRGBA {
+		onHistogram(ComputeHistogram(img, bins, space, parallelism))
+		return prism.ConvertImageToNRGBA(img, parallelism)
+	}
+}
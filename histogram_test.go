@@ -0,0 +1,103 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestComputeHistogram(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 3))
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 4; j++ {
+			img.SetNRGBA(j, i, color.NRGBA{R: uint8(j * 64), G: 128, B: 255, A: 255})
+		}
+	}
+
+	t.Run("counts encoded values per channel", func(t *testing.T) {
+		h := ComputeHistogram(img, 4, HistogramEncoded, 2)
+
+		expected := [4][]uint64{
+			{3, 3, 3, 3},
+			{0, 0, 12, 0},
+			{0, 0, 0, 12},
+			{0, 0, 0, 12},
+		}
+
+		for c := range expected {
+			for i := range expected[c] {
+				if expected[c][i] != h.Bins[c][i] {
+					t.Errorf("Expected channel %d bin %d to be %d but was %d", c, i, expected[c][i], h.Bins[c][i])
+				}
+			}
+		}
+		if expected, actual := uint64(12), h.Pixels; expected != actual {
+			t.Errorf("Expected %d pixels but was %d", expected, actual)
+		}
+	})
+
+	t.Run("treats parallelism below one as a single worker", func(t *testing.T) {
+		expected := ComputeHistogram(img, 4, HistogramEncoded, 1)
+
+		for _, parallelism := range []int{0, -2} {
+			h := ComputeHistogram(img, 4, HistogramEncoded, parallelism)
+
+			if !reflect.DeepEqual(expected, h) {
+				t.Errorf("Expected histogram with parallelism %d to be %+v but was %+v", parallelism, expected, h)
+			}
+		}
+	})
+
+	t.Run("counts linear values per channel", func(t *testing.T) {
+		h := ComputeHistogram(img, 4, HistogramLinear, 2)
+
+		// Encoded 128 is about 0.22 in linear light
+		if expected, actual := uint64(12), h.Bins[1][0]; expected != actual {
+			t.Errorf("Expected linear mid grey to be in the first bin but bin count was %d", actual)
+		}
+	})
+
+	t.Run("counts out of range linear values in the end bins", func(t *testing.T) {
+		f := NewFloatImage(image.Rect(0, 0, 2, 1))
+		f.SetRGBA(0, 0, -1, 0, 0, 1)
+		f.SetRGBA(1, 0, 5, 0, 0, 1)
+
+		h := ComputeHistogram(f, 10, HistogramLinear, 1)
+
+		if h.Bins[0][0] != 1 || h.Bins[0][9] != 1 {
+			t.Errorf("Expected values to be counted in the end bins but were %v", h.Bins[0])
+		}
+	})
+
+	t.Run("finds percentiles", func(t *testing.T) {
+		h := ComputeHistogram(img, 256, HistogramEncoded, 2)
+
+		if actual := h.Percentile(1, 0.5); actual < 128.0/256 || actual > 129.0/256 {
+			t.Errorf("Expected median of green to be within its bin but was %v", actual)
+		}
+		if actual := h.Percentile(0, 0.2); actual > 1.0/256 {
+			t.Errorf("Expected 20th percentile of red to be in the first bin but was %v", actual)
+		}
+		if actual := h.Percentile(0, 0.8); actual < 192.0/256 {
+			t.Errorf("Expected 80th percentile of red to be in the last occupied bin but was %v", actual)
+		}
+	})
+
+	t.Run("can be collected within a pipeline", func(t *testing.T) {
+		var h *Histogram
+
+		result := NewPipeline(HistogramStage(4, HistogramEncoded, func(histogram *Histogram) {
+			h = histogram
+		})).Apply(img, 2)
+
+		if h == nil || h.Pixels != 12 {
+			t.Fatalf("Expected histogram of 12 pixels but was %+v", h)
+		}
+		for i := range img.Pix {
+			if img.Pix[i] != result.Pix[i] {
+				t.Fatalf("Expected image to pass through unchanged but differs at byte %d", i)
+			}
+		}
+	})
+}
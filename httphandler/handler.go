@@ -0,0 +1,234 @@
+// Package httphandler exposes a convolver Pipeline as an http.Handler, so that
+// filtering can be offered by a thumbnailing or image service without
+// rewriting upload, decoding, and content negotiation glue.
+package httphandler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/mandykoh/convolver"
+	"github.com/mandykoh/convolver/imageio"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const defaultMaxUploadBytes = 32 << 20
+
+// Handler applies a Pipeline to an uploaded image and responds with the
+// filtered result.
+//
+// The image is taken from the request body of a POST, either directly or as
+// the "image" field of a multipart form. If AllowURLFetch is set, an image
+// may instead be fetched from the URL given by the "url" query parameter;
+// this should only be enabled where fetching arbitrary URLs is safe.
+//
+// The response is PNG or JPEG encoded according to the request's Accept
+// header, defaulting to the format of the input where possible.
+type Handler struct {
+	Pipeline       *convolver.Pipeline
+	Parallelism    int
+	MaxUploadBytes int64
+	AllowURLFetch  bool
+	Client         *http.Client
+	JPEGQuality    int
+
+	// MaxPixels limits the number of pixels in an input image, which is
+	// otherwise imageio.MaxPixels. Larger images are rejected from their
+	// headers before any pixel memory is allocated.
+	MaxPixels int
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader
+	var err error
+
+	switch {
+	case h.AllowURLFetch && r.URL.Query().Get("url") != "":
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err = h.fetch(r.URL.Query().Get("url"))
+
+	case r.Method == http.MethodPost:
+		body, err = h.upload(w, r)
+
+	default:
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// A small upload may declare enormous dimensions, so check them before
+	// decoding allocates the pixels. Formats the image package doesn't know
+	// are checked once decoded.
+	header := bytes.Buffer{}
+	if config, _, err := image.DecodeConfig(io.TeeReader(body, &header)); err == nil {
+		if err := h.checkSize(config.Width, config.Height); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+	}
+
+	img, inputFormat, err := imageio.Decode(io.MultiReader(&header, body))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error decoding image: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := h.checkSize(img.Bounds().Dx(), img.Bounds().Dy()); err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	outputFormat, ok := negotiateFormat(r.Header.Get("Accept"), inputFormat)
+	if !ok {
+		http.Error(w, "no acceptable image format; image/png and image/jpeg are supported", http.StatusNotAcceptable)
+		return
+	}
+
+	result := h.Pipeline.Apply(img, h.parallelism())
+
+	w.Header().Set("Content-Type", "image/"+outputFormat)
+	w.Header().Set("Vary", "Accept")
+
+	if outputFormat == "jpeg" {
+		quality := h.JPEGQuality
+		if quality <= 0 {
+			quality = jpeg.DefaultQuality
+		}
+		_ = jpeg.Encode(w, result, &jpeg.Options{Quality: quality})
+	} else {
+		_ = png.Encode(w, result)
+	}
+}
+
+// checkSize returns an error if an image of the given size has more pixels
+// than the handler allows.
+func (h *Handler) checkSize(width, height int) error {
+	if width > 0 && height > 0 && width > h.maxPixels()/height {
+		return fmt.Errorf("image dimensions %dx%d exceed the limit of %d pixels", width, height, h.maxPixels())
+	}
+	return nil
+}
+
+func (h *Handler) fetch(url string) (io.Reader, error) {
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching image: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching image: status %s", resp.Status)
+	}
+
+	return readLimited(resp.Body, h.maxUploadBytes())
+}
+
+func (h *Handler) maxPixels() int {
+	if h.MaxPixels > 0 {
+		return h.MaxPixels
+	}
+	return imageio.MaxPixels
+}
+
+func (h *Handler) maxUploadBytes() int64 {
+	if h.MaxUploadBytes > 0 {
+		return h.MaxUploadBytes
+	}
+	return defaultMaxUploadBytes
+}
+
+func (h *Handler) parallelism() int {
+	if h.Parallelism > 0 {
+		return h.Parallelism
+	}
+	return 1
+}
+
+func (h *Handler) upload(w http.ResponseWriter, r *http.Request) (io.Reader, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxUploadBytes())
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, nil
+	}
+
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		return nil, fmt.Errorf("error reading image upload: %v", err)
+	}
+
+	return file, nil
+}
+
+// negotiateFormat chooses an output format from an Accept header, preferring
+// the input format when the client will accept either.
+func negotiateFormat(accept string, inputFormat string) (string, bool) {
+	preferred := "png"
+	if inputFormat == "jpeg" {
+		preferred = "jpeg"
+	}
+
+	if strings.TrimSpace(accept) == "" {
+		return preferred, true
+	}
+
+	acceptsPNG, acceptsJPEG := false, false
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+
+		switch mediaType {
+		case "*/*", "image/*":
+			acceptsPNG, acceptsJPEG = true, true
+		case "image/png":
+			acceptsPNG = true
+		case "image/jpeg":
+			acceptsJPEG = true
+		}
+	}
+
+	switch {
+	case preferred == "jpeg" && acceptsJPEG, preferred == "png" && acceptsPNG:
+		return preferred, true
+	case acceptsPNG:
+		return "png", true
+	case acceptsJPEG:
+		return "jpeg", true
+	}
+
+	return "", false
+}
+
+func readLimited(r io.Reader, limit int64) (io.Reader, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, errors.New("image exceeds maximum size")
+	}
+	return bytes.NewReader(data), nil
+}
@@ -0,0 +1,214 @@
+package httphandler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/mandykoh/convolver"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	img.SetNRGBA(4, 4, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+
+	kernel := convolver.KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{
+		1, 1, 1,
+		1, 1, 1,
+		1, 1, 1,
+	})
+	pipeline := convolver.NewPipeline(kernel.ApplyMax)
+	expectedImg := pipeline.Apply(img, runtime.NumCPU())
+
+	handler := &Handler{Pipeline: pipeline, Parallelism: runtime.NumCPU()}
+
+	encodedPNG := func() []byte {
+		buf := bytes.Buffer{}
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatalf("Error encoding test image: %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	checkPNGResult := func(t *testing.T, resp *httptest.ResponseRecorder) {
+		t.Helper()
+
+		if expected, actual := http.StatusOK, resp.Code; expected != actual {
+			t.Fatalf("Expected status %d but was %d: %s", expected, actual, resp.Body.String())
+		}
+		if expected, actual := "image/png", resp.Header().Get("Content-Type"); expected != actual {
+			t.Errorf("Expected content type %s but was %s", expected, actual)
+		}
+
+		result, err := png.Decode(resp.Body)
+		if err != nil {
+			t.Fatalf("Error decoding response: %v", err)
+		}
+
+		for i := img.Rect.Min.Y; i < img.Rect.Max.Y; i++ {
+			for j := img.Rect.Min.X; j < img.Rect.Max.X; j++ {
+				if expected, actual := expectedImg.NRGBAAt(j, i), color.NRGBAModel.Convert(result.At(j, i)); expected != actual {
+					t.Fatalf("Expected pixel %d,%d to be %+v but was %+v", j, i, expected, actual)
+				}
+			}
+		}
+	}
+
+	t.Run("filters an image posted as the request body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encodedPNG()))
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+
+		checkPNGResult(t, resp)
+	})
+
+	t.Run("filters an image uploaded as a multipart form", func(t *testing.T) {
+		body := bytes.Buffer{}
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("image", "test.png")
+		_, _ = part.Write(encodedPNG())
+		_ = form.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+
+		checkPNGResult(t, resp)
+	})
+
+	t.Run("fetches an image by URL when allowed", func(t *testing.T) {
+		source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(encodedPNG())
+		}))
+		defer source.Close()
+
+		urlHandler := *handler
+		urlHandler.AllowURLFetch = true
+
+		req := httptest.NewRequest(http.MethodGet, "/?url="+source.URL, nil)
+		resp := httptest.NewRecorder()
+
+		urlHandler.ServeHTTP(resp, req)
+
+		checkPNGResult(t, resp)
+	})
+
+	t.Run("rejects URL fetches unless allowed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/?url=http://example.com/", nil)
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+
+		if expected, actual := http.StatusMethodNotAllowed, resp.Code; expected != actual {
+			t.Errorf("Expected status %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("encodes JPEG when requested", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encodedPNG()))
+		req.Header.Set("Accept", "image/jpeg")
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+
+		if expected, actual := "image/jpeg", resp.Header().Get("Content-Type"); expected != actual {
+			t.Fatalf("Expected content type %s but was %s", expected, actual)
+		}
+		if _, err := jpeg.Decode(resp.Body); err != nil {
+			t.Errorf("Error decoding response: %v", err)
+		}
+	})
+
+	t.Run("rejects unsupported formats", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encodedPNG()))
+		req.Header.Set("Accept", "image/webp")
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+
+		if expected, actual := http.StatusNotAcceptable, resp.Code; expected != actual {
+			t.Errorf("Expected status %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("rejects images with too many pixels before decoding them", func(t *testing.T) {
+		// Declare a huge image in the PNG header of a small file
+		data := encodedPNG()
+		binary.BigEndian.PutUint32(data[16:], 100000)
+		binary.BigEndian.PutUint32(data[20:], 100000)
+		binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(data))
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+
+		if expected, actual := http.StatusRequestEntityTooLarge, resp.Code; expected != actual {
+			t.Errorf("Expected status %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("rejects images beyond a configured pixel limit", func(t *testing.T) {
+		limited := *handler
+		limited.MaxPixels = 63
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(encodedPNG()))
+		resp := httptest.NewRecorder()
+
+		limited.ServeHTTP(resp, req)
+
+		if expected, actual := http.StatusRequestEntityTooLarge, resp.Code; expected != actual {
+			t.Errorf("Expected status %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("rejects undecodable input", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte("not an image")))
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+
+		if expected, actual := http.StatusBadRequest, resp.Code; expected != actual {
+			t.Errorf("Expected status %d but was %d", expected, actual)
+		}
+	})
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	cases := []struct {
+		Accept         string
+		InputFormat    string
+		ExpectedFormat string
+		ExpectedOK     bool
+	}{
+		{Accept: "", InputFormat: "png", ExpectedFormat: "png", ExpectedOK: true},
+		{Accept: "", InputFormat: "jpeg", ExpectedFormat: "jpeg", ExpectedOK: true},
+		{Accept: "", InputFormat: "gif", ExpectedFormat: "png", ExpectedOK: true},
+		{Accept: "*/*", InputFormat: "jpeg", ExpectedFormat: "jpeg", ExpectedOK: true},
+		{Accept: "image/png, image/jpeg", InputFormat: "jpeg", ExpectedFormat: "jpeg", ExpectedOK: true},
+		{Accept: "image/png", InputFormat: "jpeg", ExpectedFormat: "png", ExpectedOK: true},
+		{Accept: "image/webp, image/jpeg;q=0.5", InputFormat: "png", ExpectedFormat: "jpeg", ExpectedOK: true},
+		{Accept: "image/png;q=0", InputFormat: "png", ExpectedOK: false},
+		{Accept: "text/html", InputFormat: "png", ExpectedOK: false},
+	}
+
+	for _, c := range cases {
+		format, ok := negotiateFormat(c.Accept, c.InputFormat)
+
+		if ok != c.ExpectedOK || format != c.ExpectedFormat {
+			t.Errorf("Expected Accept %q with %s input to give %q, %v but got %q, %v", c.Accept, c.InputFormat, c.ExpectedFormat, c.ExpectedOK, format, ok)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package imageio
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+	"time"
+)
+
+// EncodeAnimation encodes a sequence of frames, such as the snapshots of each
+// pass collected with convolver.RepeatWithSnapshots, as an animation which
+// shows each frame for the given delay and loops forever. The format is
+// either "gif" or "png" (producing an animated PNG). All frames must have the
+// same bounds.
+//
+// GIF frames are dithered to a fixed 256 colour palette and lose any
+// transparency, so animated PNG should be preferred where exact colours
+// matter.
+func EncodeAnimation(w io.Writer, frames []image.Image, delay time.Duration, format string) error {
+	if len(frames) == 0 {
+		return errors.New("an animation requires at least one frame")
+	}
+
+	bounds := frames[0].Bounds()
+	for i, frame := range frames[1:] {
+		if frame.Bounds() != bounds {
+			return fmt.Errorf("frame %d has bounds %v but expected %v", i+2, frame.Bounds(), bounds)
+		}
+	}
+
+	switch format {
+	case "gif":
+		return encodeGIFAnimation(w, frames, delay)
+	case "png":
+		return encodeAPNG(w, frames, delay)
+	}
+
+	return fmt.Errorf("unsupported animation format %q", format)
+}
+
+// SaveAnimation encodes an animation as EncodeAnimation does to the file at
+// path, using the format implied by the file's extension.
+func SaveAnimation(path string, frames []image.Image, delay time.Duration) error {
+	format, ok := FormatForPath(path)
+	if !ok {
+		return fmt.Errorf("unsupported animation format for %s", path)
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeAnimation(&buf, frames, delay, format); err != nil {
+		return fmt.Errorf("error encoding %s: %v", path, err)
+	}
+
+	return writeFile(path, buf.Bytes())
+}
+
+func encodeAPNG(w io.Writer, frames []image.Image, delay time.Duration) error {
+	bounds := frames[0].Bounds()
+	delayMillis := uint16(clampDuration(delay/time.Millisecond, 0, 65535))
+
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+
+	header := make([]byte, 13)
+	binary.BigEndian.PutUint32(header[0:], uint32(bounds.Dx()))
+	binary.BigEndian.PutUint32(header[4:], uint32(bounds.Dy()))
+	header[8] = 8 // Bit depth
+	header[9] = 6 // Colour type: RGBA
+	writePNGChunk(&buf, "IHDR", header)
+
+	animControl := make([]byte, 8)
+	binary.BigEndian.PutUint32(animControl[0:], uint32(len(frames)))
+	writePNGChunk(&buf, "acTL", animControl)
+
+	sequence := uint32(0)
+
+	for i, frame := range frames {
+		frameControl := make([]byte, 26)
+		binary.BigEndian.PutUint32(frameControl[0:], sequence)
+		binary.BigEndian.PutUint32(frameControl[4:], uint32(bounds.Dx()))
+		binary.BigEndian.PutUint32(frameControl[8:], uint32(bounds.Dy()))
+		binary.BigEndian.PutUint16(frameControl[20:], delayMillis)
+		binary.BigEndian.PutUint16(frameControl[22:], 1000)
+		writePNGChunk(&buf, "fcTL", frameControl)
+		sequence++
+
+		data, err := pngImageData(frame)
+		if err != nil {
+			return err
+		}
+
+		// The first frame is stored as ordinary image data, so that decoders
+		// without animation support show it as a still image.
+		if i == 0 {
+			writePNGChunk(&buf, "IDAT", data)
+		} else {
+			frameData := make([]byte, 4, 4+len(data))
+			binary.BigEndian.PutUint32(frameData, sequence)
+			writePNGChunk(&buf, "fdAT", append(frameData, data...))
+			sequence++
+		}
+	}
+
+	writePNGChunk(&buf, "IEND", nil)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func encodeGIFAnimation(w io.Writer, frames []image.Image, delay time.Duration) error {
+	anim := &gif.GIF{
+		Image: make([]*image.Paletted, len(frames)),
+		Delay: make([]int, len(frames)),
+	}
+
+	delayHundredths := int(clampDuration(delay/(10*time.Millisecond), 0, 65535))
+
+	for i, frame := range frames {
+		paletted := image.NewPaletted(frame.Bounds(), palette.Plan9)
+		draw.FloydSteinberg.Draw(paletted, frame.Bounds(), frame, frame.Bounds().Min)
+
+		anim.Image[i] = paletted
+		anim.Delay[i] = delayHundredths
+	}
+
+	return gif.EncodeAll(w, anim)
+}
+
+// pngImageData returns the compressed 8-bit RGBA scanlines of an image, as
+// stored in PNG image data chunks. No filtering is applied.
+func pngImageData(img image.Image) ([]byte, error) {
+	bounds := img.Bounds()
+
+	nrgba := image.NewNRGBA(bounds)
+	draw.Draw(nrgba, bounds, img, bounds.Min, draw.Src)
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+
+	for i := bounds.Min.Y; i < bounds.Max.Y; i++ {
+		row := nrgba.Pix[nrgba.PixOffset(bounds.Min.X, i):nrgba.PixOffset(bounds.Max.X, i)]
+		if _, err := zw.Write(append([]byte{0}, row...)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func clampDuration(d, min, max time.Duration) time.Duration {
+	if d < min {
+		return min
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
+
+func writePNGChunk(buf *bytes.Buffer, chunkType string, data []byte) {
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(data)))
+
+	chunk := append([]byte(chunkType), data...)
+	buf.Write(chunk)
+	_ = binary.Write(buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+}
@@ -0,0 +1,115 @@
+package imageio
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestEncodeAnimation(t *testing.T) {
+	frames := make([]image.Image, 3)
+	for i := range frames {
+		frame := image.NewNRGBA(image.Rect(0, 0, 4, 3))
+		for j := 0; j < len(frame.Pix); j += 4 {
+			frame.Pix[j] = uint8(i * 100)
+			frame.Pix[j+3] = 255
+		}
+		frames[i] = frame
+	}
+
+	t.Run("encodes an animated GIF", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := EncodeAnimation(&buf, frames, 200*time.Millisecond, "gif"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		anim, err := gif.DecodeAll(&buf)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if expected, actual := len(frames), len(anim.Image); expected != actual {
+			t.Fatalf("Expected %d frames but got %d", expected, actual)
+		}
+		for i, delay := range anim.Delay {
+			if expected, actual := 20, delay; expected != actual {
+				t.Errorf("Expected frame %d delay to be %d but was %d", i, expected, actual)
+			}
+		}
+	})
+
+	t.Run("encodes an animated PNG", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := EncodeAnimation(&buf, frames, 250*time.Millisecond, "png"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		still, err := png.Decode(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("Unexpected error decoding as a still image: %v", err)
+		}
+		if expected, actual := frames[0].At(1, 1), color.NRGBAModel.Convert(still.At(1, 1)); expected != actual {
+			t.Errorf("Expected still image to show first frame colour %v but was %v", expected, actual)
+		}
+
+		var frameControls, frameData [][]byte
+		data := buf.Bytes()[8:]
+		for len(data) >= 12 {
+			length := binary.BigEndian.Uint32(data)
+			chunkType, body := string(data[4:8]), data[8:8+length]
+			switch chunkType {
+			case "acTL":
+				if expected, actual := uint32(len(frames)), binary.BigEndian.Uint32(body); expected != actual {
+					t.Errorf("Expected animation control to declare %d frames but was %d", expected, actual)
+				}
+			case "fcTL":
+				frameControls = append(frameControls, body)
+			case "fdAT":
+				frameData = append(frameData, body)
+			}
+			data = data[12+length:]
+		}
+
+		if expected, actual := len(frames), len(frameControls); expected != actual {
+			t.Fatalf("Expected %d frame controls but got %d", expected, actual)
+		}
+		if expected, actual := uint16(250), binary.BigEndian.Uint16(frameControls[1][20:]); expected != actual {
+			t.Errorf("Expected frame delay numerator to be %d but was %d", expected, actual)
+		}
+		if expected, actual := len(frames)-1, len(frameData); expected != actual {
+			t.Fatalf("Expected %d frame data chunks but got %d", expected, actual)
+		}
+
+		zr, err := zlib.NewReader(bytes.NewReader(frameData[1][4:]))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		scanlines, err := ioutil.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if expected, actual := uint8(200), scanlines[1]; expected != actual {
+			t.Errorf("Expected last frame's first red value to be %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("rejects frames with differing bounds", func(t *testing.T) {
+		mismatched := []image.Image{frames[0], image.NewNRGBA(image.Rect(0, 0, 2, 2))}
+
+		if err := EncodeAnimation(ioutil.Discard, mismatched, time.Second, "gif"); err == nil {
+			t.Errorf("Expected an error but got none")
+		}
+	})
+
+	t.Run("rejects unsupported formats", func(t *testing.T) {
+		if err := EncodeAnimation(ioutil.Discard, frames, time.Second, "jpeg"); err == nil {
+			t.Errorf("Expected an error but got none")
+		}
+	})
+}
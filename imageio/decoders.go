@@ -0,0 +1,130 @@
+package imageio
+
+import (
+	"bufio"
+	"github.com/mandykoh/prism/adobergb"
+	"github.com/mandykoh/prism/ciexyz"
+	"github.com/mandykoh/prism/displayp3"
+	"github.com/mandykoh/prism/srgb"
+	"image"
+	"image/color"
+	"io"
+	"sync"
+)
+
+// ColorSpace identifies the colour space in which a decoded image's pixel
+// values are encoded.
+type ColorSpace int
+
+const (
+	ColorSpaceSRGB ColorSpace = iota
+	ColorSpaceLinearSRGB
+	ColorSpaceDisplayP3
+	ColorSpaceAdobeRGB
+)
+
+// DecodeFunc decodes an image, reporting the colour space its pixel values are
+// encoded in.
+type DecodeFunc func(r io.Reader) (image.Image, ColorSpace, error)
+
+type decoder struct {
+	name   string
+	magic  string
+	decode DecodeFunc
+}
+
+var decodersMutex sync.RWMutex
+var decoders []decoder
+
+// RegisterDecoder registers a decoder for an image format such as AVIF or HEIF
+// for use by Decode and Load. Images are identified by magic, a prefix of the
+// encoded data in which "?" matches any byte, as with image.RegisterFormat.
+// Registered decoders take precedence over formats registered with the image
+// package.
+//
+// Decoded images are adapted to 8-bit sRGB, as expected by the convolution
+// pipeline; decoders may return images of any bit depth, and pixel values are
+// converted from the reported colour space at full precision.
+func RegisterDecoder(name, magic string, decode DecodeFunc) {
+	decodersMutex.Lock()
+	defer decodersMutex.Unlock()
+
+	decoders = append(decoders, decoder{name: name, magic: magic, decode: decode})
+}
+
+// adaptToSRGB converts an image with pixel values in the given colour space to
+// an 8-bit sRGB encoded image. Colours outside the sRGB gamut are clipped.
+func adaptToSRGB(img image.Image, cs ColorSpace) *image.NRGBA {
+	if nrgba, ok := img.(*image.NRGBA); ok && cs == ColorSpaceSRGB {
+		return nrgba
+	}
+
+	var toXYZ func(c color.NRGBA64) ciexyz.Color
+
+	switch cs {
+	case ColorSpaceLinearSRGB:
+		toXYZ = func(c color.NRGBA64) ciexyz.Color {
+			return srgb.ColorFromLinear(float32(c.R)/0xffff, float32(c.G)/0xffff, float32(c.B)/0xffff).ToXYZ()
+		}
+	case ColorSpaceDisplayP3:
+		toXYZ = func(c color.NRGBA64) ciexyz.Color {
+			return displayp3.ColorFromLinear(srgb.From16Bit(c.R), srgb.From16Bit(c.G), srgb.From16Bit(c.B)).ToXYZ()
+		}
+	case ColorSpaceAdobeRGB:
+		toXYZ = func(c color.NRGBA64) ciexyz.Color {
+			return adobergb.ColorFromLinear(adobergb.From16Bit(c.R), adobergb.From16Bit(c.G), adobergb.From16Bit(c.B)).ToXYZ()
+		}
+	}
+
+	bounds := img.Bounds()
+	result := image.NewNRGBA(bounds)
+
+	for i := bounds.Min.Y; i < bounds.Max.Y; i++ {
+		for j := bounds.Min.X; j < bounds.Max.X; j++ {
+			c := color.NRGBA64Model.Convert(img.At(j, i)).(color.NRGBA64)
+
+			if toXYZ == nil {
+				result.SetNRGBA(j, i, color.NRGBA{R: to8Bit(c.R), G: to8Bit(c.G), B: to8Bit(c.B), A: to8Bit(c.A)})
+			} else {
+				result.SetNRGBA(j, i, srgb.ColorFromXYZ(toXYZ(c)).ToNRGBA(float32(c.A)/0xffff))
+			}
+		}
+	}
+
+	return result
+}
+
+func decodeRegistered(br *bufio.Reader) (img image.Image, format string, ok bool, err error) {
+	decodersMutex.RLock()
+	registered := decoders
+	decodersMutex.RUnlock()
+
+	for _, d := range registered {
+		header, peekErr := br.Peek(len(d.magic))
+		if peekErr != nil || !matchesMagic(d.magic, header) {
+			continue
+		}
+
+		img, cs, err := d.decode(br)
+		if err != nil {
+			return nil, d.name, true, err
+		}
+
+		return adaptToSRGB(img, cs), d.name, true, nil
+	}
+
+	return nil, "", false, nil
+}
+
+func to8Bit(v uint16) uint8 {
+	return uint8((uint32(v)*255 + 0x7fff) / 0xffff)
+}
+
+func matchesMagic(magic string, header []byte) bool {
+	for i := range header {
+		if magic[i] != '?' && magic[i] != header[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package imageio
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestRegisterDecoder(t *testing.T) {
+	decoded := image.NewNRGBA64(image.Rect(0, 0, 3, 1))
+	decoded.SetNRGBA64(0, 0, color.NRGBA64{R: 0xffff, G: 0xffff, B: 0xffff, A: 0xffff})
+	decoded.SetNRGBA64(1, 0, color.NRGBA64{R: 0x8000, G: 0x8000, B: 0x8000, A: 0x8000})
+	decoded.SetNRGBA64(2, 0, color.NRGBA64{R: 0xffff, A: 0xffff})
+
+	var colorSpace ColorSpace
+	var readHeader []byte
+
+	RegisterDecoder("test", "TST?FMT", func(r io.Reader) (image.Image, ColorSpace, error) {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, 0, err
+		}
+		readHeader = data
+		if bytes.HasSuffix(data, []byte("broken")) {
+			return nil, 0, errors.New("broken image")
+		}
+		return decoded, colorSpace, nil
+	})
+
+	decode := func(t *testing.T, cs ColorSpace) *image.NRGBA {
+		colorSpace = cs
+
+		img, format, err := Decode(bytes.NewReader([]byte("TST1FMT")))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if expected, actual := "test", format; expected != actual {
+			t.Errorf("Expected format %q but was %q", expected, actual)
+		}
+		if expected, actual := "TST1FMT", string(readHeader); expected != actual {
+			t.Errorf("Expected decoder to receive the full input %q but got %q", expected, actual)
+		}
+
+		return img.(*image.NRGBA)
+	}
+
+	t.Run("reduces sRGB images to 8 bits", func(t *testing.T) {
+		result := decode(t, ColorSpaceSRGB)
+
+		if expected, actual := (color.NRGBA{R: 128, G: 128, B: 128, A: 128}), result.NRGBAAt(1, 0); expected != actual {
+			t.Errorf("Expected pixel to be %+v but was %+v", expected, actual)
+		}
+	})
+
+	t.Run("encodes linear images", func(t *testing.T) {
+		result := decode(t, ColorSpaceLinearSRGB)
+
+		if actual := result.NRGBAAt(1, 0); actual.R < 186 || actual.R > 189 || actual.A != 128 {
+			t.Errorf("Expected linear half intensity to be encoded as about 188 but was %+v", actual)
+		}
+	})
+
+	t.Run("converts wide gamut images", func(t *testing.T) {
+		for _, cs := range []ColorSpace{ColorSpaceDisplayP3, ColorSpaceAdobeRGB} {
+			result := decode(t, cs)
+
+			if white := result.NRGBAAt(0, 0); white.R < 254 || white.G < 254 || white.B < 254 {
+				t.Errorf("Expected white to remain white in colour space %d but was %+v", cs, white)
+			}
+			if red := result.NRGBAAt(2, 0); red.R != 255 || red.G != 0 || red.B != 0 {
+				t.Errorf("Expected fully saturated red to clip to sRGB red in colour space %d but was %+v", cs, red)
+			}
+		}
+	})
+
+	t.Run("passes on decoding errors", func(t *testing.T) {
+		if _, _, err := Decode(bytes.NewReader([]byte("TSTXFMTbroken"))); err == nil {
+			t.Errorf("Expected an error but got none")
+		}
+	})
+
+	t.Run("falls back to the image package for other formats", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 1, 1)), "png"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if _, format, err := Decode(&buf); err != nil || format != "png" {
+			t.Errorf("Expected PNG to be decoded but got format %q and error %v", format, err)
+		}
+	})
+}
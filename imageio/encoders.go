@@ -0,0 +1,62 @@
+package imageio
+
+import (
+	"image"
+	"io"
+	"strings"
+	"sync"
+)
+
+// EncodeFunc encodes an image produced by the convolution pipeline.
+type EncodeFunc func(w io.Writer, img image.Image) error
+
+type encoder struct {
+	name       string
+	extensions []string
+	encode     EncodeFunc
+}
+
+var encodersMutex sync.RWMutex
+var encoders []encoder
+
+// RegisterEncoder registers an encoder for an image format such as WebP or
+// TIFF for use by Encode and Save. Files whose extension, such as ".webp",
+// is one of the given extensions are saved in the format, and registered
+// encoders take precedence over the built-in ones for the same format name.
+func RegisterEncoder(name string, extensions []string, encode EncodeFunc) {
+	encodersMutex.Lock()
+	defer encodersMutex.Unlock()
+
+	lowered := make([]string, len(extensions))
+	for i, ext := range extensions {
+		lowered[i] = strings.ToLower(ext)
+	}
+
+	encoders = append(encoders, encoder{name: name, extensions: lowered, encode: encode})
+}
+
+func lookupEncoder(format string) EncodeFunc {
+	encodersMutex.RLock()
+	defer encodersMutex.RUnlock()
+
+	for _, e := range encoders {
+		if e.name == format {
+			return e.encode
+		}
+	}
+	return nil
+}
+
+func registeredFormatForExt(ext string) (string, bool) {
+	encodersMutex.RLock()
+	defer encodersMutex.RUnlock()
+
+	for _, e := range encoders {
+		for _, candidate := range e.extensions {
+			if candidate == ext {
+				return e.name, true
+			}
+		}
+	}
+	return "", false
+}
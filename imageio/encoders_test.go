@@ -0,0 +1,59 @@
+package imageio
+
+import (
+	"bytes"
+	"image"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegisterEncoder(t *testing.T) {
+	var encoded []image.Image
+
+	RegisterEncoder("test-encoded", []string{".TSTE"}, func(w io.Writer, img image.Image) error {
+		encoded = append(encoded, img)
+		_, err := w.Write([]byte("encoded"))
+		return err
+	})
+
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+
+	t.Run("formats are chosen by registered extension", func(t *testing.T) {
+		format, ok := FormatForPath("output.tste")
+		if !ok || format != "test-encoded" {
+			t.Errorf("Expected format %q but was %q (%v)", "test-encoded", format, ok)
+		}
+	})
+
+	t.Run("encode uses the registered encoder", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+
+		if err := Encode(buf, img, "test-encoded"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if expected, actual := "encoded", buf.String(); expected != actual {
+			t.Errorf("Expected output %q but was %q", expected, actual)
+		}
+	})
+
+	t.Run("save uses the registered encoder", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "imageio-encoders")
+		if err != nil {
+			t.Fatalf("Error creating temporary directory: %v", err)
+		}
+		defer os.RemoveAll(dir)
+
+		encoded = nil
+		path := filepath.Join(dir, "output.tste")
+
+		if err := Save(path, img); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(encoded) != 1 || encoded[0] != image.Image(img) {
+			t.Errorf("Expected the image to be passed to the encoder once")
+		}
+	})
+}
@@ -0,0 +1,371 @@
+package imageio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/mandykoh/convolver"
+	"image"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	image.RegisterFormat("pfm", "PF", decodePFMImage, decodePFMConfig)
+	image.RegisterFormat("pfm", "Pf", decodePFMImage, decodePFMConfig)
+	image.RegisterFormat("hdr", "#?RADIANCE", decodeHDRImage, decodeHDRConfig)
+	image.RegisterFormat("hdr", "#?RGBE", decodeHDRImage, decodeHDRConfig)
+}
+
+// DecodeHDR decodes a Radiance RGBE (.hdr) image into linear float values.
+// Only the standard top-to-bottom, left-to-right orientation is supported.
+func DecodeHDR(r io.Reader) (*convolver.FloatImage, error) {
+	br := bufio.NewReader(r)
+
+	width, height, err := readHDRHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	img := convolver.NewFloatImage(image.Rect(0, 0, width, height))
+	scanline := make([]byte, width*4)
+
+	for y := 0; y < height; y++ {
+		if err := readHDRScanline(br, scanline); err != nil {
+			return nil, err
+		}
+
+		for x := 0; x < width; x++ {
+			r, g, b := rgbeToFloat(scanline[x*4 : x*4+4])
+			img.SetRGBA(x, y, r, g, b, 1)
+		}
+	}
+
+	return img, nil
+}
+
+// DecodePFM decodes a Portable Float Map (.pfm) image, in either its colour
+// or greyscale variant, into linear float values.
+func DecodePFM(r io.Reader) (*convolver.FloatImage, error) {
+	br := bufio.NewReader(r)
+
+	channels, width, height, order, err := readPFMHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	img := convolver.NewFloatImage(image.Rect(0, 0, width, height))
+	row := make([]float32, width*channels)
+
+	// Rows are stored from the bottom of the image to the top
+	for y := height - 1; y >= 0; y-- {
+		if err := binary.Read(br, order, row); err != nil {
+			return nil, fmt.Errorf("error reading PFM data: %v", err)
+		}
+
+		for x := 0; x < width; x++ {
+			if channels == 1 {
+				v := row[x]
+				img.SetRGBA(x, y, v, v, v, 1)
+			} else {
+				img.SetRGBA(x, y, row[x*3], row[x*3+1], row[x*3+2], 1)
+			}
+		}
+	}
+
+	return img, nil
+}
+
+// EncodeHDR encodes an image as a run-length encoded Radiance RGBE (.hdr)
+// image. Images narrower than 8 or wider than 32767 pixels, which the encoding
+// doesn't allow, are written without it. Alpha is discarded.
+func EncodeHDR(w io.Writer, img *convolver.FloatImage) error {
+	bw := bufio.NewWriter(w)
+
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	fmt.Fprintf(bw, "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y %d +X %d\n", height, width)
+
+	rle := width >= 8 && width <= 0x7fff
+	scanline := make([]byte, width*4)
+
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, _ := img.RGBAAt(img.Rect.Min.X+x, y)
+			floatToRGBE(scanline[x*4:x*4+4], r, g, b)
+		}
+
+		if !rle {
+			bw.Write(scanline)
+			continue
+		}
+
+		bw.Write([]byte{2, 2, byte(width >> 8), byte(width)})
+
+		for c := 0; c < 4; c++ {
+			writeHDRChannel(bw, scanline, c, width)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// hdrMinRun is the shortest run of equal values which writeHDRChannel encodes
+// as a run rather than including in a literal run.
+const hdrMinRun = 4
+
+// writeHDRChannel writes one channel of an RGBE scanline in the new
+// run-length encoding: runs of at least hdrMinRun equal bytes are written as a
+// count above 128 followed by the byte, and the bytes between them as literal
+// runs of up to 128 bytes preceded by their count.
+func writeHDRChannel(bw *bufio.Writer, scanline []byte, c int, width int) {
+	value := func(x int) byte {
+		return scanline[x*4+c]
+	}
+
+	writeLiterals := func(from, to int) {
+		for from < to {
+			n := to - from
+			if n > 128 {
+				n = 128
+			}
+
+			bw.WriteByte(byte(n))
+			for i := 0; i < n; i++ {
+				bw.WriteByte(value(from + i))
+			}
+
+			from += n
+		}
+	}
+
+	literalStart := 0
+
+	for x := 0; x < width; {
+		run := 1
+		for x+run < width && run < 127 && value(x+run) == value(x) {
+			run++
+		}
+
+		if run >= hdrMinRun {
+			writeLiterals(literalStart, x)
+			bw.Write([]byte{byte(128 + run), value(x)})
+			literalStart = x + run
+		}
+
+		x += run
+	}
+
+	writeLiterals(literalStart, width)
+}
+
+// EncodePFM encodes an image as a colour Portable Float Map (.pfm) image in
+// little endian byte order. Alpha is discarded.
+func EncodePFM(w io.Writer, img *convolver.FloatImage) error {
+	bw := bufio.NewWriter(w)
+
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	fmt.Fprintf(bw, "PF\n%d %d\n-1.0\n", width, height)
+
+	row := make([]float32, width*3)
+
+	for y := img.Rect.Max.Y - 1; y >= img.Rect.Min.Y; y-- {
+		for x := 0; x < width; x++ {
+			row[x*3], row[x*3+1], row[x*3+2], _ = img.RGBAAt(img.Rect.Min.X+x, y)
+		}
+
+		if err := binary.Write(bw, binary.LittleEndian, row); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+func decodeHDRConfig(r io.Reader) (image.Config, error) {
+	width, height, err := readHDRHeader(bufio.NewReader(r))
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: (&convolver.FloatImage{}).ColorModel(), Width: width, Height: height}, nil
+}
+
+func decodeHDRImage(r io.Reader) (image.Image, error) {
+	return DecodeHDR(r)
+}
+
+func decodePFMConfig(r io.Reader) (image.Config, error) {
+	_, width, height, _, err := readPFMHeader(bufio.NewReader(r))
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: (&convolver.FloatImage{}).ColorModel(), Width: width, Height: height}, nil
+}
+
+func decodePFMImage(r io.Reader) (image.Image, error) {
+	return DecodePFM(r)
+}
+
+func floatToRGBE(dst []byte, r, g, b float32) {
+	v := math.Max(float64(r), math.Max(float64(g), float64(b)))
+	if v < 1e-32 {
+		dst[0], dst[1], dst[2], dst[3] = 0, 0, 0, 0
+		return
+	}
+
+	mantissa, exponent := math.Frexp(v)
+	scale := mantissa * 256 / v
+
+	dst[0] = byte(math.Max(0, float64(r)*scale))
+	dst[1] = byte(math.Max(0, float64(g)*scale))
+	dst[2] = byte(math.Max(0, float64(b)*scale))
+	dst[3] = byte(exponent + 128)
+}
+
+func readHDRHeader(br *bufio.Reader) (width, height int, err error) {
+	magic, err := br.ReadString('\n')
+	if err != nil || !strings.HasPrefix(magic, "#?") {
+		return 0, 0, errors.New("invalid Radiance HDR header")
+	}
+
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return 0, 0, fmt.Errorf("error reading Radiance HDR header: %v", err)
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, "FORMAT=") && line != "FORMAT=32-bit_rle_rgbe" {
+			return 0, 0, fmt.Errorf("unsupported Radiance HDR format %q", line[len("FORMAT="):])
+		}
+	}
+
+	resolution, err := br.ReadString('\n')
+	if err != nil {
+		return 0, 0, fmt.Errorf("error reading Radiance HDR resolution: %v", err)
+	}
+
+	fields := strings.Fields(resolution)
+	if len(fields) != 4 || fields[0] != "-Y" || fields[2] != "+X" {
+		return 0, 0, fmt.Errorf("unsupported Radiance HDR orientation %q", strings.TrimSpace(resolution))
+	}
+
+	height, errH := strconv.Atoi(fields[1])
+	width, errW := strconv.Atoi(fields[3])
+	if errH != nil || errW != nil {
+		return 0, 0, fmt.Errorf("invalid Radiance HDR resolution %q", strings.TrimSpace(resolution))
+	}
+	if err := checkDimensions("Radiance HDR", width, height); err != nil {
+		return 0, 0, err
+	}
+
+	return width, height, nil
+}
+
+func readHDRScanline(br *bufio.Reader, scanline []byte) error {
+	width := len(scanline) / 4
+
+	if _, err := io.ReadFull(br, scanline[:4]); err != nil {
+		return fmt.Errorf("error reading Radiance HDR data: %v", err)
+	}
+
+	if width < 8 || width > 0x7fff || scanline[0] != 2 || scanline[1] != 2 || scanline[2]&0x80 != 0 {
+		// Flat scanline
+		if _, err := io.ReadFull(br, scanline[4:]); err != nil {
+			return fmt.Errorf("error reading Radiance HDR data: %v", err)
+		}
+		return nil
+	}
+
+	if encodedWidth := int(scanline[2])<<8 | int(scanline[3]); encodedWidth != width {
+		return fmt.Errorf("Radiance HDR scanline width %d doesn't match image width %d", encodedWidth, width)
+	}
+
+	for c := 0; c < 4; c++ {
+		for x := 0; x < width; {
+			count, err := br.ReadByte()
+			if err != nil {
+				return fmt.Errorf("error reading Radiance HDR data: %v", err)
+			}
+
+			if count > 128 {
+				n := int(count) - 128
+				v, err := br.ReadByte()
+				if err != nil {
+					return fmt.Errorf("error reading Radiance HDR data: %v", err)
+				}
+				if x+n > width {
+					return errors.New("Radiance HDR run overflows scanline")
+				}
+				for i := 0; i < n; i++ {
+					scanline[(x+i)*4+c] = v
+				}
+				x += n
+
+			} else {
+				n := int(count)
+				if n == 0 || x+n > width {
+					return errors.New("invalid Radiance HDR run length")
+				}
+				for i := 0; i < n; i++ {
+					v, err := br.ReadByte()
+					if err != nil {
+						return fmt.Errorf("error reading Radiance HDR data: %v", err)
+					}
+					scanline[(x+i)*4+c] = v
+				}
+				x += n
+			}
+		}
+	}
+
+	return nil
+}
+
+func readPFMHeader(br *bufio.Reader) (channels, width, height int, order binary.ByteOrder, err error) {
+	var magic string
+	var scale float64
+
+	if _, err := fmt.Fscan(br, &magic, &width, &height, &scale); err != nil {
+		return 0, 0, 0, nil, fmt.Errorf("invalid PFM header: %v", err)
+	}
+
+	// A single whitespace character separates the header from the data
+	if _, err := br.ReadByte(); err != nil {
+		return 0, 0, 0, nil, fmt.Errorf("invalid PFM header: %v", err)
+	}
+
+	switch magic {
+	case "PF":
+		channels = 3
+	case "Pf":
+		channels = 1
+	default:
+		return 0, 0, 0, nil, fmt.Errorf("invalid PFM type %q", magic)
+	}
+
+	if err := checkDimensions("PFM", width, height); err != nil {
+		return 0, 0, 0, nil, err
+	}
+
+	order = binary.BigEndian
+	if scale < 0 {
+		order = binary.LittleEndian
+	}
+
+	return channels, width, height, order, nil
+}
+
+func rgbeToFloat(rgbe []byte) (r, g, b float32) {
+	if rgbe[3] == 0 {
+		return 0, 0, 0
+	}
+
+	f := math.Ldexp(1, int(rgbe[3])-(128+8))
+	return float32((float64(rgbe[0]) + 0.5) * f), float32((float64(rgbe[1]) + 0.5) * f), float32((float64(rgbe[2]) + 0.5) * f)
+}
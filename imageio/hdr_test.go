@@ -0,0 +1,179 @@
+package imageio
+
+import (
+	"bytes"
+	"github.com/mandykoh/convolver"
+	"image"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestHDRFormats(t *testing.T) {
+	testImage := func(width int) *convolver.FloatImage {
+		img := convolver.NewFloatImage(image.Rect(0, 0, width, 3))
+		for i := img.Rect.Min.Y; i < img.Rect.Max.Y; i++ {
+			for j := img.Rect.Min.X; j < img.Rect.Max.X; j++ {
+				img.SetRGBA(j, i, float32(j)*1.5, float32(i)*0.25, float32(i*j)/7, 1)
+			}
+		}
+		return img
+	}
+
+	cases := []struct {
+		Format    string
+		Tolerance float64
+	}{
+		{Format: "pfm", Tolerance: 0},
+		{Format: "hdr", Tolerance: 1.0 / 128},
+	}
+
+	for _, c := range cases {
+
+		t.Run(c.Format+" round trips values above 1", func(t *testing.T) {
+			for _, width := range []int{19, 5} {
+				src := testImage(width)
+
+				var buf bytes.Buffer
+				if err := Encode(&buf, src, c.Format); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+
+				decoded, format, err := Decode(&buf)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if expected, actual := c.Format, format; expected != actual {
+					t.Errorf("Expected format %q but was %q", expected, actual)
+				}
+
+				result := decoded.(*convolver.FloatImage)
+				if expected, actual := src.Rect, result.Rect; expected != actual {
+					t.Fatalf("Expected bounds %v but were %v", expected, actual)
+				}
+
+				for i := src.Rect.Min.Y; i < src.Rect.Max.Y; i++ {
+					for j := src.Rect.Min.X; j < src.Rect.Max.X; j++ {
+						er, eg, eb, _ := src.RGBAAt(j, i)
+						ar, ag, ab, aa := result.RGBAAt(j, i)
+
+						// RGBE shares an exponent between channels, so precision is
+						// relative to the brightest channel
+						brightest := math.Max(1, math.Max(float64(er), math.Max(float64(eg), float64(eb))))
+
+						for _, pair := range [][2]float32{{er, ar}, {eg, ag}, {eb, ab}} {
+							if diff := math.Abs(float64(pair[0] - pair[1])); diff > c.Tolerance*brightest {
+								t.Fatalf("Expected value at %d,%d to be %v but was %v", j, i, pair[0], pair[1])
+							}
+						}
+						if aa != 1 {
+							t.Fatalf("Expected alpha at %d,%d to be 1 but was %v", j, i, aa)
+						}
+					}
+				}
+			}
+		})
+	}
+
+	t.Run("decodes greyscale big endian PFM", func(t *testing.T) {
+		data := append([]byte("Pf\n2 1\n1.0\n"), 0x40, 0x00, 0x00, 0x00, 0x3f, 0x00, 0x00, 0x00)
+
+		result, err := DecodePFM(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if r, g, b, _ := result.RGBAAt(0, 0); r != 2 || g != 2 || b != 2 {
+			t.Errorf("Expected first pixel to be 2 but was %v, %v, %v", r, g, b)
+		}
+		if r, _, _, _ := result.RGBAAt(1, 0); r != 0.5 {
+			t.Errorf("Expected second pixel to be 0.5 but was %v", r)
+		}
+	})
+
+	t.Run("decodes run-length encoded HDR runs", func(t *testing.T) {
+		var data bytes.Buffer
+		data.WriteString("#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y 1 +X 8\n")
+		data.Write([]byte{2, 2, 0, 8})
+		for _, v := range []byte{128, 64, 0, 129} {
+			data.Write([]byte{128 + 8, v})
+		}
+
+		result, err := DecodeHDR(&data)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		for j := 0; j < 8; j++ {
+			if r, g, b, _ := result.RGBAAt(j, 0); math.Abs(float64(r)-1.0) > 0.01 || math.Abs(float64(g)-0.5) > 0.01 || b > 0.01 {
+				t.Errorf("Expected pixel %d to be about 1, 0.5, 0 but was %v, %v, %v", j, r, g, b)
+			}
+		}
+	})
+
+	t.Run("encodes repeated HDR values as runs", func(t *testing.T) {
+		src := convolver.NewFloatImage(image.Rect(0, 0, 300, 2))
+		for i := src.Rect.Min.Y; i < src.Rect.Max.Y; i++ {
+			for j := src.Rect.Min.X; j < src.Rect.Max.X; j++ {
+				v := float32(2)
+				if j%100 == 50 {
+					v = float32(j) / 100
+				}
+				src.SetRGBA(j, i, v, v/2, 0, 1)
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := EncodeHDR(&buf, src); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		encoded := buf.Len()
+
+		result, err := DecodeHDR(&buf)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if encoded > 300 {
+			t.Errorf("Expected runs to encode in at most 300 bytes but took %d", encoded)
+		}
+
+		for i := src.Rect.Min.Y; i < src.Rect.Max.Y; i++ {
+			for j := src.Rect.Min.X; j < src.Rect.Max.X; j++ {
+				er, eg, _, _ := src.RGBAAt(j, i)
+				if ar, ag, _, _ := result.RGBAAt(j, i); math.Abs(float64(er-ar)) > 0.02 || math.Abs(float64(eg-ag)) > 0.02 {
+					t.Fatalf("Expected pixel %d,%d to be %v, %v but was %v, %v", j, i, er, eg, ar, ag)
+				}
+			}
+		}
+	})
+
+	t.Run("rejects unsupported HDR orientations", func(t *testing.T) {
+		_, err := DecodeHDR(strings.NewReader("#?RADIANCE\n\n+Y 1 +X 1\n\x00\x00\x00\x00"))
+		if err == nil {
+			t.Errorf("Expected an error but got none")
+		}
+	})
+	t.Run("rejects dimensions beyond the pixel limit before allocating", func(t *testing.T) {
+		headers := map[string]string{
+			"huge PFM":          "PF\n100000 100000\n-1.0\n",
+			"overflowing PFM":   "PF\n9223372036854775807 9223372036854775807\n-1.0\n",
+			"huge HDR":          "#?RADIANCE\n\n-Y 100000 +X 100000\n",
+			"overflowing HDR":   "#?RADIANCE\n\n-Y 4611686018427387904 +X 4\n",
+			"zero width PFM":    "Pf\n0 5\n-1.0\n",
+			"negative size HDR": "#?RADIANCE\n\n-Y -1 +X 5\n",
+		}
+
+		for name, header := range headers {
+			var err error
+			if strings.HasPrefix(header, "#?") {
+				_, err = DecodeHDR(strings.NewReader(header))
+			} else {
+				_, err = DecodePFM(strings.NewReader(header))
+			}
+
+			if err == nil {
+				t.Errorf("Expected an error for %s header but got none", name)
+			}
+		}
+	})
+}
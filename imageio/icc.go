@@ -0,0 +1,228 @@
+package imageio
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/mandykoh/prism/meta/autometa"
+	"github.com/mandykoh/prism/srgb"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// Metadata holds properties of a decoded image which should be carried
+// through to an encoded result, so that filtering doesn't silently change how
+// the image's colours are interpreted.
+type Metadata struct {
+	// ICCProfile is the raw ICC profile embedded in the image, or nil if there
+	// was none.
+	ICCProfile []byte
+
+	// curve is set when pixel values have been re-encoded from the profile's
+	// transfer curve to the sRGB one on decoding.
+	curve *toneCurve
+}
+
+// DecodeWithMetadata decodes an image as Decode does, additionally extracting
+// any embedded ICC profile from PNG and JPEG images.
+//
+// Pixel values are left in the colour space described by the profile. Only
+// the profile's tone reproduction curve matters for filtering: operations are
+// performed in linear light, and weighted sums of linear values are the same
+// whatever the primaries, so the colorant tags are not needed. Profiles which
+// share the sRGB transfer curve (as sRGB and Display P3 do) are therefore
+// exact as they are. For RGB profiles whose rTRC, gTRC and bTRC tags give some
+// other curve common to all three channels, such as the pure 2.2 gamma of
+// Adobe RGB or a sampled table, pixel values are re-encoded with the sRGB
+// transfer curve so that they are linearised correctly, and
+// EncodeWithMetadata reverses this. Profiles with differing per-channel
+// curves, or described by lookup tables rather than curves, are passed
+// through unchanged.
+func DecodeWithMetadata(r io.Reader) (image.Image, string, *Metadata, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	md := &Metadata{}
+
+	if imgMeta, _, err := autometa.Load(bytes.NewReader(data)); err == nil {
+		md.ICCProfile, _ = imgMeta.ICCProfileData()
+
+		if curve, ok := toneCurveFromICCProfile(md.ICCProfile); ok {
+			md.curve = curve
+		}
+	}
+
+	img, format, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	if md.curve != nil {
+		img = transcode(img, md.curve.from16Bit, srgb.To16Bit)
+	}
+
+	return img, format, md, nil
+}
+
+// EncodeWithMetadata encodes an image as Encode does, embedding the ICC
+// profile from md (if any) in the output.
+func EncodeWithMetadata(w io.Writer, img image.Image, format string, md *Metadata) error {
+	if md == nil || md.ICCProfile == nil {
+		return Encode(w, img, format)
+	}
+
+	if md.curve != nil {
+		img = transcode(img, srgb.From16Bit, md.curve.to16Bit)
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, img, format); err != nil {
+		return err
+	}
+
+	var encoded []byte
+	var err error
+
+	switch format {
+	case "png":
+		encoded, err = embedICCProfilePNG(buf.Bytes(), md.ICCProfile)
+	case "jpeg":
+		encoded, err = embedICCProfileJPEG(buf.Bytes(), md.ICCProfile)
+	default:
+		return fmt.Errorf("embedding ICC profiles in %s images is not supported", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(encoded)
+	return err
+}
+
+// LoadWithMetadata reads and decodes the image file at path as
+// DecodeWithMetadata does.
+func LoadWithMetadata(path string) (image.Image, *Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	img, _, md, err := DecodeWithMetadata(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error decoding %s: %v", path, err)
+	}
+
+	return img, md, nil
+}
+
+// SaveWithMetadata encodes an image to the file at path as EncodeWithMetadata
+// does, using the format implied by the file's extension.
+func SaveWithMetadata(path string, img image.Image, md *Metadata) error {
+	format, ok := FormatForPath(path)
+	if !ok || format == "gif" {
+		return fmt.Errorf("unsupported output format for %s", path)
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeWithMetadata(&buf, img, format, md); err != nil {
+		return fmt.Errorf("error encoding %s: %v", path, err)
+	}
+
+	return writeFile(path, buf.Bytes())
+}
+
+func embedICCProfileJPEG(encoded []byte, profile []byte) ([]byte, error) {
+	const maxChunkLength = 0xffff - 2 - 14
+
+	if len(encoded) < 2 || encoded[0] != 0xff || encoded[1] != 0xd8 {
+		return nil, errors.New("invalid JPEG data")
+	}
+
+	chunkCount := (len(profile) + maxChunkLength - 1) / maxChunkLength
+	if chunkCount > 255 {
+		return nil, errors.New("ICC profile is too large to embed")
+	}
+
+	var buf bytes.Buffer
+	buf.Write(encoded[:2])
+
+	for i := 0; i < chunkCount; i++ {
+		chunk := profile[i*maxChunkLength:]
+		if len(chunk) > maxChunkLength {
+			chunk = chunk[:maxChunkLength]
+		}
+
+		buf.Write([]byte{0xff, 0xe2})
+		_ = binary.Write(&buf, binary.BigEndian, uint16(2+14+len(chunk)))
+		buf.WriteString("ICC_PROFILE\x00")
+		buf.Write([]byte{byte(i + 1), byte(chunkCount)})
+		buf.Write(chunk)
+	}
+
+	buf.Write(encoded[2:])
+
+	return buf.Bytes(), nil
+}
+
+func embedICCProfilePNG(encoded []byte, profile []byte) ([]byte, error) {
+	// The iCCP chunk must precede the image data, so it's placed directly
+	// after the signature and header chunk.
+	const headerEnd = 8 + 4 + 4 + 13 + 4
+
+	if len(encoded) < headerEnd || string(encoded[12:16]) != "IHDR" {
+		return nil, errors.New("invalid PNG data")
+	}
+
+	var chunkData bytes.Buffer
+	chunkData.WriteString("ICC Profile\x00\x00")
+
+	zw := zlib.NewWriter(&chunkData)
+	if _, err := zw.Write(profile); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(encoded[:headerEnd])
+
+	_ = binary.Write(&buf, binary.BigEndian, uint32(chunkData.Len()))
+	chunk := append([]byte("iCCP"), chunkData.Bytes()...)
+	buf.Write(chunk)
+	_ = binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+
+	buf.Write(encoded[headerEnd:])
+
+	return buf.Bytes(), nil
+}
+
+// transcode re-encodes an image's colour values from one transfer curve to
+// another, preserving alpha.
+func transcode(img image.Image, decode func(uint16) float32, encode func(float32) uint16) *image.NRGBA64 {
+	bounds := img.Bounds()
+	result := image.NewNRGBA64(bounds)
+
+	for i := bounds.Min.Y; i < bounds.Max.Y; i++ {
+		for j := bounds.Min.X; j < bounds.Max.X; j++ {
+			c := color.NRGBA64Model.Convert(img.At(j, i)).(color.NRGBA64)
+			result.SetNRGBA64(j, i, color.NRGBA64{
+				R: encode(decode(c.R)),
+				G: encode(decode(c.G)),
+				B: encode(decode(c.B)),
+				A: c.A,
+			})
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,224 @@
+package imageio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/mandykoh/prism/adobergb"
+	"github.com/mandykoh/prism/srgb"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestEncodeWithMetadata(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+
+	for _, format := range []string{"png", "jpeg"} {
+
+		t.Run("round trips an embedded profile in "+format, func(t *testing.T) {
+			md := &Metadata{ICCProfile: testICCProfile("Display P3", testSRGBCurve())}
+
+			var buf bytes.Buffer
+			if err := EncodeWithMetadata(&buf, img, format, md); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			result, decodedFormat, decodedMD, err := DecodeWithMetadata(&buf)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if expected, actual := format, decodedFormat; expected != actual {
+				t.Errorf("Expected format %q but was %q", expected, actual)
+			}
+			if !bytes.Equal(md.ICCProfile, decodedMD.ICCProfile) {
+				t.Errorf("Expected ICC profile to be preserved but got %d bytes", len(decodedMD.ICCProfile))
+			}
+			if expected, actual := img.Bounds(), result.Bounds(); expected != actual {
+				t.Errorf("Expected bounds %v but were %v", expected, actual)
+			}
+		})
+	}
+
+	t.Run("splits large profiles across JPEG segments", func(t *testing.T) {
+		profile := testICCProfile(string(bytes.Repeat([]byte("x"), 100000)), nil)
+
+		var buf bytes.Buffer
+		if err := EncodeWithMetadata(&buf, img, "jpeg", &Metadata{ICCProfile: profile}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		_, _, md, err := DecodeWithMetadata(&buf)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !bytes.Equal(profile, md.ICCProfile) {
+			t.Errorf("Expected ICC profile to be preserved but got %d bytes", len(md.ICCProfile))
+		}
+	})
+
+	t.Run("encodes without a profile", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := EncodeWithMetadata(&buf, img, "png", nil); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		_, _, md, err := DecodeWithMetadata(&buf)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if md.ICCProfile != nil {
+			t.Errorf("Expected no ICC profile but got %d bytes", len(md.ICCProfile))
+		}
+	})
+}
+
+func TestDecodeWithMetadata(t *testing.T) {
+
+	t.Run("re-encodes values from the profile's tone curve to the sRGB one", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+		img.SetNRGBA(0, 0, color.NRGBA{R: 64, G: 128, B: 192, A: 255})
+
+		// The description is deliberately not one which identifies the
+		// profile, so that only the curve is used.
+		var buf bytes.Buffer
+		if err := EncodeWithMetadata(&buf, img, "png", &Metadata{ICCProfile: testICCProfile("Custom", testGammaCurve(563.0/256))}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		decoded, _, md, err := DecodeWithMetadata(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if r, _, _, _ := decoded.At(0, 0).RGBA(); r>>8 == 64 {
+			t.Errorf("Expected pixel values to be re-encoded but they were unchanged")
+		}
+
+		var reencoded bytes.Buffer
+		if err := EncodeWithMetadata(&reencoded, decoded, "png", md); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		result, _, err := Decode(&reencoded)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if expected, actual := img.NRGBAAt(0, 0), color.NRGBAModel.Convert(result.At(0, 0)).(color.NRGBA); expected != actual {
+			t.Errorf("Expected pixel to round trip as %+v but was %+v", expected, actual)
+		}
+		if r, _, _, _ := decoded.At(0, 0).RGBA(); math.Abs(float64(srgb.From16Bit(uint16(r)))-float64(adobergb.From16Bit(64*257))) > 1e-3 {
+			t.Errorf("Expected re-encoded value to have the same linear value as in Adobe RGB")
+		}
+	})
+
+	t.Run("leaves values unchanged for profiles with the sRGB curve", func(t *testing.T) {
+		for _, trc := range [][]byte{testSRGBCurve(), nil} {
+			if _, ok := toneCurveFromICCProfile(testICCProfile("Display P3", trc)); ok {
+				t.Errorf("Expected no re-encoding for curve %v", trc)
+			}
+		}
+	})
+
+	t.Run("leaves values unchanged for profiles with differing channel curves", func(t *testing.T) {
+		profile := testICCProfile("Custom", testGammaCurve(1.8))
+		copy(profile[len(profile)-2:], []byte{2, 0})
+
+		if _, ok := toneCurveFromICCProfile(profile); ok {
+			t.Errorf("Expected no re-encoding when channel curves differ")
+		}
+	})
+
+	t.Run("reads sampled curves", func(t *testing.T) {
+		table := append([]byte("curv"), 0, 0, 0, 0, 0, 0, 0, 3)
+		table = append(table, 0, 0, 0x40, 0, 0xff, 0xff)
+
+		curve, ok := toneCurveFromICCProfile(testICCProfile("Custom", table))
+		if !ok {
+			t.Fatalf("Expected sampled curve to be used")
+		}
+
+		if expected, actual := float32(0x4000)/65535, curve.from16Bit(0x8000); math.Abs(float64(expected-actual)) > 1e-4 {
+			t.Errorf("Expected midpoint to be %v but was %v", expected, actual)
+		}
+		if expected, actual := uint16(0x8000), curve.to16Bit(curve.from16Bit(0x8000)); expected != actual {
+			t.Errorf("Expected inverse to give %v but was %v", expected, actual)
+		}
+	})
+}
+
+// testICCProfile builds a minimal version 2 ICC profile containing a
+// description tag and, if trc is not nil, the same tone reproduction curve
+// for all three channels.
+func testICCProfile(description string, trc []byte) []byte {
+	var desc bytes.Buffer
+	desc.WriteString("desc")
+	_ = binary.Write(&desc, binary.BigEndian, uint32(0))
+	_ = binary.Write(&desc, binary.BigEndian, uint32(len(description)+1))
+	desc.WriteString(description)
+	desc.WriteByte(0)
+
+	tags := []struct {
+		Signature string
+		Data      []byte
+	}{{Signature: "desc", Data: desc.Bytes()}}
+
+	if trc != nil {
+		for _, sig := range []string{"rTRC", "gTRC", "bTRC"} {
+			tags = append(tags, struct {
+				Signature string
+				Data      []byte
+			}{Signature: sig, Data: trc})
+		}
+	}
+
+	tagDataOffset := 128 + 4 + 12*len(tags)
+
+	var tagTable, tagData bytes.Buffer
+	_ = binary.Write(&tagTable, binary.BigEndian, uint32(len(tags)))
+	for _, tag := range tags {
+		tagTable.WriteString(tag.Signature)
+		_ = binary.Write(&tagTable, binary.BigEndian, uint32(tagDataOffset+tagData.Len()))
+		_ = binary.Write(&tagTable, binary.BigEndian, uint32(len(tag.Data)))
+		tagData.Write(tag.Data)
+	}
+
+	header := make([]byte, 128)
+	binary.BigEndian.PutUint32(header[0:], uint32(tagDataOffset+tagData.Len()))
+	header[8] = 2
+	copy(header[12:], "mntr")
+	copy(header[16:], "RGB ")
+	copy(header[20:], "XYZ ")
+	copy(header[36:], "acsp")
+
+	var profile bytes.Buffer
+	profile.Write(header)
+	profile.Write(tagTable.Bytes())
+	profile.Write(tagData.Bytes())
+
+	return profile.Bytes()
+}
+
+// testGammaCurve returns an ICC curveType tag for a pure gamma curve.
+func testGammaCurve(gamma float64) []byte {
+	data := append([]byte("curv"), 0, 0, 0, 0, 0, 0, 0, 1)
+	return append(data, byte(uint16(gamma*256)>>8), byte(uint16(gamma*256)))
+}
+
+// testSRGBCurve returns an ICC parametricCurveType tag for the sRGB curve.
+func testSRGBCurve() []byte {
+	data := append([]byte("para"), 0, 0, 0, 0, 0, 3, 0, 0)
+	for _, p := range []float64{2.4, 1 / 1.055, 0.055 / 1.055, 1 / 12.92, 0.04045} {
+		var buf [4]byte
+		binary.BigEndian.PutUint32(buf[:], uint32(int32(math.Round(p*65536))))
+		data = append(data, buf[:]...)
+	}
+	return data
+}
@@ -0,0 +1,184 @@
+package imageio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+// toneCurve is the transfer curve of an ICC profile, with lookup tables for
+// converting 16-bit encoded values to linear light and back.
+type toneCurve struct {
+	linear [65536]float32
+}
+
+// toneCurveFromICCProfile returns the tone reproduction curve shared by the
+// red, green and blue channels of an RGB ICC profile, read from its rTRC,
+// gTRC and bTRC tags. It returns false if the profile has no such tags, if
+// the channels have different curves, if a curve is of a form which isn't
+// supported or isn't increasing, or if the curve is indistinguishable from the
+// sRGB one, in which case pixel values can be used as they are.
+func toneCurveFromICCProfile(profile []byte) (*toneCurve, bool) {
+	const headerLength = 128
+
+	if len(profile) < headerLength+4 || string(profile[16:20]) != "RGB " {
+		return nil, false
+	}
+
+	tagCount := binary.BigEndian.Uint32(profile[headerLength:])
+	if uint64(tagCount)*12 > uint64(len(profile)-headerLength-4) {
+		return nil, false
+	}
+
+	tags := make(map[string][]byte, tagCount)
+	for i := uint32(0); i < tagCount; i++ {
+		entry := profile[headerLength+4+i*12:]
+		offset, size := binary.BigEndian.Uint32(entry[4:]), binary.BigEndian.Uint32(entry[8:])
+		if uint64(offset)+uint64(size) > uint64(len(profile)) {
+			return nil, false
+		}
+		tags[string(entry[:4])] = profile[offset : offset+size]
+	}
+
+	r, g, b := tags["rTRC"], tags["gTRC"], tags["bTRC"]
+	if r == nil || !bytes.Equal(r, g) || !bytes.Equal(r, b) {
+		return nil, false
+	}
+
+	curve, ok := parseICCCurve(r)
+	if !ok {
+		return nil, false
+	}
+
+	tc := &toneCurve{}
+	matchesSRGB := true
+
+	for i := range tc.linear {
+		v := float64(i) / 65535
+		tc.linear[i] = float32(curve(v))
+
+		if i > 0 && tc.linear[i] < tc.linear[i-1] {
+			return nil, false
+		}
+		if math.Abs(curve(v)-srgbToLinear(v)) > 1.0/4096 {
+			matchesSRGB = false
+		}
+	}
+	if matchesSRGB {
+		return nil, false
+	}
+
+	return tc, true
+}
+
+// from16Bit converts an encoded 16-bit value to linear light.
+func (c *toneCurve) from16Bit(v uint16) float32 {
+	return c.linear[v]
+}
+
+// to16Bit converts a linear light value to the nearest encoded 16-bit value.
+func (c *toneCurve) to16Bit(v float32) uint16 {
+	i := sort.Search(len(c.linear), func(i int) bool { return c.linear[i] >= v })
+
+	switch {
+	case i == 0:
+		return 0
+	case i == len(c.linear):
+		return math.MaxUint16
+	case v-c.linear[i-1] < c.linear[i]-v:
+		return uint16(i - 1)
+	}
+	return uint16(i)
+}
+
+// parseICCCurve returns the function described by an ICC curveType or
+// parametricCurveType tag, mapping encoded values between 0 and 1 to linear
+// light.
+func parseICCCurve(data []byte) (func(float64) float64, bool) {
+	if len(data) < 12 {
+		return nil, false
+	}
+
+	switch string(data[:4]) {
+	case "curv":
+		count := binary.BigEndian.Uint32(data[8:])
+
+		switch {
+		case count == 0:
+			return func(v float64) float64 { return v }, true
+
+		case count == 1 && len(data) >= 14:
+			gamma := float64(binary.BigEndian.Uint16(data[12:])) / 256
+			return func(v float64) float64 { return math.Pow(v, gamma) }, true
+
+		case count > 1 && uint64(len(data)) >= 12+uint64(count)*2:
+			table := make([]float64, count)
+			for i := range table {
+				table[i] = float64(binary.BigEndian.Uint16(data[12+i*2:])) / 65535
+			}
+			return func(v float64) float64 {
+				pos := v * float64(len(table)-1)
+				i := int(pos)
+				if i >= len(table)-1 {
+					return table[len(table)-1]
+				}
+				return table[i] + (table[i+1]-table[i])*(pos-float64(i))
+			}, true
+		}
+
+	case "para":
+		// Parameter counts for function types 0 to 4.
+		counts := []int{1, 3, 4, 5, 7}
+
+		funcType := int(binary.BigEndian.Uint16(data[8:]))
+		if funcType >= len(counts) || len(data) < 12+counts[funcType]*4 {
+			return nil, false
+		}
+
+		var p [7]float64
+		for i := 0; i < counts[funcType]; i++ {
+			p[i] = float64(int32(binary.BigEndian.Uint32(data[12+i*4:]))) / 65536
+		}
+		g, a, b, c, d, e, f := p[0], p[1], p[2], p[3], p[4], p[5], p[6]
+
+		pow := func(x float64) float64 {
+			if x <= 0 {
+				return 0
+			}
+			return math.Pow(x, g)
+		}
+
+		switch funcType {
+		case 0:
+			return pow, true
+		case 1:
+			return func(v float64) float64 { return pow(a*v + b) }, true
+		case 2:
+			return func(v float64) float64 { return pow(a*v+b) + c }, true
+		case 3:
+			return func(v float64) float64 {
+				if v >= d {
+					return pow(a*v + b)
+				}
+				return c * v
+			}, true
+		case 4:
+			return func(v float64) float64 {
+				if v >= d {
+					return pow(a*v+b) + e
+				}
+				return c*v + f
+			}, true
+		}
+	}
+
+	return nil, false
+}
+
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
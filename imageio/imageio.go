@@ -0,0 +1,217 @@
+// Package imageio provides helpers for loading and saving image files for use
+// with convolver, choosing formats by content when decoding and by file
+// extension when encoding.
+//
+// Decoding supports any format registered with the standard image package or
+// with RegisterDecoder, and encoding any format registered with
+// RegisterEncoder, so that separate modules can add formats such as camera
+// RAW files.
+// PNG, JPEG and GIF are always available; WebP images can be decoded by
+// importing golang.org/x/image/webp (or any other WebP decoder which
+// registers itself with image.RegisterFormat):
+//
+//	import _ "golang.org/x/image/webp"
+//
+// There is no pure Go WebP encoder, so WebP is not supported as an output
+// format unless an encoder is registered for it with RegisterEncoder.
+//
+// High dynamic range images in the Portable Float Map (.pfm) and Radiance RGBE
+// (.hdr) formats are decoded to *convolver.FloatImage, whose linear float
+// values are not clamped, and can be encoded from one without loss of range.
+//
+// Greyscale and colour Netpbm images (.pgm and .ppm), common in scientific
+// datasets, are decoded in both their binary and plain text variants, with 16
+// bits per sample where the file uses them. Images are encoded in the binary
+// variant, with 16-bit samples for images deeper than 8 bits per channel.
+//
+// Sequences of frames, such as the result of each pass of an iterative filter,
+// can be encoded as an animated GIF or PNG with EncodeAnimation.
+//
+// DecodeWithMetadata and EncodeWithMetadata carry embedded ICC profiles
+// through filtering. Only a profile's tone reproduction curves are
+// interpreted, since they alone affect linear-light filtering; profiles whose
+// channels have different curves, or which use lookup tables instead, are
+// passed through without adjusting pixel values.
+package imageio
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/mandykoh/convolver"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// JPEGQuality is the quality used when encoding JPEG images.
+const JPEGQuality = 95
+
+// MaxPixels limits the number of pixels in PFM, Radiance HDR and Netpbm
+// images decoded by this package. Files whose headers declare more are rejected
+// before any pixel memory is allocated, so that a small or truncated file
+// can't exhaust memory. The default allows 2^28 pixels, such as 16384x16384.
+var MaxPixels = 1 << 28
+
+// checkDimensions returns an error if an image of the given size in the named
+// format is empty or larger than MaxPixels.
+func checkDimensions(format string, width, height int) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid %s dimensions %dx%d", format, width, height)
+	}
+	if width > MaxPixels/height {
+		return fmt.Errorf("%s dimensions %dx%d exceed the limit of %d pixels", format, width, height, MaxPixels)
+	}
+	return nil
+}
+
+// ErrWebPDecoderMissing is returned when decoding a WebP image without a
+// WebP decoder having been registered.
+var ErrWebPDecoderMissing = errors.New("WebP decoding requires a registered decoder such as golang.org/x/image/webp")
+
+// Decode decodes an image in any format registered with RegisterDecoder or
+// with the image package, returning the image and the name of its format.
+func Decode(r io.Reader) (image.Image, string, error) {
+	br := bufio.NewReader(r)
+
+	if img, format, ok, err := decodeRegistered(br); ok {
+		return img, format, err
+	}
+
+	img, format, err := image.Decode(br)
+	if err == image.ErrFormat && isWebP(br) {
+		return nil, "", ErrWebPDecoderMissing
+	}
+
+	return img, format, err
+}
+
+// Encode encodes an image to the named format, which is one registered with
+// RegisterEncoder or one of "png", "jpeg", "pfm", "hdr", "pgm" or "ppm".
+// Images encoded as PFM or Radiance HDR are stored as linear float values;
+// images other than a *convolver.FloatImage are linearised first.
+func Encode(w io.Writer, img image.Image, format string) error {
+	if encode := lookupEncoder(format); encode != nil {
+		return encode(w, img)
+	}
+
+	switch format {
+	case "png":
+		return png.Encode(w, img)
+	case "jpeg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: JPEGQuality})
+	case "pfm":
+		return EncodePFM(w, convolver.FloatImageFromImage(img, 1))
+	case "hdr":
+		return EncodeHDR(w, convolver.FloatImageFromImage(img, 1))
+	case "pgm":
+		return EncodePGM(w, img, isDeepImage(img))
+	case "ppm":
+		return EncodePPM(w, img, isDeepImage(img))
+	case "webp":
+		return errors.New("encoding WebP images is not supported")
+	}
+
+	return fmt.Errorf("unsupported output format %q", format)
+}
+
+// CanEncode returns whether Encode supports the named format.
+func CanEncode(format string) bool {
+	if lookupEncoder(format) != nil {
+		return true
+	}
+
+	switch format {
+	case "png", "jpeg", "pfm", "hdr", "pgm", "ppm":
+		return true
+	}
+	return false
+}
+
+// FormatForPath returns the name of the image format implied by a file's
+// extension, or false if the extension is not recognised.
+func FormatForPath(path string) (string, bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+
+	if format, ok := registeredFormatForExt(ext); ok {
+		return format, true
+	}
+
+	switch ext {
+	case ".png":
+		return "png", true
+	case ".jpg", ".jpeg":
+		return "jpeg", true
+	case ".gif":
+		return "gif", true
+	case ".webp":
+		return "webp", true
+	case ".pfm":
+		return "pfm", true
+	case ".hdr":
+		return "hdr", true
+	case ".pgm":
+		return "pgm", true
+	case ".ppm", ".pnm":
+		return "ppm", true
+	}
+
+	return "", false
+}
+
+// Load reads and decodes the image file at path.
+func Load(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %s: %v", path, err)
+	}
+
+	return img, nil
+}
+
+// Save encodes an image to the file at path, using the format implied by the
+// file's extension.
+func Save(path string, img image.Image) error {
+	format, ok := FormatForPath(path)
+	if !ok || (format == "gif" && lookupEncoder(format) == nil) {
+		return fmt.Errorf("unsupported output format for %s", path)
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, img, format); err != nil {
+		return fmt.Errorf("error encoding %s: %v", path, err)
+	}
+
+	return writeFile(path, buf.Bytes())
+}
+
+func writeFile(path string, data []byte) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func isWebP(br *bufio.Reader) bool {
+	header, err := br.Peek(12)
+	return err == nil && string(header[0:4]) == "RIFF" && string(header[8:12]) == "WEBP"
+}
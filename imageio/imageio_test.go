@@ -0,0 +1,124 @@
+package imageio
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+
+	t.Run("decodes registered formats", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+		img.SetNRGBA(1, 0, color.NRGBA{R: 10, G: 20, B: 30, A: 255})
+
+		var buf bytes.Buffer
+		if err := Encode(&buf, img, "png"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		result, format, err := Decode(&buf)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if expected, actual := "png", format; expected != actual {
+			t.Errorf("Expected format %q but was %q", expected, actual)
+		}
+		if expected, actual := img.At(1, 0), result.At(1, 0); expected != actual {
+			t.Errorf("Expected pixel to be %v but was %v", expected, actual)
+		}
+	})
+
+	t.Run("reports a missing WebP decoder", func(t *testing.T) {
+		header := []byte("RIFF\x1a\x00\x00\x00WEBPVP8L\x0d\x00\x00\x00")
+
+		if _, _, err := Decode(bytes.NewReader(header)); err != ErrWebPDecoderMissing {
+			t.Errorf("Expected missing WebP decoder error but got %v", err)
+		}
+	})
+
+	t.Run("reports unknown formats", func(t *testing.T) {
+		if _, _, err := Decode(bytes.NewReader([]byte("not an image"))); err != image.ErrFormat {
+			t.Errorf("Expected format error but got %v", err)
+		}
+	})
+}
+
+func TestCanEncode(t *testing.T) {
+	for _, format := range []string{"png", "jpeg", "pfm", "hdr", "pgm", "ppm"} {
+		if !CanEncode(format) {
+			t.Errorf("Expected %s to be encodable", format)
+		}
+	}
+	for _, format := range []string{"gif", "webp", "bmp"} {
+		if CanEncode(format) {
+			t.Errorf("Expected %s not to be encodable", format)
+		}
+	}
+}
+
+func TestFormatForPath(t *testing.T) {
+	cases := []struct {
+		Path     string
+		Expected string
+	}{
+		{Path: "a.png", Expected: "png"},
+		{Path: "a.JPG", Expected: "jpeg"},
+		{Path: "dir/a.jpeg", Expected: "jpeg"},
+		{Path: "a.gif", Expected: "gif"},
+		{Path: "a.webp", Expected: "webp"},
+		{Path: "a.pgm", Expected: "pgm"},
+		{Path: "a.PNM", Expected: "ppm"},
+		{Path: "a.bmp", Expected: ""},
+	}
+
+	for _, c := range cases {
+		format, ok := FormatForPath(c.Path)
+		if expected, actual := c.Expected, format; expected != actual || ok != (c.Expected != "") {
+			t.Errorf("Expected format for %s to be %q but was %q", c.Path, expected, actual)
+		}
+	}
+}
+
+func TestSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imageio")
+	if err != nil {
+		t.Fatalf("Error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+
+	t.Run("round trips through a file", func(t *testing.T) {
+		path := filepath.Join(dir, "out.jpg")
+
+		if err := Save(path, img); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		result, err := Load(path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if expected, actual := img.Bounds(), result.Bounds(); expected != actual {
+			t.Errorf("Expected bounds to be %v but were %v", expected, actual)
+		}
+	})
+
+	t.Run("rejects unsupported output formats without creating a file", func(t *testing.T) {
+		for _, name := range []string{"out.webp", "out.gif", "out.bmp"} {
+			path := filepath.Join(dir, name)
+
+			if err := Save(path, img); err == nil {
+				t.Errorf("Expected an error saving %s but got none", name)
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("Expected %s not to have been created", name)
+			}
+		}
+	})
+}
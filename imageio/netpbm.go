@@ -0,0 +1,279 @@
+package imageio
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/mandykoh/convolver"
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+)
+
+func init() {
+	image.RegisterFormat("pgm", "P2", decodeNetpbmImage, decodeNetpbmConfig)
+	image.RegisterFormat("pgm", "P5", decodeNetpbmImage, decodeNetpbmConfig)
+	image.RegisterFormat("ppm", "P3", decodeNetpbmImage, decodeNetpbmConfig)
+	image.RegisterFormat("ppm", "P6", decodeNetpbmImage, decodeNetpbmConfig)
+}
+
+// DecodeNetpbm decodes a Netpbm greyscale (.pgm) or colour (.ppm) image, in
+// either its binary or plain text variant. Images with a maximum value of at
+// most 255 are decoded to *image.Gray or *image.NRGBA, and deeper ones to
+// *image.Gray16 or *image.NRGBA64, with values scaled to the full range of
+// the result.
+func DecodeNetpbm(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+
+	header, err := readNetpbmHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := image.Rect(0, 0, header.width, header.height)
+	deep := header.maxValue > 255
+
+	var img image.Image
+	var set func(x, y int, v []uint16)
+
+	switch {
+	case header.channels == 1 && !deep:
+		gray := image.NewGray(bounds)
+		img, set = gray, func(x, y int, v []uint16) {
+			gray.SetGray(x, y, color.Gray{Y: uint8(v[0])})
+		}
+	case header.channels == 1:
+		gray := image.NewGray16(bounds)
+		img, set = gray, func(x, y int, v []uint16) {
+			gray.SetGray16(x, y, color.Gray16{Y: v[0]})
+		}
+	case !deep:
+		nrgba := image.NewNRGBA(bounds)
+		img, set = nrgba, func(x, y int, v []uint16) {
+			nrgba.SetNRGBA(x, y, color.NRGBA{R: uint8(v[0]), G: uint8(v[1]), B: uint8(v[2]), A: 0xff})
+		}
+	default:
+		nrgba := image.NewNRGBA64(bounds)
+		img, set = nrgba, func(x, y int, v []uint16) {
+			nrgba.SetNRGBA64(x, y, color.NRGBA64{R: v[0], G: v[1], B: v[2], A: 0xffff})
+		}
+	}
+
+	fullScale := uint32(0xff)
+	if deep {
+		fullScale = 0xffff
+	}
+
+	pixel := make([]uint16, header.channels)
+
+	for y := 0; y < header.height; y++ {
+		for x := 0; x < header.width; x++ {
+			for c := range pixel {
+				v, err := readNetpbmSample(br, header)
+				if err != nil {
+					return nil, fmt.Errorf("error reading Netpbm data: %v", err)
+				}
+				if v > header.maxValue {
+					return nil, fmt.Errorf("Netpbm sample %d exceeds maximum value %d", v, header.maxValue)
+				}
+
+				pixel[c] = uint16((v*fullScale + header.maxValue/2) / header.maxValue)
+			}
+
+			set(x, y, pixel)
+		}
+	}
+
+	return img, nil
+}
+
+// EncodePGM encodes an image as a binary Netpbm greyscale (.pgm) image with
+// 8-bit samples, or 16-bit samples if sixteenBit is true. Colours are
+// converted to grey as by color.Gray16Model, and alpha is discarded.
+func EncodePGM(w io.Writer, img image.Image, sixteenBit bool) error {
+	return encodeNetpbm(w, img, "P5", sixteenBit, func(c color.Color) []uint16 {
+		return []uint16{color.Gray16Model.Convert(c).(color.Gray16).Y}
+	})
+}
+
+// EncodePPM encodes an image as a binary Netpbm colour (.ppm) image with
+// 8-bit samples, or 16-bit samples if sixteenBit is true. Colours are stored
+// without being premultiplied, and alpha is discarded.
+func EncodePPM(w io.Writer, img image.Image, sixteenBit bool) error {
+	return encodeNetpbm(w, img, "P6", sixteenBit, func(c color.Color) []uint16 {
+		n := color.NRGBA64Model.Convert(c).(color.NRGBA64)
+		return []uint16{n.R, n.G, n.B}
+	})
+}
+
+func encodeNetpbm(w io.Writer, img image.Image, magic string, sixteenBit bool, samples func(color.Color) []uint16) error {
+	bw := bufio.NewWriter(w)
+	bounds := img.Bounds()
+
+	maxValue := 255
+	if sixteenBit {
+		maxValue = 65535
+	}
+	fmt.Fprintf(bw, "%s\n%d %d\n%d\n", magic, bounds.Dx(), bounds.Dy(), maxValue)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			for _, v := range samples(img.At(x, y)) {
+				if sixteenBit {
+					bw.WriteByte(byte(v >> 8))
+					bw.WriteByte(byte(v))
+				} else {
+					bw.WriteByte(byte((uint32(v)*0xff + 0x7fff) / 0xffff))
+				}
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+// isDeepImage returns whether an image holds more than 8 bits per channel,
+// and so should be encoded with 16-bit samples.
+func isDeepImage(img image.Image) bool {
+	switch img.(type) {
+	case *image.Gray16, *image.RGBA64, *image.NRGBA64, *convolver.FloatImage:
+		return true
+	}
+	return false
+}
+
+type netpbmHeader struct {
+	magic    string
+	channels int
+	width    int
+	height   int
+	maxValue uint32
+}
+
+func decodeNetpbmConfig(r io.Reader) (image.Config, error) {
+	header, err := readNetpbmHeader(bufio.NewReader(r))
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	var model color.Model
+	switch {
+	case header.channels == 1 && header.maxValue > 255:
+		model = color.Gray16Model
+	case header.channels == 1:
+		model = color.GrayModel
+	case header.maxValue > 255:
+		model = color.NRGBA64Model
+	default:
+		model = color.NRGBAModel
+	}
+
+	return image.Config{ColorModel: model, Width: header.width, Height: header.height}, nil
+}
+
+func decodeNetpbmImage(r io.Reader) (image.Image, error) {
+	return DecodeNetpbm(r)
+}
+
+func readNetpbmHeader(br *bufio.Reader) (netpbmHeader, error) {
+	header := netpbmHeader{}
+
+	magic, err := readNetpbmToken(br)
+	if err != nil {
+		return header, fmt.Errorf("invalid Netpbm header: %v", err)
+	}
+
+	switch magic {
+	case "P2", "P5":
+		header.channels = 1
+	case "P3", "P6":
+		header.channels = 3
+	default:
+		return header, fmt.Errorf("unsupported Netpbm type %q", magic)
+	}
+	header.magic = magic
+
+	var values [3]int
+	for i := range values {
+		token, err := readNetpbmToken(br)
+		if err != nil {
+			return header, fmt.Errorf("invalid Netpbm header: %v", err)
+		}
+		if values[i], err = strconv.Atoi(token); err != nil {
+			return header, fmt.Errorf("invalid Netpbm header value %q", token)
+		}
+	}
+
+	header.width, header.height = values[0], values[1]
+	if err := checkDimensions("Netpbm", header.width, header.height); err != nil {
+		return header, err
+	}
+	if values[2] <= 0 || values[2] > 65535 {
+		return header, fmt.Errorf("invalid Netpbm maximum value %d", values[2])
+	}
+	header.maxValue = uint32(values[2])
+
+	return header, nil
+}
+
+// readNetpbmToken reads the next whitespace separated token, skipping
+// comments, and consumes the single whitespace character which ends it.
+func readNetpbmToken(br *bufio.Reader) (string, error) {
+	var token []byte
+
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			if err == io.EOF && len(token) > 0 {
+				return string(token), nil
+			}
+			if err == io.EOF {
+				return "", io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+
+		switch {
+		case b == '#' && len(token) == 0:
+			if _, err := br.ReadString('\n'); err != nil {
+				return "", io.ErrUnexpectedEOF
+			}
+		case b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\v' || b == '\f':
+			if len(token) > 0 {
+				return string(token), nil
+			}
+		default:
+			token = append(token, b)
+		}
+	}
+}
+
+func readNetpbmSample(br *bufio.Reader, header netpbmHeader) (uint32, error) {
+	switch header.magic {
+	case "P2", "P3":
+		token, err := readNetpbmToken(br)
+		if err != nil {
+			return 0, err
+		}
+		v, err := strconv.ParseUint(token, 10, 16)
+		if err != nil {
+			return 0, errors.New("invalid sample " + strconv.Quote(token))
+		}
+		return uint32(v), nil
+	}
+
+	hi, err := br.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if header.maxValue <= 255 {
+		return uint32(hi), nil
+	}
+
+	lo, err := br.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	return uint32(hi)<<8 | uint32(lo), nil
+}
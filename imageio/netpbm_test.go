@@ -0,0 +1,159 @@
+package imageio
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestNetpbm(t *testing.T) {
+
+	t.Run("round trips 8-bit colour images", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+		for i := 0; i < 2; i++ {
+			for j := 0; j < 3; j++ {
+				img.SetNRGBA(j, i, color.NRGBA{R: uint8(j * 80), G: uint8(i * 200), B: uint8(i*j + 7), A: 255})
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := Encode(&buf, img, "ppm"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !strings.HasPrefix(buf.String(), "P6\n3 2\n255\n") {
+			t.Errorf("Expected an 8-bit binary PPM header but was %q", buf.String()[:11])
+		}
+
+		decoded, format, err := Decode(&buf)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if expected, actual := "ppm", format; expected != actual {
+			t.Errorf("Expected format %q but was %q", expected, actual)
+		}
+
+		result := decoded.(*image.NRGBA)
+		for i := range img.Pix {
+			if expected, actual := img.Pix[i], result.Pix[i]; expected != actual {
+				t.Fatalf("Expected byte %d to be %d but was %d", i, expected, actual)
+			}
+		}
+	})
+
+	t.Run("round trips 16-bit greyscale images", func(t *testing.T) {
+		img := image.NewGray16(image.Rect(0, 0, 4, 1))
+		for j, v := range []uint16{0, 1, 0x1234, 0xffff} {
+			img.SetGray16(j, 0, color.Gray16{Y: v})
+		}
+
+		var buf bytes.Buffer
+		if err := Encode(&buf, img, "pgm"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		decoded, format, err := Decode(&buf)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if expected, actual := "pgm", format; expected != actual {
+			t.Errorf("Expected format %q but was %q", expected, actual)
+		}
+
+		result := decoded.(*image.Gray16)
+		for j := 0; j < 4; j++ {
+			if expected, actual := img.Gray16At(j, 0), result.Gray16At(j, 0); expected != actual {
+				t.Errorf("Expected pixel %d to be %v but was %v", j, expected, actual)
+			}
+		}
+	})
+
+	t.Run("round trips 16-bit colour images", func(t *testing.T) {
+		img := image.NewNRGBA64(image.Rect(0, 0, 2, 1))
+		img.SetNRGBA64(0, 0, color.NRGBA64{R: 0x0102, G: 0x8000, B: 0xfffe, A: 0xffff})
+		img.SetNRGBA64(1, 0, color.NRGBA64{R: 3, A: 0xffff})
+
+		var buf bytes.Buffer
+		if err := EncodePPM(&buf, img, true); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		decoded, err := DecodeNetpbm(&buf)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		result := decoded.(*image.NRGBA64)
+		for j := 0; j < 2; j++ {
+			if expected, actual := img.NRGBA64At(j, 0), result.NRGBA64At(j, 0); expected != actual {
+				t.Errorf("Expected pixel %d to be %v but was %v", j, expected, actual)
+			}
+		}
+	})
+
+	t.Run("decodes plain text images with comments and scales values", func(t *testing.T) {
+		data := "P2\n# a comment\n3 1 # trailing comment\n15\n0 8\n15\n"
+
+		decoded, err := DecodeNetpbm(strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		result := decoded.(*image.Gray)
+		for j, expected := range []uint8{0, 136, 255} {
+			if actual := result.GrayAt(j, 0).Y; expected != actual {
+				t.Errorf("Expected pixel %d to be %d but was %d", j, expected, actual)
+			}
+		}
+	})
+
+	t.Run("decodes plain text colour images", func(t *testing.T) {
+		decoded, err := DecodeNetpbm(strings.NewReader("P3 1 1 255 10 20 30"))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if expected, actual := (color.NRGBA{R: 10, G: 20, B: 30, A: 255}), decoded.(*image.NRGBA).NRGBAAt(0, 0); expected != actual {
+			t.Errorf("Expected pixel to be %v but was %v", expected, actual)
+		}
+	})
+
+	t.Run("reports truncated and invalid data", func(t *testing.T) {
+		for _, data := range []string{
+			"P5\n2 2\n255\n\x00\x01",
+			"P2\n1 1\n10\n11\n",
+			"P5\n0 1\n255\n",
+			"P5\n1 1\n70000\n\x00",
+			"P7\n1 1\n255\n\x00",
+		} {
+			if _, err := DecodeNetpbm(strings.NewReader(data)); err == nil {
+				t.Errorf("Expected an error decoding %q but got none", data)
+			}
+		}
+	})
+
+	t.Run("rejects dimensions beyond the pixel limit before allocating", func(t *testing.T) {
+		for _, header := range []string{
+			"P6\n100000 100000\n255\n",
+			"P5\n9223372036854775807 9223372036854775807\n255\n",
+			"P3\n4611686018427387904 4\n255\n",
+		} {
+			if _, err := DecodeNetpbm(strings.NewReader(header)); err == nil {
+				t.Errorf("Expected an error for header %q but got none", header)
+			}
+		}
+	})
+
+	t.Run("honours a lowered pixel limit", func(t *testing.T) {
+		defer func(limit int) { MaxPixels = limit }(MaxPixels)
+		MaxPixels = 4
+
+		if _, err := DecodeNetpbm(strings.NewReader("P5\n2 2\n255\n\x00\x01\x02\x03")); err != nil {
+			t.Errorf("Expected no error within the limit but got %v", err)
+		}
+		if _, err := DecodeNetpbm(strings.NewReader("P5\n3 2\n255\n\x00\x01\x02\x03\x04\x05")); err == nil {
+			t.Errorf("Expected an error beyond the limit but got none")
+		}
+	})
+}
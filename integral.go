@@ -0,0 +1,94 @@
+package convolver
+
+import (
+	"github.com/mandykoh/go-parallel"
+	"image"
+)
+
+// DifferenceOfBoxes approximates a difference-of-Gaussians response on an
+// image's linear luminance by subtracting the mean over a box of outerRadius
+// from the mean over a box of innerRadius. Box means are computed from an
+// integral image, so the cost per pixel is independent of the radii.
+//
+// Boxes are clipped against the edges of the image and the means are taken
+// over the remaining area.
+func DifferenceOfBoxes(img image.Image, innerRadius, outerRadius int, parallelism int) *Plane {
+	luminance := LuminancePlane(img, parallelism)
+	integral := newIntegralImage(luminance)
+
+	result := NewPlane(luminance.Rect)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := result.Rect.Min.Y + workerNum; i < result.Rect.Max.Y; i += workerCount {
+			for j := result.Rect.Min.X; j < result.Rect.Max.X; j++ {
+				inner := integral.boxMean(j, i, innerRadius)
+				outer := integral.boxMean(j, i, outerRadius)
+				result.Pix[result.offset(j, i)] = float32(inner - outer)
+			}
+		}
+	})
+
+	return result
+}
+
+type integralImage struct {
+	rect   image.Rectangle
+	stride int
+	sums   []float64
+}
+
+func newIntegralImage(p *Plane) *integralImage {
+	width, height := p.Rect.Dx(), p.Rect.Dy()
+	stride := width + 1
+
+	ii := &integralImage{
+		rect:   p.Rect,
+		stride: stride,
+		sums:   make([]float64, bufferLength(image.Rect(0, 0, stride, height+1), 1)),
+	}
+
+	for i := 0; i < height; i++ {
+		rowSum := 0.0
+		for j := 0; j < width; j++ {
+			rowSum += float64(p.Pix[i*p.Stride+j])
+			ii.sums[(i+1)*stride+j+1] = ii.sums[i*stride+j+1] + rowSum
+		}
+	}
+
+	return ii
+}
+
+// boxMean returns the mean value of the square box of the given radius
+// centred on x, y, clipped to the bounds of the image.
+func (ii *integralImage) boxMean(x, y, radius int) float64 {
+	box := image.Rect(x-radius, y-radius, x+radius+1, y+radius+1).Intersect(ii.rect)
+	if box.Empty() {
+		return 0
+	}
+
+	return ii.boxSum(box) / float64(box.Dx()*box.Dy())
+}
+
+func (ii *integralImage) boxSum(box image.Rectangle) float64 {
+	x0, y0 := box.Min.X-ii.rect.Min.X, box.Min.Y-ii.rect.Min.Y
+	x1, y1 := box.Max.X-ii.rect.Min.X, box.Max.Y-ii.rect.Min.Y
+
+	return ii.sums[y1*ii.stride+x1] - ii.sums[y0*ii.stride+x1] - ii.sums[y1*ii.stride+x0] + ii.sums[y0*ii.stride+x0]
+}
+
+// boxBlurPlane returns the mean over a square box of the given radius around
+// each value of a plane, with boxes clipped against the plane's edges.
+func boxBlurPlane(p *Plane, radius int, parallelism int) *Plane {
+	integral := newIntegralImage(p)
+	result := NewPlane(p.Rect)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := result.Rect.Min.Y + workerNum; i < result.Rect.Max.Y; i += workerCount {
+			for j := result.Rect.Min.X; j < result.Rect.Max.X; j++ {
+				result.Pix[result.offset(j, i)] = float32(integral.boxMean(j, i, radius))
+			}
+		}
+	})
+
+	return result
+}
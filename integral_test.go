@@ -0,0 +1,72 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+func TestDifferenceOfBoxes(t *testing.T) {
+
+	t.Run("produces no response for a flat image", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+		for i := range img.Pix {
+			img.Pix[i] = 128
+		}
+
+		result := DifferenceOfBoxes(img, 1, 4, runtime.NumCPU())
+
+		for i, v := range result.Pix {
+			if math.Abs(float64(v)) > 1e-6 {
+				t.Fatalf("Expected no response but found %v at index %d", v, i)
+			}
+		}
+	})
+
+	t.Run("responds positively at the centre of a bright blob", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+		for i := 7; i <= 9; i++ {
+			for j := 7; j <= 9; j++ {
+				img.SetNRGBA(j, i, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+			}
+		}
+
+		result := DifferenceOfBoxes(img, 1, 4, runtime.NumCPU())
+
+		if expected, actual := 1-9.0/81, float64(result.ValueAt(8, 8)); math.Abs(expected-actual) > 1e-3 {
+			t.Errorf("Expected response at blob centre to be %v but was %v", expected, actual)
+		}
+	})
+}
+
+func TestIntegralImage(t *testing.T) {
+	plane := NewPlane(image.Rect(5, 5, 17, 14))
+	for i := range plane.Pix {
+		plane.Pix[i] = rand.Float32()
+	}
+
+	integral := newIntegralImage(plane)
+
+	for _, radius := range []int{0, 1, 3, 20} {
+		for i := plane.Rect.Min.Y; i < plane.Rect.Max.Y; i++ {
+			for j := plane.Rect.Min.X; j < plane.Rect.Max.X; j++ {
+				sum, count := 0.0, 0
+				for y := i - radius; y <= i+radius; y++ {
+					for x := j - radius; x <= j+radius; x++ {
+						if image.Pt(x, y).In(plane.Rect) {
+							sum += float64(plane.ValueAt(x, y))
+							count++
+						}
+					}
+				}
+
+				if expected, actual := sum/float64(count), integral.boxMean(j, i, radius); math.Abs(expected-actual) > 1e-6 {
+					t.Fatalf("Expected box mean of radius %d at %d,%d to be %v but was %v", radius, j, i, expected, actual)
+				}
+			}
+		}
+	}
+}
@@ -2,67 +2,135 @@ package convolver
 
 import (
 	"fmt"
-	"github.com/mandykoh/go-parallel"
 	"github.com/mandykoh/prism"
 	"github.com/mandykoh/prism/srgb"
 	"image"
 	"image/color"
+	"time"
 )
 
-type opFunc func(img *image.NRGBA, x, y int) color.NRGBA
+// OpFunc is an aggregation operation which computes the output colour for the
+// pixel at x, y of an image. The Avg, Max, and Min methods of a Kernel are
+// operations of this type.
+type OpFunc func(img *image.NRGBA, x, y int) color.NRGBA
 
 type Kernel struct {
-	radius     int
-	sideLength int
-	weights    []kernelWeight
+	radius         int
+	sideLength     int
+	weights        []kernelWeight
+	maxMemoryBytes int
+	maxCPUShare    float64
+	powerMode      PowerMode
+	softClipKnee   float32
+	diagnostics    *Diagnostics
+	edgeMode       EdgeMode
+	edgeColour     color.NRGBA
+	backend        string
+
+	asymmetricPairs int
 }
 
 func (k *Kernel) ApplyMax(img image.Image, parallelism int) *image.NRGBA {
-	return k.apply(prism.ConvertImageToNRGBA(img, parallelism), k.Max, parallelism)
+	return k.applyImage(img, k.Max, parallelism)
 }
 
 func (k *Kernel) ApplyMin(img image.Image, parallelism int) *image.NRGBA {
-	return k.apply(prism.ConvertImageToNRGBA(img, parallelism), k.Min, parallelism)
+	return k.applyImage(img, k.Min, parallelism)
 }
 
 func (k *Kernel) ApplyAvg(img image.Image, parallelism int) *image.NRGBA {
-	return k.apply(prism.ConvertImageToNRGBA(img, parallelism), k.Avg, parallelism)
+	if result, ok := k.applyBackendAvg(img, parallelism); ok {
+		return result
+	}
+	return k.applyImage(img, k.Avg, parallelism)
+}
+
+func (k *Kernel) apply(img *image.NRGBA, op OpFunc, parallelism int) *image.NRGBA {
+	return k.applyWithin(img, img.Rect, op, parallelism)
 }
 
-func (k *Kernel) apply(img *image.NRGBA, op opFunc, parallelism int) *image.NRGBA {
-	bounds := img.Rect
+// applyWithin applies an operation to the pixels of img within bounds,
+// producing a result with those bounds.
+func (k *Kernel) applyWithin(img *image.NRGBA, bounds image.Rectangle, op OpFunc, parallelism int) *image.NRGBA {
+	if k.powerMode == PowerLow {
+		return k.applyLowPower(img, bounds, op, parallelism)
+	}
+
 	result := image.NewNRGBA(bounds)
 
-	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+	runWorkers(currentMetrics(), parallelism, func(workerNum, workerCount int) {
+		throttle := k.newThrottle()
+
 		for i := bounds.Min.Y + workerNum; i < bounds.Max.Y; i += workerCount {
 			for j := bounds.Min.X; j < bounds.Max.X; j++ {
 				result.SetNRGBA(j, i, op(img, j, i))
 			}
+			throttle.yield()
 		}
 	})
 
 	return result
 }
 
-func (k *Kernel) Avg(img *image.NRGBA, x, y int) color.NRGBA {
-	clip := k.clipToBounds(img.Rect, x, y)
+// applyImage applies an operation to any image, converting it to NRGBA either
+// all at once or, if that would exceed the kernel's memory budget, one tile at
+// a time.
+func (k *Kernel) applyImage(img image.Image, op OpFunc, parallelism int) *image.NRGBA {
+	return k.applyImageReaching(img, op, k.radius, parallelism)
+}
 
-	totalWeight := kernelWeight{}
-	sum := kernelWeight{}
+// applyImageReaching applies an operation which reads pixels up to reach away
+// from each pixel it computes, such as a warped average, to any image as
+// applyImage does, padding by and tiling with aprons of that width.
+func (k *Kernel) applyImageReaching(img image.Image, op OpFunc, reach int, parallelism int) *image.NRGBA {
+	defer observeImage(currentMetrics(), time.Now())
 
-	for s := clip.Top; s < k.sideLength-clip.Bottom; s++ {
-		for t := clip.Left; t < k.sideLength-clip.Right; t++ {
-			weight := k.weights[s*k.sideLength+t]
-			totalWeight.R += weight.R
-			totalWeight.G += weight.G
-			totalWeight.B += weight.B
-			totalWeight.A += weight.A
+	parallelism = k.powerMode.workers(parallelism)
 
-			c, a := srgb.ColorFromNRGBA(img.NRGBAAt(x+t-k.radius, y+s-k.radius))
-			sum.R += c.R * weight.R
-			sum.G += c.G * weight.G
-			sum.B += c.B * weight.B
-			sum.A += a * weight.A
+	if k.edgeMode != EdgeClip && reach > 0 && !img.Bounds().Empty() {
+		return k.applyPadded(img, op, reach, parallelism)
+	}
+
+	if nrgba, ok := img.(*image.NRGBA); ok {
+		return k.apply(nrgba, op, parallelism)
+	}
+
+	if k.exceedsMemoryBudget(img.Bounds()) {
+		return k.applyBounded(img, img.Bounds(), reach, op, parallelism)
+	}
+
+	return k.apply(prism.ConvertImageToNRGBA(img, parallelism), op, parallelism)
+}
+
+// exceedsMemoryBudget returns whether converting an image with the given
+// bounds to NRGBA would exceed the kernel's memory budget.
+func (k *Kernel) exceedsMemoryBudget(bounds image.Rectangle) bool {
+	_, ok := checkedProduct(uint64(k.maxMemoryBytes), bounds.Dx(), bounds.Dy(), 4)
+	return k.maxMemoryBytes > 0 && !ok
+}
+
+func (k *Kernel) Avg(img *image.NRGBA, x, y int) color.NRGBA {
+	clip := k.clipToBounds(img.Rect, x, y)
+
+	var totalWeight, sum kernelWeight
+
+	if clip == (kernelClip{}) && k.pointSymmetric() {
+		sum, totalWeight = k.foldedSum(img, x, y)
+	} else {
+		for s := clip.Top; s < k.sideLength-clip.Bottom; s++ {
+			for t := clip.Left; t < k.sideLength-clip.Right; t++ {
+				weight := k.weights[s*k.sideLength+t]
+				totalWeight.R += weight.R
+				totalWeight.G += weight.G
+				totalWeight.B += weight.B
+				totalWeight.A += weight.A
+
+				c, a := srgb.ColorFromNRGBA(img.NRGBAAt(x+t-k.radius, y+s-k.radius))
+				sum.R += c.R * weight.R
+				sum.G += c.G * weight.G
+				sum.B += c.B * weight.B
+				sum.A += a * weight.A
+			}
 		}
 	}
 
@@ -79,7 +147,7 @@ func (k *Kernel) Avg(img *image.NRGBA, x, y int) color.NRGBA {
 		sum.A /= totalWeight.A
 	}
 
-	return sum.toNRGBA()
+	return k.averageToNRGBA(x, y, sum)
 }
 
 func (k *Kernel) clipToBounds(bounds image.Rectangle, x, y int) kernelClip {
@@ -157,8 +225,24 @@ func (k *Kernel) Min(img *image.NRGBA, x, y int) color.NRGBA {
 	return min.toNRGBA()
 }
 
+// MaxMemoryBytes returns the working memory limit set with SetMaxMemoryBytes,
+// or zero or less if there is none.
+func (k *Kernel) MaxMemoryBytes() int {
+	return k.maxMemoryBytes
+}
+
+// SetMaxMemoryBytes limits the working memory used when applying this kernel,
+// not counting the input and result images themselves. Inputs which are not
+// already NRGBA must be converted before processing; if converting the whole
+// image would exceed the limit, the image is instead converted and processed
+// in tiles (with aprons to cover the kernel) sized to fit within it. A limit
+// of zero or less means no limit.
+func (k *Kernel) SetMaxMemoryBytes(n int) {
+	k.maxMemoryBytes = n
+}
+
 func (k *Kernel) SetWeightRGBA(x, y int, r, g, b, a float32) {
-	k.weights[y*k.sideLength+x] = kernelWeight{R: r, G: g, B: b, A: a}
+	k.setWeight(y*k.sideLength+x, kernelWeight{R: r, G: g, B: b, A: a})
 }
 
 func (k *Kernel) SetWeightUniform(x, y int, weight float32) {
@@ -172,7 +256,7 @@ func (k *Kernel) SetWeightsRGBA(weights [][4]float32) {
 
 	for i := 0; i < len(weights); i++ {
 		w := weights[i]
-		k.weights[i] = kernelWeight{R: w[0], G: w[1], B: w[2], A: w[3]}
+		k.setWeight(i, kernelWeight{R: w[0], G: w[1], B: w[2], A: w[3]})
 	}
 }
 
@@ -183,7 +267,7 @@ func (k *Kernel) SetWeightsUniform(weights []float32) {
 
 	for i := 0; i < len(weights); i++ {
 		w := weights[i]
-		k.weights[i] = kernelWeight{R: w, G: w, B: w, A: w}
+		k.setWeight(i, kernelWeight{R: w, G: w, B: w, A: w})
 	}
 }
 
@@ -191,6 +275,13 @@ func (k *Kernel) SideLength() int {
 	return k.sideLength
 }
 
+// WeightRGBA returns the per-channel weights of the tap at x, y, where x and y
+// range from zero to SideLength() - 1.
+func (k *Kernel) WeightRGBA(x, y int) (r, g, b, a float32) {
+	w := k.weights[y*k.sideLength+x]
+	return w.R, w.G, w.B, w.A
+}
+
 func KernelWithRadius(radius int) Kernel {
 	sideLength := radius*2 + 1
 
@@ -215,6 +306,29 @@ type kernelWeight struct {
 	A float32
 }
 
+func (kw *kernelWeight) magnitude() float32 {
+	return (abs32(kw.R) + abs32(kw.G) + abs32(kw.B) + abs32(kw.A)) / 4
+}
+
+// channelMask returns a bit mask of the channels in which the weight is
+// non-zero, with red in the lowest bit.
+func (kw *kernelWeight) channelMask() uint8 {
+	var mask uint8
+	for i, c := range [4]float32{kw.R, kw.G, kw.B, kw.A} {
+		if c != 0 {
+			mask |= 1 << uint(i)
+		}
+	}
+	return mask
+}
+
 func (kw *kernelWeight) toNRGBA() color.NRGBA {
 	return srgb.ColorFromLinear(kw.R, kw.G, kw.B).ToNRGBA(kw.A)
 }
+
+func abs32(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
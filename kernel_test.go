@@ -27,7 +27,7 @@ func BenchmarkAggregation(b *testing.B) {
 
 	cases := []struct {
 		OpName string
-		Op     opFunc
+		Op     OpFunc
 	}{
 		{OpName: "Avg", Op: kernel.Avg},
 		{OpName: "Max", Op: kernel.Max},
@@ -503,6 +503,21 @@ func TestKernel(t *testing.T) {
 			}
 		})
 	})
+
+	t.Run("WeightRGBA()", func(t *testing.T) {
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightRGBA(2, 1, 0.1, 0.2, 0.3, 0.4)
+
+		r, g, b, a := kernel.WeightRGBA(2, 1)
+		if expected, actual := [4]float32{0.1, 0.2, 0.3, 0.4}, [4]float32{r, g, b, a}; expected != actual {
+			t.Errorf("Expected weight to be %v but was %v", expected, actual)
+		}
+
+		r, g, b, a = kernel.WeightRGBA(1, 2)
+		if expected, actual := [4]float32{}, [4]float32{r, g, b, a}; expected != actual {
+			t.Errorf("Expected unset weight to be %v but was %v", expected, actual)
+		}
+	})
 }
 
 func randomImage(w, h int) *image.NRGBA {
@@ -0,0 +1,138 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+)
+
+// ApplyLabelDilate applies the operation returned by LabelDilate to an image.
+func (k *Kernel) ApplyLabelDilate(img image.Image, background color.NRGBA, parallelism int) *image.NRGBA {
+	return k.applyImage(img, k.LabelDilate(background), parallelism)
+}
+
+// ApplyLabelErode applies the operation returned by LabelErode to an image.
+func (k *Kernel) ApplyLabelErode(img image.Image, background color.NRGBA, minShare float32, parallelism int) *image.NRGBA {
+	return k.applyImage(img, k.LabelErode(background, minShare), parallelism)
+}
+
+// ApplyLabelMode applies the LabelMode operation to an image.
+func (k *Kernel) ApplyLabelMode(img image.Image, parallelism int) *image.NRGBA {
+	return k.applyImage(img, k.LabelMode, parallelism)
+}
+
+// LabelMode is an operation for label images, such as segmentation maps, in
+// which each distinct colour identifies a class and averaging colours would
+// produce meaningless labels. It returns the colour with the greatest total
+// weight among the taps of the kernel, compared exactly and without
+// conversion, so results are always colours present in the input. Ties are
+// resolved in favour of the label of the pixel itself, and otherwise the
+// label found first in row-major order.
+func (k *Kernel) LabelMode(img *image.NRGBA, x, y int) color.NRGBA {
+	votes := k.labelVotes(img, x, y)
+	centre := img.NRGBAAt(x, y)
+
+	best := -1
+	for i, v := range votes {
+		if best < 0 || v.weight > votes[best].weight || (v.weight == votes[best].weight && v.label == centre) {
+			best = i
+		}
+	}
+
+	if best < 0 {
+		return centre
+	}
+	return votes[best].label
+}
+
+// LabelDilate returns an operation which grows the regions of a label image
+// into the background, for use with multiple classes of region. Background
+// pixels take the label with the greatest total weight among the taps of the
+// kernel, ignoring the background itself, so that where regions grow towards
+// each other the boundary is decided by weighted majority. Ties are resolved
+// in favour of the label found first in row-major order. Other pixels are
+// left unchanged.
+func (k *Kernel) LabelDilate(background color.NRGBA) OpFunc {
+	return func(img *image.NRGBA, x, y int) color.NRGBA {
+		centre := img.NRGBAAt(x, y)
+		if centre != background {
+			return centre
+		}
+
+		best := -1
+		votes := k.labelVotes(img, x, y)
+		for i, v := range votes {
+			if v.label != background && (best < 0 || v.weight > votes[best].weight) {
+				best = i
+			}
+		}
+
+		if best < 0 {
+			return background
+		}
+		return votes[best].label
+	}
+}
+
+// LabelErode returns an operation which shrinks the regions of a label image.
+// A pixel keeps its label only if that label holds at least minShare of the
+// total weight among the taps of the kernel, and otherwise becomes
+// background. A minShare of 1 gives classic erosion, where any other label
+// within the kernel removes the pixel; lower values erode only where other
+// labels dominate.
+func (k *Kernel) LabelErode(background color.NRGBA, minShare float32) OpFunc {
+	return func(img *image.NRGBA, x, y int) color.NRGBA {
+		centre := img.NRGBAAt(x, y)
+		if centre == background {
+			return centre
+		}
+
+		own, total := float32(0), float32(0)
+		for _, v := range k.labelVotes(img, x, y) {
+			total += v.weight
+			if v.label == centre {
+				own = v.weight
+			}
+		}
+
+		if total > 0 && own < minShare*total {
+			return background
+		}
+		return centre
+	}
+}
+
+// labelVotes returns the total weight of each distinct label among the taps
+// of the kernel at x, y, in the order in which they were first found. Each
+// tap's vote is the magnitude of its weight, and taps with zero weight are
+// ignored.
+func (k *Kernel) labelVotes(img *image.NRGBA, x, y int) []labelVote {
+	clip := k.clipToBounds(img.Rect, x, y)
+	votes := make([]labelVote, 0, 8)
+
+	for s := clip.Top; s < k.sideLength-clip.Bottom; s++ {
+		for t := clip.Left; t < k.sideLength-clip.Right; t++ {
+			weight := k.weights[s*k.sideLength+t].magnitude()
+			if weight == 0 {
+				continue
+			}
+
+			label := img.NRGBAAt(x+t-k.radius, y+s-k.radius)
+
+			index := 0
+			for index < len(votes) && votes[index].label != label {
+				index++
+			}
+			if index == len(votes) {
+				votes = append(votes, labelVote{label: label})
+			}
+			votes[index].weight += weight
+		}
+	}
+
+	return votes
+}
+
+type labelVote struct {
+	label  color.NRGBA
+	weight float32
+}
@@ -0,0 +1,147 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+)
+
+func TestLabelMode(t *testing.T) {
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{
+		1, 1, 1,
+		1, 1, 1,
+		1, 1, 1,
+	})
+
+	labels := []color.NRGBA{
+		{R: 1, A: 255},
+		{R: 2, A: 255},
+		{R: 3, A: 255},
+	}
+
+	t.Run("only produces labels present in the input", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+		for i := 0; i < 16; i++ {
+			for j := 0; j < 16; j++ {
+				img.SetNRGBA(j, i, labels[(i*7+j*3+i*j)%3])
+			}
+		}
+
+		result := kernel.ApplyLabelMode(img, runtime.NumCPU())
+
+		for i := 0; i < 16; i++ {
+			for j := 0; j < 16; j++ {
+				c := result.NRGBAAt(j, i)
+				if c != labels[0] && c != labels[1] && c != labels[2] {
+					t.Fatalf("Expected only input labels but found %+v at %d,%d", c, j, i)
+				}
+			}
+		}
+	})
+
+	t.Run("removes isolated labels", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 3, 3))
+		for i := 0; i < 9; i++ {
+			img.SetNRGBA(i%3, i/3, labels[0])
+		}
+		img.SetNRGBA(1, 1, labels[1])
+
+		result := kernel.ApplyLabelMode(img, runtime.NumCPU())
+
+		if expected, actual := labels[0], result.NRGBAAt(1, 1); expected != actual {
+			t.Errorf("Expected isolated label to be replaced with %+v but was %+v", expected, actual)
+		}
+	})
+
+	t.Run("favours the pixel's own label in a tie", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+		img.SetNRGBA(0, 0, labels[0])
+		img.SetNRGBA(1, 0, labels[1])
+
+		result := kernel.ApplyLabelMode(img, runtime.NumCPU())
+
+		if expected, actual := labels[1], result.NRGBAAt(1, 0); expected != actual {
+			t.Errorf("Expected tied pixel to keep its label %+v but was %+v", expected, actual)
+		}
+	})
+}
+
+func TestLabelDilate(t *testing.T) {
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{
+		0, 1, 0,
+		1, 2, 1,
+		0, 1, 0,
+	})
+
+	background := color.NRGBA{}
+	a := color.NRGBA{R: 255, A: 255}
+	b := color.NRGBA{G: 255, A: 255}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 5, 3))
+	img.SetNRGBA(0, 1, a)
+	img.SetNRGBA(1, 1, a)
+	img.SetNRGBA(3, 0, b)
+	img.SetNRGBA(3, 1, b)
+	img.SetNRGBA(3, 2, b)
+
+	result := kernel.ApplyLabelDilate(img, background, runtime.NumCPU())
+
+	cases := []struct {
+		X, Y     int
+		Expected color.NRGBA
+	}{
+		{0, 0, a},
+		{1, 1, a},
+		{2, 1, a}, // Tie resolved in favour of the first label found
+		{2, 0, b},
+		{4, 1, b},
+		{1, 2, a},
+	}
+
+	for _, c := range cases {
+		if actual := result.NRGBAAt(c.X, c.Y); c.Expected != actual {
+			t.Errorf("Expected label at %d,%d to be %+v but was %+v", c.X, c.Y, c.Expected, actual)
+		}
+	}
+}
+
+func TestLabelErode(t *testing.T) {
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{
+		1, 1, 1,
+		1, 1, 1,
+		1, 1, 1,
+	})
+
+	background := color.NRGBA{}
+	a := color.NRGBA{R: 255, A: 255}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 5, 5))
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 3; j++ {
+			img.SetNRGBA(j, i, a)
+		}
+	}
+
+	t.Run("removes pixels near other labels with a full share", func(t *testing.T) {
+		result := kernel.ApplyLabelErode(img, background, 1, runtime.NumCPU())
+
+		if expected, actual := a, result.NRGBAAt(1, 2); expected != actual {
+			t.Errorf("Expected interior pixel to keep label %+v but was %+v", expected, actual)
+		}
+		if expected, actual := background, result.NRGBAAt(2, 2); expected != actual {
+			t.Errorf("Expected boundary pixel to be eroded but was %+v", actual)
+		}
+	})
+
+	t.Run("keeps pixels holding at least the minimum share", func(t *testing.T) {
+		result := kernel.ApplyLabelErode(img, background, 0.5, runtime.NumCPU())
+
+		if expected, actual := a, result.NRGBAAt(2, 2); expected != actual {
+			t.Errorf("Expected boundary pixel with 2/3 share to keep label %+v but was %+v", expected, actual)
+		}
+	})
+}
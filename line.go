@@ -0,0 +1,118 @@
+package convolver
+
+import (
+	"image"
+	"math"
+)
+
+func HorizontalLineKernel() Kernel {
+	return lineKernelWithWeights([]float32{
+		-1, -1, -1,
+		2, 2, 2,
+		-1, -1, -1,
+	})
+}
+
+func VerticalLineKernel() Kernel {
+	return lineKernelWithWeights([]float32{
+		-1, 2, -1,
+		-1, 2, -1,
+		-1, 2, -1,
+	})
+}
+
+// DiagonalLineKernel returns a kernel which responds to lines running from the
+// bottom left to the top right.
+func DiagonalLineKernel() Kernel {
+	return lineKernelWithWeights([]float32{
+		-1, -1, 2,
+		-1, 2, -1,
+		2, -1, -1,
+	})
+}
+
+// AntiDiagonalLineKernel returns a kernel which responds to lines running from
+// the top left to the bottom right.
+func AntiDiagonalLineKernel() Kernel {
+	return lineKernelWithWeights([]float32{
+		2, -1, -1,
+		-1, 2, -1,
+		-1, -1, 2,
+	})
+}
+
+// LineKernel returns a zero-sum kernel which responds to bright one pixel wide
+// lines through its centre at the angle theta (in radians, measured from the
+// positive x axis towards the positive y axis). Taps within half a pixel of
+// the line share a total weight of 1, and all other taps share a total weight
+// of -1.
+func LineKernel(radius int, theta float64) Kernel {
+	kernel := KernelWithRadius(radius)
+	cos, sin := math.Cos(theta), math.Sin(theta)
+
+	onLine := make([]bool, len(kernel.weights))
+	onCount := 0
+
+	for i := 0; i < kernel.sideLength; i++ {
+		y := float64(i - radius)
+		for j := 0; j < kernel.sideLength; j++ {
+			x := float64(j - radius)
+
+			if math.Abs(y*cos-x*sin) <= 0.5+1e-9 {
+				onLine[i*kernel.sideLength+j] = true
+				onCount++
+			}
+		}
+	}
+
+	offCount := len(kernel.weights) - onCount
+	if offCount == 0 {
+		return kernel
+	}
+
+	for i, on := range onLine {
+		if on {
+			kernel.setWeight(i, uniformWeight(1/float32(onCount)))
+		} else {
+			kernel.setWeight(i, uniformWeight(-1/float32(offCount)))
+		}
+	}
+
+	return kernel
+}
+
+// SweepLineOrientations applies line kernels of the given radius at steps
+// evenly spaced orientations between 0 and π to the linear luminance of an
+// image. For each pixel, it returns the strongest response and the angle (in
+// radians) of the kernel which produced it.
+func SweepLineOrientations(img image.Image, radius, steps int, parallelism int) (response *Plane, angle *Plane) {
+	luminance := LuminancePlane(img, parallelism)
+
+	response = NewPlane(luminance.Rect)
+	angle = NewPlane(luminance.Rect)
+
+	for i := 0; i < steps; i++ {
+		theta := math.Pi * float64(i) / float64(steps)
+		kernel := LineKernel(radius, theta)
+		result := kernel.convolvePlane(luminance, parallelism)
+
+		for p, v := range result.Pix {
+			if i == 0 || v > response.Pix[p] {
+				response.Pix[p] = v
+				angle.Pix[p] = float32(theta)
+			}
+		}
+	}
+
+	return response, angle
+}
+
+func lineKernelWithWeights(weights []float32) Kernel {
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform(weights)
+	return kernel
+}
+
+func uniformWeight(w float32) kernelWeight {
+	return kernelWeight{R: w, G: w, B: w, A: w}
+}
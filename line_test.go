@@ -0,0 +1,86 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func TestLineKernel(t *testing.T) {
+
+	cases := []struct {
+		Name   string
+		Theta  float64
+		Preset Kernel
+	}{
+		{Name: "horizontal", Theta: 0, Preset: HorizontalLineKernel()},
+		{Name: "vertical", Theta: math.Pi / 2, Preset: VerticalLineKernel()},
+		{Name: "diagonal", Theta: 3 * math.Pi / 4, Preset: DiagonalLineKernel()},
+		{Name: "anti-diagonal", Theta: math.Pi / 4, Preset: AntiDiagonalLineKernel()},
+	}
+
+	for _, c := range cases {
+		t.Run("matches "+c.Name+" preset at radius 1", func(t *testing.T) {
+			kernel := LineKernel(1, c.Theta)
+
+			for i, w := range kernel.weights {
+				if expected, actual := c.Preset.weights[i].R/6, w.R; math.Abs(float64(expected-actual)) > 1e-6 {
+					t.Errorf("Expected weight %d to be %v but was %v", i, expected, actual)
+				}
+			}
+		})
+	}
+
+	t.Run("sums to zero", func(t *testing.T) {
+		kernel := LineKernel(3, 0.4)
+
+		sum := float32(0)
+		for _, w := range kernel.weights {
+			sum += w.R
+		}
+
+		if math.Abs(float64(sum)) > 1e-5 {
+			t.Errorf("Expected weights to sum to zero but sum was %v", sum)
+		}
+	})
+}
+
+func TestSweepLineOrientations(t *testing.T) {
+
+	cases := []struct {
+		Name          string
+		Line          func(img *image.NRGBA, i int)
+		ExpectedAngle float32
+	}{
+		{
+			Name:          "horizontal",
+			Line:          func(img *image.NRGBA, i int) { img.SetNRGBA(i, 8, color.NRGBA{R: 255, G: 255, B: 255, A: 255}) },
+			ExpectedAngle: 0,
+		},
+		{
+			Name:          "vertical",
+			Line:          func(img *image.NRGBA, i int) { img.SetNRGBA(8, i, color.NRGBA{R: 255, G: 255, B: 255, A: 255}) },
+			ExpectedAngle: math.Pi / 2,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run("finds orientation of "+c.Name+" line", func(t *testing.T) {
+			img := image.NewNRGBA(image.Rect(0, 0, 17, 17))
+			for i := 0; i < 17; i++ {
+				c.Line(img, i)
+			}
+
+			response, angle := SweepLineOrientations(img, 2, 8, runtime.NumCPU())
+
+			if actual := response.ValueAt(8, 8); actual <= 0 {
+				t.Errorf("Expected positive response on line but was %v", actual)
+			}
+			if expected, actual := c.ExpectedAngle, angle.ValueAt(8, 8); math.Abs(float64(expected-actual)) > 1e-6 {
+				t.Errorf("Expected angle to be %v but was %v", expected, actual)
+			}
+		})
+	}
+}
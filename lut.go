@@ -0,0 +1,248 @@
+package convolver
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/mandykoh/go-parallel"
+	"github.com/mandykoh/prism"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// LUTInterpolation selects how values between the points of a LUT3D are
+// interpolated.
+type LUTInterpolation int
+
+const (
+	// LUTTrilinear interpolates between the eight surrounding points.
+	LUTTrilinear LUTInterpolation = iota
+
+	// LUTTetrahedral interpolates between the four points of the enclosing
+	// tetrahedron, which is cheaper than trilinear interpolation and better
+	// preserves neutral greys.
+	LUTTetrahedral
+)
+
+// LUT3D is a three dimensional colour lookup table, mapping each input colour
+// to an output colour. Points are stored in Data as RGB triplets, with red
+// varying fastest, then green, then blue.
+type LUT3D struct {
+	Size      int
+	Data      []float32
+	DomainMin [3]float32
+	DomainMax [3]float32
+}
+
+// Lookup returns the interpolated output colour for an input colour.
+func (l *LUT3D) Lookup(r, g, b float32, interpolation LUTInterpolation) (float32, float32, float32) {
+	var pos [3]float32
+	var base [3]int
+
+	for c, v := range [3]float32{r, g, b} {
+		p := (v - l.DomainMin[c]) / (l.DomainMax[c] - l.DomainMin[c]) * float32(l.Size-1)
+		if !(p > 0) {
+			p = 0
+		} else if p > float32(l.Size-1) {
+			p = float32(l.Size - 1)
+		}
+
+		base[c] = int(p)
+		if base[c] == l.Size-1 && l.Size > 1 {
+			base[c]--
+		}
+		pos[c] = p - float32(base[c])
+	}
+
+	point := func(dr, dg, db int) [3]float32 {
+		i := ((base[2]+db)*l.Size*l.Size + (base[1]+dg)*l.Size + base[0] + dr) * 3
+		return [3]float32{l.Data[i], l.Data[i+1], l.Data[i+2]}
+	}
+
+	if l.Size == 1 {
+		p := point(0, 0, 0)
+		return p[0], p[1], p[2]
+	}
+
+	var out [3]float32
+
+	switch interpolation {
+	case LUTTetrahedral:
+		fr, fg, fb := pos[0], pos[1], pos[2]
+		c000, c111 := point(0, 0, 0), point(1, 1, 1)
+
+		var w [4]float32
+		var p1, p2 [3]float32
+
+		switch {
+		case fr >= fg && fg >= fb:
+			p1, p2, w = point(1, 0, 0), point(1, 1, 0), [4]float32{1 - fr, fr - fg, fg - fb, fb}
+		case fr >= fb && fb >= fg:
+			p1, p2, w = point(1, 0, 0), point(1, 0, 1), [4]float32{1 - fr, fr - fb, fb - fg, fg}
+		case fb >= fr && fr >= fg:
+			p1, p2, w = point(0, 0, 1), point(1, 0, 1), [4]float32{1 - fb, fb - fr, fr - fg, fg}
+		case fg >= fr && fr >= fb:
+			p1, p2, w = point(0, 1, 0), point(1, 1, 0), [4]float32{1 - fg, fg - fr, fr - fb, fb}
+		case fg >= fb && fb >= fr:
+			p1, p2, w = point(0, 1, 0), point(0, 1, 1), [4]float32{1 - fg, fg - fb, fb - fr, fr}
+		default:
+			p1, p2, w = point(0, 0, 1), point(0, 1, 1), [4]float32{1 - fb, fb - fg, fg - fr, fr}
+		}
+
+		for c := 0; c < 3; c++ {
+			out[c] = w[0]*c000[c] + w[1]*p1[c] + w[2]*p2[c] + w[3]*c111[c]
+		}
+
+	default:
+		for db := 0; db <= 1; db++ {
+			wb := 1 - pos[2]
+			if db == 1 {
+				wb = pos[2]
+			}
+			for dg := 0; dg <= 1; dg++ {
+				wg := 1 - pos[1]
+				if dg == 1 {
+					wg = pos[1]
+				}
+				for dr := 0; dr <= 1; dr++ {
+					wr := 1 - pos[0]
+					if dr == 1 {
+						wr = pos[0]
+					}
+					p := point(dr, dg, db)
+					w := wr * wg * wb
+					out[0] += p[0] * w
+					out[1] += p[1] * w
+					out[2] += p[2] * w
+				}
+			}
+		}
+	}
+
+	return out[0], out[1], out[2]
+}
+
+// LUTStage returns a stage which maps each pixel's colour through a LUT. As
+// with most .cube files, the LUT is applied to sRGB encoded values in the
+// range 0.0–1.0, rather than linear ones. Alpha is left unchanged.
+func LUTStage(lut *LUT3D, interpolation LUTInterpolation) Stage {
+	return func(img image.Image, parallelism int) *image.NRGBA {
+		input := prism.ConvertImageToNRGBA(img, parallelism)
+		result := image.NewNRGBA(input.Rect)
+
+		toByte := func(v float32) uint8 {
+			return uint8(math.Round(float64(clamp01(v)) * 255))
+		}
+
+		parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+			for i := input.Rect.Min.Y + workerNum; i < input.Rect.Max.Y; i += workerCount {
+				for j := input.Rect.Min.X; j < input.Rect.Max.X; j++ {
+					c := input.NRGBAAt(j, i)
+					r, g, b := lut.Lookup(float32(c.R)/255, float32(c.G)/255, float32(c.B)/255, interpolation)
+					result.SetNRGBA(j, i, color.NRGBA{R: toByte(r), G: toByte(g), B: toByte(b), A: c.A})
+				}
+			}
+		})
+
+		return result
+	}
+}
+
+// ParseCubeLUT reads a 3D LUT in the Adobe/Resolve .cube format.
+func ParseCubeLUT(r io.Reader) (*LUT3D, error) {
+	lut := &LUT3D{DomainMax: [3]float32{1, 1, 1}}
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+
+	parseTriplet := func(fields []string) ([3]float32, error) {
+		var result [3]float32
+		if len(fields) != 3 {
+			return result, fmt.Errorf("line %d: expected 3 values but got %d", lineNum, len(fields))
+		}
+		for i, f := range fields {
+			v, err := strconv.ParseFloat(f, 32)
+			if err != nil {
+				return result, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+			result[i] = float32(v)
+		}
+		return result, nil
+	}
+
+	for scanner.Scan() {
+		lineNum++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+
+		switch fields[0] {
+		case "TITLE":
+			continue
+
+		case "LUT_1D_SIZE":
+			return nil, errors.New("1D LUTs are not supported")
+
+		case "LUT_3D_SIZE":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: invalid LUT_3D_SIZE", lineNum)
+			}
+			size, err := strconv.Atoi(fields[1])
+			if err != nil || size < 2 || size > 256 {
+				return nil, fmt.Errorf("line %d: invalid LUT_3D_SIZE %q", lineNum, fields[1])
+			}
+			lut.Size = size
+			lut.Data = make([]float32, 0, size*size*size*3)
+
+		case "DOMAIN_MIN", "DOMAIN_MAX":
+			v, err := parseTriplet(fields[1:])
+			if err != nil {
+				return nil, err
+			}
+			if fields[0] == "DOMAIN_MIN" {
+				lut.DomainMin = v
+			} else {
+				lut.DomainMax = v
+			}
+
+		default:
+			if lut.Size == 0 {
+				return nil, fmt.Errorf("line %d: data before LUT_3D_SIZE", lineNum)
+			}
+			v, err := parseTriplet(fields)
+			if err != nil {
+				return nil, err
+			}
+			if len(lut.Data) == cap(lut.Data) {
+				return nil, fmt.Errorf("line %d: more points than LUT_3D_SIZE allows", lineNum)
+			}
+			lut.Data = append(lut.Data, v[0], v[1], v[2])
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if lut.Size == 0 {
+		return nil, errors.New("missing LUT_3D_SIZE")
+	}
+	if expected := lut.Size * lut.Size * lut.Size * 3; len(lut.Data) != expected {
+		return nil, fmt.Errorf("expected %d points but got %d", expected/3, len(lut.Data)/3)
+	}
+	for c := 0; c < 3; c++ {
+		if lut.DomainMax[c] <= lut.DomainMin[c] {
+			return nil, errors.New("DOMAIN_MAX must be greater than DOMAIN_MIN")
+		}
+	}
+
+	return lut, nil
+}
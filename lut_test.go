@@ -0,0 +1,121 @@
+package convolver
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func cubeLUTSource(size int, f func(r, g, b float64) (float64, float64, float64)) string {
+	var sb strings.Builder
+	sb.WriteString("TITLE \"test\"\n# comment\n")
+	fmt.Fprintf(&sb, "LUT_3D_SIZE %d\n", size)
+
+	for b := 0; b < size; b++ {
+		for g := 0; g < size; g++ {
+			for r := 0; r < size; r++ {
+				s := float64(size - 1)
+				or, og, ob := f(float64(r)/s, float64(g)/s, float64(b)/s)
+				fmt.Fprintf(&sb, "%f %f %f\n", or, og, ob)
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+func TestParseCubeLUT(t *testing.T) {
+
+	t.Run("reads points with red varying fastest", func(t *testing.T) {
+		lut, err := ParseCubeLUT(strings.NewReader(cubeLUTSource(2, func(r, g, b float64) (float64, float64, float64) {
+			return r, g, b
+		})))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if expected, actual := 2, lut.Size; expected != actual {
+			t.Errorf("Expected size %d but was %d", expected, actual)
+		}
+		if expected, actual := []float32{1, 0, 0}, lut.Data[3:6]; expected[0] != actual[0] || expected[1] != actual[1] || expected[2] != actual[2] {
+			t.Errorf("Expected second point to be %v but was %v", expected, actual)
+		}
+	})
+
+	t.Run("rejects invalid files", func(t *testing.T) {
+		cases := []struct {
+			Name   string
+			Source string
+		}{
+			{Name: "missing size", Source: "0 0 0\n"},
+			{Name: "1D LUT", Source: "LUT_1D_SIZE 2\n0 0 0\n1 1 1\n"},
+			{Name: "too few points", Source: "LUT_3D_SIZE 2\n0 0 0\n"},
+			{Name: "too many points", Source: cubeLUTSource(2, func(r, g, b float64) (float64, float64, float64) { return r, g, b }) + "0 0 0\n"},
+			{Name: "malformed value", Source: "LUT_3D_SIZE 2\n0 x 0\n"},
+			{Name: "empty domain", Source: "DOMAIN_MIN 1 1 1\nDOMAIN_MAX 1 1 1\n" + cubeLUTSource(2, func(r, g, b float64) (float64, float64, float64) { return r, g, b })},
+		}
+
+		for _, c := range cases {
+			if _, err := ParseCubeLUT(strings.NewReader(c.Source)); err == nil {
+				t.Errorf("Expected an error for %s but got none", c.Name)
+			}
+		}
+	})
+}
+
+func TestLUT3D(t *testing.T) {
+	affine := func(r, g, b float64) (float64, float64, float64) {
+		return b, 1 - g, 0.5*r + 0.25
+	}
+
+	lut, err := ParseCubeLUT(strings.NewReader(cubeLUTSource(5, affine)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, interpolation := range []LUTInterpolation{LUTTrilinear, LUTTetrahedral} {
+
+		t.Run(fmt.Sprintf("interpolation %d reproduces affine mappings exactly", interpolation), func(t *testing.T) {
+			for _, in := range [][3]float32{{0, 0, 0}, {1, 1, 1}, {0.3, 0.7, 0.1}, {0.9, 0.2, 0.55}, {0.6, 0.6, 0.6}} {
+				er, eg, eb := affine(float64(in[0]), float64(in[1]), float64(in[2]))
+				r, g, b := lut.Lookup(in[0], in[1], in[2], interpolation)
+
+				for _, pair := range [][2]float64{{er, float64(r)}, {eg, float64(g)}, {eb, float64(b)}} {
+					if math.Abs(pair[0]-pair[1]) > 1e-5 {
+						t.Errorf("Expected %v to map to %v, %v, %v but got %v, %v, %v", in, er, eg, eb, r, g, b)
+						break
+					}
+				}
+			}
+		})
+	}
+
+	t.Run("clamps inputs to the domain", func(t *testing.T) {
+		r, g, b := lut.Lookup(-1, 2, 0.5, LUTTrilinear)
+		er, eg, eb := affine(0, 1, 0.5)
+
+		if math.Abs(float64(r)-er) > 1e-5 || math.Abs(float64(g)-eg) > 1e-5 || math.Abs(float64(b)-eb) > 1e-5 {
+			t.Errorf("Expected clamped lookup to be %v, %v, %v but was %v, %v, %v", er, eg, eb, r, g, b)
+		}
+	})
+
+	t.Run("applies as a stage on encoded values", func(t *testing.T) {
+		img := randomImage(16, 16)
+		result := LUTStage(lut, LUTTetrahedral)(img, runtime.NumCPU())
+
+		for i := img.Rect.Min.Y; i < img.Rect.Max.Y; i++ {
+			for j := img.Rect.Min.X; j < img.Rect.Max.X; j++ {
+				in, out := img.NRGBAAt(j, i), result.NRGBAAt(j, i)
+
+				if expected, actual := int(255-in.G), int(out.G); expected != actual && expected != actual+1 && expected != actual-1 {
+					t.Fatalf("Expected green at %d,%d to be inverted to %d but was %d", j, i, expected, actual)
+				}
+				if in.A != out.A {
+					t.Fatalf("Expected alpha at %d,%d to be unchanged", j, i)
+				}
+			}
+		}
+	})
+}
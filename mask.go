@@ -0,0 +1,100 @@
+package convolver
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// LinearGradientMask returns a mask which ramps linearly from 0 at the point
+// from to 255 at the point to, and is constant beyond those points.
+func LinearGradientMask(bounds image.Rectangle, from, to image.Point) *image.Gray {
+	mask := image.NewGray(bounds)
+
+	dx, dy := float64(to.X-from.X), float64(to.Y-from.Y)
+	lengthSquared := dx*dx + dy*dy
+
+	for i := bounds.Min.Y; i < bounds.Max.Y; i++ {
+		for j := bounds.Min.X; j < bounds.Max.X; j++ {
+			t := 1.0
+			if lengthSquared > 0 {
+				t = (float64(j-from.X)*dx + float64(i-from.Y)*dy) / lengthSquared
+			}
+			mask.Pix[mask.PixOffset(j, i)] = maskValue(t)
+		}
+	}
+
+	return mask
+}
+
+// RadialGradientMask returns a mask which is 255 within innerRadius of the
+// centre, falling linearly to 0 at outerRadius.
+func RadialGradientMask(bounds image.Rectangle, centre image.Point, innerRadius, outerRadius float64) *image.Gray {
+	mask := image.NewGray(bounds)
+
+	for i := bounds.Min.Y; i < bounds.Max.Y; i++ {
+		for j := bounds.Min.X; j < bounds.Max.X; j++ {
+			dist := math.Hypot(float64(j-centre.X), float64(i-centre.Y))
+
+			t := 0.0
+			switch {
+			case dist <= innerRadius:
+				t = 1
+			case dist < outerRadius:
+				t = (outerRadius - dist) / (outerRadius - innerRadius)
+			}
+			mask.Pix[mask.PixOffset(j, i)] = maskValue(t)
+		}
+	}
+
+	return mask
+}
+
+// BlueNoiseMask returns a mask of blue noise: noise with an even distribution
+// of values but little low-frequency content, so it has no visible clumps.
+// This is suitable for dithering and for breaking up banding in soft effect
+// falloffs. The result is deterministic for a given seed.
+func BlueNoiseMask(bounds image.Rectangle, seed int64, parallelism int) *image.Gray {
+	noise := NewPlane(bounds)
+	for i := bounds.Min.Y; i < bounds.Max.Y; i++ {
+		for j := bounds.Min.X; j < bounds.Max.X; j++ {
+			noise.Pix[noise.offset(j, i)] = sampleUniform(seed, j, i, 0)
+		}
+	}
+
+	// High-pass filter the white noise by removing a blurred copy of it
+	lowPass := noise
+	for _, radius := range gaussianBoxRadii(1, 3) {
+		lowPass = boxBlurPlane(lowPass, radius, parallelism)
+	}
+	for i := range noise.Pix {
+		noise.Pix[i] -= lowPass.Pix[i]
+	}
+
+	// Equalise so that all mask values are equally represented
+	order := make([]int, len(noise.Pix))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return noise.Pix[order[a]] < noise.Pix[order[b]]
+	})
+
+	mask := image.NewGray(bounds)
+	for rank, index := range order {
+		y, x := index/noise.Stride, index%noise.Stride
+		mask.Pix[y*mask.Stride+x] = uint8(rank * 256 / len(order))
+	}
+
+	return mask
+}
+
+func maskValue(t float64) uint8 {
+	if t <= 0 {
+		return 0
+	}
+	if t >= 1 {
+		return 255
+	}
+	return uint8(math.Round(t * 255))
+}
@@ -0,0 +1,98 @@
+package convolver
+
+import (
+	"image"
+	"runtime"
+	"testing"
+)
+
+func TestLinearGradientMask(t *testing.T) {
+	mask := LinearGradientMask(image.Rect(0, 0, 11, 3), image.Pt(0, 1), image.Pt(10, 1))
+
+	cases := []struct {
+		X        int
+		Expected uint8
+	}{
+		{X: 0, Expected: 0},
+		{X: 5, Expected: 128},
+		{X: 10, Expected: 255},
+	}
+
+	for _, c := range cases {
+		for y := 0; y < 3; y++ {
+			if expected, actual := c.Expected, mask.GrayAt(c.X, y).Y; expected != actual {
+				t.Errorf("Expected mask value at %d,%d to be %d but was %d", c.X, y, expected, actual)
+			}
+		}
+	}
+}
+
+func TestRadialGradientMask(t *testing.T) {
+	mask := RadialGradientMask(image.Rect(0, 0, 21, 21), image.Pt(10, 10), 4, 8)
+
+	cases := []struct {
+		Point    image.Point
+		Expected uint8
+	}{
+		{Point: image.Pt(10, 10), Expected: 255},
+		{Point: image.Pt(14, 10), Expected: 255},
+		{Point: image.Pt(10, 16), Expected: 128},
+		{Point: image.Pt(18, 10), Expected: 0},
+		{Point: image.Pt(0, 0), Expected: 0},
+	}
+
+	for _, c := range cases {
+		if expected, actual := c.Expected, mask.GrayAt(c.Point.X, c.Point.Y).Y; expected != actual {
+			t.Errorf("Expected mask value at %v to be %d but was %d", c.Point, expected, actual)
+		}
+	}
+}
+
+func TestBlueNoiseMask(t *testing.T) {
+	bounds := image.Rect(0, 0, 64, 64)
+	mask := BlueNoiseMask(bounds, 1, runtime.NumCPU())
+
+	t.Run("has a uniform histogram", func(t *testing.T) {
+		histogram := [256]int{}
+		for _, v := range mask.Pix {
+			histogram[v]++
+		}
+
+		for v, count := range histogram {
+			if expected := len(mask.Pix) / 256; count != expected {
+				t.Fatalf("Expected value %d to occur %d times but occurred %d times", v, expected, count)
+			}
+		}
+	})
+
+	t.Run("has less low-frequency content than white noise", func(t *testing.T) {
+		neighbourDiff := func(img *image.Gray) int {
+			sum := 0
+			for i := bounds.Min.Y; i < bounds.Max.Y; i++ {
+				for j := bounds.Min.X + 1; j < bounds.Max.X; j++ {
+					sum += int(absDiff(img.GrayAt(j, i).Y, img.GrayAt(j-1, i).Y))
+				}
+			}
+			return sum
+		}
+
+		white := image.NewGray(bounds)
+		for i := range white.Pix {
+			white.Pix[i] = uint8(sampleHash(1, i, 0, 0))
+		}
+
+		if whiteDiff, blueDiff := neighbourDiff(white), neighbourDiff(mask); blueDiff <= whiteDiff {
+			t.Errorf("Expected blue noise neighbour difference %d to exceed white noise difference %d", blueDiff, whiteDiff)
+		}
+	})
+
+	t.Run("is deterministic for a given seed", func(t *testing.T) {
+		other := BlueNoiseMask(bounds, 1, 1)
+
+		for i := range mask.Pix {
+			if mask.Pix[i] != other.Pix[i] {
+				t.Fatalf("Expected masks to match but differ at index %d", i)
+			}
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package convolver
+
+import (
+	"fmt"
+	"github.com/mandykoh/go-parallel"
+	"github.com/mandykoh/prism"
+	"github.com/mandykoh/prism/srgb"
+	"image"
+)
+
+// MatteSource selects which property of an image Matte thresholds.
+type MatteSource int
+
+const (
+	// MatteAlpha thresholds each pixel's alpha.
+	MatteAlpha MatteSource = iota
+
+	// MatteLuminance thresholds each pixel's linear luminance, ignoring
+	// alpha, as for a scanned mask or a greenscreen key already rendered to
+	// greyscale.
+	MatteLuminance
+)
+
+// Matte converts an image's alpha or luminance into a binary matte, which is
+// 255 where the value is at least threshold (between 0 and 1) and 0
+// elsewhere. This is the usual first step before dilating or eroding a
+// shape, since soft or noisy edges would otherwise spread unevenly.
+//
+// If cleanupRadius is positive, the matte is then opened and closed with a
+// square of that radius, removing specks and filling holes smaller than the
+// square while leaving larger shapes unchanged.
+func Matte(img image.Image, source MatteSource, threshold float32, cleanupRadius int, parallelism int) *image.Gray {
+	if source < MatteAlpha || source > MatteLuminance {
+		panic(fmt.Sprintf("unknown matte source %d", int(source)))
+	}
+	if cleanupRadius < 0 {
+		panic(fmt.Sprintf("cleanup radius must not be negative but was %d", cleanupRadius))
+	}
+
+	input := prism.ConvertImageToNRGBA(img, parallelism)
+	bounds := input.Rect
+	matte := image.NewGray(bounds)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := bounds.Min.Y + workerNum; i < bounds.Max.Y; i += workerCount {
+			for j := bounds.Min.X; j < bounds.Max.X; j++ {
+				c, a := srgb.ColorFromNRGBA(input.NRGBAAt(j, i))
+
+				v := a
+				if source == MatteLuminance {
+					v = c.ToXYZ().Y
+				}
+
+				if v >= threshold {
+					matte.Pix[matte.PixOffset(j, i)] = 255
+				}
+			}
+		}
+	})
+
+	if cleanupRadius == 0 {
+		return matte
+	}
+
+	kernel := KernelWithRadius(cleanupRadius)
+	weights := make([]float32, kernel.sideLength*kernel.sideLength)
+	for i := range weights {
+		weights[i] = 1
+	}
+	kernel.SetWeightsUniform(weights)
+
+	cleaned := image.Image(matte)
+	for _, apply := range []Stage{kernel.ApplyMin, kernel.ApplyMax, kernel.ApplyMax, kernel.ApplyMin} {
+		cleaned = apply(cleaned, parallelism)
+	}
+
+	nrgba := cleaned.(*image.NRGBA)
+	for i := bounds.Min.Y; i < bounds.Max.Y; i++ {
+		for j := bounds.Min.X; j < bounds.Max.X; j++ {
+			matte.Pix[matte.PixOffset(j, i)] = nrgba.NRGBAAt(j, i).R
+		}
+	}
+
+	return matte
+}
@@ -0,0 +1,84 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestMatte(t *testing.T) {
+
+	t.Run("thresholds alpha", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(1, 2, 4, 3))
+		img.SetNRGBA(1, 2, color.NRGBA{R: 255, A: 127})
+		img.SetNRGBA(2, 2, color.NRGBA{A: 128})
+		img.SetNRGBA(3, 2, color.NRGBA{A: 255})
+
+		matte := Matte(img, MatteAlpha, 0.5, 0, 2)
+
+		for j, expected := range []uint8{0, 255, 255} {
+			if actual := matte.GrayAt(1+j, 2).Y; expected != actual {
+				t.Errorf("Expected matte at %d to be %d but was %d", 1+j, expected, actual)
+			}
+		}
+	})
+
+	t.Run("thresholds linear luminance", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+		img.SetNRGBA(0, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 0})
+		img.SetNRGBA(1, 0, color.NRGBA{R: 180, G: 180, B: 180, A: 255})
+		img.SetNRGBA(2, 0, color.NRGBA{R: 100, G: 100, B: 100, A: 255})
+
+		matte := Matte(img, MatteLuminance, 0.25, 0, 2)
+
+		for j, expected := range []uint8{255, 255, 0} {
+			if actual := matte.GrayAt(j, 0).Y; expected != actual {
+				t.Errorf("Expected matte at %d to be %d but was %d", j, expected, actual)
+			}
+		}
+	})
+
+	t.Run("removes specks and fills holes with cleanup", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 20, 20))
+		for i := 5; i < 15; i++ {
+			for j := 5; j < 15; j++ {
+				img.SetNRGBA(j, i, color.NRGBA{A: 255})
+			}
+		}
+		img.SetNRGBA(10, 10, color.NRGBA{})
+		img.SetNRGBA(1, 1, color.NRGBA{A: 255})
+
+		raw := Matte(img, MatteAlpha, 0.5, 0, 2)
+		matte := Matte(img, MatteAlpha, 0.5, 1, 2)
+
+		if expected, actual := uint8(255), raw.GrayAt(1, 1).Y; expected != actual {
+			t.Errorf("Expected speck to be %d without cleanup but was %d", expected, actual)
+		}
+		if expected, actual := uint8(0), matte.GrayAt(1, 1).Y; expected != actual {
+			t.Errorf("Expected speck to be removed but was %d", actual)
+		}
+		if expected, actual := uint8(255), matte.GrayAt(10, 10).Y; expected != actual {
+			t.Errorf("Expected hole to be filled but was %d", actual)
+		}
+		for i := 0; i < 20; i++ {
+			for j := 0; j < 20; j++ {
+				if j == 1 && i == 1 || j == 10 && i == 10 {
+					continue
+				}
+				if expected, actual := raw.GrayAt(j, i).Y, matte.GrayAt(j, i).Y; expected != actual {
+					t.Fatalf("Expected matte at %d,%d to be unchanged at %d but was %d", j, i, expected, actual)
+				}
+			}
+		}
+	})
+
+	t.Run("panics with a negative cleanup radius", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic but none occurred")
+			}
+		}()
+
+		Matte(image.NewNRGBA(image.Rect(0, 0, 1, 1)), MatteAlpha, 0.5, -1, 1)
+	})
+}
@@ -0,0 +1,67 @@
+package convolver
+
+import (
+	"github.com/mandykoh/go-parallel"
+	"sync"
+	"time"
+)
+
+// Metrics receives measurements of the work done by kernels, so that services
+// can monitor filtering without wrapping every call. Methods may be called
+// concurrently and should return quickly.
+//
+// The measurements map directly onto Prometheus metric types: ObserveImage can
+// increment a counter of images processed and observe a histogram of
+// durations, and AddActiveWorkers can add to a gauge of busy workers.
+type Metrics interface {
+	// ObserveImage is called each time a kernel has been applied to an image,
+	// with the time it took.
+	ObserveImage(duration time.Duration)
+
+	// AddActiveWorkers is called with 1 as each worker starts processing an
+	// image and with -1 as it finishes.
+	AddActiveWorkers(delta int)
+}
+
+var metricsMutex sync.RWMutex
+var metrics Metrics
+
+// SetMetrics sets the Metrics which receives measurements from all subsequent
+// kernel applications, such as ApplyAvg or ApplyMode, in the process. Passing
+// nil stops measurements being reported.
+func SetMetrics(m Metrics) {
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+
+	metrics = m
+}
+
+func currentMetrics() Metrics {
+	metricsMutex.RLock()
+	defer metricsMutex.RUnlock()
+
+	return metrics
+}
+
+// observeImage reports the time elapsed since start to m, if it is not nil.
+func observeImage(m Metrics, start time.Time) {
+	if m != nil {
+		m.ObserveImage(time.Since(start))
+	}
+}
+
+// runWorkers is like parallel.RunWorkers, but reports each worker as active to
+// m while it runs, if m is not nil.
+func runWorkers(m Metrics, parallelism int, f func(workerNum, workerCount int)) {
+	if m == nil {
+		parallel.RunWorkers(parallelism, f)
+		return
+	}
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		m.AddActiveWorkers(1)
+		defer m.AddActiveWorkers(-1)
+
+		f(workerNum, workerCount)
+	})
+}
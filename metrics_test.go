@@ -0,0 +1,86 @@
+package convolver
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingMetrics struct {
+	mutex         sync.Mutex
+	images        int
+	activeWorkers int
+	peakWorkers   int
+}
+
+func (m *recordingMetrics) ObserveImage(duration time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.images++
+}
+
+func (m *recordingMetrics) AddActiveWorkers(delta int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.activeWorkers += delta
+	if m.activeWorkers > m.peakWorkers {
+		m.peakWorkers = m.activeWorkers
+	}
+}
+
+func TestSetMetrics(t *testing.T) {
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{1, 1, 1, 1, 1, 1, 1, 1, 1})
+
+	img := randomImage(16, 16)
+
+	t.Run("reports images processed and active workers", func(t *testing.T) {
+		m := &recordingMetrics{}
+		SetMetrics(m)
+		defer SetMetrics(nil)
+
+		kernel.ApplyAvg(img, 2)
+		kernel.ApplyMax(img.SubImage(img.Rect), 2)
+
+		if expected, actual := 2, m.images; expected != actual {
+			t.Errorf("Expected %d images to be reported but was %d", expected, actual)
+		}
+		if expected, actual := 0, m.activeWorkers; expected != actual {
+			t.Errorf("Expected %d active workers after completion but was %d", expected, actual)
+		}
+		if m.peakWorkers < 1 || m.peakWorkers > 2 {
+			t.Errorf("Expected peak active workers to be between 1 and 2 but was %d", m.peakWorkers)
+		}
+	})
+
+	t.Run("reports workers of memory bounded applications", func(t *testing.T) {
+		m := &recordingMetrics{}
+		SetMetrics(m)
+		defer SetMetrics(nil)
+
+		bounded := kernel
+		bounded.SetMaxMemoryBytes(256)
+		bounded.ApplyAvg(img.SubImage(img.Rect), 2)
+
+		if expected, actual := 1, m.images; expected != actual {
+			t.Errorf("Expected %d image to be reported but was %d", expected, actual)
+		}
+		if m.peakWorkers < 1 {
+			t.Errorf("Expected workers to be reported")
+		}
+	})
+
+	t.Run("stops reporting when cleared", func(t *testing.T) {
+		m := &recordingMetrics{}
+		SetMetrics(m)
+		SetMetrics(nil)
+
+		kernel.ApplyAvg(img, 2)
+
+		if m.images != 0 || m.peakWorkers != 0 {
+			t.Errorf("Expected no measurements but got %d images and %d workers", m.images, m.peakWorkers)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package convolver
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sync"
+)
+
+// ApplyMode applies the Mode operation with the given bucket size to an image.
+func (k *Kernel) ApplyMode(img image.Image, bucketSize int, parallelism int) *image.NRGBA {
+	return k.applyImage(img, k.Mode(bucketSize), parallelism)
+}
+
+// Mode returns an operation which finds the most common colour within the
+// kernel, after quantising each channel into buckets of the given size, and
+// returns the average colour of the pixels in that bucket. Each tap's vote is
+// the magnitude of its weight, and taps with zero weight are ignored; ties are
+// resolved in favour of the bucket found first in row-major order.
+//
+// With a large bucket size this produces the classic "oil painting" effect.
+// Colours are compared and averaged as encoded values, not linear ones.
+func (k *Kernel) Mode(bucketSize int) OpFunc {
+	if bucketSize < 1 {
+		panic(fmt.Sprintf("bucket size must be positive but was %d", bucketSize))
+	}
+
+	// Operations run on many workers at once, so each borrows its own
+	// buckets rather than allocating them for every pixel.
+	scratch := sync.Pool{
+		New: func() interface{} {
+			buckets := make([]modeBucket, 0, k.sideLength*k.sideLength)
+			return &buckets
+		},
+	}
+
+	return func(img *image.NRGBA, x, y int) color.NRGBA {
+		clip := k.clipToBounds(img.Rect, x, y)
+
+		pooled := scratch.Get().(*[]modeBucket)
+		defer scratch.Put(pooled)
+		buckets := (*pooled)[:0]
+
+		for s := clip.Top; s < k.sideLength-clip.Bottom; s++ {
+			for t := clip.Left; t < k.sideLength-clip.Right; t++ {
+				weight := k.weights[s*k.sideLength+t].magnitude()
+				if weight == 0 {
+					continue
+				}
+
+				c := img.NRGBAAt(x+t-k.radius, y+s-k.radius)
+				key := [4]uint8{
+					uint8(int(c.R) / bucketSize),
+					uint8(int(c.G) / bucketSize),
+					uint8(int(c.B) / bucketSize),
+					uint8(int(c.A) / bucketSize),
+				}
+
+				index := 0
+				for index < len(buckets) && buckets[index].key != key {
+					index++
+				}
+				if index == len(buckets) {
+					buckets = append(buckets, modeBucket{key: key})
+				}
+
+				b := &buckets[index]
+				b.votes += weight
+				b.sum[0] += float32(c.R) * weight
+				b.sum[1] += float32(c.G) * weight
+				b.sum[2] += float32(c.B) * weight
+				b.sum[3] += float32(c.A) * weight
+			}
+		}
+		*pooled = buckets
+
+		if len(buckets) == 0 {
+			return color.NRGBA{}
+		}
+
+		// Buckets are in the order they were first found, so keeping the
+		// earliest of any tied for the most votes favours the one found
+		// first in row-major order.
+		best := 0
+		for i := range buckets {
+			if buckets[i].votes > buckets[best].votes {
+				best = i
+			}
+		}
+
+		b := buckets[best]
+		return color.NRGBA{
+			R: uint8(b.sum[0]/b.votes + 0.5),
+			G: uint8(b.sum[1]/b.votes + 0.5),
+			B: uint8(b.sum[2]/b.votes + 0.5),
+			A: uint8(b.sum[3]/b.votes + 0.5),
+		}
+	}
+}
+
+type modeBucket struct {
+	key   [4]uint8
+	votes float32
+	sum   [4]float32
+}
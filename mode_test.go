@@ -0,0 +1,100 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+)
+
+func TestMode(t *testing.T) {
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{
+		1, 1, 1,
+		1, 1, 1,
+		1, 1, 1,
+	})
+
+	red := color.NRGBA{R: 200, A: 255}
+	blue := color.NRGBA{B: 200, A: 255}
+
+	t.Run("returns the most common colour", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 3, 3))
+		for i := 0; i < 9; i++ {
+			c := red
+			if i%3 == 0 {
+				c = blue
+			}
+			img.SetNRGBA(i%3, i/3, c)
+		}
+
+		result := kernel.ApplyMode(img, 1, runtime.NumCPU())
+
+		if expected, actual := red, result.NRGBAAt(1, 1); expected != actual {
+			t.Errorf("Expected mode to be %+v but was %+v", expected, actual)
+		}
+		if expected, actual := blue, result.NRGBAAt(0, 2); expected != actual {
+			t.Errorf("Expected tie at clipped edge to favour the first colour %+v but was %+v", expected, actual)
+		}
+	})
+
+	t.Run("resolves ties in favour of the bucket found first in row-major order", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 3, 3))
+		colours := []color.NRGBA{
+			red, blue, blue,
+			red, {G: 10, A: 255}, {G: 20, A: 255},
+			{G: 30, A: 255}, {G: 40, A: 255}, {G: 50, A: 255},
+		}
+		for i, c := range colours {
+			img.SetNRGBA(i%3, i/3, c)
+		}
+
+		result := kernel.ApplyMode(img, 1, runtime.NumCPU())
+
+		if expected, actual := red, result.NRGBAAt(1, 1); expected != actual {
+			t.Errorf("Expected tie to favour %+v but was %+v", expected, actual)
+		}
+	})
+
+	t.Run("averages the colours in the winning bucket", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+		img.SetNRGBA(0, 0, color.NRGBA{R: 100, A: 255})
+		img.SetNRGBA(1, 0, color.NRGBA{R: 110, A: 255})
+		img.SetNRGBA(2, 0, color.NRGBA{R: 200, A: 255})
+
+		result := kernel.ApplyMode(img, 32, runtime.NumCPU())
+
+		if expected, actual := (color.NRGBA{R: 105, A: 255}), result.NRGBAAt(1, 0); expected != actual {
+			t.Errorf("Expected mode to be %+v but was %+v", expected, actual)
+		}
+	})
+
+	t.Run("weights votes by the kernel", func(t *testing.T) {
+		weighted := KernelWithRadius(1)
+		weighted.SetWeightsUniform([]float32{
+			0, 0, 0,
+			1, 3, 1,
+			0, 0, 0,
+		})
+
+		img := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+		img.SetNRGBA(0, 0, red)
+		img.SetNRGBA(1, 0, blue)
+		img.SetNRGBA(2, 0, red)
+
+		result := weighted.ApplyMode(img, 1, runtime.NumCPU())
+
+		if expected, actual := blue, result.NRGBAAt(1, 0); expected != actual {
+			t.Errorf("Expected mode to be %+v but was %+v", expected, actual)
+		}
+	})
+
+	t.Run("panics for non-positive bucket sizes", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic")
+			}
+		}()
+		kernel.Mode(0)
+	})
+}
@@ -0,0 +1,118 @@
+package convolver
+
+import (
+	"fmt"
+	"github.com/mandykoh/go-parallel"
+	"image"
+	"math"
+)
+
+// GaussianNoise returns a stage which adds independent Gaussian noise with
+// standard deviation sigma to each linear colour channel, leaving alpha
+// unchanged. The noise is deterministic for a given seed, regardless of
+// parallelism, so that denoising filters can be evaluated reproducibly.
+func GaussianNoise(sigma float32, seed int64) Stage {
+	if sigma < 0 {
+		panic(fmt.Sprintf("noise standard deviation must not be negative but was %v", sigma))
+	}
+
+	return positionalStage(func(v float32, x, y, c int) float32 {
+		return v + sigma*noiseNormal(seed, x, y, c)
+	})
+}
+
+// PoissonNoise returns a stage which simulates photon shot noise, treating
+// each linear colour channel value multiplied by scale as the expected number
+// of photons and replacing it with a Poisson distributed count (divided by
+// scale again). Lower scales give noisier results. Alpha is left unchanged and
+// the noise is deterministic for a given seed.
+func PoissonNoise(scale float32, seed int64) Stage {
+	if scale <= 0 {
+		panic(fmt.Sprintf("photon scale must be positive but was %v", scale))
+	}
+
+	return positionalStage(func(v float32, x, y, c int) float32 {
+		return noisePoisson(seed, x, y, c, v*scale) / scale
+	})
+}
+
+// noiseNormal returns a standard normally distributed value for channel c of
+// the pixel at x, y, using the Box-Muller transform.
+func noiseNormal(seed int64, x, y, c int) float32 {
+	u1 := 1 - float64(sampleUniform(seed, x, y, c*2))
+	u2 := float64(sampleUniform(seed, x, y, c*2+1))
+
+	return float32(math.Sqrt(-2*math.Log(u1)) * math.Cos(2*math.Pi*u2))
+}
+
+// noisePoisson returns a Poisson distributed value with the given mean for
+// channel c of the pixel at x, y. Large means are approximated by a normal
+// distribution.
+func noisePoisson(seed int64, x, y, c int, mean float32) float32 {
+	if mean <= 0 {
+		return 0
+	}
+
+	if mean > 30 {
+		v := mean + float32(math.Sqrt(float64(mean)))*noiseNormal(seed, x, y, c)
+		if v < 0 {
+			return 0
+		}
+		return float32(math.Round(float64(v)))
+	}
+
+	limit := math.Exp(-float64(mean))
+	p := 1.0
+	count := -1
+
+	for p > limit {
+		count++
+		p *= float64(sampleUniform(seed, x, y, 8+c*128+count))
+	}
+
+	return float32(count)
+}
+
+// EstimateNoise returns an estimate of the standard deviation of additive
+// noise in the linear luminance of an image, using Immerkær's method: the
+// image is filtered with a 3×3 kernel which cancels out smooth variation, and
+// the mean magnitude of the response is scaled to a standard deviation.
+// Strong edges and fine texture also contribute, so the estimate is biased
+// upwards for highly detailed images. Images smaller than 3×3 give zero.
+func EstimateNoise(img image.Image, parallelism int) float64 {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	luminance := LuminancePlane(img, parallelism)
+	bounds := luminance.Rect
+
+	if bounds.Dx() < 3 || bounds.Dy() < 3 {
+		return 0
+	}
+
+	sums := make([]float64, parallelism)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := bounds.Min.Y + 1 + workerNum; i < bounds.Max.Y-1; i += workerCount {
+			for j := bounds.Min.X + 1; j < bounds.Max.X-1; j++ {
+				v := func(dx, dy int) float64 {
+					return float64(luminance.Pix[luminance.offset(j+dx, i+dy)])
+				}
+
+				response := v(-1, -1) - 2*v(0, -1) + v(1, -1) -
+					2*v(-1, 0) + 4*v(0, 0) - 2*v(1, 0) +
+					v(-1, 1) - 2*v(0, 1) + v(1, 1)
+
+				sums[workerNum] += math.Abs(response)
+			}
+		}
+	})
+
+	var sum float64
+	for _, s := range sums {
+		sum += s
+	}
+
+	return math.Sqrt(math.Pi/2) * sum / float64(6*(bounds.Dx()-2)*(bounds.Dy()-2))
+}
@@ -0,0 +1,155 @@
+package convolver
+
+import (
+	"image"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func TestGaussianNoise(t *testing.T) {
+	img := NewFloatImage(image.Rect(0, 0, 64, 64))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = 0.2, 0.2, 0.2, 1
+	}
+
+	t.Run("adds noise with the given standard deviation", func(t *testing.T) {
+		result := FloatImageFromImage(GaussianNoise(0.05, 1)(img, runtime.NumCPU()), runtime.NumCPU())
+
+		sum, sumSquares := 0.0, 0.0
+		n := 0
+		for i := 0; i < len(result.Pix); i += 4 {
+			d := float64(result.Pix[i] - 0.2)
+			sum += d
+			sumSquares += d * d
+			n++
+		}
+		mean := sum / float64(n)
+		stdDev := math.Sqrt(sumSquares/float64(n) - mean*mean)
+
+		if math.Abs(mean) > 0.005 {
+			t.Errorf("Expected noise to have zero mean but was %v", mean)
+		}
+		if math.Abs(stdDev-0.05) > 0.005 {
+			t.Errorf("Expected noise standard deviation to be 0.05 but was %v", stdDev)
+		}
+	})
+
+	t.Run("is reproducible for a seed regardless of parallelism", func(t *testing.T) {
+		a := GaussianNoise(0.05, 7)(img, 1)
+		b := GaussianNoise(0.05, 7)(img, runtime.NumCPU())
+		c := GaussianNoise(0.05, 8)(img, 1)
+
+		differs := false
+		for i := range a.Pix {
+			if a.Pix[i] != b.Pix[i] {
+				t.Fatalf("Expected results to match but differ at byte %d", i)
+			}
+			differs = differs || a.Pix[i] != c.Pix[i]
+		}
+		if !differs {
+			t.Errorf("Expected different seeds to give different noise")
+		}
+	})
+
+	t.Run("leaves alpha unchanged", func(t *testing.T) {
+		result := GaussianNoise(0.5, 1)(img, runtime.NumCPU())
+
+		for i := 3; i < len(result.Pix); i += 4 {
+			if result.Pix[i] != 255 {
+				t.Fatalf("Expected alpha to be unchanged but was %d at byte %d", result.Pix[i], i)
+			}
+		}
+	})
+}
+
+func TestPoissonNoise(t *testing.T) {
+
+	t.Run("produces counts with variance equal to the mean", func(t *testing.T) {
+		for _, mean := range []float32{4, 100} {
+			sum, sumSquares := 0.0, 0.0
+			n := 0
+			for i := 0; i < 64; i++ {
+				for j := 0; j < 64; j++ {
+					v := float64(noisePoisson(3, j, i, 0, mean))
+					sum += v
+					sumSquares += v * v
+					n++
+				}
+			}
+			sampleMean := sum / float64(n)
+			variance := sumSquares/float64(n) - sampleMean*sampleMean
+
+			if math.Abs(sampleMean-float64(mean)) > float64(mean)*0.05 {
+				t.Errorf("Expected mean %v but was %v", mean, sampleMean)
+			}
+			if math.Abs(variance-float64(mean)) > float64(mean)*0.15 {
+				t.Errorf("Expected variance to equal the mean %v but was %v", mean, variance)
+			}
+		}
+	})
+
+	t.Run("is noisier at lower scales", func(t *testing.T) {
+		img := NewFloatImage(image.Rect(0, 0, 64, 64))
+		for i := 0; i < len(img.Pix); i += 4 {
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = 0.5, 0.5, 0.5, 1
+		}
+
+		deviation := func(scale float32) float64 {
+			result := FloatImageFromImage(PoissonNoise(scale, 1)(img, runtime.NumCPU()), runtime.NumCPU())
+			sumSquares := 0.0
+			for i := 0; i < len(result.Pix); i += 4 {
+				d := float64(result.Pix[i] - 0.5)
+				sumSquares += d * d
+			}
+			return math.Sqrt(sumSquares / float64(len(result.Pix)/4))
+		}
+
+		if low, high := deviation(20), deviation(2000); low <= high {
+			t.Errorf("Expected deviation at low scale (%v) to exceed that at high scale (%v)", low, high)
+		}
+	})
+}
+
+func TestEstimateNoise(t *testing.T) {
+	img := NewFloatImage(image.Rect(0, 0, 128, 128))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = 0.2, 0.2, 0.2, 1
+	}
+
+	t.Run("is near zero for a flat image", func(t *testing.T) {
+		if estimate := EstimateNoise(img, runtime.NumCPU()); estimate > 0.001 {
+			t.Errorf("Expected estimate to be near zero but was %v", estimate)
+		}
+	})
+
+	t.Run("matches the standard deviation of added noise", func(t *testing.T) {
+		noisy := GaussianNoise(0.02, 1)(img, runtime.NumCPU())
+
+		// Independent noise in each channel is reduced in luminance by the
+		// magnitude of the luminance coefficients
+		expected := 0.02 * math.Sqrt(0.2126*0.2126+0.7152*0.7152+0.0722*0.0722)
+		estimate := EstimateNoise(noisy, runtime.NumCPU())
+
+		if math.Abs(estimate-expected) > expected*0.2 {
+			t.Errorf("Expected estimate to be near %v but was %v", expected, estimate)
+		}
+	})
+
+	t.Run("treats parallelism below one as a single worker", func(t *testing.T) {
+		noisy := GaussianNoise(0.02, 1)(img, runtime.NumCPU())
+		expected := EstimateNoise(noisy, 1)
+
+		for _, parallelism := range []int{0, -2} {
+			if actual := EstimateNoise(noisy, parallelism); expected != actual {
+				t.Errorf("Expected estimate with parallelism %d to be %v but was %v", parallelism, expected, actual)
+			}
+		}
+	})
+
+	t.Run("is zero for images too small to filter", func(t *testing.T) {
+		if estimate := EstimateNoise(randomImage(2, 5), 1); estimate != 0 {
+			t.Errorf("Expected estimate to be zero but was %v", estimate)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package convolver
+
+import (
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var cgroupLimitOnce sync.Once
+var cgroupLimit int
+
+// DefaultParallelism returns the number of workers which can usefully run at
+// once: the current GOMAXPROCS setting, further limited by any CPU quota
+// imposed on the process's cgroup, as in a container with a CPU limit. Using
+// it rather than runtime.NumCPU avoids oversubscribing the quota, which would
+// otherwise see workers throttled by the kernel.
+//
+// GOMAXPROCS is checked on every call, so changes made at runtime are
+// followed. The cgroup quota is read once.
+func DefaultParallelism() int {
+	cgroupLimitOnce.Do(func() {
+		cgroupLimit = cgroupCPULimit("/sys/fs/cgroup")
+	})
+
+	n := runtime.GOMAXPROCS(0)
+	if cgroupLimit > 0 && cgroupLimit < n {
+		n = cgroupLimit
+	}
+
+	return n
+}
+
+// cgroupCPULimit returns the number of CPUs allowed by the CPU quota of the
+// cgroup hierarchy mounted at root, rounded up, or zero if there is no quota.
+// Both the unified (v2) hierarchy and the v1 cpu controller are supported.
+func cgroupCPULimit(root string) int {
+	if data, err := ioutil.ReadFile(filepath.Join(root, "cpu.max")); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) == 2 {
+			return cpuQuotaLimit(fields[0], fields[1])
+		}
+		return 0
+	}
+
+	for _, dir := range []string{"cpu", "cpu,cpuacct"} {
+		quota, err := ioutil.ReadFile(filepath.Join(root, dir, "cpu.cfs_quota_us"))
+		if err != nil {
+			continue
+		}
+		period, err := ioutil.ReadFile(filepath.Join(root, dir, "cpu.cfs_period_us"))
+		if err != nil {
+			continue
+		}
+		return cpuQuotaLimit(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+	}
+
+	return 0
+}
+
+// cpuQuotaLimit returns the number of CPUs a quota and period allow, rounded
+// up, or zero if the quota is unlimited or unparseable.
+func cpuQuotaLimit(quota, period string) int {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0
+	}
+
+	return int(math.Max(1, math.Ceil(q/p)))
+}
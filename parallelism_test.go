@@ -0,0 +1,66 @@
+package convolver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestDefaultParallelism(t *testing.T) {
+	t.Run("does not exceed GOMAXPROCS", func(t *testing.T) {
+		previous := runtime.GOMAXPROCS(1)
+		defer runtime.GOMAXPROCS(previous)
+
+		if expected, actual := 1, DefaultParallelism(); expected != actual {
+			t.Errorf("Expected parallelism to be %d but was %d", expected, actual)
+		}
+	})
+}
+
+func TestCgroupCPULimit(t *testing.T) {
+	writeFiles := func(t *testing.T, files map[string]string) string {
+		root, err := ioutil.TempDir("", "cgroup")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for name, content := range files {
+			path := filepath.Join(root, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return root
+	}
+
+	cases := []struct {
+		name     string
+		files    map[string]string
+		expected int
+	}{
+		{"v2 quota", map[string]string{"cpu.max": "250000 100000\n"}, 3},
+		{"v2 fractional quota", map[string]string{"cpu.max": "50000 100000\n"}, 1},
+		{"v2 unlimited", map[string]string{"cpu.max": "max 100000\n"}, 0},
+		{"v1 quota", map[string]string{"cpu/cpu.cfs_quota_us": "200000\n", "cpu/cpu.cfs_period_us": "100000\n"}, 2},
+		{"v1 combined controller", map[string]string{"cpu,cpuacct/cpu.cfs_quota_us": "400000\n", "cpu,cpuacct/cpu.cfs_period_us": "100000\n"}, 4},
+		{"v1 unlimited", map[string]string{"cpu/cpu.cfs_quota_us": "-1\n", "cpu/cpu.cfs_period_us": "100000\n"}, 0},
+		{"no cgroup", nil, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			root := writeFiles(t, c.files)
+			defer os.RemoveAll(root)
+
+			if actual := cgroupCPULimit(root); c.expected != actual {
+				t.Errorf("Expected limit to be %d but was %d", c.expected, actual)
+			}
+		})
+	}
+}
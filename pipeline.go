@@ -0,0 +1,98 @@
+package convolver
+
+import (
+	"github.com/mandykoh/prism"
+	"image"
+)
+
+// Stage is a single step of a Pipeline. The Apply methods of a Kernel, such as
+// kernel.ApplyAvg, can be used directly as stages.
+type Stage func(img image.Image, parallelism int) *image.NRGBA
+
+// Pipeline is a sequence of stages, each applied to the result of the one
+// before it.
+type Pipeline struct {
+	stages []Stage
+	plans  []PlanFunc
+}
+
+// SnapshotFunc receives the intermediate result after each step of a pipeline
+// or repeated stage, with steps numbered from 1. The image is passed on to the
+// next step and must not be modified.
+type SnapshotFunc func(step int, img *image.NRGBA)
+
+// Apply applies each stage of the pipeline in turn. The input image is given
+// to the first stage as is, so a pipeline beginning with a stage which accepts
+// a FloatImage (such as a tone mapping stage) can process HDR input.
+func (p *Pipeline) Apply(img image.Image, parallelism int) *image.NRGBA {
+	return p.ApplyWithSnapshots(img, nil, parallelism)
+}
+
+// ApplyWithSnapshots is like Apply, but calls onStage with the result of each
+// stage as it is completed, so that the progression through the pipeline can
+// be inspected.
+func (p *Pipeline) ApplyWithSnapshots(img image.Image, onStage SnapshotFunc, parallelism int) *image.NRGBA {
+	if len(p.stages) == 0 {
+		return prism.ConvertImageToNRGBA(img, parallelism)
+	}
+
+	result := p.stages[0](img, parallelism)
+	if onStage != nil {
+		onStage(1, result)
+	}
+
+	for i, stage := range p.stages[1:] {
+		result = stage(result, parallelism)
+		if onStage != nil {
+			onStage(i+2, result)
+		}
+	}
+
+	return result
+}
+
+func (p *Pipeline) Stages() []Stage {
+	return append([]Stage(nil), p.stages...)
+}
+
+func NewPipeline(stages ...Stage) *Pipeline {
+	return &Pipeline{
+		stages: append([]Stage(nil), stages...),
+		plans:  make([]PlanFunc, len(stages)),
+	}
+}
+
+// NewPlannedPipeline returns a pipeline of stages which can describe how they
+// will be executed, as reported by Explain.
+func NewPlannedPipeline(stages ...PlannedStage) *Pipeline {
+	p := &Pipeline{}
+
+	for _, s := range stages {
+		p.stages = append(p.stages, s.Stage)
+		p.plans = append(p.plans, s.Plan)
+	}
+
+	return p
+}
+
+// Repeat returns a stage which applies the given stage the specified number of
+// times in succession.
+func Repeat(stage Stage, passes int) Stage {
+	return RepeatWithSnapshots(stage, passes, nil)
+}
+
+// RepeatWithSnapshots is like Repeat, but calls onPass with the result of each
+// pass, so that the effect of varying the number of passes can be seen
+// without re-running the stage.
+func RepeatWithSnapshots(stage Stage, passes int, onPass SnapshotFunc) Stage {
+	return func(img image.Image, parallelism int) *image.NRGBA {
+		result := prism.ConvertImageToNRGBA(img, parallelism)
+		for i := 0; i < passes; i++ {
+			result = stage(result, parallelism)
+			if onPass != nil {
+				onPass(i+1, result)
+			}
+		}
+		return result
+	}
+}
@@ -0,0 +1,124 @@
+package convolver
+
+import (
+	"image"
+	"runtime"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	img := randomImage(24, 24)
+
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{
+		0, 1, 0,
+		1, 1, 1,
+		0, 1, 0,
+	})
+
+	t.Run("Apply()", func(t *testing.T) {
+
+		t.Run("applies stages in order", func(t *testing.T) {
+			expectedImg := kernel.ApplyMin(kernel.ApplyMax(img, runtime.NumCPU()), runtime.NumCPU())
+
+			pipeline := NewPipeline(kernel.ApplyMax, kernel.ApplyMin)
+			result := pipeline.Apply(img, runtime.NumCPU())
+
+			for i := range expectedImg.Pix {
+				if expectedImg.Pix[i] != result.Pix[i] {
+					t.Fatalf("Expected results to match but differ at byte %d", i)
+				}
+			}
+		})
+
+		t.Run("returns the input unchanged for an empty pipeline", func(t *testing.T) {
+			result := NewPipeline().Apply(img, runtime.NumCPU())
+
+			for i := range img.Pix {
+				if img.Pix[i] != result.Pix[i] {
+					t.Fatalf("Expected results to match but differ at byte %d", i)
+				}
+			}
+		})
+	})
+
+	t.Run("Repeat()", func(t *testing.T) {
+		calls := 0
+		counting := func(img image.Image, parallelism int) *image.NRGBA {
+			calls++
+			return kernel.ApplyAvg(img, parallelism)
+		}
+
+		expectedImg := img
+		for i := 0; i < 3; i++ {
+			expectedImg = kernel.ApplyAvg(expectedImg, runtime.NumCPU())
+		}
+
+		result := Repeat(counting, 3)(img, runtime.NumCPU())
+
+		if expected, actual := 3, calls; expected != actual {
+			t.Errorf("Expected stage to be applied %d times but was applied %d times", expected, actual)
+		}
+		for i := range expectedImg.Pix {
+			if expectedImg.Pix[i] != result.Pix[i] {
+				t.Fatalf("Expected results to match but differ at byte %d", i)
+			}
+		}
+	})
+
+	t.Run("ApplyWithSnapshots()", func(t *testing.T) {
+		var steps []int
+		var snapshots []*image.NRGBA
+
+		pipeline := NewPipeline(kernel.ApplyMax, kernel.ApplyMin)
+		result := pipeline.ApplyWithSnapshots(img, func(step int, snapshot *image.NRGBA) {
+			steps = append(steps, step)
+			snapshots = append(snapshots, snapshot)
+		}, runtime.NumCPU())
+
+		if expected, actual := 2, len(steps); expected != actual {
+			t.Fatalf("Expected %d snapshots but got %d", expected, actual)
+		}
+		if steps[0] != 1 || steps[1] != 2 {
+			t.Errorf("Expected snapshots to be numbered 1 and 2 but were %v", steps)
+		}
+
+		expectedFirst := kernel.ApplyMax(img, runtime.NumCPU())
+		for i := range expectedFirst.Pix {
+			if expectedFirst.Pix[i] != snapshots[0].Pix[i] {
+				t.Fatalf("Expected first snapshot to match first stage but differs at byte %d", i)
+			}
+		}
+		if snapshots[1] != result {
+			t.Errorf("Expected last snapshot to be the result")
+		}
+	})
+
+	t.Run("RepeatWithSnapshots()", func(t *testing.T) {
+		expectedImg := img
+		passes := 0
+
+		result := RepeatWithSnapshots(kernel.ApplyAvg, 3, func(step int, snapshot *image.NRGBA) {
+			passes++
+			expectedImg = kernel.ApplyAvg(expectedImg, runtime.NumCPU())
+
+			if expected, actual := passes, step; expected != actual {
+				t.Errorf("Expected pass %d but got %d", expected, actual)
+			}
+			for i := range expectedImg.Pix {
+				if expectedImg.Pix[i] != snapshot.Pix[i] {
+					t.Fatalf("Expected snapshot of pass %d to match but differs at byte %d", step, i)
+				}
+			}
+		})(img, runtime.NumCPU())
+
+		if expected, actual := 3, passes; expected != actual {
+			t.Errorf("Expected %d snapshots but got %d", expected, actual)
+		}
+		for i := range expectedImg.Pix {
+			if expectedImg.Pix[i] != result.Pix[i] {
+				t.Fatalf("Expected results to match but differ at byte %d", i)
+			}
+		}
+	})
+}
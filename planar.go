@@ -0,0 +1,132 @@
+package convolver
+
+import (
+	"fmt"
+	"github.com/mandykoh/go-parallel"
+	"image"
+)
+
+// PlaneFromBuffer wraps an existing buffer of float32 values, such as the
+// backing slice of a gonum matrix or memory shared with C, as a Plane of the
+// given size without copying it. Rows start stride values apart. Changes made
+// through the plane are visible in the buffer and vice versa.
+func PlaneFromBuffer(pix []float32, width, height, stride int) *Plane {
+	if width < 0 || height < 0 {
+		panic(fmt.Sprintf("plane dimensions must not be negative but were %dx%d", width, height))
+	}
+	if stride < width {
+		panic(fmt.Sprintf("stride must be at least the width %d but was %d", width, stride))
+	}
+	if height > 0 && len(pix) < (height-1)*stride+width {
+		panic(fmt.Sprintf("buffer of length %d is too short for %dx%d values with stride %d", len(pix), width, height, stride))
+	}
+
+	return &Plane{
+		Pix:    pix,
+		Stride: stride,
+		Rect:   image.Rect(0, 0, width, height),
+	}
+}
+
+// FloatImageFromPlanes combines separate planes of linear red, green, blue
+// and alpha values, which must all be the same size, into a FloatImage. If
+// the alpha plane is nil, the image is opaque.
+func FloatImageFromPlanes(r, g, b, a *Plane, parallelism int) *FloatImage {
+	width, height := r.Rect.Dx(), r.Rect.Dy()
+	for _, p := range []*Plane{g, b, a} {
+		if p != nil && (p.Rect.Dx() != width || p.Rect.Dy() != height) {
+			panic(fmt.Sprintf("planes must be the same size but were %dx%d and %dx%d", width, height, p.Rect.Dx(), p.Rect.Dy()))
+		}
+	}
+
+	result := NewFloatImage(image.Rect(0, 0, width, height))
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := workerNum; i < height; i += workerCount {
+			row := result.Pix[i*result.Stride:]
+
+			for j := 0; j < width; j++ {
+				row[j*4] = r.Pix[i*r.Stride+j]
+				row[j*4+1] = g.Pix[i*g.Stride+j]
+				row[j*4+2] = b.Pix[i*b.Stride+j]
+				row[j*4+3] = 1
+				if a != nil {
+					row[j*4+3] = a.Pix[i*a.Stride+j]
+				}
+			}
+		}
+	})
+
+	return result
+}
+
+// ExportPlanes copies the linear red, green, blue and alpha values of the
+// image into caller-owned planes, such as ones wrapping external buffers
+// with PlaneFromBuffer, which must be the same size as the image. Channels
+// whose plane is nil are skipped.
+func (f *FloatImage) ExportPlanes(r, g, b, a *Plane, parallelism int) {
+	width, height := f.Rect.Dx(), f.Rect.Dy()
+	planes := [4]*Plane{r, g, b, a}
+
+	for _, p := range planes {
+		if p != nil && (p.Rect.Dx() != width || p.Rect.Dy() != height) {
+			panic(fmt.Sprintf("planes must be %dx%d to match the image but were %dx%d", width, height, p.Rect.Dx(), p.Rect.Dy()))
+		}
+	}
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := workerNum; i < height; i += workerCount {
+			row := f.Pix[i*f.Stride:]
+
+			for c, p := range planes {
+				if p == nil {
+					continue
+				}
+				dst := p.Pix[i*p.Stride:]
+				for j := 0; j < width; j++ {
+					dst[j] = row[j*4+c]
+				}
+			}
+		}
+	})
+}
+
+// ApplyAvgPlane applies the kernel's red weights to a single plane of values
+// as Avg does, writing the result into dst, which must be the same size as
+// src and must not share its buffer. Values are used as they are, without
+// conversion to or from linear light, so arbitrary numerical data can be
+// filtered directly between caller-owned buffers without copying.
+func (k *Kernel) ApplyAvgPlane(src, dst *Plane, parallelism int) {
+	width, height := src.Rect.Dx(), src.Rect.Dy()
+	if dst.Rect.Dx() != width || dst.Rect.Dy() != height {
+		panic(fmt.Sprintf("destination plane must be %dx%d to match the source but was %dx%d", width, height, dst.Rect.Dx(), dst.Rect.Dy()))
+	}
+
+	bounds := image.Rect(0, 0, width, height)
+
+	parallel.RunWorkers(k.powerMode.workers(parallelism), func(workerNum, workerCount int) {
+		for i := workerNum; i < height; i += workerCount {
+			for j := 0; j < width; j++ {
+				clip := k.clipToBounds(bounds, j, i)
+
+				var totalWeight, sum float32
+
+				for s := clip.Top; s < k.sideLength-clip.Bottom; s++ {
+					row := src.Pix[(i+s-k.radius)*src.Stride:]
+
+					for t := clip.Left; t < k.sideLength-clip.Right; t++ {
+						weight := k.weights[s*k.sideLength+t].R
+						totalWeight += weight
+						sum += row[j+t-k.radius] * weight
+					}
+				}
+
+				if totalWeight > 0 {
+					sum /= totalWeight
+				}
+
+				dst.Pix[i*dst.Stride+j] = sum
+			}
+		}
+	})
+}
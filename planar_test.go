@@ -0,0 +1,81 @@
+package convolver
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPlanarBuffers(t *testing.T) {
+
+	t.Run("wraps buffers without copying", func(t *testing.T) {
+		buffer := make([]float32, 3*5)
+		p := PlaneFromBuffer(buffer, 4, 3, 5)
+
+		p.SetValue(3, 2, 7)
+
+		if expected, actual := float32(7), buffer[2*5+3]; expected != actual {
+			t.Errorf("Expected buffer value to be %v but was %v", expected, actual)
+		}
+	})
+
+	t.Run("panics for buffers too short for the stride", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic but none occurred")
+			}
+		}()
+
+		PlaneFromBuffer(make([]float32, 13), 4, 3, 5)
+	})
+
+	t.Run("round trips planes through a FloatImage", func(t *testing.T) {
+		r := PlaneFromBuffer([]float32{0.1, 0.2, 9, 0.3, 0.4, 9}, 2, 2, 3)
+		g := PlaneFromBuffer([]float32{1, 2, 3, 4}, 2, 2, 2)
+		b := PlaneFromBuffer([]float32{-1, -2, -3, -4}, 2, 2, 2)
+
+		img := FloatImageFromPlanes(r, g, b, nil, 2)
+
+		if r, g, b, a := img.RGBAAt(1, 1); r != 0.4 || g != 4 || b != -4 || a != 1 {
+			t.Errorf("Expected pixel to be 0.4, 4, -4, 1 but was %v, %v, %v, %v", r, g, b, a)
+		}
+
+		outR := PlaneFromBuffer(make([]float32, 8), 2, 2, 4)
+		outA := PlaneFromBuffer(make([]float32, 4), 2, 2, 2)
+		img.ExportPlanes(outR, nil, nil, outA, 2)
+
+		for i, expected := range []float32{0.1, 0.2, 0, 0, 0.3, 0.4, 0, 0} {
+			if actual := outR.Pix[i]; expected != actual {
+				t.Errorf("Expected exported red value %d to be %v but was %v", i, expected, actual)
+			}
+		}
+		for i, actual := range outA.Pix {
+			if actual != 1 {
+				t.Errorf("Expected exported alpha value %d to be 1 but was %v", i, actual)
+			}
+		}
+	})
+
+	t.Run("filters a plane into a caller-owned buffer", func(t *testing.T) {
+		src := PlaneFromBuffer([]float32{
+			0, 0, 0, 0,
+			0, 90, 0, 0,
+			0, 0, 0, 0,
+		}, 4, 3, 4)
+		dst := PlaneFromBuffer(make([]float32, 12), 4, 3, 4)
+
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightsUniform([]float32{1, 1, 1, 1, 1, 1, 1, 1, 1})
+		kernel.ApplyAvgPlane(src, dst, 2)
+
+		expected := []float32{
+			90.0 / 4, 90.0 / 6, 90.0 / 6, 0,
+			90.0 / 6, 90.0 / 9, 90.0 / 9, 0,
+			90.0 / 4, 90.0 / 6, 90.0 / 6, 0,
+		}
+		for i := range expected {
+			if math.Abs(float64(expected[i]-dst.Pix[i])) > 1e-5 {
+				t.Errorf("Expected value %d to be %v but was %v", i, expected[i], dst.Pix[i])
+			}
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package convolver
+
+import (
+	"github.com/mandykoh/go-parallel"
+	"github.com/mandykoh/prism"
+	"github.com/mandykoh/prism/srgb"
+	"image"
+	"image/color"
+)
+
+// Plane is a single channel of float32 values, such as a linear luminance
+// channel or the signed response of a derivative filter. Values are unbounded
+// and are stored row by row in Pix, in the same layout as image.Gray.
+//
+// When used as an image.Image, values are interpreted as linear intensities
+// and are sRGB encoded and clipped to 0.0–1.0.
+type Plane struct {
+	Pix    []float32
+	Stride int
+	Rect   image.Rectangle
+}
+
+func (p *Plane) At(x, y int) color.Color {
+	return color.Gray{Y: srgb.To8Bit(p.ValueAt(x, y))}
+}
+
+func (p *Plane) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *Plane) ColorModel() color.Model {
+	return color.GrayModel
+}
+
+func (p *Plane) SetValue(x, y int, v float32) {
+	if !(image.Point{X: x, Y: y}.In(p.Rect)) {
+		return
+	}
+	p.Pix[p.offset(x, y)] = v
+}
+
+func (p *Plane) ValueAt(x, y int) float32 {
+	if !(image.Point{X: x, Y: y}.In(p.Rect)) {
+		return 0
+	}
+	return p.Pix[p.offset(x, y)]
+}
+
+func (p *Plane) offset(x, y int) int {
+	return (y-p.Rect.Min.Y)*p.Stride + (x - p.Rect.Min.X)
+}
+
+func LuminancePlane(img image.Image, parallelism int) *Plane {
+	nrgba := prism.ConvertImageToNRGBA(img, parallelism)
+	result := NewPlane(nrgba.Rect)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := result.Rect.Min.Y + workerNum; i < result.Rect.Max.Y; i += workerCount {
+			for j := result.Rect.Min.X; j < result.Rect.Max.X; j++ {
+				c, _ := srgb.ColorFromNRGBA(nrgba.NRGBAAt(j, i))
+				result.Pix[result.offset(j, i)] = c.ToXYZ().Y
+			}
+		}
+	})
+
+	return result
+}
+
+func NewPlane(r image.Rectangle) *Plane {
+	return &Plane{
+		Pix:    make([]float32, bufferLength(r, 1)),
+		Stride: r.Dx(),
+		Rect:   r,
+	}
+}
+
+// convolvePlane computes the unnormalised weighted sum of the kernel over a
+// plane, replicating edge values for samples falling outside its bounds.
+// Planes have only one channel, so only the red channel weights are used.
+func (k *Kernel) convolvePlane(p *Plane, parallelism int) *Plane {
+	bounds := p.Rect
+	result := NewPlane(bounds)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := bounds.Min.Y + workerNum; i < bounds.Max.Y; i += workerCount {
+			for j := bounds.Min.X; j < bounds.Max.X; j++ {
+				sum := float32(0)
+
+				for s := 0; s < k.sideLength; s++ {
+					y := clampInt(i+s-k.radius, bounds.Min.Y, bounds.Max.Y-1)
+
+					for t := 0; t < k.sideLength; t++ {
+						weight := k.weights[s*k.sideLength+t].R
+						if weight == 0 {
+							continue
+						}
+						x := clampInt(j+t-k.radius, bounds.Min.X, bounds.Max.X-1)
+						sum += p.Pix[p.offset(x, y)] * weight
+					}
+				}
+
+				result.Pix[result.offset(j, i)] = sum
+			}
+		}
+	})
+
+	return result
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// linearPlanes splits an image into planes of linear R, G, B, and alpha
+// values.
+func linearPlanes(img *image.NRGBA, parallelism int) [4]*Plane {
+	planes := [4]*Plane{NewPlane(img.Rect), NewPlane(img.Rect), NewPlane(img.Rect), NewPlane(img.Rect)}
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := img.Rect.Min.Y + workerNum; i < img.Rect.Max.Y; i += workerCount {
+			for j := img.Rect.Min.X; j < img.Rect.Max.X; j++ {
+				c, a := srgb.ColorFromNRGBA(img.NRGBAAt(j, i))
+				offset := planes[0].offset(j, i)
+
+				planes[0].Pix[offset] = c.R
+				planes[1].Pix[offset] = c.G
+				planes[2].Pix[offset] = c.B
+				planes[3].Pix[offset] = a
+			}
+		}
+	})
+
+	return planes
+}
+
+// nrgbaFromPlanes combines planes of linear R, G, B, and alpha values into an
+// sRGB encoded image.
+func nrgbaFromPlanes(planes [4]*Plane, parallelism int) *image.NRGBA {
+	bounds := planes[0].Rect
+	result := image.NewNRGBA(bounds)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := bounds.Min.Y + workerNum; i < bounds.Max.Y; i += workerCount {
+			for j := bounds.Min.X; j < bounds.Max.X; j++ {
+				offset := planes[0].offset(j, i)
+				c := srgb.ColorFromLinear(planes[0].Pix[offset], planes[1].Pix[offset], planes[2].Pix[offset])
+				result.SetNRGBA(j, i, c.ToNRGBA(planes[3].Pix[offset]))
+			}
+		}
+	})
+
+	return result
+}
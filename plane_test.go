@@ -0,0 +1,113 @@
+package convolver
+
+import (
+	"github.com/mandykoh/prism/srgb"
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+)
+
+func TestPlane(t *testing.T) {
+
+	t.Run("ValueAt() and SetValue()", func(t *testing.T) {
+		plane := NewPlane(image.Rect(10, 20, 13, 22))
+
+		plane.SetValue(12, 21, 0.5)
+
+		if expected, actual := float32(0.5), plane.ValueAt(12, 21); expected != actual {
+			t.Errorf("Expected value to be %v but was %v", expected, actual)
+		}
+
+		t.Run("ignores coordinates outside bounds", func(t *testing.T) {
+			plane.SetValue(13, 21, 1)
+
+			if expected, actual := float32(0), plane.ValueAt(13, 21); expected != actual {
+				t.Errorf("Expected value outside bounds to be %v but was %v", expected, actual)
+			}
+		})
+	})
+
+	t.Run("At()", func(t *testing.T) {
+		plane := NewPlane(image.Rect(0, 0, 1, 1))
+
+		cases := []struct {
+			Value    float32
+			Expected color.Gray
+		}{
+			{Value: 0, Expected: color.Gray{Y: 0}},
+			{Value: 0.5, Expected: color.Gray{Y: srgb.To8Bit(0.5)}},
+			{Value: 1, Expected: color.Gray{Y: 255}},
+			{Value: -3, Expected: color.Gray{Y: 0}},
+			{Value: 7, Expected: color.Gray{Y: 255}},
+		}
+
+		for _, c := range cases {
+			plane.SetValue(0, 0, c.Value)
+
+			if expected, actual := c.Expected, plane.At(0, 0); expected != actual {
+				t.Errorf("Expected value %v to be encoded as %+v but was %+v", c.Value, expected, actual)
+			}
+		}
+	})
+
+	t.Run("LuminancePlane()", func(t *testing.T) {
+		img := randomImage(16, 16)
+		plane := LuminancePlane(img, runtime.NumCPU())
+
+		if expected, actual := img.Rect, plane.Rect; expected != actual {
+			t.Fatalf("Expected plane bounds to be %v but was %v", expected, actual)
+		}
+
+		for i := img.Rect.Min.Y; i < img.Rect.Max.Y; i++ {
+			for j := img.Rect.Min.X; j < img.Rect.Max.X; j++ {
+				c, _ := srgb.ColorFromNRGBA(img.NRGBAAt(j, i))
+
+				if expected, actual := c.ToXYZ().Y, plane.ValueAt(j, i); expected != actual {
+					t.Fatalf("Expected luminance at %d,%d to be %v but was %v", j, i, expected, actual)
+				}
+			}
+		}
+	})
+
+	t.Run("convolvePlane()", func(t *testing.T) {
+		plane := NewPlane(image.Rect(0, 0, 4, 1))
+		copy(plane.Pix, []float32{1, 2, 3, 4})
+
+		t.Run("computes unnormalised sums", func(t *testing.T) {
+			kernel := KernelWithRadius(1)
+			kernel.SetWeightsUniform([]float32{
+				0, 0, 0,
+				-1, 0, 1,
+				0, 0, 0,
+			})
+
+			result := kernel.convolvePlane(plane, runtime.NumCPU())
+
+			expected := []float32{1, 2, 2, 1}
+			for i, v := range expected {
+				if actual := result.Pix[i]; v != actual {
+					t.Errorf("Expected value %d to be %v but was %v", i, v, actual)
+				}
+			}
+		})
+
+		t.Run("replicates edge values", func(t *testing.T) {
+			kernel := KernelWithRadius(1)
+			kernel.SetWeightsUniform([]float32{
+				0, 0, 0,
+				1, 0, 0,
+				0, 0, 0,
+			})
+
+			result := kernel.convolvePlane(plane, runtime.NumCPU())
+
+			expected := []float32{1, 1, 2, 3}
+			for i, v := range expected {
+				if actual := result.Pix[i]; v != actual {
+					t.Errorf("Expected value %d to be %v but was %v", i, v, actual)
+				}
+			}
+		})
+	})
+}
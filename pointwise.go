@@ -0,0 +1,208 @@
+package convolver
+
+import (
+	"fmt"
+	"github.com/mandykoh/go-parallel"
+	"github.com/mandykoh/prism/srgb"
+	"image"
+	"math"
+)
+
+// Exposure returns a stage which scales linear colour values by 2 to the power
+// of stops, as adjusting a camera's exposure would.
+func Exposure(stops float32) Stage {
+	scale := float32(math.Exp2(float64(stops)))
+
+	return pointwiseStage(func(r, g, b float32) (float32, float32, float32) {
+		return r * scale, g * scale, b * scale
+	})
+}
+
+// Gamma returns a stage which raises linear colour values to the power of
+// 1 / gamma, so that gamma values above 1 brighten mid tones and values below
+// 1 darken them, leaving black and white unchanged. Negative values are
+// clipped to zero.
+func Gamma(gamma float32) Stage {
+	if gamma <= 0 {
+		panic(fmt.Sprintf("gamma must be positive but was %v", gamma))
+	}
+
+	exponent := 1 / float64(gamma)
+	curve := func(v float32) float32 {
+		if v <= 0 {
+			return 0
+		}
+		return float32(math.Pow(float64(v), exponent))
+	}
+
+	return pointwiseStage(func(r, g, b float32) (float32, float32, float32) {
+		return curve(r), curve(g), curve(b)
+	})
+}
+
+// Levels returns a stage which linearly remaps colour values so that the
+// black point becomes 0 and the white point becomes 1. Points are given as
+// linear values, and HDR input such as a FloatImage is read without
+// clipping, so a white point above 1 brings highlights back into range. The
+// result is an 8-bit image, so remapped values beyond the black and white
+// points are clipped.
+func Levels(black, white float32) Stage {
+	if white <= black {
+		panic(fmt.Sprintf("white point %v must be greater than black point %v", white, black))
+	}
+
+	scale := 1 / (white - black)
+
+	return pointwiseStage(func(r, g, b float32) (float32, float32, float32) {
+		return (r - black) * scale, (g - black) * scale, (b - black) * scale
+	})
+}
+
+// autoLevelsBins is the number of histogram bins used to find the black and
+// white points for AutoLevels.
+const autoLevelsBins = 4096
+
+// AutoLevels returns a stage which stretches contrast as Levels does, with
+// the black and white points found from the image's linear histogram. The
+// black point is the value below which the fraction low of pixels fall in the
+// darkest colour channel, and the white point is the value below which the
+// fraction high fall in the brightest, so that a small proportion of outliers
+// such as dust or specular highlights is clipped rather than limiting the
+// stretch. Fractions of 0.005 and 0.995 are typical.
+//
+// Images whose points are too close together to be resolved by the
+// histogram, such as flat images, are returned unchanged.
+func AutoLevels(low, high float64) Stage {
+	if low < 0 || high > 1 || low >= high {
+		panic(fmt.Sprintf("percentiles must satisfy 0 <= low < high <= 1 but were %v and %v", low, high))
+	}
+
+	return func(img image.Image, parallelism int) *image.NRGBA {
+		input := FloatImageFromImage(img, parallelism)
+		h := ComputeHistogram(input, autoLevelsBins, HistogramLinear, parallelism)
+
+		black, white := float32(1), float32(0)
+		for c := 0; c < 3; c++ {
+			if v := h.Percentile(c, low); v < black {
+				black = v
+			}
+			if v := h.Percentile(c, high); v > white {
+				white = v
+			}
+		}
+
+		if white-black <= 1.0/autoLevelsBins {
+			return nrgbaFromFloatImage(input, parallelism)
+		}
+
+		return Levels(black, white)(input, parallelism)
+	}
+}
+
+// WhiteBalance returns a stage which scales each linear colour channel so that
+// the given neutral colour, such as that of a grey card or a white wall under
+// the scene's lighting, becomes a grey of the same luminance. The neutral
+// colour is given in linear light, and each of its channels must be positive.
+func WhiteBalance(neutralR, neutralG, neutralB float32) Stage {
+	if neutralR <= 0 || neutralG <= 0 || neutralB <= 0 {
+		panic(fmt.Sprintf("neutral colour channels must be positive but were %v, %v, %v", neutralR, neutralG, neutralB))
+	}
+
+	luminance := 0.2126*neutralR + 0.7152*neutralG + 0.0722*neutralB
+	scaleR, scaleG, scaleB := luminance/neutralR, luminance/neutralG, luminance/neutralB
+
+	return pointwiseStage(func(r, g, b float32) (float32, float32, float32) {
+		return r * scaleR, g * scaleG, b * scaleB
+	})
+}
+
+// GreyWorld returns a stage which white balances an image on the assumption
+// that the scene averages to grey, using the mean linear colour of its pixels
+// as the neutral colour for WhiteBalance. Pixels are weighted by their alpha.
+// Images whose mean has a channel of zero are returned unchanged.
+func GreyWorld() Stage {
+	return func(img image.Image, parallelism int) *image.NRGBA {
+		if parallelism < 1 {
+			parallelism = 1
+		}
+
+		input := FloatImageFromImage(img, parallelism)
+		sums := make([][4]float64, parallelism)
+
+		parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+			sum := &sums[workerNum]
+
+			for i := input.Rect.Min.Y + workerNum; i < input.Rect.Max.Y; i += workerCount {
+				for j := input.Rect.Min.X; j < input.Rect.Max.X; j++ {
+					r, g, b, a := input.RGBAAt(j, i)
+					sum[0] += float64(r * a)
+					sum[1] += float64(g * a)
+					sum[2] += float64(b * a)
+					sum[3] += float64(a)
+				}
+			}
+		})
+
+		var total [4]float64
+		for _, sum := range sums {
+			for c := range total {
+				total[c] += sum[c]
+			}
+		}
+
+		if total[0] <= 0 || total[1] <= 0 || total[2] <= 0 {
+			return nrgbaFromFloatImage(input, parallelism)
+		}
+
+		return WhiteBalance(float32(total[0]/total[3]), float32(total[1]/total[3]), float32(total[2]/total[3]))(input, parallelism)
+	}
+}
+
+// pointwiseFunc maps a linear colour to a new linear colour.
+type pointwiseFunc func(r, g, b float32) (float32, float32, float32)
+
+// pointwiseStage returns a stage which applies f to the linear colour of each
+// pixel independently, leaving alpha unchanged. A FloatImage input is used
+// without clamping, so such stages can be applied to HDR images.
+func pointwiseStage(f pointwiseFunc) Stage {
+	return func(img image.Image, parallelism int) *image.NRGBA {
+		input := FloatImageFromImage(img, parallelism)
+		result := image.NewNRGBA(input.Rect)
+
+		parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+			for i := input.Rect.Min.Y + workerNum; i < input.Rect.Max.Y; i += workerCount {
+				for j := input.Rect.Min.X; j < input.Rect.Max.X; j++ {
+					r, g, b, a := input.RGBAAt(j, i)
+					r, g, b = f(r, g, b)
+					result.SetNRGBA(j, i, srgb.ColorFromLinear(r, g, b).ToNRGBA(a))
+				}
+			}
+		})
+
+		return result
+	}
+}
+
+// positionalStage returns a stage which replaces each linear colour channel
+// value using f, leaving alpha unchanged. Unlike pointwiseStage, f is given
+// the pixel position and channel index, so that results can vary across the
+// image, such as by drawing reproducible random samples or following a
+// pattern.
+func positionalStage(f func(v float32, x, y, c int) float32) Stage {
+	return func(img image.Image, parallelism int) *image.NRGBA {
+		input := FloatImageFromImage(img, parallelism)
+		result := image.NewNRGBA(input.Rect)
+
+		parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+			for i := input.Rect.Min.Y + workerNum; i < input.Rect.Max.Y; i += workerCount {
+				for j := input.Rect.Min.X; j < input.Rect.Max.X; j++ {
+					r, g, b, a := input.RGBAAt(j, i)
+					r, g, b = f(r, j, i, 0), f(g, j, i, 1), f(b, j, i, 2)
+					result.SetNRGBA(j, i, srgb.ColorFromLinear(r, g, b).ToNRGBA(a))
+				}
+			}
+		})
+
+		return result
+	}
+}
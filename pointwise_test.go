@@ -0,0 +1,236 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+)
+
+func TestExposure(t *testing.T) {
+	img := NewFloatImage(image.Rect(0, 0, 1, 1))
+	img.SetRGBA(0, 0, 0.125, 0.25, 0.5, 0.5)
+
+	result := Exposure(1)(img, runtime.NumCPU())
+
+	// Linear 0.25, 0.5 and 1.0 encode to 137, 188 and 255 in sRGB
+	if expected, actual := (color.NRGBA{R: 137, G: 188, B: 255, A: 128}), result.NRGBAAt(0, 0); expected != actual {
+		t.Errorf("Expected exposed colour to be %+v but was %+v", expected, actual)
+	}
+}
+
+func TestGamma(t *testing.T) {
+	img := NewFloatImage(image.Rect(0, 0, 3, 1))
+	img.SetRGBA(0, 0, 0, 0, 0, 1)
+	img.SetRGBA(1, 0, 0.25, 0.25, 0.25, 1)
+	img.SetRGBA(2, 0, 1, 1, 1, 1)
+
+	t.Run("brightens mid tones while fixing black and white", func(t *testing.T) {
+		result := Gamma(2)(img, runtime.NumCPU())
+
+		if expected, actual := uint8(0), result.NRGBAAt(0, 0).R; expected != actual {
+			t.Errorf("Expected black to be %d but was %d", expected, actual)
+		}
+		if expected, actual := uint8(188), result.NRGBAAt(1, 0).R; expected != actual {
+			t.Errorf("Expected 0.25 to become 0.5 (%d) but was %d", expected, actual)
+		}
+		if expected, actual := uint8(255), result.NRGBAAt(2, 0).R; expected != actual {
+			t.Errorf("Expected white to be %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("panics for non-positive gamma", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic")
+			}
+		}()
+		Gamma(0)
+	})
+}
+
+func TestLevels(t *testing.T) {
+	img := NewFloatImage(image.Rect(0, 0, 3, 1))
+	img.SetRGBA(0, 0, 0.1, 0.1, 0.1, 1)
+	img.SetRGBA(1, 0, 0.3, 0.3, 0.3, 1)
+	img.SetRGBA(2, 0, 0.5, 0.5, 0.5, 1)
+
+	t.Run("maps black and white points to 0 and 1", func(t *testing.T) {
+		result := Levels(0.1, 0.5)(img, runtime.NumCPU())
+
+		if expected, actual := uint8(0), result.NRGBAAt(0, 0).R; expected != actual {
+			t.Errorf("Expected black point to be %d but was %d", expected, actual)
+		}
+		if expected, actual := uint8(188), result.NRGBAAt(1, 0).R; expected != actual {
+			t.Errorf("Expected midpoint to become 0.5 (%d) but was %d", expected, actual)
+		}
+		if expected, actual := uint8(255), result.NRGBAAt(2, 0).R; expected != actual {
+			t.Errorf("Expected white point to be %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("panics when the white point is not above the black point", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic")
+			}
+		}()
+		Levels(0.5, 0.5)
+	})
+
+	t.Run("combines with blurring in a pipeline", func(t *testing.T) {
+		kernel := KernelWithRadius(0)
+		kernel.SetWeightUniform(0, 0, 1)
+
+		result := NewPipeline(kernel.ApplyAvg, Levels(0.1, 0.5)).Apply(img, runtime.NumCPU())
+
+		if r := result.NRGBAAt(2, 0).R; r < 254 {
+			t.Errorf("Expected white point to map close to white but was %d", r)
+		}
+	})
+}
+
+func TestAutoLevels(t *testing.T) {
+
+	t.Run("stretches the range of values to black and white", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 100, 1))
+		for j := 0; j < 100; j++ {
+			v := uint8(60 + j)
+			img.SetNRGBA(j, 0, color.NRGBA{R: v, G: v, B: v, A: 255})
+		}
+
+		result := AutoLevels(0, 1)(img, runtime.NumCPU())
+
+		if actual := result.NRGBAAt(0, 0).R; actual > 2 {
+			t.Errorf("Expected darkest value to become black but was %d", actual)
+		}
+		if actual := result.NRGBAAt(99, 0).R; actual < 253 {
+			t.Errorf("Expected brightest value to become white but was %d", actual)
+		}
+	})
+
+	t.Run("clips outliers beyond the percentiles", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 100, 1))
+		for j := 0; j < 100; j++ {
+			img.SetNRGBA(j, 0, color.NRGBA{R: 100, G: 100, B: 100, A: 255})
+		}
+		img.SetNRGBA(0, 0, color.NRGBA{A: 255})
+		img.SetNRGBA(1, 0, color.NRGBA{R: 200, G: 200, B: 200, A: 255})
+		img.SetNRGBA(2, 0, color.NRGBA{R: 120, G: 120, B: 120, A: 255})
+		img.SetNRGBA(3, 0, color.NRGBA{R: 80, G: 80, B: 80, A: 255})
+
+		result := AutoLevels(0.02, 0.98)(img, runtime.NumCPU())
+
+		if actual := result.NRGBAAt(2, 0).R; actual < 250 {
+			t.Errorf("Expected brightest non-outlier to become white but was %d", actual)
+		}
+		if actual := result.NRGBAAt(3, 0).R; actual > 5 {
+			t.Errorf("Expected darkest non-outlier to become black but was %d", actual)
+		}
+	})
+
+	t.Run("leaves flat images unchanged", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+		for i := range img.Pix {
+			img.Pix[i] = 90
+		}
+
+		result := AutoLevels(0.01, 0.99)(img, runtime.NumCPU())
+
+		for i := range img.Pix {
+			if img.Pix[i] != result.Pix[i] {
+				t.Fatalf("Expected flat image to be unchanged but differs at byte %d", i)
+			}
+		}
+	})
+
+	t.Run("panics for invalid percentiles", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic")
+			}
+		}()
+		AutoLevels(0.9, 0.1)
+	})
+}
+
+func TestWhiteBalance(t *testing.T) {
+
+	t.Run("maps the neutral colour to grey of the same luminance", func(t *testing.T) {
+		img := NewFloatImage(image.Rect(0, 0, 1, 1))
+		img.SetRGBA(0, 0, 0.4, 0.2, 0.1, 1)
+
+		result := FloatImageFromImage(WhiteBalance(0.4, 0.2, 0.1)(img, 1), 1)
+
+		r, g, b, _ := result.RGBAAt(0, 0)
+		luminance := float32(0.2126*0.4 + 0.7152*0.2 + 0.0722*0.1)
+
+		for _, v := range []float32{r, g, b} {
+			if abs32(v-luminance) > 0.005 {
+				t.Errorf("Expected neutral to become grey of %v but was %v, %v, %v", luminance, r, g, b)
+				break
+			}
+		}
+	})
+
+	t.Run("panics for a neutral colour with a non-positive channel", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic")
+			}
+		}()
+		WhiteBalance(0.5, 0, 0.5)
+	})
+}
+
+func TestGreyWorld(t *testing.T) {
+
+	t.Run("removes a colour cast", func(t *testing.T) {
+		img := NewFloatImage(image.Rect(0, 0, 10, 10))
+		for i := 0; i < 10; i++ {
+			for j := 0; j < 10; j++ {
+				v := 0.1 + float32(i)*0.05
+				img.SetRGBA(j, i, v, v*0.7, v*0.5, 1)
+			}
+		}
+
+		result := GreyWorld()(img, runtime.NumCPU())
+
+		for i := 0; i < 10; i++ {
+			c := result.NRGBAAt(5, i)
+			if absDiff(c.R, c.G) > 1 || absDiff(c.G, c.B) > 1 {
+				t.Errorf("Expected row %d to be close to grey but was %v", i, c)
+			}
+		}
+	})
+
+	t.Run("treats parallelism below one as a single worker", func(t *testing.T) {
+		img := randomImage(10, 10)
+		expected := GreyWorld()(img, 1)
+
+		for _, parallelism := range []int{0, -2} {
+			result := GreyWorld()(img, parallelism)
+
+			for i := range expected.Pix {
+				if expected.Pix[i] != result.Pix[i] {
+					t.Fatalf("Expected results with parallelism %d to match but differ at byte %d", parallelism, i)
+				}
+			}
+		}
+	})
+
+	t.Run("leaves images without a channel unchanged", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+		for i := 0; i < len(img.Pix); i += 4 {
+			img.Pix[i], img.Pix[i+3] = 200, 255
+		}
+
+		result := GreyWorld()(img, runtime.NumCPU())
+
+		for i := range img.Pix {
+			if img.Pix[i] != result.Pix[i] {
+				t.Fatalf("Expected image to be unchanged but differs at byte %d", i)
+			}
+		}
+	})
+}
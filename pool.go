@@ -0,0 +1,188 @@
+package convolver
+
+import (
+	"container/heap"
+	"fmt"
+	"image"
+	"sync"
+)
+
+// Priority orders work submitted to a Pool. Tiles of higher priority
+// applications are always started before those of lower priority ones.
+type Priority int
+
+const (
+	// PriorityBatch is for background work such as bulk exports.
+	PriorityBatch Priority = iota
+
+	// PriorityInteractive is for latency sensitive work such as previews
+	// shown while editing.
+	PriorityInteractive
+)
+
+// Pool is a fixed set of workers shared between concurrent kernel
+// applications, such as the requests handled by an editor backend. Work is
+// scheduled one tile at a time, so an interactive preview submitted while a
+// batch job is running waits only for the tiles already in progress rather
+// than for the whole job.
+//
+// A pool created with zero workers adapts its size to DefaultParallelism as
+// work is scheduled, so that it follows changes to GOMAXPROCS at runtime.
+type Pool struct {
+	tileSize int
+	workers  int
+
+	mutex   sync.Mutex
+	ready   *sync.Cond
+	tasks   poolTasks
+	seq     uint64
+	running int
+	closed  bool
+
+	powerMode PowerMode
+
+	done sync.WaitGroup
+}
+
+// ApplyPooled applies an operation such as k.Avg to an image using the
+// workers of the pool, at the given priority. It blocks until the result is
+// complete.
+func (k *Kernel) ApplyPooled(img image.Image, op OpFunc, pool *Pool, priority Priority) *image.NRGBA {
+	input := k.newTileInput(img, pool.limit())
+	result := image.NewNRGBA(img.Bounds())
+
+	tiles := tilesCovering(result.Rect, pool.tileSize)
+	remaining := sync.WaitGroup{}
+	remaining.Add(len(tiles))
+
+	pool.submit(priority, len(tiles), func(index int) {
+		defer remaining.Done()
+
+		var buffer []uint8
+		applyToRect(input.around(tiles[index], &buffer), result, tiles[index], op)
+	})
+
+	remaining.Wait()
+	return result
+}
+
+// Close stops the pool's workers once all submitted work has completed. The
+// pool must not be used afterwards.
+func (p *Pool) Close() {
+	p.mutex.Lock()
+	p.closed = true
+	p.ready.Broadcast()
+	p.mutex.Unlock()
+
+	p.done.Wait()
+}
+
+func (p *Pool) submit(priority Priority, count int, run func(index int)) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		panic("work submitted to a closed pool")
+	}
+
+	for i := 0; i < count; i++ {
+		heap.Push(&p.tasks, poolTask{priority: priority, seq: p.seq, index: i, run: run})
+		p.seq++
+	}
+
+	for limit := p.limit(); p.running < limit && p.running < len(p.tasks); p.running++ {
+		p.done.Add(1)
+		go p.work()
+	}
+
+	p.ready.Broadcast()
+}
+
+// limit returns the number of workers the pool should currently have.
+func (p *Pool) limit() int {
+	workers := p.workers
+	if workers == 0 {
+		workers = DefaultParallelism()
+	}
+	return p.powerMode.workers(workers)
+}
+
+func (p *Pool) work() {
+	defer p.done.Done()
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for {
+		for len(p.tasks) == 0 && !p.closed {
+			p.ready.Wait()
+		}
+		if len(p.tasks) == 0 || p.running > p.limit() {
+			p.running--
+			return
+		}
+		task := heap.Pop(&p.tasks).(poolTask)
+
+		p.mutex.Unlock()
+		task.run(task.index)
+		p.mutex.Lock()
+	}
+}
+
+// NewPool returns a pool with the given number of workers, or zero to follow
+// DefaultParallelism, which processes images in square tiles of the given
+// size. Smaller tiles let higher priority work start sooner at the cost of
+// more scheduling overhead. Workers are started as work is submitted.
+func NewPool(workers, tileSize int) *Pool {
+	if workers < 0 {
+		panic(fmt.Sprintf("pool worker count must not be negative but was %d", workers))
+	}
+	if tileSize < 1 {
+		panic(fmt.Sprintf("tile size must be positive but was %d", tileSize))
+	}
+
+	p := &Pool{
+		tileSize: tileSize,
+		workers:  workers,
+	}
+	p.ready = sync.NewCond(&p.mutex)
+
+	return p
+}
+
+type poolTask struct {
+	priority Priority
+	seq      uint64
+	index    int
+	run      func(index int)
+}
+
+// poolTasks is a heap of tasks ordered by descending priority, then in the
+// order they were submitted.
+type poolTasks []poolTask
+
+func (t poolTasks) Len() int {
+	return len(t)
+}
+
+func (t poolTasks) Less(i, j int) bool {
+	if t[i].priority != t[j].priority {
+		return t[i].priority > t[j].priority
+	}
+	return t[i].seq < t[j].seq
+}
+
+func (t poolTasks) Swap(i, j int) {
+	t[i], t[j] = t[j], t[i]
+}
+
+func (t *poolTasks) Push(x interface{}) {
+	*t = append(*t, x.(poolTask))
+}
+
+func (t *poolTasks) Pop() interface{} {
+	old := *t
+	task := old[len(old)-1]
+	*t = old[:len(old)-1]
+	return task
+}
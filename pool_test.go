@@ -0,0 +1,105 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPool(t *testing.T) {
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{1, 2, 1, 2, 4, 2, 1, 2, 1})
+
+	t.Run("produces the same result as applying directly", func(t *testing.T) {
+		pool := NewPool(3, 5)
+		defer pool.Close()
+
+		img := randomImage(23, 17)
+
+		expected := kernel.ApplyAvg(img, 3)
+		actual := kernel.ApplyPooled(img, kernel.Avg, pool, PriorityBatch)
+
+		for i := range expected.Pix {
+			if expected.Pix[i] != actual.Pix[i] {
+				t.Fatalf("Expected pooled result to match at offset %d", i)
+			}
+		}
+	})
+
+	t.Run("follows the default parallelism when created without workers", func(t *testing.T) {
+		pool := NewPool(0, 4)
+		defer pool.Close()
+
+		img := randomImage(19, 13)
+
+		expected := kernel.ApplyAvg(img, 1)
+		actual := kernel.ApplyPooled(img, kernel.Avg, pool, PriorityInteractive)
+
+		for i := range expected.Pix {
+			if expected.Pix[i] != actual.Pix[i] {
+				t.Fatalf("Expected pooled result to match at offset %d", i)
+			}
+		}
+
+		if pool.running > DefaultParallelism() {
+			t.Errorf("Expected at most %d workers but %d were running", DefaultParallelism(), pool.running)
+		}
+	})
+
+	t.Run("runs interactive work ahead of queued batch work", func(t *testing.T) {
+		pool := NewPool(1, 1)
+		defer pool.Close()
+
+		started := make(chan struct{}, 8)
+		var batchDone int32
+
+		slowOp := func(img *image.NRGBA, x, y int) color.NRGBA {
+			started <- struct{}{}
+			time.Sleep(5 * time.Millisecond)
+			return img.NRGBAAt(x, y)
+		}
+
+		batchFinished := make(chan struct{})
+		go func() {
+			kernel.ApplyPooled(randomImage(8, 1), slowOp, pool, PriorityBatch)
+			atomic.StoreInt32(&batchDone, 1)
+			close(batchFinished)
+		}()
+
+		<-started
+		kernel.ApplyPooled(randomImage(1, 1), kernel.Avg, pool, PriorityInteractive)
+
+		if atomic.LoadInt32(&batchDone) != 0 {
+			t.Errorf("Expected interactive work to complete before the batch")
+		}
+
+		<-batchFinished
+	})
+
+	t.Run("panics for invalid configurations", func(t *testing.T) {
+		for _, config := range [][2]int{{-1, 8}, {2, 0}} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("Expected a panic for %d workers and tile size %d", config[0], config[1])
+					}
+				}()
+				NewPool(config[0], config[1])
+			}()
+		}
+	})
+
+	t.Run("panics when used after closing", func(t *testing.T) {
+		pool := NewPool(1, 8)
+		pool.Close()
+
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic")
+			}
+		}()
+		kernel.ApplyPooled(randomImage(2, 2), kernel.Avg, pool, PriorityBatch)
+	})
+}
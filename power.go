@@ -0,0 +1,98 @@
+package convolver
+
+import (
+	"fmt"
+	"image"
+	"sync/atomic"
+)
+
+// lowPowerTileSize is the size of the tiles processed in low power mode,
+// chosen so that a tile's input and output stay within a core's L1 and L2
+// caches for typical kernel sizes.
+const lowPowerTileSize = 64
+
+// PowerMode trades throughput against energy use.
+type PowerMode int
+
+const (
+	// PowerThroughput uses as many workers as requested to finish as soon as
+	// possible. This is the default.
+	PowerThroughput PowerMode = iota
+
+	// PowerLow uses half as many workers as requested and processes images in
+	// small tiles, reducing energy use on battery powered devices by keeping
+	// fewer cores busy and avoiding trips to main memory, at the cost of
+	// taking longer.
+	PowerLow
+)
+
+func (m PowerMode) String() string {
+	switch m {
+	case PowerThroughput:
+		return "throughput"
+	case PowerLow:
+		return "low"
+	}
+	return fmt.Sprintf("PowerMode(%d)", int(m))
+}
+
+// workers returns the number of workers to use in this mode when the given
+// parallelism is requested, which is always at least one.
+func (m PowerMode) workers(parallelism int) int {
+	if parallelism < 1 {
+		return 1
+	}
+	if m == PowerLow && parallelism > 1 {
+		return (parallelism + 1) / 2
+	}
+	return parallelism
+}
+
+// SetPowerMode sets whether applying this kernel favours throughput or energy
+// efficiency.
+func (k *Kernel) SetPowerMode(mode PowerMode) {
+	if mode != PowerThroughput && mode != PowerLow {
+		panic(fmt.Sprintf("unknown power mode %d", int(mode)))
+	}
+
+	k.powerMode = mode
+}
+
+// SetPowerMode sets whether the pool favours throughput or energy efficiency.
+// In low power mode, the pool runs at most half as many workers as it
+// otherwise would.
+func (p *Pool) SetPowerMode(mode PowerMode) {
+	if mode != PowerThroughput && mode != PowerLow {
+		panic(fmt.Sprintf("unknown power mode %d", int(mode)))
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.powerMode = mode
+}
+
+// applyLowPower applies an operation one small tile at a time, so that each
+// worker's data stays in cache.
+func (k *Kernel) applyLowPower(img *image.NRGBA, bounds image.Rectangle, op OpFunc, parallelism int) *image.NRGBA {
+	result := image.NewNRGBA(bounds)
+
+	tiles := tilesCovering(bounds, lowPowerTileSize)
+	nextTile := int64(-1)
+
+	runWorkers(currentMetrics(), parallelism, func(workerNum, workerCount int) {
+		throttle := k.newThrottle()
+
+		for {
+			index := int(atomic.AddInt64(&nextTile, 1))
+			if index >= len(tiles) {
+				return
+			}
+
+			applyToRect(img, result, tiles[index], op)
+			throttle.yield()
+		}
+	})
+
+	return result
+}
@@ -0,0 +1,99 @@
+package convolver
+
+import (
+	"testing"
+)
+
+func TestPowerMode(t *testing.T) {
+	img := randomImage(150, 70)
+
+	t.Run("low power mode produces the same result as throughput mode", func(t *testing.T) {
+		kernel := KernelWithRadius(2)
+		kernel.SetWeightsUniform([]float32{
+			1, 2, 3, 2, 1,
+			2, 3, 4, 3, 2,
+			3, 4, 5, 4, 3,
+			2, 3, 4, 3, 2,
+			1, 2, 3, 2, 1,
+		})
+
+		expected := kernel.ApplyAvg(img, 4)
+
+		kernel.SetPowerMode(PowerLow)
+		actual := kernel.ApplyAvg(img, 4)
+
+		for i := range expected.Pix {
+			if expected.Pix[i] != actual.Pix[i] {
+				t.Fatalf("Expected low power result to match at offset %d", i)
+			}
+		}
+	})
+
+	t.Run("low power mode uses fewer workers", func(t *testing.T) {
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightsUniform([]float32{1, 1, 1, 1, 1, 1, 1, 1, 1})
+		kernel.SetPowerMode(PowerLow)
+
+		m := &recordingMetrics{}
+		SetMetrics(m)
+		defer SetMetrics(nil)
+
+		kernel.ApplyAvg(img, 4)
+
+		if m.peakWorkers < 1 || m.peakWorkers > 2 {
+			t.Errorf("Expected peak active workers to be between 1 and 2 but was %d", m.peakWorkers)
+		}
+	})
+
+	t.Run("low power mode halves the workers of a pool", func(t *testing.T) {
+		pool := NewPool(5, 8)
+		defer pool.Close()
+
+		if expected, actual := 5, pool.limit(); expected != actual {
+			t.Errorf("Expected worker limit to be %d but was %d", expected, actual)
+		}
+
+		pool.SetPowerMode(PowerLow)
+
+		if expected, actual := 3, pool.limit(); expected != actual {
+			t.Errorf("Expected worker limit to be %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("a single worker is not reduced", func(t *testing.T) {
+		if expected, actual := 1, PowerLow.workers(1); expected != actual {
+			t.Errorf("Expected %d workers but was %d", expected, actual)
+		}
+	})
+
+	t.Run("parallelism below one uses a single worker", func(t *testing.T) {
+		for _, mode := range []PowerMode{PowerThroughput, PowerLow} {
+			if expected, actual := 1, mode.workers(0); expected != actual {
+				t.Errorf("Expected %d workers in %v mode but was %d", expected, mode, actual)
+			}
+		}
+
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightsUniform([]float32{1, 1, 1, 1, 1, 1, 1, 1, 1})
+
+		expected := kernel.ApplyAvg(img, 1)
+		result := kernel.ApplyAvg(img, 0)
+
+		for i := range expected.Pix {
+			if expected.Pix[i] != result.Pix[i] {
+				t.Fatalf("Expected results to match but differ at byte %d", i)
+			}
+		}
+	})
+
+	t.Run("panics for an unknown mode", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic")
+			}
+		}()
+
+		kernel := KernelWithRadius(1)
+		kernel.SetPowerMode(PowerMode(7))
+	})
+}
@@ -0,0 +1,165 @@
+package convolver
+
+import (
+	"fmt"
+	"github.com/mandykoh/go-parallel"
+	"github.com/mandykoh/prism"
+	"image"
+	"math"
+)
+
+// GaussianKernel returns a Gaussian blur kernel with standard deviation sigma
+// and a radius of three standard deviations.
+func GaussianKernel(sigma float64) Kernel {
+	return gaussianKernelFromFunc(sigma, func(_, _, g float64) float64 {
+		return g
+	})
+}
+
+// SobelKernels returns the horizontal and vertical Sobel gradient kernels.
+func SobelKernels() (Kernel, Kernel) {
+	gx := KernelWithRadius(1)
+	gx.SetWeightsUniform([]float32{
+		-1, 0, 1,
+		-2, 0, 2,
+		-1, 0, 1,
+	})
+
+	gy := KernelWithRadius(1)
+	gy.SetWeightsUniform([]float32{
+		-1, -2, -1,
+		0, 0, 0,
+		1, 2, 1,
+	})
+
+	return gx, gy
+}
+
+// Sobel returns the Sobel gradient magnitude of an image's linear luminance.
+func Sobel(img image.Image, parallelism int) *Plane {
+	luminance := LuminancePlane(img, parallelism)
+	kx, ky := SobelKernels()
+
+	gx := kx.convolvePlane(luminance, parallelism)
+	gy := ky.convolvePlane(luminance, parallelism)
+
+	for i := range gx.Pix {
+		gx.Pix[i] = float32(math.Hypot(float64(gx.Pix[i]), float64(gy.Pix[i])))
+	}
+
+	return gx
+}
+
+// UnsharpMask returns a stage which sharpens an image by adding amount times
+// the difference between the image and a Gaussian blurred copy of it with
+// standard deviation sigma. Colour channels are sharpened in linear space and
+// alpha is left unchanged.
+func UnsharpMask(sigma, amount float64) Stage {
+	blur := GaussianKernel(sigma)
+
+	return func(img image.Image, parallelism int) *image.NRGBA {
+		input := prism.ConvertImageToNRGBA(img, parallelism)
+		planes := linearPlanes(input, parallelism)
+		blurred := linearPlanes(blur.ApplyAvg(input, parallelism), parallelism)
+
+		a := float32(amount)
+
+		parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+			for c := 0; c < 3; c++ {
+				for i := workerNum; i < len(planes[c].Pix); i += workerCount {
+					v := planes[c].Pix[i]
+					planes[c].Pix[i] = v + a*(v-blurred[c].Pix[i])
+				}
+			}
+		})
+
+		return nrgbaFromPlanes(planes, parallelism)
+	}
+}
+
+// autoSharpenSigma is the standard deviation of the blur used by AutoSharpen,
+// which sharpens detail at the scale of a pixel or two.
+const autoSharpenSigma = 1
+
+// autoSharpenWindowRadius is the radius of the neighbourhood over which
+// AutoSharpen measures the strength of detail.
+const autoSharpenWindowRadius = 3
+
+// AutoSharpen returns a stage which sharpens an image like UnsharpMask with a
+// standard deviation of one pixel and the given strength as its amount, but
+// adapts the amount to the image's noise as estimated by EstimateNoise. The
+// amount is reduced wherever the detail in a pixel's neighbourhood is weak
+// compared with what the noise alone would produce, so that flat but noisy
+// areas such as skies are left almost untouched while edges and texture are
+// sharpened fully. This suits batch processing of photos of varying quality
+// with a single setting; a strength of around 0.5 to 1 is typical.
+//
+// The noise is estimated once over the whole image, as is appropriate for
+// the fairly uniform noise of a camera sensor.
+func AutoSharpen(strength float64) Stage {
+	if strength < 0 {
+		panic(fmt.Sprintf("sharpening strength must not be negative but was %v", strength))
+	}
+
+	blur := GaussianKernel(autoSharpenSigma)
+
+	// The variance which noise of unit standard deviation contributes to the
+	// difference between a pixel and its blurred value.
+	var total, centre, squares float64
+	for _, w := range blur.weights {
+		total += float64(w.R)
+	}
+	for i, w := range blur.weights {
+		v := float64(w.R) / total
+		squares += v * v
+		if i == len(blur.weights)/2 {
+			centre = v
+		}
+	}
+	unitNoiseVariance := 1 - 2*centre + squares
+
+	window := KernelWithRadius(autoSharpenWindowRadius)
+	for i := range window.weights {
+		window.setWeight(i, kernelWeight{R: 1, G: 1, B: 1, A: 1})
+	}
+	windowArea := float32(len(window.weights))
+
+	return func(img image.Image, parallelism int) *image.NRGBA {
+		input := prism.ConvertImageToNRGBA(img, parallelism)
+		blurredInput := blur.ApplyAvg(input, parallelism)
+
+		planes := linearPlanes(input, parallelism)
+		blurred := linearPlanes(blurredInput, parallelism)
+
+		noise := EstimateNoise(input, parallelism)
+		noiseVariance := float32(noise * noise * unitNoiseVariance)
+
+		detail := LuminancePlane(input, parallelism)
+		blurredLuminance := LuminancePlane(blurredInput, parallelism)
+		for i, v := range blurredLuminance.Pix {
+			d := detail.Pix[i] - v
+			detail.Pix[i] = d * d
+		}
+		detailVariance := window.convolvePlane(detail, parallelism)
+
+		a := float32(strength)
+
+		parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+			for i := workerNum; i < len(detailVariance.Pix); i += workerCount {
+				variance := detailVariance.Pix[i] / windowArea
+
+				gain := float32(0)
+				if variance > noiseVariance {
+					gain = 1 - noiseVariance/variance
+				}
+
+				for c := 0; c < 3; c++ {
+					v := planes[c].Pix[i]
+					planes[c].Pix[i] = v + a*gain*(v-blurred[c].Pix[i])
+				}
+			}
+		})
+
+		return nrgbaFromPlanes(planes, parallelism)
+	}
+}
@@ -0,0 +1,140 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func TestGaussianKernel(t *testing.T) {
+	kernel := GaussianKernel(1)
+
+	if expected, actual := 3, kernel.radius; expected != actual {
+		t.Errorf("Expected radius to be %d but was %d", expected, actual)
+	}
+
+	centre := kernel.weights[len(kernel.weights)/2].R
+	for i, w := range kernel.weights {
+		if w.R > centre {
+			t.Errorf("Expected weight %d to be no greater than the centre weight but was %v", i, w.R)
+		}
+	}
+}
+
+func TestSobel(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for i := img.Rect.Min.Y; i < img.Rect.Max.Y; i++ {
+		for j := 4; j < img.Rect.Max.X; j++ {
+			img.SetNRGBA(j, i, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+		}
+	}
+
+	result := Sobel(img, runtime.NumCPU())
+
+	if expected, actual := 4.0, float64(result.ValueAt(3, 4)); math.Abs(expected-actual) > 1e-3 {
+		t.Errorf("Expected magnitude at edge to be %v but was %v", expected, actual)
+	}
+	if actual := result.ValueAt(1, 4); actual != 0 {
+		t.Errorf("Expected magnitude away from edge to be zero but was %v", actual)
+	}
+}
+
+func TestUnsharpMask(t *testing.T) {
+
+	t.Run("increases contrast across an edge", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 16, 4))
+		for i := img.Rect.Min.Y; i < img.Rect.Max.Y; i++ {
+			for j := img.Rect.Min.X; j < img.Rect.Max.X; j++ {
+				v := uint8(64)
+				if j >= 8 {
+					v = 192
+				}
+				img.SetNRGBA(j, i, color.NRGBA{R: v, G: v, B: v, A: 255})
+			}
+		}
+
+		result := UnsharpMask(1, 1)(img, runtime.NumCPU())
+
+		if dark := result.NRGBAAt(7, 2); dark.R >= 64 {
+			t.Errorf("Expected dark side of edge to be darkened but was %+v", dark)
+		}
+		if light := result.NRGBAAt(8, 2); light.R <= 192 {
+			t.Errorf("Expected light side of edge to be lightened but was %+v", light)
+		}
+		if expected, actual := uint8(255), result.NRGBAAt(8, 2).A; expected != actual {
+			t.Errorf("Expected alpha to be unchanged at %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("has no effect with zero amount", func(t *testing.T) {
+		img := randomImage(8, 8)
+		expectedImg := nrgbaFromPlanes(linearPlanes(img, 1), 1)
+
+		result := UnsharpMask(1, 0)(img, runtime.NumCPU())
+
+		for i := range expectedImg.Pix {
+			if expectedImg.Pix[i] != result.Pix[i] {
+				t.Fatalf("Expected results to match but differ at byte %d", i)
+			}
+		}
+	})
+}
+
+func TestAutoSharpen(t *testing.T) {
+	bounds := image.Rect(0, 0, 96, 64)
+
+	// The left half is flat, and the right half vertical stripes 8 pixels
+	// wide, with noise added throughout as from a camera sensor
+	clean := NewFloatImage(bounds)
+	for i := bounds.Min.Y; i < bounds.Max.Y; i++ {
+		for j := bounds.Min.X; j < bounds.Max.X; j++ {
+			v := float32(0.2)
+			if j >= bounds.Dx()/2 {
+				v = 0.05 + 0.5*float32((j/8)%2)
+			}
+			clean.SetRGBA(j, i, v, v, v, 1)
+		}
+	}
+
+	img := GaussianNoise(0.03, 1)(clean, runtime.NumCPU())
+
+	change := func(result *image.NRGBA, region image.Rectangle) float64 {
+		var sum float64
+		for i := region.Min.Y; i < region.Max.Y; i++ {
+			for j := region.Min.X; j < region.Max.X; j++ {
+				sum += math.Abs(float64(result.NRGBAAt(j, i).G) - float64(img.NRGBAAt(j, i).G))
+			}
+		}
+		return sum
+	}
+
+	noisyRegion := image.Rect(4, 4, bounds.Dx()/2-4, bounds.Max.Y-4)
+	stripedRegion := image.Rect(bounds.Dx()/2+4, 4, bounds.Max.X-4, bounds.Max.Y-4)
+
+	plain := UnsharpMask(1, 1)(img, runtime.NumCPU())
+	adaptive := AutoSharpen(1)(img, runtime.NumCPU())
+
+	t.Run("sharpens noisy flat areas much less than UnsharpMask", func(t *testing.T) {
+		if plainChange, adaptiveChange := change(plain, noisyRegion), change(adaptive, noisyRegion); adaptiveChange > plainChange/3 {
+			t.Errorf("Expected noise to be amplified much less than %v but was %v", plainChange, adaptiveChange)
+		}
+	})
+
+	t.Run("sharpens edges nearly as much as UnsharpMask", func(t *testing.T) {
+		if plainChange, adaptiveChange := change(plain, stripedRegion), change(adaptive, stripedRegion); adaptiveChange < plainChange*0.8 {
+			t.Errorf("Expected edges to be sharpened nearly as much as %v but was %v", plainChange, adaptiveChange)
+		}
+	})
+
+	t.Run("leaves the image unchanged with zero strength", func(t *testing.T) {
+		result := AutoSharpen(0)(img, runtime.NumCPU())
+
+		for i := range img.Pix {
+			if absDiff(img.Pix[i], result.Pix[i]) > 3 {
+				t.Fatalf("Expected image to be unchanged but differs at offset %d", i)
+			}
+		}
+	})
+}
@@ -0,0 +1,552 @@
+package convolver
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Wire types of the protocol buffer encoding.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// protoFormatVersion is the current version of the Kernel and Pipeline
+// messages, which MarshalProto writes. Messages without a version predate
+// versioning and are identical to version 1. Every change which older
+// readers would misinterpret must increment this version, and the
+// unmarshalling functions must upgrade messages from earlier versions.
+const protoFormatVersion = 1
+
+// StageSpec describes a pipeline stage which applies a kernel with an
+// operation registered with RegisterOp, so that it can be sent to another
+// process and reconstructed there.
+type StageSpec struct {
+	Kernel Kernel
+	Op     string
+	Passes int
+}
+
+// Stage returns the stage described, which applies the operation Passes
+// times, or once if Passes is zero. It returns an error if the operation is
+// not registered.
+func (s StageSpec) Stage() (Stage, error) {
+	if _, ok := LookupOp(s.Op); !ok {
+		return nil, fmt.Errorf("unknown op %q", s.Op)
+	}
+	if s.Passes < 0 {
+		return nil, fmt.Errorf("passes must not be negative but was %d", s.Passes)
+	}
+
+	k := s.Kernel
+	op := s.Op
+	stage := func(img image.Image, parallelism int) *image.NRGBA {
+		if op == "avg" {
+			return k.ApplyAvg(img, parallelism)
+		}
+		return k.ApplyOp(img, op, parallelism)
+	}
+
+	if s.Passes > 1 {
+		return Repeat(stage, s.Passes), nil
+	}
+	return stage, nil
+}
+
+// PipelineSpec describes a pipeline of kernel stages, so that filter
+// definitions can be sent between services in the protocol buffer format
+// defined by convolver.proto and executed by the receiver.
+type PipelineSpec struct {
+	Stages []StageSpec
+}
+
+// Pipeline returns the pipeline described. It returns an error if any stage
+// names an operation which is not registered.
+func (s *PipelineSpec) Pipeline() (*Pipeline, error) {
+	stages := make([]Stage, len(s.Stages))
+
+	for i, spec := range s.Stages {
+		stage, err := spec.Stage()
+		if err != nil {
+			return nil, fmt.Errorf("stage %d: %v", i+1, err)
+		}
+		stages[i] = stage
+	}
+
+	return NewPipeline(stages...), nil
+}
+
+// MarshalProto encodes the pipeline description as a Pipeline message of
+// convolver.proto.
+func (s *PipelineSpec) MarshalProto() []byte {
+	var b []byte
+
+	for _, stage := range s.Stages {
+		var sb []byte
+		sb = appendProtoBytes(sb, 1, stage.Kernel.MarshalProto())
+		if stage.Op != "" {
+			sb = appendProtoBytes(sb, 2, []byte(stage.Op))
+		}
+		if stage.Passes != 0 {
+			sb = appendProtoVarint(sb, 3, uint64(int64(stage.Passes)))
+		}
+
+		b = appendProtoBytes(b, 1, sb)
+	}
+
+	return appendProtoVarint(b, 2, protoFormatVersion)
+}
+
+// UnmarshalPipelineSpecProto decodes a Pipeline message of convolver.proto.
+// Operation names are not checked until the pipeline is built. It returns an
+// error if the message was written with a newer format version than this
+// package supports.
+func UnmarshalPipelineSpecProto(b []byte) (*PipelineSpec, error) {
+	spec := &PipelineSpec{}
+	var version uint64
+	r := protoReader{buf: b}
+
+	for !r.done() {
+		field, wireType, err := r.tag()
+		if err != nil {
+			return nil, err
+		}
+
+		switch field {
+		case 1:
+			data, err := r.bytesOf(wireType)
+			if err != nil {
+				return nil, err
+			}
+			stage, err := unmarshalStageSpecProto(data)
+			if err != nil {
+				return nil, fmt.Errorf("stage %d: %v", len(spec.Stages)+1, err)
+			}
+			spec.Stages = append(spec.Stages, stage)
+
+		case 2:
+			v, err := r.varintOf(wireType)
+			if err != nil {
+				return nil, err
+			}
+			version = v
+
+		default:
+			if err := r.skip(wireType); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := checkProtoFormatVersion("pipeline", version); err != nil {
+		return nil, err
+	}
+
+	return spec, nil
+}
+
+func unmarshalStageSpecProto(b []byte) (StageSpec, error) {
+	spec := StageSpec{Kernel: KernelWithRadius(0)}
+	r := protoReader{buf: b}
+
+	for !r.done() {
+		field, wireType, err := r.tag()
+		if err != nil {
+			return spec, err
+		}
+
+		switch field {
+		case 1:
+			data, err := r.bytesOf(wireType)
+			if err != nil {
+				return spec, err
+			}
+			if spec.Kernel, err = UnmarshalKernelProto(data); err != nil {
+				return spec, err
+			}
+
+		case 2:
+			data, err := r.bytesOf(wireType)
+			if err != nil {
+				return spec, err
+			}
+			spec.Op = string(data)
+
+		case 3:
+			v, err := r.varintOf(wireType)
+			if err != nil {
+				return spec, err
+			}
+			spec.Passes = int(int32(v))
+
+		default:
+			if err := r.skip(wireType); err != nil {
+				return spec, err
+			}
+		}
+	}
+
+	return spec, nil
+}
+
+// MarshalProto encodes the kernel's weights and settings as a Kernel message
+// of convolver.proto. Diagnostics are not included.
+func (k *Kernel) MarshalProto() []byte {
+	var b []byte
+
+	if k.radius != 0 {
+		b = appendProtoVarint(b, 1, uint64(k.radius))
+	}
+
+	weights := make([]byte, 0, len(k.weights)*16)
+	for _, w := range k.weights {
+		for _, v := range [4]float32{w.R, w.G, w.B, w.A} {
+			weights = appendProtoFixed32(weights, math.Float32bits(v))
+		}
+	}
+	b = appendProtoBytes(b, 2, weights)
+
+	if k.maxMemoryBytes != 0 {
+		b = appendProtoVarint(b, 3, uint64(int64(k.maxMemoryBytes)))
+	}
+	if k.maxCPUShare != 0 {
+		b = appendProtoTag(b, 4, protoFixed64)
+		b = appendProtoFixed64(b, math.Float64bits(k.maxCPUShare))
+	}
+	if k.powerMode != PowerThroughput {
+		b = appendProtoVarint(b, 5, uint64(k.powerMode))
+	}
+	if k.softClipKnee != 0 {
+		b = appendProtoTag(b, 6, protoFixed32)
+		b = appendProtoFixed32(b, math.Float32bits(k.softClipKnee))
+	}
+	if k.edgeMode != EdgeClip {
+		b = appendProtoVarint(b, 7, uint64(k.edgeMode))
+	}
+	if c := k.edgeColour; c != (color.NRGBA{}) {
+		b = appendProtoTag(b, 8, protoFixed32)
+		b = appendProtoFixed32(b, uint32(c.R)<<24|uint32(c.G)<<16|uint32(c.B)<<8|uint32(c.A))
+	}
+	if k.backend != "" {
+		b = appendProtoBytes(b, 9, []byte(k.backend))
+	}
+
+	return appendProtoVarint(b, 10, protoFormatVersion)
+}
+
+// UnmarshalKernelProto decodes a Kernel message of convolver.proto. It
+// returns an error if the message is malformed, if the number of weights
+// doesn't match the radius, or if any setting is out of range; a backend
+// which isn't registered in this process, or a format version newer than this
+// package supports, is also an error.
+func UnmarshalKernelProto(b []byte) (Kernel, error) {
+	var radius int
+	var weights []float32
+	var maxMemoryBytes int
+	var maxCPUShare float64
+	var powerMode PowerMode
+	var softClipKnee float32
+	var edgeMode EdgeMode
+	var edgeColour color.NRGBA
+	var backend string
+	var version uint64
+
+	r := protoReader{buf: b}
+
+	for !r.done() {
+		field, wireType, err := r.tag()
+		if err != nil {
+			return Kernel{}, err
+		}
+
+		switch field {
+		case 1:
+			v, err := r.varintOf(wireType)
+			if err != nil {
+				return Kernel{}, err
+			}
+			radius = int(int32(v))
+
+		case 2:
+			// Repeated scalars may be sent packed or one at a time.
+			if wireType == protoFixed32 {
+				v, err := r.fixed32()
+				if err != nil {
+					return Kernel{}, err
+				}
+				weights = append(weights, math.Float32frombits(v))
+				break
+			}
+
+			data, err := r.bytesOf(wireType)
+			if err != nil {
+				return Kernel{}, err
+			}
+			if len(data)%4 != 0 {
+				return Kernel{}, errors.New("packed weights are not a whole number of floats")
+			}
+			for i := 0; i < len(data); i += 4 {
+				weights = append(weights, math.Float32frombits(binary.LittleEndian.Uint32(data[i:])))
+			}
+
+		case 3:
+			v, err := r.varintOf(wireType)
+			if err != nil {
+				return Kernel{}, err
+			}
+			maxMemoryBytes = int(int64(v))
+
+		case 4:
+			v, err := r.fixed64Of(wireType)
+			if err != nil {
+				return Kernel{}, err
+			}
+			maxCPUShare = math.Float64frombits(v)
+
+		case 5:
+			v, err := r.varintOf(wireType)
+			if err != nil {
+				return Kernel{}, err
+			}
+			powerMode = PowerMode(int32(v))
+
+		case 6:
+			v, err := r.fixed32Of(wireType)
+			if err != nil {
+				return Kernel{}, err
+			}
+			softClipKnee = math.Float32frombits(v)
+
+		case 7:
+			v, err := r.varintOf(wireType)
+			if err != nil {
+				return Kernel{}, err
+			}
+			edgeMode = EdgeMode(int32(v))
+
+		case 8:
+			v, err := r.fixed32Of(wireType)
+			if err != nil {
+				return Kernel{}, err
+			}
+			edgeColour = color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}
+
+		case 9:
+			data, err := r.bytesOf(wireType)
+			if err != nil {
+				return Kernel{}, err
+			}
+			backend = string(data)
+
+		case 10:
+			v, err := r.varintOf(wireType)
+			if err != nil {
+				return Kernel{}, err
+			}
+			version = v
+
+		default:
+			if err := r.skip(wireType); err != nil {
+				return Kernel{}, err
+			}
+		}
+	}
+
+	if err := checkProtoFormatVersion("kernel", version); err != nil {
+		return Kernel{}, err
+	}
+	if radius < 0 {
+		return Kernel{}, fmt.Errorf("radius must not be negative but was %d", radius)
+	}
+	if expected, ok := checkedProduct(uint64(len(weights)), radius*2+1, radius*2+1, 4); !ok || expected != len(weights) {
+		return Kernel{}, fmt.Errorf("kernel of radius %d requires %d weights per channel but %d values were provided", radius, (radius*2+1)*(radius*2+1), len(weights))
+	}
+	if maxCPUShare < 0 || maxCPUShare > 1 {
+		return Kernel{}, fmt.Errorf("CPU share must be between 0 and 1 but was %v", maxCPUShare)
+	}
+	if powerMode != PowerThroughput && powerMode != PowerLow {
+		return Kernel{}, fmt.Errorf("unknown power mode %d", int(powerMode))
+	}
+	if softClipKnee < 0 || softClipKnee > 0.5 {
+		return Kernel{}, fmt.Errorf("soft clip knee must be between 0 and 0.5 but was %v", softClipKnee)
+	}
+	if edgeMode < EdgeClip || edgeMode > EdgeConstant {
+		return Kernel{}, fmt.Errorf("unknown edge mode %d", int(edgeMode))
+	}
+	if backend != "" {
+		if _, ok := lookupBackend(backend); !ok {
+			return Kernel{}, fmt.Errorf("unknown backend %q", backend)
+		}
+	}
+
+	k := KernelWithRadius(radius)
+	for i := range k.weights {
+		w := weights[i*4:]
+		k.setWeight(i, kernelWeight{R: w[0], G: w[1], B: w[2], A: w[3]})
+	}
+	k.maxMemoryBytes = maxMemoryBytes
+	k.maxCPUShare = maxCPUShare
+	k.powerMode = powerMode
+	k.softClipKnee = softClipKnee
+	k.edgeMode = edgeMode
+	k.edgeColour = edgeColour
+	k.backend = backend
+
+	return k, nil
+}
+
+// checkProtoFormatVersion returns an error if a message was written with a
+// newer format version than protoFormatVersion. Earlier versions, including
+// unversioned messages, need no upgrading yet.
+func checkProtoFormatVersion(message string, version uint64) error {
+	if version > protoFormatVersion {
+		return fmt.Errorf("%s message version %d is newer than the supported version %d", message, version, protoFormatVersion)
+	}
+	return nil
+}
+
+func appendProtoTag(b []byte, field int, wireType int) []byte {
+	return appendVarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+func appendProtoVarint(b []byte, field int, v uint64) []byte {
+	return appendVarint(appendProtoTag(b, field, protoVarint), v)
+}
+
+func appendProtoBytes(b []byte, field int, data []byte) []byte {
+	b = appendVarint(appendProtoTag(b, field, protoBytes), uint64(len(data)))
+	return append(b, data...)
+}
+
+func appendProtoFixed32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendProtoFixed64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+// protoReader reads the fields of a protocol buffer message.
+type protoReader struct {
+	buf []byte
+}
+
+var errProtoTruncated = errors.New("protocol buffer message is truncated")
+
+func (r *protoReader) done() bool {
+	return len(r.buf) == 0
+}
+
+func (r *protoReader) tag() (field int, wireType int, err error) {
+	v, err := r.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+	if v>>3 == 0 || v>>3 > math.MaxInt32 {
+		return 0, 0, fmt.Errorf("invalid field number %d", v>>3)
+	}
+	return int(v >> 3), int(v & 7), nil
+}
+
+func (r *protoReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		return 0, errProtoTruncated
+	}
+	r.buf = r.buf[n:]
+	return v, nil
+}
+
+func (r *protoReader) varintOf(wireType int) (uint64, error) {
+	if wireType != protoVarint {
+		return 0, fmt.Errorf("expected a varint but found wire type %d", wireType)
+	}
+	return r.varint()
+}
+
+func (r *protoReader) fixed32() (uint32, error) {
+	if len(r.buf) < 4 {
+		return 0, errProtoTruncated
+	}
+	v := binary.LittleEndian.Uint32(r.buf)
+	r.buf = r.buf[4:]
+	return v, nil
+}
+
+func (r *protoReader) fixed32Of(wireType int) (uint32, error) {
+	if wireType != protoFixed32 {
+		return 0, fmt.Errorf("expected a 32-bit value but found wire type %d", wireType)
+	}
+	return r.fixed32()
+}
+
+func (r *protoReader) fixed64() (uint64, error) {
+	if len(r.buf) < 8 {
+		return 0, errProtoTruncated
+	}
+	v := binary.LittleEndian.Uint64(r.buf)
+	r.buf = r.buf[8:]
+	return v, nil
+}
+
+func (r *protoReader) fixed64Of(wireType int) (uint64, error) {
+	if wireType != protoFixed64 {
+		return 0, fmt.Errorf("expected a 64-bit value but found wire type %d", wireType)
+	}
+	return r.fixed64()
+}
+
+func (r *protoReader) bytesOf(wireType int) ([]byte, error) {
+	if wireType != protoBytes {
+		return nil, fmt.Errorf("expected length-delimited data but found wire type %d", wireType)
+	}
+
+	n, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(r.buf)) {
+		return nil, errProtoTruncated
+	}
+
+	data := r.buf[:n]
+	r.buf = r.buf[n:]
+	return data, nil
+}
+
+// skip discards the value of a field which isn't understood, so that
+// messages from newer versions of the schema can still be read.
+func (r *protoReader) skip(wireType int) error {
+	var err error
+
+	switch wireType {
+	case protoVarint:
+		_, err = r.varint()
+	case protoFixed64:
+		_, err = r.fixed64()
+	case protoBytes:
+		_, err = r.bytesOf(wireType)
+	case protoFixed32:
+		_, err = r.fixed32()
+	default:
+		err = fmt.Errorf("unsupported wire type %d", wireType)
+	}
+
+	return err
+}
@@ -0,0 +1,196 @@
+package convolver
+
+import (
+	"image/color"
+	"math"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestKernelProto(t *testing.T) {
+
+	t.Run("round trips weights and settings", func(t *testing.T) {
+		k := KernelWithRadius(1)
+		k.SetWeightsRGBA([][4]float32{
+			{1, 2, 3, 4}, {0, 0, 0, 0}, {-1, -0.5, 0.25, 1},
+			{5, 6, 7, 8}, {9, 10, 11, 12}, {0.125, 0, 0, 1},
+			{-3, 3, -3, 3}, {1, 1, 1, 1}, {2, 2, 2, 2},
+		})
+		k.SetMaxMemoryBytes(1 << 20)
+		k.SetMaxCPUShare(0.5)
+		k.SetPowerMode(PowerLow)
+		k.SetSoftClip(0.1)
+		k.SetEdgeMode(EdgeConstant)
+		k.SetEdgeColour(color.NRGBA{R: 10, G: 20, B: 30, A: 40})
+
+		result, err := UnmarshalKernelProto(k.MarshalProto())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(k, result) {
+			t.Errorf("Expected kernel %+v but got %+v", k, result)
+		}
+	})
+
+	t.Run("round trips a default kernel", func(t *testing.T) {
+		k := KernelWithRadius(0)
+		k.SetWeightsUniform([]float32{1})
+
+		result, err := UnmarshalKernelProto(k.MarshalProto())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(k, result) {
+			t.Errorf("Expected kernel %+v but got %+v", k, result)
+		}
+	})
+
+	t.Run("accepts unpacked weights and skips unknown fields", func(t *testing.T) {
+		var b []byte
+		b = appendProtoVarint(b, 99, 12345)
+		for _, w := range []float32{0.5, 0.25, 1, 2} {
+			b = appendProtoTag(b, 2, protoFixed32)
+			b = appendProtoFixed32(b, math.Float32bits(w))
+		}
+		b = appendProtoBytes(b, 100, []byte("ignored"))
+
+		result, err := UnmarshalKernelProto(b)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if r, g, bl, a := result.WeightRGBA(0, 0); r != 0.5 || g != 0.25 || bl != 1 || a != 2 {
+			t.Errorf("Expected weights 0.5, 0.25, 1, 2 but got %v, %v, %v, %v", r, g, bl, a)
+		}
+	})
+
+	t.Run("rejects invalid messages", func(t *testing.T) {
+		k := KernelWithRadius(1)
+		valid := k.MarshalProto()
+
+		cases := []struct {
+			Name    string
+			Message []byte
+		}{
+			{Name: "truncated", Message: valid[:len(valid)-1]},
+			{Name: "too few weights", Message: appendProtoVarint(nil, 1, 2)},
+			{Name: "huge radius", Message: appendProtoVarint(nil, 1, 1<<30)},
+			{Name: "negative radius", Message: appendProtoVarint(nil, 1, uint64(1<<64-1))},
+			{Name: "unknown edge mode", Message: appendProtoVarint(append([]byte(nil), valid...), 7, 42)},
+			{Name: "unknown power mode", Message: appendProtoVarint(append([]byte(nil), valid...), 5, 7)},
+			{Name: "unknown backend", Message: appendProtoBytes(append([]byte(nil), valid...), 9, []byte("no-such-backend"))},
+			{Name: "wrong wire type", Message: appendProtoBytes(nil, 1, []byte{1})},
+			{Name: "newer version", Message: appendProtoVarint(append([]byte(nil), valid...), 10, protoFormatVersion+1)},
+		}
+
+		for _, c := range cases {
+			if _, err := UnmarshalKernelProto(c.Message); err == nil {
+				t.Errorf("Expected an error for %s message", c.Name)
+			}
+		}
+	})
+}
+
+func TestPipelineSpecProto(t *testing.T) {
+	img := randomImage(23, 17)
+
+	blur := KernelWithRadius(1)
+	blur.SetWeightsUniform([]float32{
+		1, 2, 1,
+		2, 4, 2,
+		1, 2, 1,
+	})
+	blur.SetEdgeMode(EdgeExtend)
+
+	dilate := KernelWithRadius(1)
+	dilate.SetWeightsUniform([]float32{
+		0, 1, 0,
+		1, 1, 1,
+		0, 1, 0,
+	})
+
+	spec := &PipelineSpec{
+		Stages: []StageSpec{
+			{Kernel: blur, Op: "avg"},
+			{Kernel: dilate, Op: "max", Passes: 2},
+		},
+	}
+
+	t.Run("round trips stages", func(t *testing.T) {
+		result, err := UnmarshalPipelineSpecProto(spec.MarshalProto())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(spec, result) {
+			t.Errorf("Expected spec %+v but got %+v", spec, result)
+		}
+	})
+
+	t.Run("reconstructs a pipeline which gives the same result", func(t *testing.T) {
+		expected := NewPipeline(blur.ApplyAvg, Repeat(dilate.ApplyMax, 2)).Apply(img, runtime.NumCPU())
+
+		decoded, err := UnmarshalPipelineSpecProto(spec.MarshalProto())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		pipeline, err := decoded.Pipeline()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		result := pipeline.Apply(img, runtime.NumCPU())
+
+		for i := range expected.Pix {
+			if expected.Pix[i] != result.Pix[i] {
+				t.Fatalf("Expected results to match but differ at byte %d", i)
+			}
+		}
+	})
+
+	t.Run("reads unversioned messages", func(t *testing.T) {
+		var b []byte
+		for _, stage := range spec.Stages {
+			var sb []byte
+			sb = appendProtoBytes(sb, 1, stage.Kernel.MarshalProto())
+			sb = appendProtoBytes(sb, 2, []byte(stage.Op))
+			if stage.Passes != 0 {
+				sb = appendProtoVarint(sb, 3, uint64(stage.Passes))
+			}
+			b = appendProtoBytes(b, 1, sb)
+		}
+
+		result, err := UnmarshalPipelineSpecProto(b)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(spec, result) {
+			t.Errorf("Expected spec %+v but got %+v", spec, result)
+		}
+	})
+
+	t.Run("rejects messages from a newer version", func(t *testing.T) {
+		b := appendProtoVarint(spec.MarshalProto(), 2, protoFormatVersion+1)
+
+		if _, err := UnmarshalPipelineSpecProto(b); err == nil {
+			t.Errorf("Expected an error for a newer version")
+		}
+	})
+
+	t.Run("rejects unknown ops when building the pipeline", func(t *testing.T) {
+		unknown := &PipelineSpec{Stages: []StageSpec{{Kernel: blur, Op: "no-such-op"}}}
+
+		decoded, err := UnmarshalPipelineSpecProto(unknown.MarshalProto())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if _, err := decoded.Pipeline(); err == nil {
+			t.Errorf("Expected an error for an unknown op")
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package convolver
+
+import (
+	"github.com/mandykoh/prism/srgb"
+	"image"
+	"image/color"
+)
+
+// ApplyRange applies the Range operation to an image.
+func (k *Kernel) ApplyRange(img image.Image, parallelism int) *image.NRGBA {
+	return k.applyImage(img, k.Range, parallelism)
+}
+
+// Range computes the difference between the largest and smallest linear value
+// of each colour channel under the kernel, in a single pass, giving the same
+// result as subtracting the result of Min from that of Max. This is the
+// morphological gradient, which highlights edges and local contrast.
+//
+// Taps are chosen as for Max and Min, ignoring those with zero weight. Alpha
+// is the largest alpha under the kernel, as for Max, so the result is opaque
+// wherever any of the neighbourhood is.
+func (k *Kernel) Range(img *image.NRGBA, x, y int) color.NRGBA {
+	clip := k.clipToBounds(img.Rect, x, y)
+
+	max := kernelWeight{}
+	min := kernelWeight{255, 255, 255, 255}
+
+	for s := clip.Top; s < k.sideLength-clip.Bottom; s++ {
+		for t := clip.Left; t < k.sideLength-clip.Right; t++ {
+			weight := k.weights[s*k.sideLength+t]
+
+			c, a := srgb.ColorFromNRGBA(img.NRGBAAt(x+t-k.radius, y+s-k.radius))
+			if weight.R != 0 {
+				if c.R*weight.R > max.R {
+					max.R = c.R
+				}
+				if c.R*weight.R < min.R {
+					min.R = c.R
+				}
+			}
+			if weight.G != 0 {
+				if c.G*weight.G > max.G {
+					max.G = c.G
+				}
+				if c.G*weight.G < min.G {
+					min.G = c.G
+				}
+			}
+			if weight.B != 0 {
+				if c.B*weight.B > max.B {
+					max.B = c.B
+				}
+				if c.B*weight.B < min.B {
+					min.B = c.B
+				}
+			}
+			if a*weight.A > max.A && weight.A != 0 {
+				max.A = a
+			}
+		}
+	}
+
+	result := kernelWeight{A: max.A}
+	if min.R < max.R {
+		result.R = max.R - min.R
+	}
+	if min.G < max.G {
+		result.G = max.G - min.G
+	}
+	if min.B < max.B {
+		result.B = max.B - min.B
+	}
+
+	return result.toNRGBA()
+}
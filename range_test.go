@@ -0,0 +1,50 @@
+package convolver
+
+import (
+	"github.com/mandykoh/prism/srgb"
+	"testing"
+)
+
+func TestRange(t *testing.T) {
+	img := randomImage(24, 18)
+
+	kernel := KernelWithRadius(2)
+	weights := make([]float32, 25)
+	for i := range weights {
+		weights[i] = 1
+	}
+	weights[0], weights[24] = 0, 0
+	kernel.SetWeightsUniform(weights)
+
+	t.Run("is the difference between Max and Min", func(t *testing.T) {
+		max := kernel.ApplyMax(img, 2)
+		min := kernel.ApplyMin(img, 2)
+		result := kernel.ApplyRange(img, 2)
+
+		for i := img.Rect.Min.Y; i < img.Rect.Max.Y; i++ {
+			for j := img.Rect.Min.X; j < img.Rect.Max.X; j++ {
+				maxC, maxA := srgb.ColorFromNRGBA(max.NRGBAAt(j, i))
+				minC, _ := srgb.ColorFromNRGBA(min.NRGBAAt(j, i))
+				expected := srgb.ColorFromLinear(maxC.R-minC.R, maxC.G-minC.G, maxC.B-minC.B).ToNRGBA(maxA)
+				actual := result.NRGBAAt(j, i)
+
+				for _, pair := range [][2]uint8{{expected.R, actual.R}, {expected.G, actual.G}, {expected.B, actual.B}, {expected.A, actual.A}} {
+					if absDiff(pair[0], pair[1]) > 1 {
+						t.Fatalf("Expected range at %d,%d to be %v but was %v", j, i, expected, actual)
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("is available as a registered op", func(t *testing.T) {
+		expected := kernel.ApplyRange(img, 2)
+		actual := kernel.ApplyOp(img, "range", 2)
+
+		for i := range expected.Pix {
+			if expected.Pix[i] != actual.Pix[i] {
+				t.Fatalf("Expected registered range to match ApplyRange at offset %d", i)
+			}
+		}
+	})
+}
@@ -0,0 +1,200 @@
+package convolver
+
+import (
+	"fmt"
+	"github.com/mandykoh/prism/srgb"
+	"image"
+	"image/color"
+	"sort"
+)
+
+// ApplyWeightedRank applies the operation returned by WeightedRank to an
+// image.
+func (k *Kernel) ApplyWeightedRank(img image.Image, rank float32, parallelism int) *image.NRGBA {
+	return k.applyImage(img, k.WeightedRank(rank), parallelism)
+}
+
+// WeightedRank returns a weighted rank filter operation, a soft form of Max
+// and Min in which each tap's weight scales its contribution to the order
+// statistic rather than merely gating it. For each channel, the result is the
+// smallest linear value v for which the taps with values no greater than v
+// hold at least the given fraction of the total weight. A rank of 0 is
+// equivalent to Min, 1 to Max, and 0.5 gives a weighted median.
+//
+// With a kernel whose weights fall off towards its edge, ranks slightly below
+// 1 give dilation which respects soft edges (such as antialiased alpha)
+// rather than spreading every partially covered pixel. Taps with zero or
+// negative weight are ignored.
+func (k *Kernel) WeightedRank(rank float32) OpFunc {
+	if rank < 0 || rank > 1 {
+		panic(fmt.Sprintf("rank must be between 0 and 1 but was %v", rank))
+	}
+
+	return k.rankOp(func(samples []rankSample) float32 {
+		return weightedRankValue(samples, rank)
+	})
+}
+
+// ApplyPercentile applies the operation returned by Percentile to an image.
+func (k *Kernel) ApplyPercentile(img image.Image, p float32, parallelism int) *image.NRGBA {
+	return k.applyImage(img, k.Percentile(p), parallelism)
+}
+
+// Percentile returns a rank-order filter operation which selects, for each
+// channel, the p-th percentile (between 0 and 100) of the linear values under
+// the kernel, with each tap counted in proportion to its weight. Percentiles
+// of 0 and 100 are equivalent to Min and Max, 50 gives a median, and those in
+// between give robust despeckling and soft morphology. This is WeightedRank
+// with the rank expressed as a percentage.
+func (k *Kernel) Percentile(p float32) OpFunc {
+	if p < 0 || p > 100 {
+		panic(fmt.Sprintf("percentile must be between 0 and 100 but was %v", p))
+	}
+
+	return k.WeightedRank(p / 100)
+}
+
+// ApplyDespeckledDilate applies the operation returned by DespeckledDilate to
+// an image.
+func (k *Kernel) ApplyDespeckledDilate(img image.Image, fraction float32, parallelism int) *image.NRGBA {
+	return k.applyImage(img, k.DespeckledDilate(fraction), parallelism)
+}
+
+// ApplyDespeckledErode applies the operation returned by DespeckledErode to an
+// image.
+func (k *Kernel) ApplyDespeckledErode(img image.Image, fraction float32, parallelism int) *image.NRGBA {
+	return k.applyImage(img, k.DespeckledErode(fraction), parallelism)
+}
+
+// DespeckledDilate returns a dilation operation which ignores outliers such
+// as hot pixels in noisy camera images. For each channel, the brightest taps
+// are discarded until they hold at least the given fraction of the total
+// weight, and the brightest of the remaining values is taken. A fraction of
+// 0.02 gives approximately the 98th percentile.
+//
+// At least one tap is discarded for any positive fraction, so a single hot
+// pixel never dominates the result even with a small kernel. As with
+// WeightedRank, values are compared in linear light and taps with zero or
+// negative weight are ignored.
+func (k *Kernel) DespeckledDilate(fraction float32) OpFunc {
+	checkDespeckleFraction(fraction)
+
+	return k.rankOp(func(samples []rankSample) float32 {
+		return trimmedExtremeValue(samples, fraction, true)
+	})
+}
+
+// DespeckledErode is like DespeckledDilate, but discards the darkest taps and
+// takes the darkest of the remaining values, so that dead pixels do not
+// dominate erosion.
+func (k *Kernel) DespeckledErode(fraction float32) OpFunc {
+	checkDespeckleFraction(fraction)
+
+	return k.rankOp(func(samples []rankSample) float32 {
+		return trimmedExtremeValue(samples, fraction, false)
+	})
+}
+
+func checkDespeckleFraction(fraction float32) {
+	if fraction < 0 || fraction >= 0.5 {
+		panic(fmt.Sprintf("despeckle fraction must be at least 0 and less than 0.5 but was %v", fraction))
+	}
+}
+
+// rankOp returns an operation which gathers the linear value and weight of
+// each tap with positive weight, separately for each channel, and combines
+// them with the given function.
+func (k *Kernel) rankOp(combine func(samples []rankSample) float32) OpFunc {
+	return func(img *image.NRGBA, x, y int) color.NRGBA {
+		clip := k.clipToBounds(img.Rect, x, y)
+
+		var samples [4][]rankSample
+
+		for s := clip.Top; s < k.sideLength-clip.Bottom; s++ {
+			for t := clip.Left; t < k.sideLength-clip.Right; t++ {
+				weight := k.weights[s*k.sideLength+t]
+
+				c, a := srgb.ColorFromNRGBA(img.NRGBAAt(x+t-k.radius, y+s-k.radius))
+				for channel, sample := range [4]rankSample{{c.R, weight.R}, {c.G, weight.G}, {c.B, weight.B}, {a, weight.A}} {
+					if sample.weight > 0 {
+						samples[channel] = append(samples[channel], sample)
+					}
+				}
+			}
+		}
+
+		result := kernelWeight{
+			R: combine(samples[0]),
+			G: combine(samples[1]),
+			B: combine(samples[2]),
+			A: combine(samples[3]),
+		}
+
+		return result.toNRGBA()
+	}
+}
+
+type rankSample struct {
+	value  float32
+	weight float32
+}
+
+func weightedRankValue(samples []rankSample, rank float32) float32 {
+	if len(samples) == 0 {
+		return 0
+	}
+
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].value < samples[j].value
+	})
+
+	total := float32(0)
+	for _, s := range samples {
+		total += s.weight
+	}
+
+	threshold := rank * total
+	cumulative := float32(0)
+
+	for _, s := range samples {
+		cumulative += s.weight
+		if cumulative >= threshold {
+			return s.value
+		}
+	}
+
+	return samples[len(samples)-1].value
+}
+
+// trimmedExtremeValue returns the highest (or lowest) value among samples
+// after discarding the most extreme ones until they hold at least the given
+// fraction of the total weight. The last sample is never discarded.
+func trimmedExtremeValue(samples []rankSample, fraction float32, highest bool) float32 {
+	if len(samples) == 0 {
+		return 0
+	}
+
+	sort.Slice(samples, func(i, j int) bool {
+		if highest {
+			return samples[i].value > samples[j].value
+		}
+		return samples[i].value < samples[j].value
+	})
+
+	total := float32(0)
+	for _, s := range samples {
+		total += s.weight
+	}
+
+	threshold := fraction * total
+	discarded := float32(0)
+
+	for i, s := range samples[:len(samples)-1] {
+		if discarded >= threshold && (fraction == 0 || i > 0) {
+			return s.value
+		}
+		discarded += s.weight
+	}
+
+	return samples[len(samples)-1].value
+}
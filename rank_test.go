@@ -0,0 +1,191 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+)
+
+func TestWeightedRank(t *testing.T) {
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{
+		1, 1, 1,
+		1, 1, 1,
+		1, 1, 1,
+	})
+
+	img := randomImage(16, 16)
+
+	t.Run("matches Min and Max at the extremes", func(t *testing.T) {
+		for _, c := range []struct {
+			Rank     float32
+			Expected *image.NRGBA
+		}{
+			{0, kernel.ApplyMin(img, runtime.NumCPU())},
+			{1, kernel.ApplyMax(img, runtime.NumCPU())},
+		} {
+			result := kernel.ApplyWeightedRank(img, c.Rank, runtime.NumCPU())
+
+			for i := range c.Expected.Pix {
+				if c.Expected.Pix[i] != result.Pix[i] {
+					t.Fatalf("Expected rank %v to match but differs at byte %d", c.Rank, i)
+				}
+			}
+		}
+	})
+
+	t.Run("scales each tap's contribution by its weight", func(t *testing.T) {
+		weighted := KernelWithRadius(1)
+		weighted.SetWeightsUniform([]float32{
+			0, 0, 0,
+			1, 4, 1,
+			0, 0, 0,
+		})
+
+		row := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+		row.SetNRGBA(0, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+		row.SetNRGBA(1, 0, color.NRGBA{R: 100, G: 100, B: 100, A: 255})
+		row.SetNRGBA(2, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+
+		// The centre holds 4/6 of the weight, so ranks up to 2/3 select it
+		if expected, actual := uint8(100), weighted.ApplyWeightedRank(row, 0.6, runtime.NumCPU()).NRGBAAt(1, 0).R; expected != actual {
+			t.Errorf("Expected rank 0.6 to be %d but was %d", expected, actual)
+		}
+		if expected, actual := uint8(255), weighted.ApplyWeightedRank(row, 0.7, runtime.NumCPU()).NRGBAAt(1, 0).R; expected != actual {
+			t.Errorf("Expected rank 0.7 to be %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("panics for ranks out of range", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic")
+			}
+		}()
+		kernel.WeightedRank(1.5)
+	})
+}
+
+func TestPercentile(t *testing.T) {
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{
+		1, 1, 1,
+		1, 1, 1,
+		1, 1, 1,
+	})
+
+	img := randomImage(16, 16)
+
+	t.Run("matches Min, the median and Max", func(t *testing.T) {
+		for _, c := range []struct {
+			Percentile float32
+			Expected   *image.NRGBA
+		}{
+			{0, kernel.ApplyMin(img, runtime.NumCPU())},
+			{50, kernel.ApplyWeightedRank(img, 0.5, runtime.NumCPU())},
+			{100, kernel.ApplyMax(img, runtime.NumCPU())},
+		} {
+			result := kernel.ApplyPercentile(img, c.Percentile, runtime.NumCPU())
+
+			for i := range c.Expected.Pix {
+				if c.Expected.Pix[i] != result.Pix[i] {
+					t.Fatalf("Expected percentile %v to match but differs at byte %d", c.Percentile, i)
+				}
+			}
+		}
+	})
+
+	t.Run("panics for percentiles out of range", func(t *testing.T) {
+		for _, p := range []float32{-1, 101} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("Expected a panic for percentile %v", p)
+					}
+				}()
+				kernel.Percentile(p)
+			}()
+		}
+	})
+}
+
+func TestDespeckled(t *testing.T) {
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{
+		1, 1, 1,
+		1, 1, 1,
+		1, 1, 1,
+	})
+
+	speckled := image.NewNRGBA(image.Rect(0, 0, 3, 3))
+	for i := 0; i < 9; i++ {
+		speckled.SetNRGBA(i%3, i/3, color.NRGBA{R: uint8(100 + i), G: uint8(100 + i), B: uint8(100 + i), A: 255})
+	}
+	speckled.SetNRGBA(0, 0, color.NRGBA{R: 0, G: 0, B: 0, A: 255})
+	speckled.SetNRGBA(2, 2, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+
+	t.Run("dilation ignores a single hot pixel", func(t *testing.T) {
+		result := kernel.ApplyDespeckledDilate(speckled, 0.02, runtime.NumCPU())
+
+		// The hot pixel replaced the brightest value, so the next brightest
+		// (at 1, 2) is taken
+		if expected, actual := uint8(107), result.NRGBAAt(1, 1).R; expected != actual {
+			t.Errorf("Expected dilated value to be %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("erosion ignores a single dead pixel", func(t *testing.T) {
+		result := kernel.ApplyDespeckledErode(speckled, 0.02, runtime.NumCPU())
+
+		if expected, actual := uint8(101), result.NRGBAAt(1, 1).R; expected != actual {
+			t.Errorf("Expected eroded value to be %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("discards taps until the fraction of weight is reached", func(t *testing.T) {
+		result := kernel.ApplyDespeckledDilate(speckled, 0.2, runtime.NumCPU())
+
+		// 0.2 of 9 taps requires two to be discarded
+		if expected, actual := uint8(106), result.NRGBAAt(1, 1).R; expected != actual {
+			t.Errorf("Expected dilated value to be %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("matches Max and Min with a fraction of zero", func(t *testing.T) {
+		img := randomImage(16, 16)
+
+		for _, c := range []struct {
+			Name     string
+			Expected *image.NRGBA
+			Actual   *image.NRGBA
+		}{
+			{"dilate", kernel.ApplyMax(img, runtime.NumCPU()), kernel.ApplyDespeckledDilate(img, 0, runtime.NumCPU())},
+			{"erode", kernel.ApplyMin(img, runtime.NumCPU()), kernel.ApplyDespeckledErode(img, 0, runtime.NumCPU())},
+		} {
+			for i := range c.Expected.Pix {
+				if c.Expected.Pix[i] != c.Actual.Pix[i] {
+					t.Fatalf("Expected %s to match but differs at byte %d", c.Name, i)
+				}
+			}
+		}
+	})
+
+	t.Run("never discards every tap", func(t *testing.T) {
+		single := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+		single.SetNRGBA(0, 0, color.NRGBA{R: 200, G: 200, B: 200, A: 255})
+
+		if expected, actual := uint8(200), kernel.ApplyDespeckledDilate(single, 0.4, 1).NRGBAAt(0, 0).R; expected != actual {
+			t.Errorf("Expected value to be %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("panics for fractions out of range", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic")
+			}
+		}()
+		kernel.DespeckledDilate(0.5)
+	})
+}
@@ -0,0 +1,174 @@
+package convolver
+
+import (
+	"fmt"
+	"github.com/mandykoh/prism"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+)
+
+// ReferenceOp identifies one of a kernel's operations for the reference
+// implementation.
+type ReferenceOp int
+
+const (
+	ReferenceAvg ReferenceOp = iota
+	ReferenceMax
+	ReferenceMin
+)
+
+func (op ReferenceOp) String() string {
+	switch op {
+	case ReferenceAvg:
+		return "avg"
+	case ReferenceMax:
+		return "max"
+	case ReferenceMin:
+		return "min"
+	}
+	return fmt.Sprintf("ReferenceOp(%d)", int(op))
+}
+
+// ApplyReference applies one of the kernel's operations with a deliberately
+// simple single threaded implementation, working in float64 with the exact
+// sRGB transfer function rather than lookup tables. It defines the expected
+// results for faster implementations, and is not intended for general use.
+//
+// As with Avg, Max and Min, taps falling outside the image are ignored and
+// averages are normalised by the total weight of the remaining taps. Max and
+// Min select among taps with non-zero weight by comparing values scaled by
+// their weights.
+func (k *Kernel) ApplyReference(img image.Image, op ReferenceOp) *image.NRGBA {
+	input := prism.ConvertImageToNRGBA(img, 1)
+	bounds := input.Rect
+	result := image.NewNRGBA(bounds)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			var acc, total [4]float64
+			if op == ReferenceMin {
+				acc = [4]float64{255, 255, 255, 255}
+			}
+
+			for s := 0; s < k.sideLength; s++ {
+				for t := 0; t < k.sideLength; t++ {
+					p := image.Pt(x+t-k.radius, y+s-k.radius)
+					if !p.In(bounds) {
+						continue
+					}
+
+					w := k.weights[s*k.sideLength+t]
+					weights := [4]float64{float64(w.R), float64(w.G), float64(w.B), float64(w.A)}
+					values := referenceLinearValues(input.NRGBAAt(p.X, p.Y))
+
+					for c := 0; c < 4; c++ {
+						switch op {
+						case ReferenceAvg:
+							acc[c] += values[c] * weights[c]
+							total[c] += weights[c]
+						case ReferenceMax:
+							if weights[c] != 0 && values[c]*weights[c] > acc[c] {
+								acc[c] = values[c]
+							}
+						case ReferenceMin:
+							if weights[c] != 0 && values[c]*weights[c] < acc[c] {
+								acc[c] = values[c]
+							}
+						}
+					}
+				}
+			}
+
+			if op == ReferenceAvg {
+				for c := 0; c < 4; c++ {
+					if total[c] > 0 {
+						acc[c] /= total[c]
+					}
+				}
+			}
+
+			offset := result.PixOffset(x, y)
+			for c := 0; c < 3; c++ {
+				result.Pix[offset+c] = referenceTo8Bit(referenceEncode(acc[c]))
+			}
+			result.Pix[offset+3] = referenceTo8Bit(acc[3])
+		}
+	}
+
+	return result
+}
+
+// ValidateBackend checks that a stage implementing one of the kernel's
+// operations (such as k.ApplyAvg, or an optimised path for the same kernel)
+// agrees with ApplyReference. The stage is run on the given number of random
+// images of varying sizes, including ones smaller than the kernel, and an
+// error describing the first disagreement of more than tolerance in any 8-bit
+// channel is returned. The images are deterministic for a given seed.
+//
+// The lookup tables used by the standard operations to convert to and from
+// linear values quantise dark tones by up to 3 levels, so a tolerance of at
+// least 3 is needed for backends built on them.
+func (k *Kernel) ValidateBackend(backend Stage, op ReferenceOp, tolerance uint8, trials int, seed int64) error {
+	rng := rand.New(rand.NewSource(seed))
+
+	for trial := 0; trial < trials; trial++ {
+		w, h := 1+rng.Intn(k.sideLength+32), 1+rng.Intn(k.sideLength+32)
+		img := image.NewNRGBA(image.Rect(0, 0, w, h))
+		rng.Read(img.Pix)
+
+		expected := k.ApplyReference(img, op)
+		actual := backend(img, 1+rng.Intn(4))
+
+		if actual.Rect != expected.Rect {
+			return fmt.Errorf("trial %d: expected bounds %v but got %v", trial+1, expected.Rect, actual.Rect)
+		}
+
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				e, a := expected.NRGBAAt(x, y), actual.NRGBAAt(x, y)
+				if absDiffUint8(e.R, a.R) > tolerance || absDiffUint8(e.G, a.G) > tolerance || absDiffUint8(e.B, a.B) > tolerance || absDiffUint8(e.A, a.A) > tolerance {
+					return fmt.Errorf("trial %d (%dx%d image): %s result at %d,%d was %v but expected %v", trial+1, w, h, op, x, y, a, e)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func absDiffUint8(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func referenceDecode(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func referenceEncode(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// referenceLinearValues returns the linear colour and alpha of a pixel.
+func referenceLinearValues(c color.NRGBA) [4]float64 {
+	return [4]float64{
+		referenceDecode(float64(c.R) / 255),
+		referenceDecode(float64(c.G) / 255),
+		referenceDecode(float64(c.B) / 255),
+		float64(c.A) / 255,
+	}
+}
+
+func referenceTo8Bit(v float64) uint8 {
+	return uint8(math.Round(math.Max(0, math.Min(1, v)) * 255))
+}
@@ -0,0 +1,83 @@
+package convolver
+
+import (
+	"image"
+	"runtime"
+	"testing"
+)
+
+func TestApplyReference(t *testing.T) {
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{
+		1, 1, 1,
+		1, 1, 1,
+		1, 1, 1,
+	})
+
+	img := randomImage(12, 9)
+
+	for _, c := range []struct {
+		Op       ReferenceOp
+		Expected *image.NRGBA
+	}{
+		{ReferenceAvg, kernel.ApplyAvg(img, runtime.NumCPU())},
+		{ReferenceMax, kernel.ApplyMax(img, runtime.NumCPU())},
+		{ReferenceMin, kernel.ApplyMin(img, runtime.NumCPU())},
+	} {
+		result := kernel.ApplyReference(img, c.Op)
+
+		for i := range c.Expected.Pix {
+			if absDiff(c.Expected.Pix[i], result.Pix[i]) > 3 {
+				t.Fatalf("Expected %s reference to be within 3 of %d but was %d at byte %d", c.Op, c.Expected.Pix[i], result.Pix[i], i)
+			}
+		}
+	}
+}
+
+func TestValidateBackend(t *testing.T) {
+	kernel := KernelWithRadius(2)
+	for i := 0; i < kernel.SideLength(); i++ {
+		for j := 0; j < kernel.SideLength(); j++ {
+			kernel.SetWeightUniform(j, i, float32(i+j+1))
+		}
+	}
+
+	structuringElement := KernelWithRadius(1)
+	structuringElement.SetWeightsUniform([]float32{
+		0, 1, 0,
+		1, 1, 1,
+		0, 1, 0,
+	})
+
+	t.Run("accepts matching backends", func(t *testing.T) {
+		for _, c := range []struct {
+			Kernel  *Kernel
+			Op      ReferenceOp
+			Backend Stage
+		}{
+			{&kernel, ReferenceAvg, kernel.ApplyAvg},
+			{&structuringElement, ReferenceMax, structuringElement.ApplyMax},
+			{&structuringElement, ReferenceMin, structuringElement.ApplyMin},
+		} {
+			if err := c.Kernel.ValidateBackend(c.Backend, c.Op, 3, 10, 1); err != nil {
+				t.Errorf("Expected %s backend to validate but got %v", c.Op, err)
+			}
+		}
+	})
+
+	t.Run("rejects mismatching backends", func(t *testing.T) {
+		if err := kernel.ValidateBackend(kernel.ApplyMax, ReferenceAvg, 3, 10, 1); err == nil {
+			t.Errorf("Expected an error but got none")
+		}
+	})
+
+	t.Run("rejects backends with the wrong bounds", func(t *testing.T) {
+		cropping := func(img image.Image, parallelism int) *image.NRGBA {
+			return image.NewNRGBA(image.Rect(0, 0, 1, 1))
+		}
+
+		if err := kernel.ValidateBackend(cropping, ReferenceAvg, 255, 10, 1); err == nil {
+			t.Errorf("Expected an error but got none")
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package convolver
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"sync"
+)
+
+// OpFactory returns an operation using a kernel's weights, such as its Avg
+// method. Factories are registered with RegisterOp so that operations can be
+// chosen by name, such as from a configuration file.
+type OpFactory func(k *Kernel) OpFunc
+
+// Backend is an alternative implementation of a kernel's average, such as one
+// running on a GPU, which can be shipped as a separate module and selected
+// with Kernel.SetBackend.
+type Backend interface {
+	// Accepts returns whether the backend can apply the kernel to an image
+	// with the given bounds, without doing the work, so that plans such as
+	// those of PlannedAvg name the implementation which will actually run.
+	Accepts(k *Kernel, bounds image.Rectangle) bool
+
+	// ApplyAvg applies the kernel to an image as Kernel.ApplyAvg does,
+	// honouring the kernel's settings, which can be read with its
+	// SideLength, WeightRGBA, EdgeMode, EdgeColour, SoftClip and
+	// MaxMemoryBytes methods. It returns false if the backend cannot handle
+	// the kernel or image, which should include every case Accepts rejects,
+	// in which case the built-in implementation is used instead.
+	ApplyAvg(k *Kernel, img image.Image, parallelism int) (*image.NRGBA, bool)
+}
+
+var registryMutex sync.RWMutex
+
+var ops = map[string]OpFactory{
+	"avg":         func(k *Kernel) OpFunc { return k.Avg },
+	"avg-encoded": func(k *Kernel) OpFunc { return k.AvgEncoded },
+
+	"despeckled-dilate": func(k *Kernel) OpFunc { return k.DespeckledDilate(0.02) },
+	"despeckled-erode":  func(k *Kernel) OpFunc { return k.DespeckledErode(0.02) },
+
+	"grey-dilate": func(k *Kernel) OpFunc { return k.GreyDilate },
+	"grey-erode":  func(k *Kernel) OpFunc { return k.GreyErode },
+	"label-mode":  func(k *Kernel) OpFunc { return k.LabelMode },
+	"max":         func(k *Kernel) OpFunc { return k.Max },
+	"min":         func(k *Kernel) OpFunc { return k.Min },
+	"range":       func(k *Kernel) OpFunc { return k.Range },
+	"std-dev":     func(k *Kernel) OpFunc { return k.StdDev },
+	"sum":         func(k *Kernel) OpFunc { return k.Sum(0) },
+	"variance":    func(k *Kernel) OpFunc { return k.Variance },
+}
+
+var backends = map[string]Backend{}
+
+// RegisterOp makes an operation available by name to ApplyOp and LookupOp.
+// It is intended to be called from the init function of a package providing
+// the operation, and panics if the name is empty or already registered.
+func RegisterOp(name string, factory OpFactory) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if name == "" {
+		panic("op name must not be empty")
+	}
+	if _, exists := ops[name]; exists {
+		panic(fmt.Sprintf("op %q is already registered", name))
+	}
+
+	ops[name] = factory
+}
+
+// LookupOp returns the factory for the named operation, or false if no such
+// operation is registered. The built-in operations "avg", "avg-encoded",
+// "despeckled-dilate", "despeckled-erode", "grey-dilate", "grey-erode",
+// "label-mode", "max", "min", "range", "std-dev", "sum" and "variance" are
+// always available; the despeckled ones discard a fraction of 0.02, and "sum"
+// adds no bias.
+func LookupOp(name string) (OpFactory, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	factory, ok := ops[name]
+	return factory, ok
+}
+
+// OpNames returns the names of all registered operations in sorted order.
+func OpNames() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	names := make([]string, 0, len(ops))
+	for name := range ops {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// ApplyOp applies the named registered operation to an image, as ApplyAvg
+// does for "avg". It panics if the operation is not registered.
+//
+// The operation is always run by the built-in traversal; a kernel's backend
+// replaces only ApplyAvg.
+func (k *Kernel) ApplyOp(img image.Image, name string, parallelism int) *image.NRGBA {
+	factory, ok := LookupOp(name)
+	if !ok {
+		panic(fmt.Sprintf("unknown op %q", name))
+	}
+
+	return k.applyImage(img, factory(k), parallelism)
+}
+
+// RegisterBackend makes a backend available by name to Kernel.SetBackend. It
+// is intended to be called from the init function of the package providing
+// the backend, and panics if the name is empty or already registered.
+func RegisterBackend(name string, b Backend) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if name == "" {
+		panic("backend name must not be empty")
+	}
+	if _, exists := backends[name]; exists {
+		panic(fmt.Sprintf("backend %q is already registered", name))
+	}
+
+	backends[name] = b
+}
+
+// BackendNames returns the names of all registered backends in sorted order.
+func BackendNames() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// SetBackend selects the registered backend which ApplyAvg uses for this
+// kernel, or the built-in implementation if name is empty. It panics if no
+// backend of that name is registered.
+func (k *Kernel) SetBackend(name string) {
+	if name != "" {
+		if _, ok := lookupBackend(name); !ok {
+			panic(fmt.Sprintf("unknown backend %q", name))
+		}
+	}
+
+	k.backend = name
+}
+
+// acceptingBackend returns the name of the kernel's selected backend if it
+// would accept work on an image with the given bounds, or an empty string.
+func (k *Kernel) acceptingBackend(bounds image.Rectangle) string {
+	if k.backend == "" {
+		return ""
+	}
+
+	if b, ok := lookupBackend(k.backend); ok && b.Accepts(k, bounds) {
+		return k.backend
+	}
+	return ""
+}
+
+// applyBackendAvg applies the kernel with its selected backend, returning
+// false if there is none or it declined the work.
+func (k *Kernel) applyBackendAvg(img image.Image, parallelism int) (*image.NRGBA, bool) {
+	if k.backend == "" {
+		return nil, false
+	}
+
+	b, _ := lookupBackend(k.backend)
+	return b.ApplyAvg(k, img, parallelism)
+}
+
+func lookupBackend(name string) (Backend, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	b, ok := backends[name]
+	return b, ok
+}
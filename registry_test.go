@@ -0,0 +1,163 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+type recordingBackend struct {
+	calls   int
+	decline bool
+}
+
+func (b *recordingBackend) Accepts(k *Kernel, bounds image.Rectangle) bool {
+	return !b.decline
+}
+
+func (b *recordingBackend) ApplyAvg(k *Kernel, img image.Image, parallelism int) (*image.NRGBA, bool) {
+	b.calls++
+	if b.decline {
+		return nil, false
+	}
+	return image.NewNRGBA(img.Bounds()), true
+}
+
+func TestRegistry(t *testing.T) {
+	img := randomImage(40, 30)
+
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{1, 2, 1, 2, 4, 2, 1, 2, 1})
+
+	t.Run("built-in ops match the kernel's methods", func(t *testing.T) {
+		expected := kernel.ApplyMax(img, 2)
+		actual := kernel.ApplyOp(img, "max", 2)
+
+		for i := range expected.Pix {
+			if expected.Pix[i] != actual.Pix[i] {
+				t.Fatalf("Expected registered max to match ApplyMax at offset %d", i)
+			}
+		}
+	})
+
+	t.Run("registered ops can be applied by name", func(t *testing.T) {
+		RegisterOp("test-invert", func(k *Kernel) OpFunc {
+			return func(img *image.NRGBA, x, y int) color.NRGBA {
+				c := img.NRGBAAt(x, y)
+				return color.NRGBA{R: 255 - c.R, G: 255 - c.G, B: 255 - c.B, A: c.A}
+			}
+		})
+
+		if _, ok := LookupOp("test-invert"); !ok {
+			t.Fatalf("Expected registered op to be found")
+		}
+
+		found := false
+		for _, name := range OpNames() {
+			found = found || name == "test-invert"
+		}
+		if !found {
+			t.Errorf("Expected op names %v to include the registered op", OpNames())
+		}
+
+		result := kernel.ApplyOp(img, "test-invert", 2)
+
+		if expected, actual := 255-img.Pix[0], result.Pix[0]; expected != actual {
+			t.Errorf("Expected inverted value %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("registering a duplicate op panics", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic but there was none")
+			}
+		}()
+
+		RegisterOp("avg", func(k *Kernel) OpFunc { return k.Avg })
+	})
+
+	t.Run("applying an unknown op panics", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic but there was none")
+			}
+		}()
+
+		kernel.ApplyOp(img, "nonexistent", 1)
+	})
+
+	t.Run("a selected backend replaces ApplyAvg", func(t *testing.T) {
+		backend := &recordingBackend{}
+		RegisterBackend("test-replacing", backend)
+
+		k := kernel
+		k.SetBackend("test-replacing")
+		result := k.ApplyAvg(img, 2)
+
+		if backend.calls != 1 {
+			t.Errorf("Expected backend to be called once but was called %d times", backend.calls)
+		}
+		if result.Pix[3] != 0 {
+			t.Errorf("Expected the backend's result but got the built-in one")
+		}
+		if plan := k.avgPlan("avg", 1, img.Rect, 1); plan.Backend != "test-replacing" {
+			t.Errorf("Expected plan backend to be %q but was %q", "test-replacing", plan.Backend)
+		}
+	})
+
+	t.Run("a declining backend falls back to the built-in implementation", func(t *testing.T) {
+		backend := &recordingBackend{decline: true}
+		RegisterBackend("test-declining", backend)
+
+		expected := kernel.ApplyAvg(img, 2)
+
+		k := kernel
+		k.SetBackend("test-declining")
+		actual := k.ApplyAvg(img, 2)
+
+		if backend.calls != 1 {
+			t.Errorf("Expected backend to be called once but was called %d times", backend.calls)
+		}
+		for i := range expected.Pix {
+			if expected.Pix[i] != actual.Pix[i] {
+				t.Fatalf("Expected fallback result to match at offset %d", i)
+			}
+		}
+		if plan := k.avgPlan("avg", 1, img.Rect, 1); plan.Backend != "folded" {
+			t.Errorf("Expected plan backend to be %q but was %q", "folded", plan.Backend)
+		}
+	})
+
+	t.Run("backends can read the kernel's settings", func(t *testing.T) {
+		k := kernel
+		k.SetEdgeMode(EdgeConstant)
+		k.SetEdgeColour(color.NRGBA{R: 1, G: 2, B: 3, A: 4})
+		k.SetSoftClip(0.25)
+		k.SetMaxMemoryBytes(4096)
+
+		if expected, actual := EdgeConstant, k.EdgeMode(); expected != actual {
+			t.Errorf("Expected edge mode %v but was %v", expected, actual)
+		}
+		if expected, actual := (color.NRGBA{R: 1, G: 2, B: 3, A: 4}), k.EdgeColour(); expected != actual {
+			t.Errorf("Expected edge colour %v but was %v", expected, actual)
+		}
+		if expected, actual := float32(0.25), k.SoftClip(); expected != actual {
+			t.Errorf("Expected soft clip knee %v but was %v", expected, actual)
+		}
+		if expected, actual := 4096, k.MaxMemoryBytes(); expected != actual {
+			t.Errorf("Expected memory limit %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("selecting an unknown backend panics", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic but there was none")
+			}
+		}()
+
+		k := kernel
+		k.SetBackend("nonexistent")
+	})
+}
@@ -0,0 +1,196 @@
+package convolver
+
+import (
+	"fmt"
+)
+
+// repeatPassCost is the estimated cost of each pass of a repeated kernel
+// beyond its taps, such as for converting and writing the intermediate
+// result, in units of the cost of one tap.
+const repeatPassCost = 8
+
+// RepeatAvg returns a stage which applies ApplyAvg the given number of times
+// in succession, as Repeat(k.ApplyAvg, passes) does. When it is estimated to
+// be faster, the kernel is instead convolved with itself into a single larger
+// kernel with the same effect, which is applied once.
+//
+// The estimate compares the number of taps with non-zero weights (halved for
+// point symmetric kernels, whose mirrored taps are folded) plus a fixed cost
+// per pass. This favours fusing sparse kernels and few passes; for dense
+// kernels, the fused kernel grows faster than the number of passes.
+//
+// Kernels are only fused when all their weights are non-negative with a
+// positive total, so that intermediate results never need clamping. The
+// results are then the same away from the edges, apart from the rounding of
+// intermediate results to 8 bits, which the fused kernel avoids. Within the
+// fused kernel's radius of the edges, results can differ slightly, since
+// clipping the larger kernel is not the same as clipping each pass.
+func (k *Kernel) RepeatAvg(passes int) Stage {
+	if passes < 0 {
+		panic(fmt.Sprintf("number of passes must not be negative but was %d", passes))
+	}
+
+	if fused := k.fusedRepeat(passes); fused != nil {
+		return fused.ApplyAvg
+	}
+
+	return Repeat(k.ApplyAvg, passes)
+}
+
+// fusedRepeat returns the kernel to use in place of applying this one the
+// given number of times, or nil if repeated application is cheaper.
+func (k *Kernel) fusedRepeat(passes int) *Kernel {
+	if passes < 2 || !k.fusible() {
+		return nil
+	}
+
+	limit := passes*(k.tapCost()+repeatPassCost) - repeatPassCost
+	if k.fusedTapCost(passes, limit) >= limit {
+		return nil
+	}
+
+	return k.selfConvolved(passes)
+}
+
+// fusedTapCost returns the tapCost of the kernel convolved with itself the
+// given number of times, without computing its weights, or some value of at
+// least limit if it would reach that. Since a fusible kernel has no negative
+// weights, no taps cancel, so the fused kernel's taps in each channel are
+// exactly the sums of the given number of this kernel's taps in that channel,
+// and its radius is the given multiple of this kernel's. Repeated sums of a
+// point symmetric kernel's taps are also point symmetric.
+func (k *Kernel) fusedTapCost(passes int, limit int) int {
+	// Each tap records the channels in which it is non-zero as a bit mask
+	taps := make([]uint8, len(k.weights))
+	for i, w := range k.weights {
+		taps[i] = w.channelMask()
+	}
+
+	cost := func(support []uint8) int {
+		count := 0
+		for _, m := range support {
+			if m != 0 {
+				count++
+			}
+		}
+		if k.pointSymmetric() {
+			return (count + 1) / 2
+		}
+		return count
+	}
+
+	support, sideLength := taps, k.sideLength
+
+	for i := 1; i < passes; i++ {
+		if c := cost(support); c >= limit {
+			return c
+		}
+
+		nextSideLength := sideLength + k.sideLength - 1
+		next := make([]uint8, nextSideLength*nextSideLength)
+
+		for y := 0; y < sideLength; y++ {
+			for x := 0; x < sideLength; x++ {
+				m := support[y*sideLength+x]
+				if m == 0 {
+					continue
+				}
+
+				for s := 0; s < k.sideLength; s++ {
+					for t := 0; t < k.sideLength; t++ {
+						next[(y+s)*nextSideLength+x+t] |= m & taps[s*k.sideLength+t]
+					}
+				}
+			}
+		}
+
+		support, sideLength = next, nextSideLength
+	}
+
+	return cost(support)
+}
+
+// fusible returns whether repeated averaging with the kernel is equivalent to
+// averaging once with the kernel convolved with itself.
+func (k *Kernel) fusible() bool {
+	total := kernelWeight{}
+
+	for _, w := range k.weights {
+		if w.R < 0 || w.G < 0 || w.B < 0 || w.A < 0 {
+			return false
+		}
+		total.R += w.R
+		total.G += w.G
+		total.B += w.B
+		total.A += w.A
+	}
+
+	return total.R > 0 && total.G > 0 && total.B > 0 && total.A > 0
+}
+
+// tapCost returns the estimated cost of Avg at each pixel away from the
+// edges, in units of the cost of one tap.
+func (k *Kernel) tapCost() int {
+	taps := 0
+	for _, w := range k.weights {
+		if w != (kernelWeight{}) {
+			taps++
+		}
+	}
+
+	if k.pointSymmetric() {
+		return (taps + 1) / 2
+	}
+	return taps
+}
+
+// selfConvolved returns a kernel equivalent to applying this one the given
+// number of times, with the same settings.
+func (k *Kernel) selfConvolved(passes int) *Kernel {
+	result := *k
+
+	for i := 1; i < passes; i++ {
+		result = convolveKernels(&result, k)
+	}
+
+	return &result
+}
+
+// convolveKernels returns a kernel whose weights are the convolution of those
+// of a and b, so that averaging with it is equivalent to averaging with a and
+// then b. Other settings are taken from a.
+func convolveKernels(a, b *Kernel) Kernel {
+	radius := a.radius + b.radius
+	sideLength := radius*2 + 1
+	weights := make([]kernelWeight, sideLength*sideLength)
+
+	for i := 0; i < a.sideLength; i++ {
+		for j := 0; j < a.sideLength; j++ {
+			wa := a.weights[i*a.sideLength+j]
+
+			for s := 0; s < b.sideLength; s++ {
+				for t := 0; t < b.sideLength; t++ {
+					wb := b.weights[s*b.sideLength+t]
+					w := &weights[(i+s)*sideLength+j+t]
+
+					w.R += wa.R * wb.R
+					w.G += wa.G * wb.G
+					w.B += wa.B * wb.B
+					w.A += wa.A * wb.A
+				}
+			}
+		}
+	}
+
+	result := *a
+	result.radius = radius
+	result.sideLength = sideLength
+	result.weights = make([]kernelWeight, len(weights))
+	result.asymmetricPairs = 0
+
+	for i, w := range weights {
+		result.setWeight(i, w)
+	}
+
+	return result
+}
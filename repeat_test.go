@@ -0,0 +1,134 @@
+package convolver
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestRepeatAvg(t *testing.T) {
+	img := randomImage(40, 30)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+
+	interior := image.Rect(4, 4, 36, 26)
+
+	t.Run("fuses sparse kernels into a single larger kernel", func(t *testing.T) {
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightsUniform([]float32{
+			0, 1, 0,
+			1, 1, 1,
+			0, 1, 0,
+		})
+
+		fused := kernel.selfConvolved(2)
+
+		if expected, actual := 5, fused.SideLength(); expected != actual {
+			t.Fatalf("Expected fused kernel side length to be %d but was %d", expected, actual)
+		}
+
+		expectedWeights := []float32{
+			0, 0, 1, 0, 0,
+			0, 2, 2, 2, 0,
+			1, 2, 5, 2, 1,
+			0, 2, 2, 2, 0,
+			0, 0, 1, 0, 0,
+		}
+		for i, expected := range expectedWeights {
+			if actual, _, _, _ := fused.WeightRGBA(i%5, i/5); expected != actual {
+				t.Errorf("Expected fused weight %d to be %v but was %v", i, expected, actual)
+			}
+		}
+
+		expected := Repeat(kernel.ApplyAvg, 2)(img, 2)
+		actual := kernel.RepeatAvg(2)(img, 2)
+
+		for i := interior.Min.Y; i < interior.Max.Y; i++ {
+			for j := interior.Min.X; j < interior.Max.X; j++ {
+				e, a := expected.NRGBAAt(j, i), actual.NRGBAAt(j, i)
+				if absDiff(e.R, a.R) > 1 || absDiff(e.G, a.G) > 1 || absDiff(e.B, a.B) > 1 || e.A != a.A {
+					t.Fatalf("Expected fused result at %d, %d to be %v but was %v", j, i, e, a)
+				}
+			}
+		}
+	})
+
+	t.Run("applies dense kernels repeatedly when that is cheaper", func(t *testing.T) {
+		kernel := KernelWithRadius(2)
+		kernel.SetWeightsUniform([]float32{
+			1, 4, 6, 4, 1,
+			4, 16, 24, 16, 4,
+			6, 24, 36, 24, 6,
+			4, 16, 24, 16, 4,
+			1, 4, 6, 4, 1,
+		})
+
+		expected := Repeat(kernel.ApplyAvg, 3)(img, 2)
+		actual := kernel.RepeatAvg(3)(img, 2)
+
+		for i := range expected.Pix {
+			if expected.Pix[i] != actual.Pix[i] {
+				t.Fatalf("Expected repeated result to match at offset %d", i)
+			}
+		}
+	})
+
+	t.Run("estimates the fused cost without building the fused kernel", func(t *testing.T) {
+		sparse := KernelWithRadius(1)
+		sparse.SetWeightsUniform([]float32{
+			0, 1, 0,
+			1, 1, 1,
+			0, 1, 0,
+		})
+
+		asymmetric := KernelWithRadius(2)
+		asymmetric.SetWeightsUniform([]float32{
+			1, 0, 0, 0, 0,
+			0, 0, 0, 0, 0,
+			0, 0, 1, 0, 0,
+			0, 0, 0, 0, 3,
+			0, 0, 0, 0, 0,
+		})
+
+		channels := KernelWithRadius(1)
+		channels.SetWeightRGBA(0, 1, 1, 0, 0, 1)
+		channels.SetWeightRGBA(1, 1, 1, 1, 1, 1)
+		channels.SetWeightRGBA(2, 1, 0, 1, 0, 1)
+		channels.SetWeightRGBA(1, 2, 0, 0, 1, 1)
+
+		for _, kernel := range []Kernel{sparse, asymmetric, channels} {
+			for passes := 2; passes <= 4; passes++ {
+				if expected, actual := kernel.selfConvolved(passes).tapCost(), kernel.fusedTapCost(passes, math.MaxInt32); expected != actual {
+					t.Errorf("Expected fused cost of %d passes to be %d but was %d", passes, expected, actual)
+				}
+			}
+		}
+
+		if cost := sparse.fusedTapCost(4, 10); cost < 10 {
+			t.Errorf("Expected cost beyond the limit to be at least the limit but was %d", cost)
+		}
+	})
+
+	t.Run("doesn't fuse kernels with negative weights", func(t *testing.T) {
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightsUniform([]float32{
+			0, -1, 0,
+			-1, 5, -1,
+			0, -1, 0,
+		})
+
+		if kernel.fusible() {
+			t.Errorf("Expected kernel with negative weights not to be fusible")
+		}
+
+		expected := Repeat(kernel.ApplyAvg, 2)(img, 2)
+		actual := kernel.RepeatAvg(2)(img, 2)
+
+		for i := range expected.Pix {
+			if expected.Pix[i] != actual.Pix[i] {
+				t.Fatalf("Expected repeated result to match at offset %d", i)
+			}
+		}
+	})
+}
@@ -0,0 +1,297 @@
+package convolver
+
+import (
+	"fmt"
+	"github.com/mandykoh/go-parallel"
+	"image"
+	"math"
+)
+
+// ResampleFilter selects the reconstruction filter used when resampling.
+type ResampleFilter int
+
+const (
+	// ResampleBox averages each block of source pixels equally. It is the
+	// sharpest choice for integer reductions but allows some aliasing.
+	ResampleBox ResampleFilter = iota
+
+	// ResampleTriangle (or bilinear) weights source pixels by distance,
+	// giving slightly softer results than box filtering with less aliasing.
+	ResampleTriangle
+
+	// ResampleMitchell is the Mitchell-Netravali cubic filter (B = C = 1/3), a
+	// good compromise between sharpness, ringing and aliasing.
+	ResampleMitchell
+
+	// ResampleLanczos3 is a windowed sinc filter with three lobes. It is the
+	// sharpest filter with little aliasing, but may produce slight ringing
+	// around hard edges.
+	ResampleLanczos3
+
+	// ResampleCatmullRom is the Catmull-Rom cubic spline (B = 0, C = 1/2), an
+	// interpolating filter which leaves pixels unchanged when they fall
+	// exactly on source positions.
+	ResampleCatmullRom
+)
+
+func (f ResampleFilter) filter() resampleFilter {
+	switch f {
+	case ResampleBox:
+		return boxFilter
+	case ResampleTriangle:
+		return triangleFilter
+	case ResampleMitchell:
+		return mitchellFilter
+	case ResampleLanczos3:
+		return lanczos3Filter
+	case ResampleCatmullRom:
+		return catmullRomFilter
+	}
+	panic(fmt.Sprintf("unknown resample filter %d", int(f)))
+}
+
+// Downsample reduces an image by an integer factor in each dimension, as is
+// needed to produce a cleanly antialiased image from a supersampled render.
+// Resampling is done in linear light with premultiplied alpha, using the given
+// reconstruction filter. If the image's dimensions are not multiples of the
+// factor, the remaining pixels at the right and bottom edges are dropped.
+func Downsample(img image.Image, factor int, filter ResampleFilter, parallelism int) *image.NRGBA {
+	if factor < 1 {
+		panic(fmt.Sprintf("downsampling factor must be positive but was %d", factor))
+	}
+
+	input := FloatImageFromImage(img, parallelism)
+	bounds := input.Rect
+
+	width, height := bounds.Dx()/factor, bounds.Dy()/factor
+	if width < 1 || height < 1 {
+		panic(fmt.Sprintf("image of size %dx%d is too small to downsample by a factor of %d", bounds.Dx(), bounds.Dy(), factor))
+	}
+
+	cropped := &FloatImage{
+		Pix:    input.Pix,
+		Stride: input.Stride,
+		Rect:   image.Rectangle{Min: bounds.Min, Max: bounds.Min.Add(image.Pt(width*factor, height*factor))},
+	}
+
+	return nrgbaFromFloatImage(resampleFloat(cropped, width, height, filter.filter(), 0, 0, parallelism), parallelism)
+}
+
+// Shift translates an image by a possibly fractional number of pixels, moving
+// its content right and down for positive dx and dy, for example to align
+// images before stacking them. Values between pixels are interpolated with the
+// Catmull-Rom cubic filter in linear light with premultiplied alpha. The
+// result has the same bounds as the image, and areas uncovered by the shift
+// repeat the pixels at the image's edges.
+func Shift(img image.Image, dx, dy float64, parallelism int) *image.NRGBA {
+	input := FloatImageFromImage(img, parallelism)
+
+	result := resampleFloat(input, input.Rect.Dx(), input.Rect.Dy(), catmullRomFilter, dx, dy, parallelism)
+	result.Rect = input.Rect
+
+	return nrgbaFromFloatImage(result, parallelism)
+}
+
+// Thumbnail returns a copy of an image scaled down to fit within the given
+// maximum width and height while preserving its aspect ratio. Images which
+// already fit are returned at their original size. Resampling is done with a
+// Lanczos filter widened in proportion to the reduction, so that detail too
+// fine for the thumbnail is filtered out rather than aliased, and in linear
+// light with premultiplied alpha, so that fine bright and dark detail averages
+// to the correct brightness and transparent areas don't bleed colour. It
+// panics if either maximum is less than one.
+func Thumbnail(img image.Image, maxWidth, maxHeight int, parallelism int) *image.NRGBA {
+	if maxWidth < 1 || maxHeight < 1 {
+		panic(fmt.Sprintf("thumbnail size must be positive but was %dx%d", maxWidth, maxHeight))
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	if width > maxWidth {
+		height = int(math.Max(1, math.Round(float64(height)*float64(maxWidth)/float64(width))))
+		width = maxWidth
+	}
+	if height > maxHeight {
+		width = int(math.Max(1, math.Round(float64(width)*float64(maxHeight)/float64(height))))
+		height = maxHeight
+	}
+
+	input := FloatImageFromImage(img, parallelism)
+	return nrgbaFromFloatImage(resampleFloat(input, width, height, lanczos3Filter, 0, 0, parallelism), parallelism)
+}
+
+// resampleFilter is a separable reconstruction filter, evaluated at offsets
+// (in source pixels) within its support on either side of a sample.
+type resampleFilter struct {
+	support float64
+	weight  func(x float64) float64
+}
+
+var boxFilter = resampleFilter{
+	support: 0.5,
+	weight: func(x float64) float64 {
+		if x >= -0.5 && x < 0.5 {
+			return 1
+		}
+		return 0
+	},
+}
+
+var triangleFilter = resampleFilter{
+	support: 1,
+	weight: func(x float64) float64 {
+		return math.Max(0, 1-math.Abs(x))
+	},
+}
+
+var catmullRomFilter = cubicFilter(0, 0.5)
+
+var mitchellFilter = cubicFilter(1.0/3, 1.0/3)
+
+var lanczos3Filter = resampleFilter{
+	support: 3,
+	weight: func(x float64) float64 {
+		if x == 0 {
+			return 1
+		}
+		if x <= -3 || x >= 3 {
+			return 0
+		}
+		px := math.Pi * x
+		return 3 * math.Sin(px) * math.Sin(px/3) / (px * px)
+	},
+}
+
+// cubicFilter returns the Mitchell-Netravali family cubic filter with the
+// given B and C parameters.
+func cubicFilter(b, c float64) resampleFilter {
+	return resampleFilter{
+		support: 2,
+		weight: func(x float64) float64 {
+			x = math.Abs(x)
+			switch {
+			case x < 1:
+				return ((12-9*b-6*c)*x*x*x + (-18+12*b+6*c)*x*x + (6 - 2*b)) / 6
+			case x < 2:
+				return ((-b-6*c)*x*x*x + (6*b+30*c)*x*x + (-12*b-48*c)*x + (8*b + 24*c)) / 6
+			}
+			return 0
+		},
+	}
+}
+
+// resampleTaps are the source positions and normalised weights contributing
+// to one output sample.
+type resampleTaps struct {
+	start   int
+	weights []float32
+}
+
+// resampleWeights computes the taps for each of outSize samples covering
+// inSize source samples, with the output displaced by shift source pixels.
+// When reducing, the filter is widened to remove frequencies the output can't
+// represent. Source positions beyond the edges are clamped.
+func resampleWeights(inSize, outSize int, shift float64, filter resampleFilter) []resampleTaps {
+	scale := float64(inSize) / float64(outSize)
+	filterScale := math.Max(scale, 1)
+	support := filter.support * filterScale
+
+	taps := make([]resampleTaps, outSize)
+
+	for o := range taps {
+		centre := (float64(o)+0.5)*scale - 0.5 - shift
+		start := int(math.Ceil(centre - support))
+		end := int(math.Floor(centre + support))
+
+		weights := make([]float32, end-start+1)
+		total := 0.0
+		for i := start; i <= end; i++ {
+			w := filter.weight((float64(i) - centre) / filterScale)
+			weights[i-start] = float32(w)
+			total += w
+		}
+		if total != 0 {
+			for i := range weights {
+				weights[i] /= float32(total)
+			}
+		}
+
+		taps[o] = resampleTaps{start: start, weights: weights}
+	}
+
+	return taps
+}
+
+// resampleFloat resamples an image to the given size with a separable filter,
+// optionally displacing it by a sub-pixel shift. The result's bounds start at
+// the origin. Values are premultiplied by alpha while filtering.
+func resampleFloat(img *FloatImage, width, height int, filter resampleFilter, shiftX, shiftY float64, parallelism int) *FloatImage {
+	bounds := img.Rect
+	columns := resampleWeights(bounds.Dx(), width, shiftX, filter)
+	rows := resampleWeights(bounds.Dy(), height, shiftY, filter)
+
+	horizontal := NewFloatImage(image.Rect(0, 0, width, bounds.Dy()))
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := workerNum; i < bounds.Dy(); i += workerCount {
+			src := img.Pix[i*img.Stride:]
+
+			for o, taps := range columns {
+				var sum [4]float32
+				for k, w := range taps.weights {
+					offset := clampInt(taps.start+k, 0, bounds.Dx()-1) * 4
+					a := src[offset+3]
+					sum[0] += src[offset] * a * w
+					sum[1] += src[offset+1] * a * w
+					sum[2] += src[offset+2] * a * w
+					sum[3] += a * w
+				}
+				copy(horizontal.Pix[i*horizontal.Stride+o*4:], sum[:])
+			}
+		}
+	})
+
+	result := NewFloatImage(image.Rect(0, 0, width, height))
+	accumulate := vector().accumulate
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for o := workerNum; o < height; o += workerCount {
+			taps := rows[o]
+			dst := result.Pix[o*result.Stride:][:width*4]
+
+			for k, w := range taps.weights {
+				row := clampInt(taps.start+k, 0, bounds.Dy()-1)
+				accumulate(dst, horizontal.Pix[row*horizontal.Stride:], w)
+			}
+
+			for j := 0; j < len(dst); j += 4 {
+				if a := dst[j+3]; a > 0 {
+					dst[j] /= a
+					dst[j+1] /= a
+					dst[j+2] /= a
+				} else {
+					dst[j], dst[j+1], dst[j+2], dst[j+3] = 0, 0, 0, 0
+				}
+			}
+		}
+	})
+
+	return result
+}
+
+// nrgbaFromFloatImage encodes a FloatImage as an 8-bit sRGB image, clipping
+// values to 0.0–1.0.
+func nrgbaFromFloatImage(img *FloatImage, parallelism int) *image.NRGBA {
+	result := image.NewNRGBA(img.Rect)
+	quantise := vector().quantise
+	width := img.Rect.Dx() * 4
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := workerNum; i < img.Rect.Dy(); i += workerCount {
+			quantise(result.Pix[i*result.Stride:][:width], img.Pix[i*img.Stride:][:width])
+		}
+	})
+
+	return result
+}
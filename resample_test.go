@@ -0,0 +1,207 @@
+package convolver
+
+import (
+	"github.com/mandykoh/prism/srgb"
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+)
+
+func TestThumbnail(t *testing.T) {
+
+	t.Run("fits within the maximum size preserving aspect ratio", func(t *testing.T) {
+		cases := []struct {
+			Width, Height       int
+			MaxWidth, MaxHeight int
+			Expected            image.Rectangle
+		}{
+			{400, 200, 100, 100, image.Rect(0, 0, 100, 50)},
+			{200, 400, 100, 100, image.Rect(0, 0, 50, 100)},
+			{400, 200, 300, 50, image.Rect(0, 0, 100, 50)},
+			{1000, 3, 100, 100, image.Rect(0, 0, 100, 1)},
+		}
+
+		for _, c := range cases {
+			img := image.NewNRGBA(image.Rect(0, 0, c.Width, c.Height))
+
+			if actual := Thumbnail(img, c.MaxWidth, c.MaxHeight, runtime.NumCPU()).Rect; c.Expected != actual {
+				t.Errorf("Expected %dx%d within %dx%d to give %v but was %v", c.Width, c.Height, c.MaxWidth, c.MaxHeight, c.Expected, actual)
+			}
+		}
+	})
+
+	t.Run("leaves images which already fit unchanged", func(t *testing.T) {
+		img := randomImage(20, 10)
+		for i := 3; i < len(img.Pix); i += 4 {
+			img.Pix[i] = 255
+		}
+
+		result := Thumbnail(img, 20, 20, runtime.NumCPU())
+
+		for i := 0; i < 10; i++ {
+			for j := 0; j < 20; j++ {
+				c, a := srgb.ColorFromNRGBA(img.NRGBAAt(j, i))
+				expected, actual := c.ToNRGBA(a), result.NRGBAAt(j, i)
+
+				if absDiff(expected.R, actual.R) > 1 || absDiff(expected.G, actual.G) > 1 || absDiff(expected.B, actual.B) > 1 || expected.A != actual.A {
+					t.Fatalf("Expected pixel %d,%d to be %+v but was %+v", j, i, expected, actual)
+				}
+			}
+		}
+	})
+
+	t.Run("averages in linear light", func(t *testing.T) {
+		img := Checkerboard(image.Rect(0, 0, 64, 64), 1)
+
+		result := Thumbnail(img, 16, 16, runtime.NumCPU())
+
+		// Half black and half white is linear 0.5, which encodes to 188
+		if expected, actual := uint8(188), result.NRGBAAt(8, 8).R; absDiff(expected, actual) > 2 {
+			t.Errorf("Expected downscaled checkerboard to be %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("doesn't bleed colour from transparent pixels", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+		for i := 0; i < 32; i++ {
+			for j := 0; j < 32; j++ {
+				if (i+j)%2 == 0 {
+					img.SetNRGBA(j, i, color.NRGBA{R: 255, A: 255})
+				} else {
+					img.SetNRGBA(j, i, color.NRGBA{G: 255})
+				}
+			}
+		}
+
+		result := Thumbnail(img, 8, 8, runtime.NumCPU())
+		c := result.NRGBAAt(4, 4)
+
+		if c.G != 0 || c.R < 250 {
+			t.Errorf("Expected colour to be red but was %+v", c)
+		}
+		if expected, actual := uint8(128), c.A; absDiff(expected, actual) > 1 {
+			t.Errorf("Expected alpha to be %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("panics for sizes less than one", func(t *testing.T) {
+		for _, size := range []image.Point{{X: 0, Y: 8}, {X: 8, Y: 0}, {X: -1, Y: -1}} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("Expected a panic for size %v", size)
+					}
+				}()
+				Thumbnail(image.NewNRGBA(image.Rect(0, 0, 16, 16)), size.X, size.Y, runtime.NumCPU())
+			}()
+		}
+	})
+}
+
+func TestDownsample(t *testing.T) {
+
+	t.Run("reduces by the factor, dropping remaining pixels", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(3, 5, 3+17, 5+9))
+
+		result := Downsample(img, 4, ResampleBox, runtime.NumCPU())
+
+		if expected, actual := image.Rect(0, 0, 4, 2), result.Rect; expected != actual {
+			t.Errorf("Expected bounds to be %v but was %v", expected, actual)
+		}
+	})
+
+	t.Run("box filter averages each block in linear light", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+		img.SetNRGBA(0, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+		img.SetNRGBA(1, 0, color.NRGBA{A: 255})
+		img.SetNRGBA(0, 1, color.NRGBA{A: 255})
+		img.SetNRGBA(1, 1, color.NRGBA{A: 255})
+		img.SetNRGBA(2, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+		img.SetNRGBA(3, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+		img.SetNRGBA(2, 1, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+		img.SetNRGBA(3, 1, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+
+		result := Downsample(img, 2, ResampleBox, runtime.NumCPU())
+
+		// Linear 0.25 encodes to 137
+		if expected, actual := uint8(137), result.NRGBAAt(0, 0).R; absDiff(expected, actual) > 1 {
+			t.Errorf("Expected quarter covered block to be %d but was %d", expected, actual)
+		}
+		if expected, actual := uint8(255), result.NRGBAAt(1, 0).R; expected != actual {
+			t.Errorf("Expected fully covered block to be %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("preserves flat areas with every filter", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+		for i := 0; i < len(img.Pix); i += 4 {
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = 200, 100, 50, 255
+		}
+
+		for _, filter := range []ResampleFilter{ResampleBox, ResampleTriangle, ResampleMitchell, ResampleLanczos3} {
+			result := Downsample(img, 4, filter, runtime.NumCPU())
+
+			for i := 0; i < len(result.Pix); i += 4 {
+				if absDiff(result.Pix[i], 200) > 1 || absDiff(result.Pix[i+1], 100) > 1 || absDiff(result.Pix[i+2], 50) > 1 || result.Pix[i+3] != 255 {
+					t.Fatalf("Expected filter %d to preserve flat colour but got %v", filter, result.Pix[i:i+4])
+				}
+			}
+		}
+	})
+
+	t.Run("panics for images smaller than the factor", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic")
+			}
+		}()
+		Downsample(image.NewNRGBA(image.Rect(0, 0, 3, 3)), 4, ResampleBox, runtime.NumCPU())
+	})
+}
+
+func TestShift(t *testing.T) {
+	img := randomImage(24, 16)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+
+	t.Run("moves content by whole pixels exactly", func(t *testing.T) {
+		result := Shift(img, 3, -2, runtime.NumCPU())
+
+		if expected, actual := img.Rect, result.Rect; expected != actual {
+			t.Fatalf("Expected bounds to be %v but were %v", expected, actual)
+		}
+
+		for i := 0; i < 14; i++ {
+			for j := 3; j < 24; j++ {
+				c, a := srgb.ColorFromNRGBA(img.NRGBAAt(j-3, i+2))
+				expected, actual := c.ToNRGBA(a), result.NRGBAAt(j, i)
+
+				if absDiff(expected.R, actual.R) > 1 || absDiff(expected.G, actual.G) > 1 || absDiff(expected.B, actual.B) > 1 {
+					t.Fatalf("Expected pixel %d,%d to be %+v but was %+v", j, i, expected, actual)
+				}
+			}
+		}
+	})
+
+	t.Run("interpolates sub-pixel shifts in linear light", func(t *testing.T) {
+		edge := image.NewNRGBA(image.Rect(0, 0, 16, 1))
+		for j := 8; j < 16; j++ {
+			edge.SetNRGBA(j, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+		}
+		for j := 0; j < 8; j++ {
+			edge.SetNRGBA(j, 0, color.NRGBA{A: 255})
+		}
+
+		result := Shift(edge, 0.5, 0, runtime.NumCPU())
+
+		// Halfway between black and white is linear 0.5, which encodes to 188
+		if expected, actual := uint8(188), result.NRGBAAt(8, 0).R; absDiff(expected, actual) > 2 {
+			t.Errorf("Expected pixel straddling the edge to be %d but was %d", expected, actual)
+		}
+		if expected, actual := uint8(0), result.NRGBAAt(2, 0).R; expected != actual {
+			t.Errorf("Expected dark side to remain %d but was %d", expected, actual)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package convolver
+
+import (
+	"image"
+	"math"
+)
+
+// RobertsCrossKernels returns the pair of Roberts cross diagonal difference
+// kernels, anchored at the top left of each 2x2 neighbourhood. The
+// differences are really centred between the four pixels, so results lie
+// half a pixel up and to the left of where odd-sized kernels would place
+// them; use RobertsCrossKernelsAnchored to choose a different corner.
+func RobertsCrossKernels() (Kernel, Kernel) {
+	return RobertsCrossKernelsAnchored(image.Point{})
+}
+
+// RobertsCrossKernelsAnchored returns the pair of Roberts cross diagonal
+// difference kernels with the pixel being processed at the given corner of
+// the 2x2 neighbourhood, from (0, 0) for the top left to (1, 1) for the
+// bottom right. Whichever corner is chosen, the results are offset by half a
+// pixel diagonally from it.
+func RobertsCrossKernelsAnchored(anchor image.Point) (Kernel, Kernel) {
+	k1 := KernelWithAnchor(2, 2, anchor, []float32{
+		1, 0,
+		0, -1,
+	})
+
+	k2 := KernelWithAnchor(2, 2, anchor, []float32{
+		0, 1,
+		-1, 0,
+	})
+
+	return k1, k2
+}
+
+// RobertsCross returns the Roberts cross gradient magnitude of an image's
+// linear luminance, anchored as for RobertsCrossKernels.
+func RobertsCross(img image.Image, parallelism int) *Plane {
+	luminance := LuminancePlane(img, parallelism)
+	k1, k2 := RobertsCrossKernels()
+
+	g1 := k1.convolvePlane(luminance, parallelism)
+	g2 := k2.convolvePlane(luminance, parallelism)
+
+	for i := range g1.Pix {
+		g1.Pix[i] = float32(math.Hypot(float64(g1.Pix[i]), float64(g2.Pix[i])))
+	}
+
+	return g1
+}
@@ -0,0 +1,73 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func TestRobertsCross(t *testing.T) {
+
+	t.Run("computes diagonal differences from the top left of each 2x2 neighbourhood", func(t *testing.T) {
+		plane := NewPlane(image.Rect(0, 0, 3, 3))
+		copy(plane.Pix, []float32{
+			1, 2, 3,
+			4, 5, 6,
+			7, 8, 10,
+		})
+
+		k1, k2 := RobertsCrossKernels()
+		g1 := k1.convolvePlane(plane, runtime.NumCPU())
+		g2 := k2.convolvePlane(plane, runtime.NumCPU())
+
+		if expected, actual := float32(1-5), g1.ValueAt(0, 0); expected != actual {
+			t.Errorf("Expected first diagonal difference to be %v but was %v", expected, actual)
+		}
+		if expected, actual := float32(2-4), g2.ValueAt(0, 0); expected != actual {
+			t.Errorf("Expected second diagonal difference to be %v but was %v", expected, actual)
+		}
+	})
+
+	t.Run("responds to edges only", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+		for i := img.Rect.Min.Y; i < img.Rect.Max.Y; i++ {
+			for j := 4; j < img.Rect.Max.X; j++ {
+				img.SetNRGBA(j, i, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+			}
+		}
+
+		result := RobertsCross(img, runtime.NumCPU())
+
+		if expected, actual := math.Sqrt2, float64(result.ValueAt(3, 4)); math.Abs(expected-actual) > 1e-3 {
+			t.Errorf("Expected magnitude at edge to be %v but was %v", expected, actual)
+		}
+		if actual := result.ValueAt(1, 4); actual != 0 {
+			t.Errorf("Expected magnitude away from edge to be zero but was %v", actual)
+		}
+	})
+}
+
+func TestRobertsCrossKernelsAnchored(t *testing.T) {
+
+	t.Run("computes differences from the chosen corner", func(t *testing.T) {
+		plane := NewPlane(image.Rect(0, 0, 3, 3))
+		copy(plane.Pix, []float32{
+			1, 2, 3,
+			4, 5, 6,
+			7, 8, 10,
+		})
+
+		k1, k2 := RobertsCrossKernelsAnchored(image.Pt(1, 1))
+		g1 := k1.convolvePlane(plane, runtime.NumCPU())
+		g2 := k2.convolvePlane(plane, runtime.NumCPU())
+
+		if expected, actual := float32(5-10), g1.ValueAt(2, 2); expected != actual {
+			t.Errorf("Expected first diagonal difference to be %v but was %v", expected, actual)
+		}
+		if expected, actual := float32(6-8), g2.ValueAt(2, 2); expected != actual {
+			t.Errorf("Expected second diagonal difference to be %v but was %v", expected, actual)
+		}
+	})
+}
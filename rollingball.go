@@ -0,0 +1,162 @@
+package convolver
+
+import (
+	"fmt"
+	"github.com/mandykoh/go-parallel"
+	"github.com/mandykoh/prism"
+	"image"
+	"math"
+)
+
+// RollingBall returns a stage which removes smooth, uneven background from an
+// image in the manner of ImageJ's "Subtract Background" command. A ball of the
+// given radius is rolled beneath the surface formed by each colour channel's
+// values, and the surface traced out by its top is subtracted as the
+// background. The radius should be at least as large as the largest object
+// which is not part of the background.
+//
+// As in ImageJ, values are treated as encoded 0–255 intensities, with one
+// intensity level being equivalent to one pixel of distance. With
+// lightBackground set, the image is treated as having a light background and
+// dark objects, so the background becomes white rather than black. Alpha is
+// left unchanged.
+//
+// Unlike ImageJ, the image is not downscaled for large radii, so processing
+// time grows with the square of the radius.
+func RollingBall(radius float64, lightBackground bool) Stage {
+	return func(img image.Image, parallelism int) *image.NRGBA {
+		input := prism.ConvertImageToNRGBA(img, parallelism)
+		background := RollingBallBackground(input, radius, lightBackground, parallelism)
+		result := image.NewNRGBA(input.Rect)
+
+		parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+			for i := input.Rect.Min.Y + workerNum; i < input.Rect.Max.Y; i += workerCount {
+				for j := input.Rect.Min.X; j < input.Rect.Max.X; j++ {
+					in := input.Pix[input.PixOffset(j, i):]
+					bg := background.Pix[background.PixOffset(j, i):]
+					out := result.Pix[result.PixOffset(j, i):]
+
+					for c := 0; c < 3; c++ {
+						if lightBackground {
+							out[c] = uint8(clampInt(255-int(bg[c])+int(in[c]), 0, 255))
+						} else {
+							out[c] = uint8(clampInt(int(in[c])-int(bg[c]), 0, 255))
+						}
+					}
+					out[3] = in[3]
+				}
+			}
+		})
+
+		return result
+	}
+}
+
+// RollingBallBackground returns the background estimated by RollingBall,
+// without subtracting it from the image.
+func RollingBallBackground(img image.Image, radius float64, lightBackground bool, parallelism int) *image.NRGBA {
+	if radius <= 0 {
+		panic(fmt.Sprintf("ball radius must be positive but was %v", radius))
+	}
+
+	input := prism.ConvertImageToNRGBA(img, parallelism)
+	ball := newRollingBall(radius)
+	result := image.NewNRGBA(input.Rect)
+
+	for c := 0; c < 3; c++ {
+		channel := NewPlane(input.Rect)
+		for i := input.Rect.Min.Y; i < input.Rect.Max.Y; i++ {
+			for j := input.Rect.Min.X; j < input.Rect.Max.X; j++ {
+				v := float32(input.Pix[input.PixOffset(j, i)+c])
+				if lightBackground {
+					v = 255 - v
+				}
+				channel.Pix[channel.offset(j, i)] = v
+			}
+		}
+
+		opened := ball.roll(ball.roll(channel, false, parallelism), true, parallelism)
+
+		for i := input.Rect.Min.Y; i < input.Rect.Max.Y; i++ {
+			for j := input.Rect.Min.X; j < input.Rect.Max.X; j++ {
+				v := opened.Pix[opened.offset(j, i)]
+				if lightBackground {
+					v = 255 - v
+				}
+				result.Pix[result.PixOffset(j, i)+c] = uint8(clampInt(int(math.Round(float64(v))), 0, 255))
+			}
+		}
+	}
+
+	for i := input.Rect.Min.Y; i < input.Rect.Max.Y; i++ {
+		for j := input.Rect.Min.X; j < input.Rect.Max.X; j++ {
+			result.Pix[result.PixOffset(j, i)+3] = input.Pix[input.PixOffset(j, i)+3]
+		}
+	}
+
+	return result
+}
+
+// rollingBall is a spherical structuring element, given as the offsets it
+// covers and the height of the ball's surface at each.
+type rollingBall struct {
+	offsets []image.Point
+	heights []float32
+}
+
+func newRollingBall(radius float64) rollingBall {
+	ball := rollingBall{}
+	extent := int(radius)
+
+	for dy := -extent; dy <= extent; dy++ {
+		for dx := -extent; dx <= extent; dx++ {
+			distSquared := float64(dx*dx + dy*dy)
+			if distSquared > radius*radius {
+				continue
+			}
+			ball.offsets = append(ball.offsets, image.Pt(dx, dy))
+			ball.heights = append(ball.heights, float32(math.Sqrt(radius*radius-distSquared)))
+		}
+	}
+
+	return ball
+}
+
+// roll erodes (or dilates) a plane with the ball as a non-flat structuring
+// element. Offsets falling outside the plane are ignored. The ball is
+// symmetric, so no reflection is needed for dilation.
+func (b rollingBall) roll(p *Plane, dilate bool, parallelism int) *Plane {
+	result := NewPlane(p.Rect)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := p.Rect.Min.Y + workerNum; i < p.Rect.Max.Y; i += workerCount {
+			for j := p.Rect.Min.X; j < p.Rect.Max.X; j++ {
+				v := float32(math.Inf(1))
+				if dilate {
+					v = float32(math.Inf(-1))
+				}
+
+				for k, offset := range b.offsets {
+					x, y := j+offset.X, i+offset.Y
+					if !(image.Point{X: x, Y: y}.In(p.Rect)) {
+						continue
+					}
+
+					if dilate {
+						if s := p.Pix[p.offset(x, y)] + b.heights[k]; s > v {
+							v = s
+						}
+					} else {
+						if s := p.Pix[p.offset(x, y)] - b.heights[k]; s < v {
+							v = s
+						}
+					}
+				}
+
+				result.Pix[result.offset(j, i)] = v
+			}
+		}
+	})
+
+	return result
+}
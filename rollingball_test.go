@@ -0,0 +1,65 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+)
+
+func TestRollingBall(t *testing.T) {
+	// A gentle ramp with a small bright spot on top of it
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	for i := 0; i < 40; i++ {
+		for j := 0; j < 40; j++ {
+			v := uint8(40 + j)
+			img.SetNRGBA(j, i, color.NRGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+	for i := 18; i < 21; i++ {
+		for j := 18; j < 21; j++ {
+			img.SetNRGBA(j, i, color.NRGBA{R: 200, G: 200, B: 200, A: 255})
+		}
+	}
+
+	t.Run("estimates the background beneath small objects", func(t *testing.T) {
+		background := RollingBallBackground(img, 10, false, runtime.NumCPU())
+
+		if actual := background.NRGBAAt(19, 19).R; actual > 62 {
+			t.Errorf("Expected background under the spot to follow the ramp but was %d", actual)
+		}
+		if expected, actual := uint8(45), background.NRGBAAt(5, 30).R; absDiff(expected, actual) > 1 {
+			t.Errorf("Expected background away from the spot to match the ramp value %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("subtracts the background", func(t *testing.T) {
+		result := RollingBall(10, false)(img, runtime.NumCPU())
+
+		if actual := result.NRGBAAt(5, 30).R; actual > 1 {
+			t.Errorf("Expected background to be removed but was %d", actual)
+		}
+		if actual := result.NRGBAAt(19, 19).R; actual < 130 {
+			t.Errorf("Expected spot to remain bright but was %d", actual)
+		}
+		if expected, actual := uint8(255), result.NRGBAAt(19, 19).A; expected != actual {
+			t.Errorf("Expected alpha to be unchanged at %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("makes a light background white", func(t *testing.T) {
+		inverted := image.NewNRGBA(img.Rect)
+		for i := 0; i < len(img.Pix); i += 4 {
+			inverted.Pix[i], inverted.Pix[i+1], inverted.Pix[i+2], inverted.Pix[i+3] = 255-img.Pix[i], 255-img.Pix[i+1], 255-img.Pix[i+2], 255
+		}
+
+		result := RollingBall(10, true)(inverted, runtime.NumCPU())
+
+		if actual := result.NRGBAAt(5, 30).R; actual < 254 {
+			t.Errorf("Expected background to become white but was %d", actual)
+		}
+		if actual := result.NRGBAAt(19, 19).R; actual > 125 {
+			t.Errorf("Expected spot to remain dark but was %d", actual)
+		}
+	})
+}
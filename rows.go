@@ -0,0 +1,76 @@
+package convolver
+
+import (
+	"github.com/mandykoh/go-parallel"
+	"image"
+)
+
+// RowFunc receives a completed row of output. row is a one pixel high image
+// whose bounds span the row at y; it is reused for later rows and must not be
+// retained after returning. Returning an error stops processing.
+type RowFunc func(y int, row *image.NRGBA) error
+
+// ApplyRows applies an operation such as k.Avg to an image, delivering output
+// rows to onRow strictly in top-to-bottom order without ever holding the
+// whole output image in memory. This allows results to be piped directly into
+// a streaming encoder. Any error returned by onRow is returned.
+//
+// Unless img is already an *image.NRGBA, only the band of rows being worked on
+// and its apron are converted at a time, so images whose pixel count would
+// overflow an int as a single buffer (as very large panoramas can on 32-bit
+// platforms) can still be processed from a source which generates or decodes
+// pixels on demand. Edge modes other than EdgeClip are applied as the band's
+// apron is converted, so the image is never padded as a whole.
+func (k *Kernel) ApplyRows(img image.Image, op OpFunc, onRow RowFunc, parallelism int) error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	bounds := img.Bounds()
+	input, whole := img.(*image.NRGBA)
+
+	source := img
+	if k.edgeMode != EdgeClip && k.radius > 0 && !bounds.Empty() {
+		source = edgePaddedImage{img: img, rect: bounds.Inset(-k.radius), mode: k.edgeMode, fill: k.edgeFill()}
+		whole = false
+	}
+
+	bandHeight := parallelism * 4
+	band := image.NewNRGBA(image.Rect(bounds.Min.X, 0, bounds.Max.X, bandHeight))
+	var buffer []uint8
+
+	for bandTop := bounds.Min.Y; bandTop < bounds.Max.Y; bandTop += bandHeight {
+		rows := bandHeight
+		if remaining := bounds.Max.Y - bandTop; remaining < rows {
+			rows = remaining
+		}
+
+		if !whole {
+			bandRect := image.Rect(bounds.Min.X, bandTop, bounds.Max.X, bandTop+rows)
+			input = nrgbaRegion(source, bandRect.Inset(-k.radius).Intersect(source.Bounds()), &buffer)
+		}
+
+		parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+			for r := workerNum; r < rows; r += workerCount {
+				for j := bounds.Min.X; j < bounds.Max.X; j++ {
+					band.SetNRGBA(j, r, op(input, j, bandTop+r))
+				}
+			}
+		})
+
+		for r := 0; r < rows; r++ {
+			y := bandTop + r
+			row := &image.NRGBA{
+				Pix:    band.Pix[band.PixOffset(bounds.Min.X, r):band.PixOffset(bounds.Min.X, r+1)],
+				Stride: band.Stride,
+				Rect:   image.Rect(bounds.Min.X, y, bounds.Max.X, y+1),
+			}
+
+			if err := onRow(y, row); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package convolver
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"runtime"
+	"testing"
+)
+
+func TestKernelApplyRows(t *testing.T) {
+	img := randomImage(33, 29)
+	img.Rect = img.Rect.Add(image.Pt(5, 7))
+
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{
+		1, 2, 1,
+		2, 4, 2,
+		1, 2, 1,
+	})
+
+	expectedImg := kernel.ApplyAvg(img, runtime.NumCPU())
+
+	t.Run("delivers every row in order", func(t *testing.T) {
+		nextY := img.Rect.Min.Y
+
+		err := kernel.ApplyRows(img, kernel.Avg, func(y int, row *image.NRGBA) error {
+			if y != nextY {
+				t.Fatalf("Expected row %d but got row %d", nextY, y)
+			}
+			nextY++
+
+			if expected, actual := image.Rect(img.Rect.Min.X, y, img.Rect.Max.X, y+1), row.Rect; expected != actual {
+				t.Fatalf("Expected row bounds to be %v but were %v", expected, actual)
+			}
+
+			for j := img.Rect.Min.X; j < img.Rect.Max.X; j++ {
+				if expected, actual := expectedImg.NRGBAAt(j, y), row.NRGBAAt(j, y); expected != actual {
+					t.Fatalf("Expected pixel %d,%d to be %+v but was %+v", j, y, expected, actual)
+				}
+			}
+
+			return nil
+		}, runtime.NumCPU())
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if expected, actual := img.Rect.Max.Y, nextY; expected != actual {
+			t.Errorf("Expected rows up to %d to be delivered but stopped at %d", expected, actual)
+		}
+	})
+
+	t.Run("stops and returns callback errors", func(t *testing.T) {
+		failure := errors.New("encoder failed")
+		calls := 0
+
+		err := kernel.ApplyRows(img, kernel.Avg, func(y int, row *image.NRGBA) error {
+			calls++
+			if calls == 3 {
+				return failure
+			}
+			return nil
+		}, 2)
+
+		if err != failure {
+			t.Errorf("Expected callback error to be returned but got %v", err)
+		}
+		if calls != 3 {
+			t.Errorf("Expected processing to stop after 3 rows but %d were delivered", calls)
+		}
+	})
+	t.Run("treats parallelism below one as a single worker", func(t *testing.T) {
+		for _, parallelism := range []int{0, -1} {
+			rows := 0
+
+			err := kernel.ApplyRows(img, kernel.Avg, func(y int, row *image.NRGBA) error {
+				rows++
+				if expected, actual := expectedImg.NRGBAAt(img.Rect.Min.X, y), row.NRGBAAt(img.Rect.Min.X, y); expected != actual {
+					t.Fatalf("Expected pixel %d,%d to be %+v but was %+v", img.Rect.Min.X, y, expected, actual)
+				}
+				return nil
+			}, parallelism)
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if expected, actual := img.Rect.Dy(), rows; expected != actual {
+				t.Errorf("Expected %d rows with parallelism %d but got %d", expected, parallelism, actual)
+			}
+		}
+	})
+
+	t.Run("converts other image types a band at a time", func(t *testing.T) {
+		rgba := image.NewRGBA(img.Rect)
+		for i := img.Rect.Min.Y; i < img.Rect.Max.Y; i++ {
+			for j := img.Rect.Min.X; j < img.Rect.Max.X; j++ {
+				c := img.NRGBAAt(j, i)
+				c.A = 255
+				rgba.Set(j, i, c)
+			}
+		}
+		expectedOpaque := kernel.ApplyAvg(rgba, runtime.NumCPU())
+
+		err := kernel.ApplyRows(rgba, kernel.Avg, func(y int, row *image.NRGBA) error {
+			for j := img.Rect.Min.X; j < img.Rect.Max.X; j++ {
+				if expected, actual := expectedOpaque.NRGBAAt(j, y), row.NRGBAAt(j, y); expected != actual {
+					t.Fatalf("Expected pixel %d,%d to be %+v but was %+v", j, y, expected, actual)
+				}
+			}
+			return nil
+		}, 3)
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("streams images too large for a 32-bit buffer", func(t *testing.T) {
+		// 2^20 x 2^10 pixels at 4 bytes each need 2^32 bytes as one buffer,
+		// beyond what a 32-bit int can index.
+		huge := hugeUniformImage{
+			rect:   image.Rect(0, 0, 1<<20, 1<<10),
+			colour: color.NRGBA{R: 10, G: 20, B: 30, A: 255},
+		}
+		small := image.NewNRGBA(image.Rect(0, 0, 3, 3))
+		draw.Draw(small, small.Rect, huge, image.Point{}, draw.Src)
+		expected := kernel.ApplyAvg(small, 1).NRGBAAt(1, 1)
+		stop := errors.New("stop")
+
+		err := kernel.ApplyRows(huge, kernel.Avg, func(y int, row *image.NRGBA) error {
+			for _, j := range []int{0, 1 << 19, 1<<20 - 1} {
+				if actual := row.NRGBAAt(j, y); expected != actual {
+					t.Errorf("Expected pixel %d,%d to be %+v but was %+v", j, y, expected, actual)
+				}
+			}
+			return stop
+		}, 1)
+
+		if err != stop {
+			t.Errorf("Expected callback error to be returned but got %v", err)
+		}
+	})
+}
+
+// hugeUniformImage is a single colour image of any size which needs no pixel
+// storage.
+type hugeUniformImage struct {
+	rect   image.Rectangle
+	colour color.NRGBA
+}
+
+func (h hugeUniformImage) ColorModel() color.Model {
+	return color.NRGBAModel
+}
+
+func (h hugeUniformImage) Bounds() image.Rectangle {
+	return h.rect
+}
+
+func (h hugeUniformImage) At(x, y int) color.Color {
+	return h.colour
+}
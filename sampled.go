@@ -0,0 +1,209 @@
+package convolver
+
+import (
+	"github.com/mandykoh/prism/srgb"
+	"image"
+	"image/color"
+	"sort"
+)
+
+type SamplePattern int
+
+const (
+	// SampleRandom selects taps independently and uniformly at random.
+	SampleRandom SamplePattern = iota
+
+	// SampleStratified divides the kernel's taps into spatially contiguous
+	// strata and selects one tap at random from each, which spreads samples
+	// evenly over the kernel with noticeably less noise than SampleRandom.
+	SampleStratified
+
+	// SampleImportance selects taps with probability proportional to the
+	// magnitude of their weights, so that taps contributing most to the
+	// result are sampled most often. This suits kernels such as bokeh
+	// apertures where most of the weight is concentrated in a few regions.
+	SampleImportance
+
+	// SampleBlueNoise selects one tap from each stratum as SampleStratified
+	// does, but chooses where within each stratum from a tiled blue-noise
+	// mask, so that neighbouring pixels sample different taps. The remaining
+	// noise is then mostly high-frequency, which is much less visible than
+	// the clumpy noise of independent random choices.
+	SampleBlueNoise
+)
+
+// blueNoiseTileSize is the side length of the blue-noise mask tiled over the
+// image by SampleBlueNoise.
+const blueNoiseTileSize = 64
+
+// ApplyAvgSampled approximates ApplyAvg by evaluating only the given number of
+// randomly selected taps for each pixel, trading exactness for speed when the
+// kernel is very large. Taps with zero weight are never selected. The
+// selection is deterministic for a given seed.
+func (k *Kernel) ApplyAvgSampled(img image.Image, samples int, pattern SamplePattern, seed int64, parallelism int) *image.NRGBA {
+	return k.applyImage(img, k.avgSampled(samples, pattern, seed), parallelism)
+}
+
+func (k *Kernel) avgSampled(samples int, pattern SamplePattern, seed int64) OpFunc {
+	taps := k.nonZeroTaps()
+
+	var cdf []float32
+	if pattern == SampleImportance {
+		cdf = k.tapMagnitudeCDF(taps)
+	} else if samples > len(taps) {
+		samples = len(taps)
+	}
+	if len(taps) == 0 {
+		samples = 0
+	}
+
+	var blueNoise *image.Gray
+	if pattern == SampleBlueNoise {
+		blueNoise = BlueNoiseMask(image.Rect(0, 0, blueNoiseTileSize, blueNoiseTileSize), seed, 1)
+	}
+
+	return func(img *image.NRGBA, x, y int) color.NRGBA {
+		totalWeight := kernelWeight{}
+		sum := kernelWeight{}
+
+		for i := 0; i < samples; i++ {
+			var tap kernelTap
+			var scale float32
+
+			switch pattern {
+			case SampleImportance:
+				u := (float32(i) + sampleUniform(seed, x, y, i)) / float32(samples)
+				index := sort.Search(len(cdf)-1, func(n int) bool { return cdf[n] > u })
+				tap = taps[index]
+				scale = 1 / k.weights[tap.Y*k.sideLength+tap.X].magnitude()
+
+			case SampleStratified:
+				start, end := i*len(taps)/samples, (i+1)*len(taps)/samples
+				tap = taps[start+int(sampleHash(seed, x, y, i)%uint64(end-start))]
+				scale = float32(end - start)
+
+			case SampleBlueNoise:
+				// Shift the mask differently for each stratum so that the
+				// choices within strata aren't correlated with each other
+				shift := sampleHash(seed, 0, 0, i)
+				mx := blueNoiseTileCoord(x + int(shift%blueNoiseTileSize))
+				my := blueNoiseTileCoord(y + int((shift>>32)%blueNoiseTileSize))
+				u := (float64(blueNoise.Pix[my*blueNoise.Stride+mx]) + 0.5) / 256
+
+				start, end := i*len(taps)/samples, (i+1)*len(taps)/samples
+				tap = taps[start+int(u*float64(end-start))]
+				scale = float32(end - start)
+
+			default:
+				tap = taps[sampleHash(seed, x, y, i)%uint64(len(taps))]
+				scale = 1
+			}
+
+			sx, sy := x+tap.X-k.radius, y+tap.Y-k.radius
+			if !(image.Point{X: sx, Y: sy}.In(img.Rect)) {
+				continue
+			}
+
+			weight := k.weights[tap.Y*k.sideLength+tap.X]
+			totalWeight.R += weight.R * scale
+			totalWeight.G += weight.G * scale
+			totalWeight.B += weight.B * scale
+			totalWeight.A += weight.A * scale
+
+			c, a := srgb.ColorFromNRGBA(img.NRGBAAt(sx, sy))
+			sum.R += c.R * weight.R * scale
+			sum.G += c.G * weight.G * scale
+			sum.B += c.B * weight.B * scale
+			sum.A += a * weight.A * scale
+		}
+
+		if totalWeight.R > 0 {
+			sum.R /= totalWeight.R
+		}
+		if totalWeight.G > 0 {
+			sum.G /= totalWeight.G
+		}
+		if totalWeight.B > 0 {
+			sum.B /= totalWeight.B
+		}
+		if totalWeight.A > 0 {
+			sum.A /= totalWeight.A
+		}
+
+		return k.averageToNRGBA(x, y, sum)
+	}
+}
+
+// nonZeroTaps returns the positions of all taps with a non-zero weight in any
+// channel, in serpentine order so that consecutive taps are spatially close.
+func (k *Kernel) nonZeroTaps() []kernelTap {
+	taps := make([]kernelTap, 0, len(k.weights))
+
+	for s := 0; s < k.sideLength; s++ {
+		for i := 0; i < k.sideLength; i++ {
+			t := i
+			if s%2 == 1 {
+				t = k.sideLength - 1 - i
+			}
+
+			if w := k.weights[s*k.sideLength+t]; w != (kernelWeight{}) {
+				taps = append(taps, kernelTap{X: t, Y: s})
+			}
+		}
+	}
+
+	return taps
+}
+
+// tapMagnitudeCDF returns the cumulative distribution of weight magnitudes
+// over the given taps, normalised to end at 1.
+func (k *Kernel) tapMagnitudeCDF(taps []kernelTap) []float32 {
+	cdf := make([]float32, len(taps))
+
+	total := float32(0)
+	for i, tap := range taps {
+		total += k.weights[tap.Y*k.sideLength+tap.X].magnitude()
+		cdf[i] = total
+	}
+	for i := range cdf {
+		cdf[i] /= total
+	}
+
+	return cdf
+}
+
+// blueNoiseTileCoord returns the coordinate within the tiled blue-noise mask
+// corresponding to an image coordinate.
+func blueNoiseTileCoord(v int) int {
+	v %= blueNoiseTileSize
+	if v < 0 {
+		v += blueNoiseTileSize
+	}
+	return v
+}
+
+type kernelTap struct {
+	X int
+	Y int
+}
+
+// sampleHash returns a well mixed pseudo-random value for the i-th sample of
+// the pixel at x, y, allowing pixels to be sampled independently and in
+// parallel while remaining reproducible.
+func sampleHash(seed int64, x, y, i int) uint64 {
+	h := uint64(seed)
+	for _, v := range [3]int{x, y, i} {
+		h ^= uint64(int64(v))
+		h += 0x9e3779b97f4a7c15
+		h = (h ^ (h >> 30)) * 0xbf58476d1ce4e5b9
+		h = (h ^ (h >> 27)) * 0x94d049bb133111eb
+		h ^= h >> 31
+	}
+	return h
+}
+
+// sampleUniform returns a pseudo-random value in the range [0, 1) for the i-th
+// sample of the pixel at x, y.
+func sampleUniform(seed int64, x, y, i int) float32 {
+	return float32(sampleHash(seed, x, y, i)>>40) / (1 << 24)
+}
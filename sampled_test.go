@@ -0,0 +1,163 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+)
+
+func TestKernelApplyAvgSampled(t *testing.T) {
+	img := randomImage(32, 32)
+
+	kernel := KernelWithRadius(3)
+	for i := 0; i < kernel.SideLength(); i++ {
+		for j := 0; j < kernel.SideLength(); j++ {
+			kernel.SetWeightUniform(j, i, float32(1+(i+j)%3))
+		}
+	}
+	kernel.SetWeightUniform(0, 0, 0)
+
+	t.Run("is exact when stratified sampling covers every tap", func(t *testing.T) {
+		expectedImg := kernel.ApplyAvg(img, runtime.NumCPU())
+
+		for _, pattern := range []SamplePattern{SampleStratified, SampleBlueNoise} {
+			result := kernel.ApplyAvgSampled(img, 1000, pattern, 1, runtime.NumCPU())
+
+			for i := img.Rect.Min.Y; i < img.Rect.Max.Y; i++ {
+				for j := img.Rect.Min.X; j < img.Rect.Max.X; j++ {
+					expected, actual := expectedImg.NRGBAAt(j, i), result.NRGBAAt(j, i)
+
+					if absDiff(expected.R, actual.R) > 1 || absDiff(expected.G, actual.G) > 1 || absDiff(expected.B, actual.B) > 1 || absDiff(expected.A, actual.A) > 1 {
+						t.Fatalf("Expected pixel at %d,%d with pattern %d to be %+v but was %+v", j, i, pattern, expected, actual)
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("is reproducible for a given seed", func(t *testing.T) {
+		for _, pattern := range []SamplePattern{SampleRandom, SampleStratified, SampleImportance, SampleBlueNoise} {
+			first := kernel.ApplyAvgSampled(img, 8, pattern, 42, runtime.NumCPU())
+			second := kernel.ApplyAvgSampled(img, 8, pattern, 42, 1)
+
+			for i := range first.Pix {
+				if first.Pix[i] != second.Pix[i] {
+					t.Fatalf("Expected results with pattern %d to match but differ at byte %d", pattern, i)
+				}
+			}
+		}
+	})
+
+	t.Run("never selects zero-weight taps", func(t *testing.T) {
+		for _, tap := range kernel.nonZeroTaps() {
+			if tap.X == 0 && tap.Y == 0 {
+				t.Errorf("Expected zero-weight tap to be excluded")
+			}
+		}
+		if expected, actual := kernel.SideLength()*kernel.SideLength()-1, len(kernel.nonZeroTaps()); expected != actual {
+			t.Errorf("Expected %d taps but found %d", expected, actual)
+		}
+	})
+
+	t.Run("stratified sampling has less error than random sampling", func(t *testing.T) {
+		expectedImg := kernel.ApplyAvg(img, runtime.NumCPU())
+
+		totalError := func(pattern SamplePattern) int {
+			result := kernel.ApplyAvgSampled(img, 12, pattern, 7, runtime.NumCPU())
+
+			sum := 0
+			for i := range result.Pix {
+				sum += int(absDiff(expectedImg.Pix[i], result.Pix[i]))
+			}
+			return sum
+		}
+
+		if random, stratified := totalError(SampleRandom), totalError(SampleStratified); stratified >= random {
+			t.Errorf("Expected stratified error %d to be less than random error %d", stratified, random)
+		}
+	})
+
+	t.Run("blue-noise sampling has less low-frequency error than stratified sampling", func(t *testing.T) {
+		// Sampling a horizontal kernel over a horizontal gradient gives an
+		// error proportional to how far the chosen taps are from the centre
+		horizontal := KernelWithRadius(3)
+		for j := 0; j < horizontal.SideLength(); j++ {
+			horizontal.SetWeightUniform(j, 3, 1)
+		}
+
+		gradient := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+		for i := 0; i < 64; i++ {
+			for j := 0; j < 64; j++ {
+				gradient.SetNRGBA(j, i, color.NRGBA{R: uint8(j * 4), G: uint8(j * 4), B: uint8(j * 4), A: 255})
+			}
+		}
+
+		expectedImg := horizontal.ApplyAvg(gradient, runtime.NumCPU())
+
+		// Sum the error over 4x4 blocks, where high-frequency error cancels
+		// out but low-frequency error accumulates
+		blockError := func(pattern SamplePattern) int {
+			result := horizontal.ApplyAvgSampled(gradient, 2, pattern, 5, runtime.NumCPU())
+
+			total := 0
+			for by := 0; by < 64; by += 4 {
+				for bx := 0; bx < 64; bx += 4 {
+					for c := 0; c < 3; c++ {
+						sum := 0
+						for i := by; i < by+4; i++ {
+							for j := bx; j < bx+4; j++ {
+								offset := result.PixOffset(j, i) + c
+								sum += int(result.Pix[offset]) - int(expectedImg.Pix[offset])
+							}
+						}
+						if sum < 0 {
+							sum = -sum
+						}
+						total += sum
+					}
+				}
+			}
+			return total
+		}
+
+		if stratified, blueNoise := blockError(SampleStratified), blockError(SampleBlueNoise); blueNoise >= stratified {
+			t.Errorf("Expected blue-noise block error %d to be less than stratified block error %d", blueNoise, stratified)
+		}
+	})
+
+	t.Run("importance sampling has less error than random sampling for rim-weighted kernels", func(t *testing.T) {
+		aperture := KernelWithRadius(6)
+		for i := 0; i < aperture.SideLength(); i++ {
+			for j := 0; j < aperture.SideLength(); j++ {
+				dx, dy := j-6, i-6
+				distSquared := dx*dx + dy*dy
+
+				switch {
+				case distSquared > 36:
+					aperture.SetWeightUniform(j, i, 0)
+				case distSquared > 25:
+					aperture.SetWeightUniform(j, i, 20)
+				default:
+					aperture.SetWeightUniform(j, i, 0.05)
+				}
+			}
+		}
+
+		expectedImg := aperture.ApplyAvg(img, runtime.NumCPU())
+
+		totalError := func(pattern SamplePattern) int {
+			result := aperture.ApplyAvgSampled(img, 16, pattern, 3, runtime.NumCPU())
+
+			sum := 0
+			for i := range result.Pix {
+				sum += int(absDiff(expectedImg.Pix[i], result.Pix[i]))
+			}
+			return sum
+		}
+
+		if random, importance := totalError(SampleRandom), totalError(SampleImportance); importance >= random {
+			t.Errorf("Expected importance sampling error %d to be less than random error %d", importance, random)
+		}
+	})
+}
@@ -0,0 +1,47 @@
+package convolver
+
+import (
+	"context"
+	"github.com/mandykoh/prism/srgb"
+	"image"
+)
+
+// PixelSink receives completed tiles of a result as they are produced, so
+// that results can be sent directly to their destination, such as a GPU
+// texture, a video encoder or a network stream, without first being assembled
+// into an image. WriteTile may be called concurrently for different tiles, in
+// no particular order. The tile is reused once WriteTile returns, so its
+// pixels must be copied if they are needed afterwards.
+type PixelSink interface {
+	// WriteTile receives the linear, non-premultiplied values of a completed
+	// tile, whose bounds give its position within the result.
+	WriteTile(tile *FloatImage) error
+}
+
+// EncodedSinkFunc receives completed tiles of a result sRGB encoded in 8 bits,
+// and can be used as a PixelSink for destinations which expect encoded
+// pixels. The same reuse rules as for PixelSink apply.
+type EncodedSinkFunc func(tile *image.NRGBA) error
+
+// WriteTile encodes a tile and passes it to f.
+func (f EncodedSinkFunc) WriteTile(tile *FloatImage) error {
+	encoded := image.NewNRGBA(tile.Rect)
+
+	for i := tile.Rect.Min.Y; i < tile.Rect.Max.Y; i++ {
+		for j := tile.Rect.Min.X; j < tile.Rect.Max.X; j++ {
+			r, g, b, a := tile.RGBAAt(j, i)
+			encoded.SetNRGBA(j, i, srgb.ColorFromLinear(r, g, b).ToNRGBA(a))
+		}
+	}
+
+	return f(encoded)
+}
+
+// ApplyAvgSourceToSink applies the kernel to the pixels of a source as
+// ApplyAvgSource does, passing each completed tile of the result to a sink
+// instead of assembling the result as an image. If reading the source or
+// writing to the sink fails, the remaining workers stop after their current
+// tiles and the first error is returned.
+func (k *Kernel) ApplyAvgSourceToSink(ctx context.Context, src PixelSource, sink PixelSink, tileSize int, parallelism int) error {
+	return k.applyAvgTiles(ctx, src, tileSize, parallelism, sink.WriteTile)
+}
@@ -0,0 +1,93 @@
+package convolver
+
+import (
+	"context"
+	"errors"
+	"image"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+type collectingSink struct {
+	mutex  sync.Mutex
+	result *FloatImage
+	tiles  int
+}
+
+func (s *collectingSink) WriteTile(tile *FloatImage) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	copyFloatRegion(s.result, tile, tile.Rect)
+	s.tiles++
+	return nil
+}
+
+func TestApplyAvgSourceToSink(t *testing.T) {
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{1, 2, 1, 2, 4, 2, 1, 2, 1})
+
+	img := FloatImageFromImage(randomImage(30, 20), runtime.NumCPU())
+
+	t.Run("delivers every tile of the result in linear form", func(t *testing.T) {
+		sink := &collectingSink{result: NewFloatImage(img.Rect)}
+
+		if err := kernel.ApplyAvgSourceToSink(context.Background(), img, sink, 8, runtime.NumCPU()); err != nil {
+			t.Fatalf("Expected no error but got %v", err)
+		}
+
+		if expected, actual := 12, sink.tiles; expected != actual {
+			t.Errorf("Expected %d tiles but got %d", expected, actual)
+		}
+
+		expected := kernel.ApplyAvgFloat(img, runtime.NumCPU())
+		for i := range expected.Pix {
+			if diff := expected.Pix[i] - sink.result.Pix[i]; diff > 1e-6 || diff < -1e-6 {
+				t.Fatalf("Expected value at offset %d to be %v but was %v", i, expected.Pix[i], sink.result.Pix[i])
+			}
+		}
+	})
+
+	t.Run("delivers tiles in encoded form", func(t *testing.T) {
+		result := image.NewNRGBA(img.Rect)
+		mutex := sync.Mutex{}
+
+		sink := EncodedSinkFunc(func(tile *image.NRGBA) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			for i := tile.Rect.Min.Y; i < tile.Rect.Max.Y; i++ {
+				for j := tile.Rect.Min.X; j < tile.Rect.Max.X; j++ {
+					result.SetNRGBA(j, i, tile.NRGBAAt(j, i))
+				}
+			}
+			return nil
+		})
+
+		if err := kernel.ApplyAvgSourceToSink(context.Background(), img, sink, 8, runtime.NumCPU()); err != nil {
+			t.Fatalf("Expected no error but got %v", err)
+		}
+
+		expected := kernel.ApplyAvgFloat(img, runtime.NumCPU())
+		for i := img.Rect.Min.Y; i < img.Rect.Max.Y; i++ {
+			for j := img.Rect.Min.X; j < img.Rect.Max.X; j++ {
+				if e, a := expected.At(j, i), result.NRGBAAt(j, i); e != a {
+					t.Fatalf("Expected pixel %d,%d to be %+v but was %+v", j, i, e, a)
+				}
+			}
+		}
+	})
+
+	t.Run("returns the first write error", func(t *testing.T) {
+		failure := errors.New("stream closed")
+
+		sink := EncodedSinkFunc(func(tile *image.NRGBA) error {
+			return failure
+		})
+
+		if err := kernel.ApplyAvgSourceToSink(context.Background(), img, sink, 8, runtime.NumCPU()); err != failure {
+			t.Errorf("Expected error %v but got %v", failure, err)
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package convolver
+
+import (
+	"fmt"
+	"image"
+	"math/bits"
+)
+
+const maxInt = int(^uint(0) >> 1)
+
+// bufferLength returns the number of elements needed to hold the given number
+// of elements per pixel for every pixel in the bounds. It panics if the length
+// cannot be represented by an int on this platform, rather than allowing the
+// calculation to silently overflow (as it can on 32-bit platforms for very
+// large panoramas). Images that large can still be streamed with ApplyRows
+// from a source other than an *image.NRGBA.
+func bufferLength(bounds image.Rectangle, elementsPerPixel int) int {
+	length, ok := checkedProduct(uint64(maxInt), bounds.Dx(), bounds.Dy(), elementsPerPixel)
+	if !ok {
+		panic(fmt.Sprintf("buffer for %dx%d pixels with %d elements per pixel is too large", bounds.Dx(), bounds.Dy(), elementsPerPixel))
+	}
+	return length
+}
+
+// checkedProduct multiplies non-negative factors, reporting false if any is
+// negative or the product exceeds the limit.
+func checkedProduct(limit uint64, factors ...int) (int, bool) {
+	product := uint64(1)
+
+	for _, f := range factors {
+		if f < 0 {
+			return 0, false
+		}
+
+		hi, lo := bits.Mul64(product, uint64(f))
+		if hi != 0 || lo > limit {
+			return 0, false
+		}
+		product = lo
+	}
+
+	return int(product), true
+}
@@ -0,0 +1,55 @@
+package convolver
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestBufferLength(t *testing.T) {
+
+	t.Run("returns the element count for representable sizes", func(t *testing.T) {
+		if expected, actual := 3*5*4, bufferLength(image.Rect(10, 10, 13, 15), 4); expected != actual {
+			t.Errorf("Expected length to be %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("panics when the length overflows", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic but none occurred")
+			}
+		}()
+
+		bufferLength(image.Rect(0, 0, maxInt/2, maxInt/2), 4)
+	})
+}
+
+func TestCheckedProduct(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Limit    uint64
+		Factors  []int
+		Expected int
+		OK       bool
+	}{
+		{Name: "small image", Limit: math.MaxInt32, Factors: []int{640, 480, 4}, Expected: 1228800, OK: true},
+		{Name: "exactly at 32-bit limit", Limit: math.MaxInt32, Factors: []int{math.MaxInt32, 1}, Expected: math.MaxInt32, OK: true},
+		{Name: "stride just within 32-bit limit", Limit: math.MaxInt32, Factors: []int{math.MaxInt32 / 4, 1, 4}, Expected: math.MaxInt32 / 4 * 4, OK: true},
+		{Name: "stride just beyond 32-bit limit", Limit: math.MaxInt32, Factors: []int{math.MaxInt32/4 + 1, 1, 4}, OK: false},
+		{Name: "huge panorama on 32-bit", Limit: math.MaxInt32, Factors: []int{40000, 20000, 4}, OK: false},
+		{Name: "huge stride overflowing 64 bits", Limit: math.MaxUint64, Factors: []int{maxInt, maxInt, 16}, OK: false},
+		{Name: "negative factor", Limit: math.MaxInt64, Factors: []int{-1, 5}, OK: false},
+		{Name: "zero factor", Limit: math.MaxInt32, Factors: []int{0, maxInt}, Expected: 0, OK: true},
+	}
+
+	for _, c := range cases {
+		product, ok := checkedProduct(c.Limit, c.Factors...)
+
+		if ok != c.OK {
+			t.Errorf("Expected %s to report ok %v but was %v", c.Name, c.OK, ok)
+		} else if ok && product != c.Expected {
+			t.Errorf("Expected %s product to be %d but was %d", c.Name, c.Expected, product)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package convolver
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// SetSoftClip sets the output of averaging operations such as Avg to roll off
+// smoothly towards 0 and 1 instead of being hard clamped to that range. This
+// reduces the harsh flat patches and halos left by aggressive sharpening or
+// edge kernels in near-saturated regions.
+//
+// Linear colour values between 0 and 1 are left unchanged, so flat black and
+// white areas survive exactly. Overshoots beyond 1 are folded back into the
+// band within knee below 1 along an exponential curve, and undershoots below
+// 0 into the band within knee above 0, so that larger overshoots come out
+// progressively further from the end of the range and remain distinguishable
+// rather than merging into a flat patch. Alpha is always clamped. A knee of
+// zero, the default, means hard clamping; otherwise knee must be at most 0.5.
+func (k *Kernel) SetSoftClip(knee float32) {
+	if knee < 0 || knee > 0.5 {
+		panic(fmt.Sprintf("soft clip knee must be between 0 and 0.5 but was %v", knee))
+	}
+
+	k.softClipKnee = knee
+}
+
+// SoftClip returns the knee set with SetSoftClip, or zero if results are hard
+// clamped.
+func (k *Kernel) SoftClip() float32 {
+	return k.softClipKnee
+}
+
+// averageToNRGBA converts the result of an averaging operation at x, y to a
+// colour, applying the kernel's soft clipping if any.
+func (k *Kernel) averageToNRGBA(x, y int, sum kernelWeight) color.NRGBA {
+	if k.diagnostics != nil {
+		k.diagnostics.check(x, y, sum)
+	}
+
+	if k.softClipKnee > 0 {
+		sum.R = softClip(sum.R, k.softClipKnee)
+		sum.G = softClip(sum.G, k.softClipKnee)
+		sum.B = softClip(sum.B, k.softClipKnee)
+	}
+
+	return sum.toNRGBA()
+}
+
+// softClip maps v into the range 0 to 1, leaving values within it unchanged
+// and folding those beyond 1 into the band from 1 - knee to 1, and those below
+// 0 into the band from 0 to knee. The curve is continuous at 0 and 1.
+func softClip(v, knee float32) float32 {
+	switch {
+	case v > 1:
+		return 1 - knee*(1-float32(math.Exp(float64((1-v)/knee))))
+	case v < 0:
+		return knee * (1 - float32(math.Exp(float64(v/knee))))
+	}
+	return v
+}
@@ -0,0 +1,122 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestSoftClip(t *testing.T) {
+	sharpen := func() Kernel {
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightsUniform([]float32{
+			0, -1, 0,
+			-1, 5, -1,
+			0, -1, 0,
+		})
+		return kernel
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 3))
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			img.SetNRGBA(j, i, color.NRGBA{R: 100, G: 100, B: 100, A: 255})
+		}
+	}
+
+	t.Run("leaves values away from the ends of the range unchanged", func(t *testing.T) {
+		kernel := sharpen()
+		expected := kernel.ApplyAvg(img, 1)
+
+		kernel.SetSoftClip(0.1)
+		actual := kernel.ApplyAvg(img, 1)
+
+		for i := range expected.Pix {
+			if expected.Pix[i] != actual.Pix[i] {
+				t.Fatalf("Expected soft clipped result to match at offset %d", i)
+			}
+		}
+	})
+
+	t.Run("rolls off overshoots instead of clamping them", func(t *testing.T) {
+		bright := image.NewNRGBA(img.Rect)
+		copy(bright.Pix, img.Pix)
+		bright.SetNRGBA(1, 1, color.NRGBA{R: 155, G: 160, B: 165, A: 255})
+
+		kernel := sharpen()
+		clamped := kernel.ApplyAvg(bright, 1).NRGBAAt(1, 1)
+
+		kernel.SetSoftClip(0.2)
+		soft := kernel.ApplyAvg(bright, 1).NRGBAAt(1, 1)
+
+		if clamped.R != 255 || clamped.G != 255 || clamped.B != 255 {
+			t.Fatalf("Expected hard clamped centre to be white but was %v", clamped)
+		}
+		if !(soft.B < soft.G && soft.G < soft.R && soft.R < 255) {
+			t.Errorf("Expected larger overshoots to fold further below white but was %v", soft)
+		}
+		if soft.B < 231 {
+			t.Errorf("Expected overshoots to stay within the knee below white but was %v", soft)
+		}
+		if soft.A != 255 {
+			t.Errorf("Expected alpha to be %d but was %d", 255, soft.A)
+		}
+	})
+
+	t.Run("leaves flat black and white images unchanged", func(t *testing.T) {
+		for _, c := range []color.NRGBA{{A: 255}, {R: 255, G: 255, B: 255, A: 255}} {
+			flat := image.NewNRGBA(image.Rect(0, 0, 5, 5))
+			for i := 0; i < len(flat.Pix); i += 4 {
+				flat.Pix[i], flat.Pix[i+1], flat.Pix[i+2], flat.Pix[i+3] = c.R, c.G, c.B, c.A
+			}
+
+			kernel := sharpen()
+			kernel.SetSoftClip(0.5)
+			result := kernel.ApplyAvg(flat, 1)
+
+			for i := range flat.Pix {
+				if flat.Pix[i] != result.Pix[i] {
+					t.Fatalf("Expected flat %v image to be unchanged but offset %d was %d", c, i, result.Pix[i])
+				}
+			}
+		}
+	})
+
+	t.Run("is continuous and folds values into the knee", func(t *testing.T) {
+		knee := float32(0.25)
+
+		for _, v := range []float32{0, 0.1, 0.5, 0.9, 1} {
+			if c := softClip(v, knee); c != v {
+				t.Errorf("Expected %v to be unchanged but was %v", v, c)
+			}
+		}
+
+		for _, v := range []float32{0, 1} {
+			if below, above := softClip(v-1e-4, knee), softClip(v+1e-4, knee); abs32(below-above) > 3e-4 {
+				t.Errorf("Expected curve to be continuous at %v but went from %v to %v", v, below, above)
+			}
+		}
+
+		for _, v := range []float32{1.5, 10} {
+			if c := softClip(v, knee); c < 1-knee || c > 1 {
+				t.Errorf("Expected %v to be folded between %v and 1 but was %v", v, 1-knee, c)
+			}
+		}
+		for _, v := range []float32{-5, -0.5} {
+			if c := softClip(v, knee); c < 0 || c > knee {
+				t.Errorf("Expected %v to be folded between 0 and %v but was %v", v, knee, c)
+			}
+		}
+	})
+
+	t.Run("panics for a knee out of range", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic")
+			}
+		}()
+
+		kernel := sharpen()
+		kernel.SetSoftClip(0.6)
+	})
+}
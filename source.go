@@ -0,0 +1,144 @@
+package convolver
+
+import (
+	"context"
+	"fmt"
+	"github.com/mandykoh/go-parallel"
+	"image"
+	"sync"
+	"sync/atomic"
+)
+
+// PixelSource provides linear pixel values on demand, so that images held in
+// custom storage, such as tile caches, memory mapped rasters or network tile
+// servers, can be filtered without first being assembled into an image.
+// ReadRegion may be called concurrently for different regions.
+type PixelSource interface {
+	// Bounds returns the bounds of the whole image.
+	Bounds() image.Rectangle
+
+	// ReadRegion fills dst with the linear, non-premultiplied values of the
+	// pixels within dst.Rect, which always lies within Bounds.
+	ReadRegion(dst *FloatImage) error
+}
+
+// ReadRegion copies the pixels within dst.Rect from the image, allowing a
+// FloatImage to be used as a PixelSource.
+func (f *FloatImage) ReadRegion(dst *FloatImage) error {
+	if !dst.Rect.In(f.Rect) {
+		return fmt.Errorf("region %v is outside the image bounds %v", dst.Rect, f.Rect)
+	}
+
+	copyFloatRegion(dst, f, dst.Rect)
+	return nil
+}
+
+// ApplyAvgSource applies the kernel to the pixels of a source in the same way
+// as ApplyAvgFloat, reading the source in square tiles of the given size, each
+// with an apron wide enough to cover the kernel. If reading any region fails,
+// the remaining workers stop after their current tiles and the first error is
+// returned; workers likewise stop if the context is cancelled. Edge modes
+// other than EdgeClip are applied as each apron is read, so that the source is
+// never padded as a whole.
+func (k *Kernel) ApplyAvgSource(ctx context.Context, src PixelSource, tileSize int, parallelism int) (*FloatImage, error) {
+	result := NewFloatImage(src.Bounds())
+
+	err := k.applyAvgTiles(ctx, src, tileSize, parallelism, func(tile *FloatImage) error {
+		copyFloatRegion(result, tile, tile.Rect)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// applyAvgTiles computes the kernel's average over a source one tile at a
+// time, passing each completed tile to emit. Tiles passed to emit are reused
+// once it returns. The first error from reading the source or from emit stops
+// the remaining workers and is returned.
+func (k *Kernel) applyAvgTiles(ctx context.Context, src PixelSource, tileSize int, parallelism int, emit func(tile *FloatImage) error) error {
+	bounds := src.Bounds()
+
+	clipBounds := bounds
+	if k.edgeMode != EdgeClip && k.radius > 0 && !bounds.Empty() {
+		padded := k.paddedSource(src)
+		src, clipBounds = padded, padded.Bounds()
+	}
+
+	tiles := tilesCovering(bounds, tileSize)
+	nextTile := int64(-1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var firstErr error
+	var errOnce sync.Once
+
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		var apronBuffer, tileBuffer []float32
+
+		for ctx.Err() == nil {
+			index := int(atomic.AddInt64(&nextTile, 1))
+			if index >= len(tiles) {
+				return
+			}
+
+			tile := tiles[index]
+			apronRect := tile.Inset(-k.radius).Intersect(clipBounds)
+
+			apron := floatImageInBuffer(&apronBuffer, apronRect)
+			if err := src.ReadRegion(apron); err != nil {
+				fail(err)
+				return
+			}
+
+			output := floatImageInBuffer(&tileBuffer, tile)
+			for i := tile.Min.Y; i < tile.Max.Y; i++ {
+				for j := tile.Min.X; j < tile.Max.X; j++ {
+					r, g, b, a := k.avgFloat(apron, clipBounds, j, i)
+					output.SetRGBA(j, i, r, g, b, a)
+				}
+			}
+
+			if err := emit(output); err != nil {
+				fail(err)
+				return
+			}
+		}
+	})
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	return ctx.Err()
+}
+
+// copyFloatRegion copies the pixels within r, which must lie within the bounds
+// of both images, from src to dst.
+func copyFloatRegion(dst, src *FloatImage, r image.Rectangle) {
+	width := r.Dx() * 4
+	for i := r.Min.Y; i < r.Max.Y; i++ {
+		copy(dst.Pix[dst.offset(r.Min.X, i):][:width], src.Pix[src.offset(r.Min.X, i):][:width])
+	}
+}
+
+// floatImageInBuffer returns a FloatImage with the given bounds whose pixels
+// are stored in buffer, growing it if necessary.
+func floatImageInBuffer(buffer *[]float32, r image.Rectangle) *FloatImage {
+	size := bufferLength(r, 4)
+	if cap(*buffer) < size {
+		*buffer = make([]float32, size)
+	}
+
+	return &FloatImage{Pix: (*buffer)[:size], Stride: r.Dx() * 4, Rect: r}
+}
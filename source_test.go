@@ -0,0 +1,105 @@
+package convolver
+
+import (
+	"context"
+	"errors"
+	"image"
+	"runtime"
+	"sync/atomic"
+	"testing"
+)
+
+type failingSource struct {
+	bounds image.Rectangle
+	reads  int64
+}
+
+func (s *failingSource) Bounds() image.Rectangle {
+	return s.bounds
+}
+
+func (s *failingSource) ReadRegion(dst *FloatImage) error {
+	if atomic.AddInt64(&s.reads, 1) == 3 {
+		return errors.New("tile server unavailable")
+	}
+	return nil
+}
+
+func TestApplyAvgSource(t *testing.T) {
+	kernel := KernelWithRadius(2)
+	weights := make([]float32, 25)
+	for i := range weights {
+		weights[i] = float32(i%3 + 1)
+	}
+	kernel.SetWeightsUniform(weights)
+
+	t.Run("matches ApplyAvgFloat on the whole image", func(t *testing.T) {
+		img := FloatImageFromImage(randomImage(37, 29).SubImage(image.Rect(3, 2, 37, 29)), runtime.NumCPU())
+
+		result, err := kernel.ApplyAvgSource(context.Background(), img, 8, runtime.NumCPU())
+		if err != nil {
+			t.Fatalf("Expected no error but got %v", err)
+		}
+
+		expected := kernel.ApplyAvgFloat(img, runtime.NumCPU())
+
+		if expected.Rect != result.Rect {
+			t.Fatalf("Expected bounds to be %v but were %v", expected.Rect, result.Rect)
+		}
+		for i := range expected.Pix {
+			if diff := expected.Pix[i] - result.Pix[i]; diff > 1e-6 || diff < -1e-6 {
+				t.Fatalf("Expected value at offset %d to be %v but was %v", i, expected.Pix[i], result.Pix[i])
+			}
+		}
+	})
+
+	t.Run("returns the first read error", func(t *testing.T) {
+		src := &failingSource{bounds: image.Rect(0, 0, 64, 64)}
+
+		result, err := kernel.ApplyAvgSource(context.Background(), src, 4, 2)
+
+		if err == nil || err.Error() != "tile server unavailable" {
+			t.Errorf("Expected read error but got %v", err)
+		}
+		if result != nil {
+			t.Errorf("Expected no result")
+		}
+		if reads := atomic.LoadInt64(&src.reads); reads >= 256 {
+			t.Errorf("Expected reading to stop early but %d regions were read", reads)
+		}
+	})
+
+	t.Run("stops when the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := kernel.ApplyAvgSource(ctx, NewFloatImage(image.Rect(0, 0, 8, 8)), 4, 2)
+
+		if err != context.Canceled {
+			t.Errorf("Expected cancellation error but got %v", err)
+		}
+	})
+}
+
+func TestFloatImageReadRegion(t *testing.T) {
+	img := NewFloatImage(image.Rect(0, 0, 4, 4))
+	img.SetRGBA(2, 1, 0.25, 0.5, 0.75, 1)
+
+	t.Run("copies the requested region", func(t *testing.T) {
+		dst := NewFloatImage(image.Rect(1, 1, 3, 2))
+
+		if err := img.ReadRegion(dst); err != nil {
+			t.Fatalf("Expected no error but got %v", err)
+		}
+
+		if r, g, b, a := dst.RGBAAt(2, 1); r != 0.25 || g != 0.5 || b != 0.75 || a != 1 {
+			t.Errorf("Expected copied pixel to be 0.25, 0.5, 0.75, 1 but was %v, %v, %v, %v", r, g, b, a)
+		}
+	})
+
+	t.Run("fails for regions outside the image", func(t *testing.T) {
+		if err := img.ReadRegion(NewFloatImage(image.Rect(3, 3, 5, 5))); err == nil {
+			t.Errorf("Expected an error")
+		}
+	})
+}
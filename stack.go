@@ -0,0 +1,156 @@
+package convolver
+
+import (
+	"fmt"
+	"github.com/mandykoh/go-parallel"
+	"image"
+	"math"
+	"sort"
+)
+
+// StackMethod selects how the values of a pixel are combined across frames.
+type StackMethod int
+
+const (
+	// StackMean averages frames, which reduces noise the most but lets
+	// outliers such as satellite trails or hot pixels through.
+	StackMean StackMethod = iota
+
+	// StackMedian takes the middle value across frames, which rejects
+	// outliers at the cost of leaving somewhat more noise than the mean.
+	StackMedian
+)
+
+// Stack combines a sequence of aligned frames of the same size into a single
+// image, reducing noise such as for astrophotography or low light shots.
+// Values are combined per pixel and per channel in linear light with
+// premultiplied alpha, using the given method. Frames are matched by position
+// relative to their bounds, and the result has the bounds of the first frame.
+func Stack(frames []image.Image, method StackMethod, parallelism int) *image.NRGBA {
+	var combine func(values []float32) float32
+
+	switch method {
+	case StackMean:
+		combine = stackMean
+	case StackMedian:
+		combine = stackMedian
+	default:
+		panic(fmt.Sprintf("unknown stack method %d", int(method)))
+	}
+
+	return stackFrames(frames, combine, parallelism)
+}
+
+// StackSigmaClipped combines frames as Stack does, averaging the values of
+// each pixel after repeatedly discarding those further than kappa standard
+// deviations from the mean of the values remaining. This rejects outliers
+// while keeping most of the noise reduction of a plain mean; a kappa of 2 to 3
+// is typical.
+func StackSigmaClipped(frames []image.Image, kappa float64, parallelism int) *image.NRGBA {
+	if kappa <= 0 {
+		panic(fmt.Sprintf("kappa must be positive but was %v", kappa))
+	}
+
+	return stackFrames(frames, func(values []float32) float32 {
+		return stackSigmaClippedMean(values, kappa)
+	}, parallelism)
+}
+
+func stackFrames(frames []image.Image, combine func(values []float32) float32, parallelism int) *image.NRGBA {
+	if len(frames) == 0 {
+		panic("at least one frame is required for stacking")
+	}
+
+	inputs := make([]*FloatImage, len(frames))
+	for f, frame := range frames {
+		inputs[f] = FloatImageFromImage(frame, parallelism)
+
+		if inputs[f].Rect.Size() != inputs[0].Rect.Size() {
+			panic(fmt.Sprintf("frame %d of size %v differs from the first frame of size %v", f, inputs[f].Rect.Size(), inputs[0].Rect.Size()))
+		}
+	}
+
+	bounds := inputs[0].Rect
+	result := NewFloatImage(bounds)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		channels := [4][]float32{}
+		for c := range channels {
+			channels[c] = make([]float32, len(inputs))
+		}
+		values := make([]float32, len(inputs))
+
+		for i := workerNum; i < bounds.Dy(); i += workerCount {
+			for j := 0; j < bounds.Dx(); j++ {
+				for f, input := range inputs {
+					r, g, b, a := input.RGBAAt(input.Rect.Min.X+j, input.Rect.Min.Y+i)
+					channels[0][f], channels[1][f], channels[2][f], channels[3][f] = r*a, g*a, b*a, a
+				}
+
+				var combined [4]float32
+				for c := range channels {
+					copy(values, channels[c])
+					combined[c] = combine(values)
+				}
+
+				a := combined[3]
+				if a > 0 {
+					combined[0] /= a
+					combined[1] /= a
+					combined[2] /= a
+				}
+
+				result.SetRGBA(bounds.Min.X+j, bounds.Min.Y+i, combined[0], combined[1], combined[2], a)
+			}
+		}
+	})
+
+	return nrgbaFromFloatImage(result, parallelism)
+}
+
+func stackMean(values []float32) float32 {
+	var sum float64
+	for _, v := range values {
+		sum += float64(v)
+	}
+	return float32(sum / float64(len(values)))
+}
+
+// stackMedian returns the median of values, reordering them in the process.
+func stackMedian(values []float32) float32 {
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
+
+// stackSigmaClippedMean returns the mean of values after iteratively rejecting
+// outliers, reordering values in the process.
+func stackSigmaClippedMean(values []float32, kappa float64) float32 {
+	for len(values) > 2 {
+		mean := float64(stackMean(values))
+
+		var variance float64
+		for _, v := range values {
+			variance += (float64(v) - mean) * (float64(v) - mean)
+		}
+		limit := kappa * math.Sqrt(variance/float64(len(values)))
+
+		kept := values[:0]
+		for _, v := range values {
+			if math.Abs(float64(v)-mean) <= limit {
+				kept = append(kept, v)
+			}
+		}
+
+		if len(kept) == len(values) || len(kept) == 0 {
+			break
+		}
+		values = kept
+	}
+
+	return stackMean(values)
+}
@@ -0,0 +1,73 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+)
+
+func TestStack(t *testing.T) {
+	frames := make([]image.Image, 10)
+	for f := range frames {
+		frame := image.NewNRGBA(image.Rect(f, 0, f+2, 1))
+		v := uint8(126 + f%5)
+		frame.SetNRGBA(f, 0, color.NRGBA{R: v, G: v, B: v, A: 255})
+		frame.SetNRGBA(f+1, 0, color.NRGBA{R: v, G: v, B: v, A: 255})
+		frames[f] = frame
+	}
+
+	// A hot pixel in one frame
+	frames[3].(*image.NRGBA).SetNRGBA(4, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+
+	t.Run("averages frames", func(t *testing.T) {
+		result := Stack(frames, StackMean, runtime.NumCPU())
+
+		if expected, actual := frames[0].Bounds(), result.Rect; expected != actual {
+			t.Fatalf("Expected bounds to be %v but were %v", expected, actual)
+		}
+		if expected, actual := uint8(128), result.NRGBAAt(0, 0).R; absDiff(expected, actual) > 1 {
+			t.Errorf("Expected clean pixel to be %d but was %d", expected, actual)
+		}
+		if r := result.NRGBAAt(1, 0).R; r < 140 {
+			t.Errorf("Expected outlier to raise the mean but was %d", r)
+		}
+	})
+
+	t.Run("takes the median of frames", func(t *testing.T) {
+		result := Stack(frames, StackMedian, runtime.NumCPU())
+
+		if expected, actual := uint8(128), result.NRGBAAt(1, 0).R; absDiff(expected, actual) > 1 {
+			t.Errorf("Expected outlier to be rejected giving %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("rejects outliers with sigma clipping", func(t *testing.T) {
+		result := StackSigmaClipped(frames, 2, runtime.NumCPU())
+
+		if expected, actual := uint8(128), result.NRGBAAt(0, 0).R; absDiff(expected, actual) > 1 {
+			t.Errorf("Expected clean pixel to be %d but was %d", expected, actual)
+		}
+		if expected, actual := uint8(128), result.NRGBAAt(1, 0).R; absDiff(expected, actual) > 1 {
+			t.Errorf("Expected outlier to be rejected giving %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("panics for frames of different sizes", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic")
+			}
+		}()
+		Stack([]image.Image{frames[0], image.NewNRGBA(image.Rect(0, 0, 3, 1))}, StackMean, runtime.NumCPU())
+	})
+
+	t.Run("panics without frames", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic")
+			}
+		}()
+		Stack(nil, StackMedian, runtime.NumCPU())
+	})
+}
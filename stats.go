@@ -0,0 +1,198 @@
+package convolver
+
+import (
+	"fmt"
+	"github.com/mandykoh/prism"
+	"image"
+	"math"
+)
+
+// Regions divides an image into regions over which statistics are collected.
+type Regions interface {
+	// Count returns the number of regions.
+	Count() int
+
+	// RegionAt returns the index of the region containing the pixel at x, y,
+	// or -1 if it belongs to none.
+	RegionAt(x, y int) int
+}
+
+// RegionStats summarises the encoded channel values of the pixels in a
+// region. Channels are ordered R, G, B and A.
+type RegionStats struct {
+	Pixels   int
+	Mean     [4]float64
+	Variance [4]float64
+	Min      [4]uint8
+	Max      [4]uint8
+}
+
+// ApplyWithStats applies an operation such as k.Avg to an image, honouring
+// the kernel's edge and power modes, and also returns statistics of the
+// result for each of the given regions. Statistics are gathered as the result
+// is computed, so quality control doesn't need another traversal of the
+// image. Statistics of regions without any pixels are zero.
+func (k *Kernel) ApplyWithStats(img image.Image, op OpFunc, regions Regions, parallelism int) (*image.NRGBA, []RegionStats) {
+	parallelism = k.powerMode.workers(parallelism)
+
+	input := prism.ConvertImageToNRGBA(img, parallelism)
+	bounds := input.Rect
+
+	if k.edgeMode != EdgeClip && k.radius > 0 && !bounds.Empty() {
+		input = padNRGBA(input, k.radius, k.edgeMode, k.edgeFill(), parallelism)
+	}
+
+	result := image.NewNRGBA(bounds)
+	accumulators := make([][]regionAccumulator, parallelism)
+
+	runWorkers(currentMetrics(), parallelism, func(workerNum, workerCount int) {
+		throttle := k.newThrottle()
+		acc := make([]regionAccumulator, regions.Count())
+
+		for i := bounds.Min.Y + workerNum; i < bounds.Max.Y; i += workerCount {
+			for j := bounds.Min.X; j < bounds.Max.X; j++ {
+				c := op(input, j, i)
+				result.SetNRGBA(j, i, c)
+
+				if r := regions.RegionAt(j, i); r >= 0 {
+					acc[r].add([4]uint8{c.R, c.G, c.B, c.A})
+				}
+			}
+			throttle.yield()
+		}
+
+		accumulators[workerNum] = acc
+	})
+
+	stats := make([]RegionStats, regions.Count())
+	for r := range stats {
+		total := regionAccumulator{}
+		for _, acc := range accumulators {
+			if acc != nil {
+				total.merge(&acc[r])
+			}
+		}
+		stats[r] = total.stats()
+	}
+
+	return result, stats
+}
+
+// GridRegions returns regions formed by dividing bounds into square cells of
+// the given size, numbered in row-major order. Cells along the right and
+// bottom edges are truncated to fit.
+func GridRegions(bounds image.Rectangle, cellSize int) Regions {
+	if cellSize < 1 {
+		panic(fmt.Sprintf("cell size must be positive but was %d", cellSize))
+	}
+
+	return gridRegions{
+		bounds:   bounds,
+		cellSize: cellSize,
+		columns:  (bounds.Dx() + cellSize - 1) / cellSize,
+		rows:     (bounds.Dy() + cellSize - 1) / cellSize,
+	}
+}
+
+// MaskRegions returns 256 regions indexed by the values of a mask, such as
+// the labels of segmented areas. Pixels outside the mask belong to no region.
+func MaskRegions(mask *image.Gray) Regions {
+	return maskRegions{mask: mask}
+}
+
+type gridRegions struct {
+	bounds   image.Rectangle
+	cellSize int
+	columns  int
+	rows     int
+}
+
+func (g gridRegions) Count() int {
+	return g.columns * g.rows
+}
+
+func (g gridRegions) RegionAt(x, y int) int {
+	if !(image.Point{X: x, Y: y}.In(g.bounds)) {
+		return -1
+	}
+	return (y-g.bounds.Min.Y)/g.cellSize*g.columns + (x-g.bounds.Min.X)/g.cellSize
+}
+
+type maskRegions struct {
+	mask *image.Gray
+}
+
+func (m maskRegions) Count() int {
+	return 256
+}
+
+func (m maskRegions) RegionAt(x, y int) int {
+	if !(image.Point{X: x, Y: y}.In(m.mask.Rect)) {
+		return -1
+	}
+	return int(m.mask.Pix[m.mask.PixOffset(x, y)])
+}
+
+// regionAccumulator gathers the sums needed for the statistics of a region.
+type regionAccumulator struct {
+	pixels int
+	sum    [4]float64
+	sumSq  [4]float64
+	min    [4]uint8
+	max    [4]uint8
+}
+
+func (a *regionAccumulator) add(c [4]uint8) {
+	if a.pixels == 0 {
+		a.min, a.max = c, c
+	}
+	a.pixels++
+
+	for i, v := range c {
+		a.sum[i] += float64(v)
+		a.sumSq[i] += float64(v) * float64(v)
+		if v < a.min[i] {
+			a.min[i] = v
+		}
+		if v > a.max[i] {
+			a.max[i] = v
+		}
+	}
+}
+
+func (a *regionAccumulator) merge(other *regionAccumulator) {
+	if other.pixels == 0 {
+		return
+	}
+	if a.pixels == 0 {
+		*a = *other
+		return
+	}
+
+	a.pixels += other.pixels
+	for i := range a.sum {
+		a.sum[i] += other.sum[i]
+		a.sumSq[i] += other.sumSq[i]
+		if other.min[i] < a.min[i] {
+			a.min[i] = other.min[i]
+		}
+		if other.max[i] > a.max[i] {
+			a.max[i] = other.max[i]
+		}
+	}
+}
+
+func (a *regionAccumulator) stats() RegionStats {
+	s := RegionStats{Pixels: a.pixels, Min: a.min, Max: a.max}
+	if a.pixels == 0 {
+		return s
+	}
+
+	n := float64(a.pixels)
+	for i := range a.sum {
+		s.Mean[i] = a.sum[i] / n
+		s.Variance[i] = math.Max(0, a.sumSq[i]/n-s.Mean[i]*s.Mean[i])
+	}
+
+	return s
+}
@@ -0,0 +1,113 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestApplyWithStats(t *testing.T) {
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{1, 2, 1, 2, 4, 2, 1, 2, 1})
+
+	img := randomImage(23, 17)
+
+	expectedStats := func(result *image.NRGBA, rect image.Rectangle) RegionStats {
+		acc := regionAccumulator{}
+		for i := rect.Min.Y; i < rect.Max.Y; i++ {
+			for j := rect.Min.X; j < rect.Max.X; j++ {
+				c := result.NRGBAAt(j, i)
+				acc.add([4]uint8{c.R, c.G, c.B, c.A})
+			}
+		}
+		return acc.stats()
+	}
+
+	similar := func(a, b RegionStats) bool {
+		for c := 0; c < 4; c++ {
+			if math.Abs(a.Mean[c]-b.Mean[c]) > 1e-9 || math.Abs(a.Variance[c]-b.Variance[c]) > 1e-6 {
+				return false
+			}
+		}
+		return a.Pixels == b.Pixels && a.Min == b.Min && a.Max == b.Max
+	}
+
+	t.Run("produces the same result as applying directly", func(t *testing.T) {
+		expected := kernel.ApplyAvg(img, 3)
+		actual, _ := kernel.ApplyWithStats(img, kernel.Avg, GridRegions(img.Rect, 8), 3)
+
+		for i := range expected.Pix {
+			if expected.Pix[i] != actual.Pix[i] {
+				t.Fatalf("Expected result to match at offset %d", i)
+			}
+		}
+	})
+
+	t.Run("gathers statistics for each grid cell", func(t *testing.T) {
+		result, stats := kernel.ApplyWithStats(img, kernel.Avg, GridRegions(img.Rect, 8), 3)
+
+		if expected, actual := 9, len(stats); expected != actual {
+			t.Fatalf("Expected %d cells but was %d", expected, actual)
+		}
+
+		cells := []struct {
+			index int
+			rect  image.Rectangle
+		}{
+			{0, image.Rect(0, 0, 8, 8)},
+			{2, image.Rect(16, 0, 23, 8)},
+			{4, image.Rect(8, 8, 16, 16)},
+			{8, image.Rect(16, 16, 23, 17)},
+		}
+
+		for _, cell := range cells {
+			if expected, actual := expectedStats(result, cell.rect), stats[cell.index]; !similar(expected, actual) {
+				t.Errorf("Expected statistics of cell %d to be %+v but were %+v", cell.index, expected, actual)
+			}
+		}
+	})
+
+	t.Run("gathers statistics for each mask value", func(t *testing.T) {
+		mask := image.NewGray(image.Rect(0, 0, 10, 10))
+		for i := 0; i < 10; i++ {
+			for j := 5; j < 10; j++ {
+				mask.SetGray(j, i, color.Gray{Y: 7})
+			}
+		}
+
+		result, stats := kernel.ApplyWithStats(img, kernel.Avg, MaskRegions(mask), 2)
+
+		if expected, actual := expectedStats(result, image.Rect(0, 0, 5, 10)), stats[0]; !similar(expected, actual) {
+			t.Errorf("Expected statistics of region 0 to be %+v but were %+v", expected, actual)
+		}
+		if expected, actual := expectedStats(result, image.Rect(5, 0, 10, 10)), stats[7]; !similar(expected, actual) {
+			t.Errorf("Expected statistics of region 7 to be %+v but were %+v", expected, actual)
+		}
+		if expected, actual := (RegionStats{}), stats[1]; expected != actual {
+			t.Errorf("Expected statistics of an empty region to be %+v but were %+v", expected, actual)
+		}
+	})
+
+	t.Run("computes mean and variance", func(t *testing.T) {
+		acc := regionAccumulator{}
+		acc.add([4]uint8{10, 0, 0, 255})
+		acc.add([4]uint8{20, 0, 0, 255})
+		acc.add([4]uint8{30, 0, 0, 255})
+
+		stats := acc.stats()
+
+		if expected, actual := 20.0, stats.Mean[0]; expected != actual {
+			t.Errorf("Expected mean to be %v but was %v", expected, actual)
+		}
+		if expected, actual := 200.0/3, stats.Variance[0]; math.Abs(expected-actual) > 1e-9 {
+			t.Errorf("Expected variance to be %v but was %v", expected, actual)
+		}
+		if expected, actual := uint8(10), stats.Min[0]; expected != actual {
+			t.Errorf("Expected minimum to be %d but was %d", expected, actual)
+		}
+		if expected, actual := uint8(30), stats.Max[0]; expected != actual {
+			t.Errorf("Expected maximum to be %d but was %d", expected, actual)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package convolver
+
+import (
+	"math"
+)
+
+// GaussianDerivativeKernel returns a kernel whose weights are the first
+// derivative of a Gaussian with standard deviation sigma, taken along the
+// direction theta (in radians, measured from the positive x axis towards the
+// positive y axis).
+//
+// Because the first derivative is steerable, the kernel for any theta is
+// exactly cos(theta) times the kernel for 0 plus sin(theta) times the kernel
+// for π/2.
+func GaussianDerivativeKernel(sigma, theta float64) Kernel {
+	cos, sin := math.Cos(theta), math.Sin(theta)
+
+	return gaussianKernelFromFunc(sigma, func(x, y, g float64) float64 {
+		u := x*cos + y*sin
+		return -u / (sigma * sigma) * g
+	})
+}
+
+// GaussianSecondDerivativeKernel returns a kernel whose weights are the second
+// derivative of a Gaussian with standard deviation sigma, taken along the
+// direction theta (in radians, measured from the positive x axis towards the
+// positive y axis).
+//
+// The second derivative is steered by three basis kernels: the kernel for any
+// theta is cos²(theta)·Gxx + 2·cos(theta)·sin(theta)·Gxy + sin²(theta)·Gyy.
+func GaussianSecondDerivativeKernel(sigma, theta float64) Kernel {
+	cos, sin := math.Cos(theta), math.Sin(theta)
+
+	return gaussianKernelFromFunc(sigma, func(x, y, g float64) float64 {
+		u := x*cos + y*sin
+		return (u*u - sigma*sigma) / (sigma * sigma * sigma * sigma) * g
+	})
+}
+
+func gaussianKernelFromFunc(sigma float64, f func(x, y, g float64) float64) Kernel {
+	if sigma <= 0 {
+		panic("sigma must be positive")
+	}
+
+	kernel := KernelWithRadius(gaussianRadius(sigma))
+	twoSigmaSquared := 2 * sigma * sigma
+
+	for i := 0; i < kernel.sideLength; i++ {
+		y := float64(i - kernel.radius)
+
+		for j := 0; j < kernel.sideLength; j++ {
+			x := float64(j - kernel.radius)
+			g := math.Exp(-(x*x+y*y)/twoSigmaSquared) / (math.Pi * twoSigmaSquared)
+
+			kernel.SetWeightUniform(j, i, float32(f(x, y, g)))
+		}
+	}
+
+	return kernel
+}
+
+func gaussianRadius(sigma float64) int {
+	return int(math.Ceil(sigma * 3))
+}
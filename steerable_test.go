@@ -0,0 +1,101 @@
+package convolver
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGaussianDerivativeKernel(t *testing.T) {
+
+	t.Run("has a radius of three standard deviations", func(t *testing.T) {
+		kernel := GaussianDerivativeKernel(1.5, 0)
+
+		if expected, actual := 5, kernel.radius; expected != actual {
+			t.Errorf("Expected radius to be %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("is antisymmetric along the derivative direction", func(t *testing.T) {
+		kernel := GaussianDerivativeKernel(1, 0)
+
+		for i := 0; i < kernel.sideLength; i++ {
+			for j := 0; j < kernel.sideLength; j++ {
+				w := kernel.weights[i*kernel.sideLength+j]
+				mirrored := kernel.weights[i*kernel.sideLength+(kernel.sideLength-1-j)]
+
+				if w.R != -mirrored.R {
+					t.Fatalf("Expected weight at %d,%d to be the negation of its mirror but was %v vs %v", j, i, w.R, mirrored.R)
+				}
+			}
+		}
+	})
+
+	t.Run("is steerable from the horizontal and vertical basis kernels", func(t *testing.T) {
+		gx := GaussianDerivativeKernel(2, 0)
+		gy := GaussianDerivativeKernel(2, math.Pi/2)
+
+		for _, theta := range []float64{0.3, 1.2, 2.5, 4} {
+			kernel := GaussianDerivativeKernel(2, theta)
+			cos, sin := float32(math.Cos(theta)), float32(math.Sin(theta))
+
+			for i, w := range kernel.weights {
+				steered := cos*gx.weights[i].R + sin*gy.weights[i].R
+				if math.Abs(float64(steered-w.R)) > 1e-6 {
+					t.Fatalf("Expected steered weight %d at angle %v to be %v but was %v", i, theta, steered, w.R)
+				}
+			}
+		}
+	})
+
+	t.Run("panics with non-positive sigma", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic but none occurred")
+			}
+		}()
+
+		GaussianDerivativeKernel(0, 0)
+	})
+}
+
+func TestGaussianSecondDerivativeKernel(t *testing.T) {
+
+	t.Run("is symmetric", func(t *testing.T) {
+		kernel := GaussianSecondDerivativeKernel(1, 0.7)
+
+		last := len(kernel.weights) - 1
+		for i, w := range kernel.weights {
+			if mirrored := kernel.weights[last-i]; math.Abs(float64(w.R-mirrored.R)) > 1e-9 {
+				t.Fatalf("Expected weight %d to match its point reflection but was %v vs %v", i, w.R, mirrored.R)
+			}
+		}
+	})
+
+	t.Run("has a negative centre weight", func(t *testing.T) {
+		kernel := GaussianSecondDerivativeKernel(1, 0)
+
+		if centre := kernel.weights[len(kernel.weights)/2]; centre.R >= 0 {
+			t.Errorf("Expected centre weight to be negative but was %v", centre.R)
+		}
+	})
+
+	t.Run("is steerable from three basis kernels", func(t *testing.T) {
+		gxx := GaussianSecondDerivativeKernel(2, 0)
+		gyy := GaussianSecondDerivativeKernel(2, math.Pi/2)
+		gdiag := GaussianSecondDerivativeKernel(2, math.Pi/4)
+
+		for _, theta := range []float64{0.3, 1.2, 2.5, 4} {
+			kernel := GaussianSecondDerivativeKernel(2, theta)
+			cos, sin := float32(math.Cos(theta)), float32(math.Sin(theta))
+
+			for i, w := range kernel.weights {
+				gxy := gdiag.weights[i].R - (gxx.weights[i].R+gyy.weights[i].R)/2
+				steered := cos*cos*gxx.weights[i].R + 2*cos*sin*gxy + sin*sin*gyy.weights[i].R
+
+				if math.Abs(float64(steered-w.R)) > 1e-6 {
+					t.Fatalf("Expected steered weight %d at angle %v to be %v but was %v", i, theta, steered, w.R)
+				}
+			}
+		}
+	})
+}
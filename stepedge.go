@@ -0,0 +1,102 @@
+package convolver
+
+import (
+	"image"
+	"math"
+)
+
+// StepEdgeAnalysis describes how a kernel responds to a sharp edge between
+// black and white, as a measure of the halos produced by sharpening kernels
+// and the softness produced by blurring ones.
+type StepEdgeAnalysis struct {
+	// Overshoot is how far the response rises above the bright side of the
+	// edge, as a percentage of the edge's height.
+	Overshoot float64
+
+	// Undershoot is how far the response falls below the dark side of the
+	// edge, as a percentage of the edge's height.
+	Undershoot float64
+
+	// EdgeWidth is the distance in pixels over which the response rises from
+	// 10% to 90% of the edge's height.
+	EdgeWidth float64
+}
+
+// AnalyseStepEdge applies the kernel with Avg to step edges running across the
+// x and y axes and measures the responses. The edges are between linear
+// values of 0 and 1 and the results are not clipped, so overshoot beyond the
+// range of 8-bit images is reported in full. Only the red channel weights are
+// considered.
+func (k *Kernel) AnalyseStepEdge(parallelism int) (acrossX, acrossY StepEdgeAnalysis) {
+	return k.analyseStepEdge(true, parallelism), k.analyseStepEdge(false, parallelism)
+}
+
+func (k *Kernel) analyseStepEdge(acrossX bool, parallelism int) StepEdgeAnalysis {
+	length := k.radius*6 + 8
+	bounds := image.Rect(0, 0, length, k.sideLength)
+	if !acrossX {
+		bounds = image.Rect(0, 0, k.sideLength, length)
+	}
+
+	img := NewFloatImage(bounds)
+	for i := bounds.Min.Y; i < bounds.Max.Y; i++ {
+		for j := bounds.Min.X; j < bounds.Max.X; j++ {
+			pos := j
+			if !acrossX {
+				pos = i
+			}
+
+			v := float32(0)
+			if pos >= length/2 {
+				v = 1
+			}
+			img.SetRGBA(j, i, v, v, v, 1)
+		}
+	}
+
+	result := k.ApplyAvgFloat(img, parallelism)
+
+	// Only the part of the profile clear of the image's ends is measured, so
+	// that clipping of the kernel doesn't affect the results.
+	profile := make([]float64, 0, length-k.radius*2)
+	for pos := k.radius; pos < length-k.radius; pos++ {
+		x, y := pos, k.radius
+		if !acrossX {
+			x, y = k.radius, pos
+		}
+		r, _, _, _ := result.RGBAAt(x, y)
+		profile = append(profile, float64(r))
+	}
+
+	analysis := StepEdgeAnalysis{}
+
+	min, max := 0.0, 1.0
+	for _, v := range profile {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	analysis.Overshoot = (max - 1) * 100
+	analysis.Undershoot = -min * 100
+
+	analysis.EdgeWidth = profileCrossing(profile, 0.9) - profileCrossing(profile, 0.1)
+
+	return analysis
+}
+
+// profileCrossing returns the interpolated position at which a profile first
+// reaches the given level.
+func profileCrossing(profile []float64, level float64) float64 {
+	for i, v := range profile {
+		if v < level {
+			continue
+		}
+		if i == 0 {
+			return 0
+		}
+
+		prev := profile[i-1]
+		return float64(i-1) + (level-prev)/(v-prev)
+	}
+
+	return float64(len(profile) - 1)
+}
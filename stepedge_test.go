@@ -0,0 +1,64 @@
+package convolver
+
+import (
+	"math"
+	"runtime"
+	"testing"
+)
+
+func TestAnalyseStepEdge(t *testing.T) {
+
+	t.Run("reports no change for an identity kernel", func(t *testing.T) {
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightUniform(1, 1, 1)
+
+		acrossX, acrossY := kernel.AnalyseStepEdge(runtime.NumCPU())
+
+		for _, analysis := range []StepEdgeAnalysis{acrossX, acrossY} {
+			if analysis.Overshoot != 0 || analysis.Undershoot != 0 {
+				t.Errorf("Expected no overshoot or undershoot but got %+v", analysis)
+			}
+			if expected, actual := 0.8, analysis.EdgeWidth; math.Abs(expected-actual) > 1e-6 {
+				t.Errorf("Expected edge width to be %v but was %v", expected, actual)
+			}
+		}
+	})
+
+	t.Run("measures the width of a box blurred edge", func(t *testing.T) {
+		kernel := KernelWithRadius(2)
+		for i := 0; i < 5; i++ {
+			kernel.SetWeightUniform(i, 2, 1)
+		}
+
+		acrossX, acrossY := kernel.AnalyseStepEdge(runtime.NumCPU())
+
+		// A 5 tap box filter ramps over 5 pixels in steps of 0.2
+		if expected, actual := 4.0, acrossX.EdgeWidth; math.Abs(expected-actual) > 1e-6 {
+			t.Errorf("Expected edge width across x to be %v but was %v", expected, actual)
+		}
+		if expected, actual := 0.8, acrossY.EdgeWidth; math.Abs(expected-actual) > 1e-6 {
+			t.Errorf("Expected edge width across y to be %v but was %v", expected, actual)
+		}
+		if acrossX.Overshoot != 0 || acrossX.Undershoot != 0 {
+			t.Errorf("Expected no overshoot or undershoot but got %+v", acrossX)
+		}
+	})
+
+	t.Run("reports halos of a sharpening kernel", func(t *testing.T) {
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightsUniform([]float32{
+			0, -1, 0,
+			-1, 5, -1,
+			0, -1, 0,
+		})
+
+		acrossX, _ := kernel.AnalyseStepEdge(runtime.NumCPU())
+
+		if expected, actual := 100.0, acrossX.Overshoot; math.Abs(expected-actual) > 1e-4 {
+			t.Errorf("Expected overshoot to be %v%% but was %v%%", expected, actual)
+		}
+		if expected, actual := 100.0, acrossX.Undershoot; math.Abs(expected-actual) > 1e-4 {
+			t.Errorf("Expected undershoot to be %v%% but was %v%%", expected, actual)
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package convolver
+
+import (
+	"fmt"
+	"github.com/mandykoh/go-parallel"
+	"github.com/mandykoh/prism"
+	"github.com/mandykoh/prism/srgb"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Cartoon returns a stage which gives an image a flat, cartoon-like look. The
+// image is smoothed with a Gaussian blur of standard deviation sigma, each
+// encoded colour channel is posterised to the given number of levels, and
+// black outlines are drawn wherever the Sobel gradient magnitude of the
+// original image's linear luminance exceeds edgeThreshold. Alpha is left
+// unchanged.
+func Cartoon(sigma float64, levels int, edgeThreshold float32) Stage {
+	if levels < 2 {
+		panic(fmt.Sprintf("at least 2 levels are required but %d requested", levels))
+	}
+
+	blur := GaussianKernel(sigma)
+	steps := float64(levels - 1)
+
+	posterise := pointwiseStage(func(r, g, b float32) (float32, float32, float32) {
+		quantise := func(v float32) float32 {
+			encoded := float64(srgb.To16Bit(v)) / 65535
+			return srgb.From16Bit(uint16(math.Round(encoded*steps) / steps * 65535))
+		}
+		return quantise(r), quantise(g), quantise(b)
+	})
+
+	return func(img image.Image, parallelism int) *image.NRGBA {
+		input := prism.ConvertImageToNRGBA(img, parallelism)
+		edges := Sobel(input, parallelism)
+		result := posterise(blur.ApplyAvg(input, parallelism), parallelism)
+
+		parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+			for i := result.Rect.Min.Y + workerNum; i < result.Rect.Max.Y; i += workerCount {
+				for j := result.Rect.Min.X; j < result.Rect.Max.X; j++ {
+					if edges.ValueAt(j, i) > edgeThreshold {
+						result.SetNRGBA(j, i, color.NRGBA{A: result.NRGBAAt(j, i).A})
+					}
+				}
+			}
+		})
+
+		return result
+	}
+}
+
+// PencilSketch returns a stage which renders an image as a greyscale pencil
+// drawing. The image's luminance is divided by the inverse of a Gaussian
+// blurred copy of its negative (the "colour dodge" blend), which leaves flat
+// areas white and traces detail with strokes whose width depends on sigma.
+// Edges found with the Sobel operator are then darkened in proportion to
+// edgeStrength, and finally the Levels stage is applied with the given linear
+// black point to deepen the strokes. Alpha is left unchanged.
+func PencilSketch(sigma float64, edgeStrength, blackPoint float32) Stage {
+	blur := GaussianKernel(sigma)
+
+	sketch := func(img image.Image, parallelism int) *image.NRGBA {
+		input := prism.ConvertImageToNRGBA(img, parallelism)
+		luminance := LuminancePlane(input, parallelism)
+		edges := Sobel(input, parallelism)
+
+		inverted := NewPlane(input.Rect)
+		for i, v := range luminance.Pix {
+			inverted.Pix[i] = 1 - float32(srgb.To16Bit(v))/65535
+		}
+		blurred := blur.convolvePlane(inverted, parallelism)
+
+		result := image.NewNRGBA(input.Rect)
+
+		parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+			for i := input.Rect.Min.Y + workerNum; i < input.Rect.Max.Y; i += workerCount {
+				for j := input.Rect.Min.X; j < input.Rect.Max.X; j++ {
+					offset := luminance.offset(j, i)
+					grey := 1 - inverted.Pix[offset]
+
+					dodged := float32(1)
+					if denominator := 1 - blurred.Pix[offset]; denominator > grey {
+						dodged = grey / denominator
+					}
+
+					edge := edges.Pix[offset] * edgeStrength
+					if edge > 1 {
+						edge = 1
+					}
+
+					v := srgb.From16Bit(uint16(dodged * (1 - edge) * 65535))
+					result.SetNRGBA(j, i, srgb.ColorFromLinear(v, v, v).ToNRGBA(float32(input.NRGBAAt(j, i).A)/255))
+				}
+			}
+		})
+
+		return result
+	}
+
+	return NewPipeline(sketch, Levels(blackPoint, 1)).Apply
+}
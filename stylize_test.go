@@ -0,0 +1,76 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+)
+
+func TestCartoon(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for i := 0; i < 16; i++ {
+		for j := 0; j < 16; j++ {
+			v := uint8(60)
+			if j >= 8 {
+				v = 200
+			}
+			img.SetNRGBA(j, i, color.NRGBA{R: v, G: v / 2, B: 30, A: 255})
+		}
+	}
+
+	result := Cartoon(0.5, 4, 0.5)(img, runtime.NumCPU())
+
+	t.Run("posterises colours", func(t *testing.T) {
+		for _, v := range []uint8{result.NRGBAAt(2, 8).R, result.NRGBAAt(13, 8).R} {
+			if v != 0 && v != 85 && v != 170 && v != 255 {
+				t.Errorf("Expected a posterised value but got %d", v)
+			}
+		}
+	})
+
+	t.Run("outlines edges", func(t *testing.T) {
+		if expected, actual := (color.NRGBA{A: 255}), result.NRGBAAt(8, 8); expected != actual {
+			t.Errorf("Expected edge to be outlined in black but was %+v", actual)
+		}
+	})
+}
+
+func TestPencilSketch(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 24, 24))
+	for i := 0; i < 24; i++ {
+		for j := 0; j < 24; j++ {
+			v := uint8(180)
+			if j >= 12 {
+				v = 40
+			}
+			img.SetNRGBA(j, i, color.NRGBA{R: v, G: v, B: v, A: 200})
+		}
+	}
+
+	result := PencilSketch(2, 0.5, 0.05)(img, runtime.NumCPU())
+
+	t.Run("leaves flat areas white", func(t *testing.T) {
+		for _, x := range []int{2, 21} {
+			if actual := result.NRGBAAt(x, 12).R; actual < 250 {
+				t.Errorf("Expected flat area at x=%d to be white but was %d", x, actual)
+			}
+		}
+	})
+
+	t.Run("draws strokes along edges", func(t *testing.T) {
+		if actual := result.NRGBAAt(12, 12).R; actual > 200 {
+			t.Errorf("Expected edge to be drawn darker but was %d", actual)
+		}
+	})
+
+	t.Run("is greyscale and preserves alpha", func(t *testing.T) {
+		c := result.NRGBAAt(12, 12)
+		if c.R != c.G || c.G != c.B {
+			t.Errorf("Expected a grey colour but was %+v", c)
+		}
+		if expected, actual := uint8(200), c.A; expected != actual {
+			t.Errorf("Expected alpha to be %d but was %d", expected, actual)
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package convolver
+
+import (
+	"github.com/mandykoh/prism/srgb"
+	"image"
+	"image/color"
+)
+
+// ApplySum applies the operation returned by Sum to an image.
+func (k *Kernel) ApplySum(img image.Image, bias float32, parallelism int) *image.NRGBA {
+	return k.applyImage(img, k.Sum(bias), parallelism)
+}
+
+// Sum returns an operation which computes the plain weighted sum of linear
+// colour values under the kernel, without dividing by the total weight as Avg
+// does, and adds bias to the colour channels. Kernels defined as convolution
+// sums, such as embossing and gradient operators, can then be applied as
+// written. Results are clamped to 0–1 when encoded, so signed responses need
+// a bias to be visible: a bias of 0.5 maps a zero response to half intensity
+// in linear light, which is encoded as 188 rather than the perceptual
+// mid-grey of 128, for which a bias of about 0.216 is needed instead.
+//
+// Alpha is not summed, since kernels whose weights total zero, such as a
+// Laplacian, would otherwise make the result transparent. It is instead the
+// average of the alpha under the kernel, weighted by the magnitudes of the
+// alpha weights, so opaque images stay opaque. Since the colour channels are
+// not renormalised, taps clipped at the edges of the image are simply missing
+// from the sum, and an edge mode such as EdgeExtend may be preferable.
+func (k *Kernel) Sum(bias float32) OpFunc {
+	// Summing with the magnitudes of the alpha weights gives both the alpha
+	// average's numerator and its total weight, and keeps point symmetric
+	// kernels point symmetric.
+	magnitudes := *k
+	magnitudes.weights = make([]kernelWeight, len(k.weights))
+	for i, w := range k.weights {
+		w.A = abs32(w.A)
+		magnitudes.weights[i] = w
+	}
+	m := &magnitudes
+
+	return func(img *image.NRGBA, x, y int) color.NRGBA {
+		clip := m.clipToBounds(img.Rect, x, y)
+
+		var sum kernelWeight
+		var alphaWeight float32
+
+		if clip == (kernelClip{}) && m.pointSymmetric() {
+			var totalWeight kernelWeight
+			sum, totalWeight = m.foldedSum(img, x, y)
+			alphaWeight = totalWeight.A
+		} else {
+			for s := clip.Top; s < m.sideLength-clip.Bottom; s++ {
+				for t := clip.Left; t < m.sideLength-clip.Right; t++ {
+					weight := m.weights[s*m.sideLength+t]
+					alphaWeight += weight.A
+
+					c, a := srgb.ColorFromNRGBA(img.NRGBAAt(x+t-m.radius, y+s-m.radius))
+					sum.R += c.R * weight.R
+					sum.G += c.G * weight.G
+					sum.B += c.B * weight.B
+					sum.A += a * weight.A
+				}
+			}
+		}
+
+		sum.R += bias
+		sum.G += bias
+		sum.B += bias
+
+		if alphaWeight > 0 {
+			sum.A /= alphaWeight
+		}
+
+		return m.averageToNRGBA(x, y, sum)
+	}
+}
@@ -0,0 +1,113 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestSum(t *testing.T) {
+
+	t.Run("matches Avg for weights totalling one away from the edges", func(t *testing.T) {
+		img := randomImage(20, 16)
+
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightsUniform([]float32{
+			1.0 / 16, 2.0 / 16, 1.0 / 16,
+			2.0 / 16, 4.0 / 16, 2.0 / 16,
+			1.0 / 16, 2.0 / 16, 1.0 / 16,
+		})
+
+		expected := kernel.ApplyAvg(img, 2)
+		actual := kernel.ApplySum(img, 0, 2)
+
+		for i := 1; i < 15; i++ {
+			for j := 1; j < 19; j++ {
+				if e, a := expected.NRGBAAt(j, i), actual.NRGBAAt(j, i); e != a {
+					t.Fatalf("Expected sum at %d,%d to be %v but was %v", j, i, e, a)
+				}
+			}
+		}
+	})
+
+	t.Run("doesn't normalise by the total weight", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 3, 3))
+		for i := range img.Pix {
+			img.Pix[i] = 255
+		}
+
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightsRGBA([][4]float32{
+			{}, {}, {},
+			{}, {0.5, 0.5, 0.5, 1}, {},
+			{}, {}, {},
+		})
+
+		result := kernel.ApplySum(img, 0, 2)
+
+		if expected, actual := (color.NRGBA{R: 188, G: 188, B: 188, A: 255}), result.NRGBAAt(1, 1); expected != actual {
+			t.Errorf("Expected half weighted white to be %v but was %v", expected, actual)
+		}
+	})
+
+	t.Run("adds the bias to signed responses", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 5, 5))
+		for i := 0; i < 5; i++ {
+			for j := 0; j < 5; j++ {
+				img.SetNRGBA(j, i, color.NRGBA{R: 100, G: 100, B: 100, A: 255})
+			}
+		}
+
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightsRGBA([][4]float32{
+			{}, {}, {},
+			{-1, -1, -1, 0}, {0, 0, 0, 1}, {1, 1, 1, 0},
+			{}, {}, {},
+		})
+
+		result := kernel.ApplySum(img, 0.5, 2)
+
+		if expected, actual := (color.NRGBA{R: 188, G: 188, B: 188, A: 255}), result.NRGBAAt(2, 2); expected != actual {
+			t.Errorf("Expected flat response to be half intensity %v but was %v", expected, actual)
+		}
+	})
+
+	t.Run("is available as a registered op", func(t *testing.T) {
+		img := randomImage(8, 8)
+		for i := 3; i < len(img.Pix); i += 4 {
+			img.Pix[i] = 255
+		}
+
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightsUniform([]float32{0, -1, 0, -1, 4, -1, 0, -1, 0})
+
+		expected := kernel.ApplySum(img, 0, 2)
+		actual := kernel.ApplyOp(img, "sum", 2)
+
+		for i := range expected.Pix {
+			if expected.Pix[i] != actual.Pix[i] {
+				t.Fatalf("Expected registered sum to match ApplySum at offset %d", i)
+			}
+		}
+		for i := 3; i < len(actual.Pix); i += 4 {
+			if actual.Pix[i] != 255 {
+				t.Fatalf("Expected Laplacian of an opaque image to be opaque but alpha at offset %d was %d", i, actual.Pix[i])
+			}
+		}
+	})
+
+	t.Run("averages alpha rather than summing it", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 3, 3))
+		for i := 0; i < len(img.Pix); i += 4 {
+			img.Pix[i+3] = 100
+		}
+		img.Pix[img.PixOffset(1, 1)+3] = 200
+
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightsUniform([]float32{0, 1, 0, 1, 2, 1, 0, 1, 0})
+
+		if expected, actual := uint8(133), kernel.ApplySum(img, 0, 2).NRGBAAt(1, 1).A; expected != actual {
+			t.Errorf("Expected alpha to be %d but was %d", expected, actual)
+		}
+	})
+}
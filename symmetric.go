@@ -0,0 +1,131 @@
+package convolver
+
+import (
+	"github.com/mandykoh/prism/srgb"
+	"image"
+)
+
+// setWeight sets the weight at index i, keeping track of whether the kernel
+// is point symmetric.
+func (k *Kernel) setWeight(i int, w kernelWeight) {
+	mirror := len(k.weights) - 1 - i
+
+	if i != mirror && k.weights[i] != k.weights[mirror] {
+		k.asymmetricPairs--
+	}
+
+	k.weights[i] = w
+
+	if i != mirror && k.weights[i] != k.weights[mirror] {
+		k.asymmetricPairs++
+	}
+}
+
+// pointSymmetric returns whether every weight equals the weight mirrored
+// through the kernel's centre, as is the case for Gaussian and other kernels
+// symmetric under both horizontal and vertical reflection.
+func (k *Kernel) pointSymmetric() bool {
+	return k.asymmetricPairs == 0
+}
+
+// foldedSum returns the weighted sum of linear values around x, y and the
+// total weight, for a point symmetric kernel lying entirely within the image.
+// Each pair of mirrored samples is added before being multiplied by their
+// shared weight, halving the number of multiplications.
+func (k *Kernel) foldedSum(img *image.NRGBA, x, y int) (sum, totalWeight kernelWeight) {
+	base := img.PixOffset(x, y)
+	centre := len(k.weights) / 2
+
+	for s := 0; s <= k.radius; s++ {
+		for t := 0; t < k.sideLength; t++ {
+			i := s*k.sideLength + t
+			if i == centre {
+				break
+			}
+
+			w := k.weights[i]
+			if w == (kernelWeight{}) {
+				continue
+			}
+
+			offset := (s-k.radius)*img.Stride + (t-k.radius)*4
+			p := img.Pix[base+offset : base+offset+4 : base+offset+4]
+			q := img.Pix[base-offset : base-offset+4 : base-offset+4]
+
+			totalWeight.R += w.R + w.R
+			totalWeight.G += w.G + w.G
+			totalWeight.B += w.B + w.B
+			totalWeight.A += w.A + w.A
+
+			sum.R += (srgb.From8Bit(p[0]) + srgb.From8Bit(q[0])) * w.R
+			sum.G += (srgb.From8Bit(p[1]) + srgb.From8Bit(q[1])) * w.G
+			sum.B += (srgb.From8Bit(p[2]) + srgb.From8Bit(q[2])) * w.B
+			sum.A += (float32(p[3])/255 + float32(q[3])/255) * w.A
+		}
+	}
+
+	w := k.weights[centre]
+	p := img.Pix[base : base+4 : base+4]
+
+	totalWeight.R += w.R
+	totalWeight.G += w.G
+	totalWeight.B += w.B
+	totalWeight.A += w.A
+
+	sum.R += srgb.From8Bit(p[0]) * w.R
+	sum.G += srgb.From8Bit(p[1]) * w.G
+	sum.B += srgb.From8Bit(p[2]) * w.B
+	sum.A += float32(p[3]) / 255 * w.A
+
+	return sum, totalWeight
+}
+
+// foldedSumFloat is the equivalent of foldedSum for a FloatImage, giving
+// identical results for the same values.
+func (k *Kernel) foldedSumFloat(img *FloatImage, x, y int) (sum, totalWeight kernelWeight) {
+	base := img.offset(x, y)
+	centre := len(k.weights) / 2
+
+	for s := 0; s <= k.radius; s++ {
+		for t := 0; t < k.sideLength; t++ {
+			i := s*k.sideLength + t
+			if i == centre {
+				break
+			}
+
+			w := k.weights[i]
+			if w == (kernelWeight{}) {
+				continue
+			}
+
+			offset := (s-k.radius)*img.Stride + (t-k.radius)*4
+			p := img.Pix[base+offset : base+offset+4 : base+offset+4]
+			q := img.Pix[base-offset : base-offset+4 : base-offset+4]
+
+			totalWeight.R += w.R + w.R
+			totalWeight.G += w.G + w.G
+			totalWeight.B += w.B + w.B
+			totalWeight.A += w.A + w.A
+
+			sum.R += (p[0] + q[0]) * w.R
+			sum.G += (p[1] + q[1]) * w.G
+			sum.B += (p[2] + q[2]) * w.B
+			sum.A += (p[3] + q[3]) * w.A
+		}
+	}
+
+	w := k.weights[centre]
+	p := img.Pix[base : base+4 : base+4]
+
+	totalWeight.R += w.R
+	totalWeight.G += w.G
+	totalWeight.B += w.B
+	totalWeight.A += w.A
+
+	sum.R += p[0] * w.R
+	sum.G += p[1] * w.G
+	sum.B += p[2] * w.B
+	sum.A += p[3] * w.A
+
+	return sum, totalWeight
+}
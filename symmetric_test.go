@@ -0,0 +1,127 @@
+package convolver
+
+import (
+	"testing"
+)
+
+func BenchmarkSymmetricAvg(b *testing.B) {
+	img := randomImage(1024, 1024)
+
+	kernel := KernelWithRadius(2)
+	kernel.SetWeightsUniform([]float32{
+		1, 4, 6, 4, 1,
+		4, 16, 24, 16, 4,
+		6, 24, 36, 24, 6,
+		4, 16, 24, 16, 4,
+		1, 4, 6, 4, 1,
+	})
+
+	b.Run("symmetric", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			kernel.ApplyAvg(img, 1)
+		}
+	})
+
+	asymmetric := KernelWithRadius(2)
+	asymmetric.SetWeightsUniform([]float32{
+		1, 4, 6, 4, 1,
+		4, 16, 24, 16, 4,
+		6, 24, 36, 24, 6,
+		4, 16, 24, 16, 4,
+		1, 4, 6, 4, 1.001,
+	})
+
+	b.Run("asymmetric", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			asymmetric.ApplyAvg(img, 1)
+		}
+	})
+}
+
+func TestSymmetricAvg(t *testing.T) {
+
+	t.Run("detects point symmetric kernels", func(t *testing.T) {
+		cases := []struct {
+			weights  []float32
+			expected bool
+		}{
+			{[]float32{1, 2, 1, 2, 4, 2, 1, 2, 1}, true},
+			{[]float32{0, -1, 0, -1, 5, -1, 0, -1, 0}, true},
+			{[]float32{1, 0, -1, 2, 0, -2, 1, 0, -1}, false},
+			{[]float32{0, 0, 1, 0, 1, 0, 1, 0, 0}, true},
+		}
+
+		for _, c := range cases {
+			kernel := KernelWithRadius(1)
+			kernel.SetWeightsUniform(c.weights)
+
+			if actual := kernel.pointSymmetric(); c.expected != actual {
+				t.Errorf("Expected symmetry of %v to be %v but was %v", c.weights, c.expected, actual)
+			}
+		}
+	})
+
+	t.Run("tracks symmetry as weights are set individually", func(t *testing.T) {
+		kernel := KernelWithRadius(1)
+
+		if !kernel.pointSymmetric() {
+			t.Errorf("Expected empty kernel to be symmetric")
+		}
+
+		kernel.SetWeightUniform(0, 1, 2)
+		if kernel.pointSymmetric() {
+			t.Errorf("Expected kernel to be asymmetric after setting one side")
+		}
+
+		kernel.SetWeightUniform(1, 1, 3)
+		kernel.SetWeightUniform(2, 1, 2)
+		if !kernel.pointSymmetric() {
+			t.Errorf("Expected kernel to be symmetric after setting the mirrored weight")
+		}
+
+		kernel.SetWeightRGBA(2, 1, 2, 2, 2, 1)
+		if kernel.pointSymmetric() {
+			t.Errorf("Expected kernel to be asymmetric after changing one channel")
+		}
+	})
+
+	t.Run("matches the reference implementation", func(t *testing.T) {
+		img := randomImage(37, 29)
+
+		kernel := KernelWithRadius(2)
+		kernel.SetWeightsUniform([]float32{
+			1, 4, 6, 4, 1,
+			4, 16, 24, 16, 4,
+			6, 24, 36, 24, 6,
+			4, 16, 24, 16, 4,
+			1, 4, 6, 4, 1,
+		})
+		kernel.SetWeightRGBA(0, 2, 6, 6, 3, 6)
+		kernel.SetWeightRGBA(4, 2, 6, 6, 3, 6)
+
+		expected := kernel.ApplyReference(img, ReferenceAvg)
+		actual := kernel.ApplyAvg(img, 2)
+
+		for i := range expected.Pix {
+			if absDiff(expected.Pix[i], actual.Pix[i]) > 3 {
+				t.Fatalf("Expected folded result to match at offset %d but was %d instead of %d", i, actual.Pix[i], expected.Pix[i])
+			}
+		}
+	})
+
+	t.Run("gives identical results for float images", func(t *testing.T) {
+		img := randomImage(16, 12)
+
+		kernel := KernelWithRadius(1)
+		kernel.SetWeightsUniform([]float32{1, 2, 1, 2, 4, 2, 1, 2, 1})
+
+		expected := kernel.ApplyAvg(img, 2)
+		actual := nrgbaFromFloatImage(kernel.ApplyAvgFloat(FloatImageFromImage(img, 2), 2), 2)
+
+		for i := range expected.Pix {
+			if expected.Pix[i] != actual.Pix[i] {
+				t.Fatalf("Expected float result to match at offset %d but was %d instead of %d", i, actual.Pix[i], expected.Pix[i])
+			}
+		}
+	})
+}
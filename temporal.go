@@ -0,0 +1,123 @@
+package convolver
+
+import (
+	"fmt"
+	"github.com/mandykoh/go-parallel"
+	"github.com/mandykoh/prism"
+	"github.com/mandykoh/prism/srgb"
+	"image"
+	"image/color"
+	"sync"
+)
+
+// TemporalDenoiser reduces noise in a sequence of frames from a fixed camera,
+// such as a webcam or CCTV feed, by averaging each pixel with the same pixel
+// in previous frames. Where a pixel differs from its average by more than a
+// threshold, it is taken to be moving and passed through unchanged, and its
+// average starts again from the new value, so motion is not smeared.
+//
+// Frames are processed in the order Apply is called, so a denoiser should be
+// used for one sequence at a time. Its Apply method can be used as a Stage,
+// such as at the start of a pipeline applied to each frame in turn.
+type TemporalDenoiser struct {
+	threshold uint8
+	history   int
+
+	mutex   sync.Mutex
+	bounds  image.Rectangle
+	average []float32
+	counts  []int
+}
+
+// Apply denoises the next frame of the sequence. If the frame's bounds differ
+// from those of the previous frame, the history is discarded and the frame is
+// returned unchanged.
+func (d *TemporalDenoiser) Apply(frame image.Image, parallelism int) *image.NRGBA {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	input := prism.ConvertImageToNRGBA(frame, parallelism)
+	bounds := input.Rect
+
+	if bounds != d.bounds {
+		d.bounds = bounds
+		d.average = make([]float32, bounds.Dx()*bounds.Dy()*4)
+		d.counts = make([]int, bounds.Dx()*bounds.Dy())
+	}
+
+	result := image.NewNRGBA(bounds)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := workerNum; i < bounds.Dy(); i += workerCount {
+			for j := 0; j < bounds.Dx(); j++ {
+				p := i*bounds.Dx() + j
+				avg := d.average[p*4 : p*4+4 : p*4+4]
+
+				current := input.NRGBAAt(bounds.Min.X+j, bounds.Min.Y+i)
+				c, a := srgb.ColorFromNRGBA(current)
+				value := [4]float32{c.R * a, c.G * a, c.B * a, a}
+
+				if d.counts[p] == 0 || exceedsThreshold(current, premultipliedToNRGBA(avg), d.threshold) {
+					copy(avg, value[:])
+					d.counts[p] = 1
+					result.SetNRGBA(bounds.Min.X+j, bounds.Min.Y+i, current)
+					continue
+				}
+
+				if d.counts[p] < d.history {
+					d.counts[p]++
+				}
+				for ch := range avg {
+					avg[ch] += (value[ch] - avg[ch]) / float32(d.counts[p])
+				}
+
+				result.SetNRGBA(bounds.Min.X+j, bounds.Min.Y+i, premultipliedToNRGBA(avg))
+			}
+		}
+	})
+
+	return result
+}
+
+// Reset discards the history of previous frames, such as when the camera is
+// moved or the scene cut.
+func (d *TemporalDenoiser) Reset() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.bounds = image.Rectangle{}
+	d.average = nil
+	d.counts = nil
+}
+
+func exceedsThreshold(a, b color.NRGBA, threshold uint8) bool {
+	return absDiffUint8(a.R, b.R) > threshold || absDiffUint8(a.G, b.G) > threshold ||
+		absDiffUint8(a.B, b.B) > threshold || absDiffUint8(a.A, b.A) > threshold
+}
+
+// premultipliedToNRGBA encodes linear premultiplied RGBA values.
+func premultipliedToNRGBA(v []float32) color.NRGBA {
+	r, g, b, a := v[0], v[1], v[2], v[3]
+	if a > 0 {
+		r, g, b = r/a, g/a, b/a
+	}
+	return srgb.ColorFromLinear(r, g, b).ToNRGBA(a)
+}
+
+// NewTemporalDenoiser returns a denoiser which averages each pixel over up to
+// the given number of frames, including the current one, while it differs
+// from its average by no more than threshold levels in every 8-bit channel.
+// Once a pixel has been still for that many frames, each new frame is given
+// the same share of the average, so older frames fade out gradually.
+// A threshold a little above the amplitude of the camera's noise, such as 8
+// to 16, is typical.
+func NewTemporalDenoiser(threshold uint8, history int) *TemporalDenoiser {
+	if history < 1 {
+		panic(fmt.Sprintf("history must be at least 1 frame but was %d", history))
+	}
+
+	return &TemporalDenoiser{
+		threshold: threshold,
+		history:   history,
+	}
+}
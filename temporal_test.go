@@ -0,0 +1,126 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+func TestTemporalDenoiser(t *testing.T) {
+	bounds := image.Rect(0, 0, 32, 16)
+	rng := rand.New(rand.NewSource(1))
+
+	noisyFrame := func() *image.NRGBA {
+		frame := image.NewNRGBA(bounds)
+		for i := 0; i < len(frame.Pix); i += 4 {
+			v := uint8(124 + rng.Intn(9))
+			frame.Pix[i], frame.Pix[i+1], frame.Pix[i+2], frame.Pix[i+3] = v, v, v, 255
+		}
+		return frame
+	}
+
+	deviation := func(img *image.NRGBA) float64 {
+		var sum float64
+		for i := 0; i < len(img.Pix); i += 4 {
+			d := float64(img.Pix[i]) - 128
+			sum += d * d
+		}
+		return sum / float64(len(img.Pix)/4)
+	}
+
+	t.Run("passes the first frame through unchanged", func(t *testing.T) {
+		frame := noisyFrame()
+		result := NewTemporalDenoiser(16, 8).Apply(frame, 2)
+
+		for i := range frame.Pix {
+			if frame.Pix[i] != result.Pix[i] {
+				t.Fatalf("Expected first frame to be unchanged at offset %d", i)
+			}
+		}
+	})
+
+	t.Run("reduces noise in still areas", func(t *testing.T) {
+		denoiser := NewTemporalDenoiser(16, 8)
+
+		var input, output *image.NRGBA
+		for f := 0; f < 12; f++ {
+			input = noisyFrame()
+			output = denoiser.Apply(input, 2)
+		}
+
+		if inDev, outDev := deviation(input), deviation(output); outDev >= inDev/2 {
+			t.Errorf("Expected noise to be substantially reduced but variance went from %v to %v", inDev, outDev)
+		}
+	})
+
+	t.Run("passes moving pixels through", func(t *testing.T) {
+		denoiser := NewTemporalDenoiser(16, 8)
+		for f := 0; f < 4; f++ {
+			denoiser.Apply(noisyFrame(), 2)
+		}
+
+		frame := noisyFrame()
+		moved := color.NRGBA{R: 250, G: 20, B: 30, A: 255}
+		frame.SetNRGBA(5, 5, moved)
+
+		result := denoiser.Apply(frame, 2)
+		if actual := result.NRGBAAt(5, 5); actual != moved {
+			t.Errorf("Expected moving pixel to be %v but was %v", moved, actual)
+		}
+
+		// The average restarts from the new value, so the next frame with the
+		// pixel still there is not pulled back towards the old background,
+		// other than by the quantisation of dark tones in linear light
+		frame = noisyFrame()
+		frame.SetNRGBA(5, 5, moved)
+
+		actual := denoiser.Apply(frame, 2).NRGBAAt(5, 5)
+		if absDiff(actual.R, moved.R) > 3 || absDiff(actual.G, moved.G) > 3 || absDiff(actual.B, moved.B) > 3 {
+			t.Errorf("Expected moved pixel to stay close to %v but was %v", moved, actual)
+		}
+	})
+
+	t.Run("passes everything through with a history of one frame", func(t *testing.T) {
+		denoiser := NewTemporalDenoiser(255, 1)
+		denoiser.Apply(noisyFrame(), 2)
+
+		frame := noisyFrame()
+		result := denoiser.Apply(frame, 2)
+
+		for i := range frame.Pix {
+			if frame.Pix[i] != result.Pix[i] {
+				t.Fatalf("Expected frame to be unchanged at offset %d", i)
+			}
+		}
+	})
+
+	t.Run("discards history when the frame size changes or on reset", func(t *testing.T) {
+		denoiser := NewTemporalDenoiser(255, 8)
+		denoiser.Apply(noisyFrame(), 2)
+
+		for _, next := range []func() *image.NRGBA{
+			func() *image.NRGBA { return noisyFrame().SubImage(image.Rect(0, 0, 8, 8)).(*image.NRGBA) },
+			func() *image.NRGBA { denoiser.Reset(); return noisyFrame() },
+		} {
+			frame := next()
+			result := denoiser.Apply(frame, 2)
+
+			for i := 0; i < 8; i++ {
+				if expected, actual := frame.NRGBAAt(i, i), result.NRGBAAt(i, i); expected != actual {
+					t.Fatalf("Expected %v but was %v at %d, %d", expected, actual, i, i)
+				}
+			}
+		}
+	})
+
+	t.Run("panics for a history of less than one frame", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic but there was none")
+			}
+		}()
+
+		NewTemporalDenoiser(16, 0)
+	})
+}
@@ -0,0 +1,103 @@
+package convolver
+
+import (
+	"image"
+	"math"
+)
+
+// Checkerboard returns a pattern of alternating black and white squares of
+// the given size, starting with black at the top left. Its sharp edges show
+// ringing and overshoot from sharpening kernels.
+func Checkerboard(bounds image.Rectangle, squareSize int) *image.Gray {
+	if squareSize < 1 {
+		panic("square size must be positive")
+	}
+
+	pattern := image.NewGray(bounds)
+
+	for i := bounds.Min.Y; i < bounds.Max.Y; i++ {
+		for j := bounds.Min.X; j < bounds.Max.X; j++ {
+			if ((j-bounds.Min.X)/squareSize+(i-bounds.Min.Y)/squareSize)%2 == 1 {
+				pattern.Pix[pattern.PixOffset(j, i)] = 255
+			}
+		}
+	}
+
+	return pattern
+}
+
+// GradientRamp returns a horizontal ramp from black at the left edge to white
+// at the right edge, quantised to the given number of steps. A step count of
+// zero gives a smooth ramp. Stepped ramps show how a kernel spreads edges of
+// different contrasts; smooth ramps reveal banding.
+func GradientRamp(bounds image.Rectangle, steps int) *image.Gray {
+	pattern := image.NewGray(bounds)
+
+	for j := bounds.Min.X; j < bounds.Max.X; j++ {
+		t := 0.0
+		if bounds.Dx() > 1 {
+			t = float64(j-bounds.Min.X) / float64(bounds.Dx()-1)
+		}
+		if steps > 1 {
+			t = math.Min(math.Floor(t*float64(steps)), float64(steps-1)) / float64(steps-1)
+		}
+
+		v := maskValue(t)
+		for i := bounds.Min.Y; i < bounds.Max.Y; i++ {
+			pattern.Pix[pattern.PixOffset(j, i)] = v
+		}
+	}
+
+	return pattern
+}
+
+// SiemensStar returns a star of alternating black and white wedges radiating
+// from the centre of the bounds. The wedges narrow towards the centre, so the
+// point at which they blur together shows the resolving power of a kernel.
+func SiemensStar(bounds image.Rectangle, spokes int) *image.Gray {
+	if spokes < 1 {
+		panic("a Siemens star must have at least one spoke")
+	}
+
+	pattern := image.NewGray(bounds)
+	cx, cy := patternCentre(bounds)
+
+	for i := bounds.Min.Y; i < bounds.Max.Y; i++ {
+		for j := bounds.Min.X; j < bounds.Max.X; j++ {
+			angle := math.Atan2(float64(i)+0.5-cy, float64(j)+0.5-cx)
+			if math.Sin(angle*float64(spokes)) >= 0 {
+				pattern.Pix[pattern.PixOffset(j, i)] = 255
+			}
+		}
+	}
+
+	return pattern
+}
+
+// ZonePlate returns a circular zone plate: concentric rings whose spatial
+// frequency increases linearly with distance from the centre of the bounds,
+// reaching the Nyquist frequency (one cycle every two pixels) at the nearest
+// edge. Frequencies a kernel fails to suppress appear as aliasing patterns,
+// which makes this useful for evaluating filters before resampling.
+func ZonePlate(bounds image.Rectangle) *image.Gray {
+	pattern := image.NewGray(bounds)
+	cx, cy := patternCentre(bounds)
+
+	scale := 1.0
+	if size := math.Min(float64(bounds.Dx()), float64(bounds.Dy())); size > 0 {
+		scale = math.Pi / size
+	}
+
+	for i := bounds.Min.Y; i < bounds.Max.Y; i++ {
+		for j := bounds.Min.X; j < bounds.Max.X; j++ {
+			dx, dy := float64(j)+0.5-cx, float64(i)+0.5-cy
+			pattern.Pix[pattern.PixOffset(j, i)] = maskValue(0.5 + 0.5*math.Cos((dx*dx+dy*dy)*scale))
+		}
+	}
+
+	return pattern
+}
+
+func patternCentre(bounds image.Rectangle) (float64, float64) {
+	return float64(bounds.Min.X+bounds.Max.X) / 2, float64(bounds.Min.Y+bounds.Max.Y) / 2
+}
@@ -0,0 +1,91 @@
+package convolver
+
+import (
+	"image"
+	"testing"
+)
+
+func TestCheckerboard(t *testing.T) {
+	pattern := Checkerboard(image.Rect(0, 0, 8, 8), 2)
+
+	cases := []struct {
+		X, Y     int
+		Expected uint8
+	}{
+		{0, 0, 0},
+		{1, 1, 0},
+		{2, 0, 255},
+		{0, 2, 255},
+		{2, 2, 0},
+		{7, 5, 255},
+	}
+
+	for _, c := range cases {
+		if actual := pattern.GrayAt(c.X, c.Y).Y; c.Expected != actual {
+			t.Errorf("Expected value at %d,%d to be %d but was %d", c.X, c.Y, c.Expected, actual)
+		}
+	}
+}
+
+func TestGradientRamp(t *testing.T) {
+
+	t.Run("ramps smoothly from black to white", func(t *testing.T) {
+		pattern := GradientRamp(image.Rect(0, 0, 256, 2), 0)
+
+		for j := 0; j < 256; j++ {
+			if expected, actual := uint8(j), pattern.GrayAt(j, 1).Y; expected != actual {
+				t.Fatalf("Expected value at column %d to be %d but was %d", j, expected, actual)
+			}
+		}
+	})
+
+	t.Run("quantises to the given number of steps", func(t *testing.T) {
+		pattern := GradientRamp(image.Rect(0, 0, 12, 1), 4)
+
+		expected := []uint8{0, 0, 0, 85, 85, 85, 170, 170, 170, 255, 255, 255}
+		for j, e := range expected {
+			if actual := pattern.GrayAt(j, 0).Y; e != actual {
+				t.Errorf("Expected value at column %d to be %d but was %d", j, e, actual)
+			}
+		}
+	})
+}
+
+func TestSiemensStar(t *testing.T) {
+	pattern := SiemensStar(image.Rect(0, 0, 64, 64), 8)
+
+	black, white := 0, 0
+	for _, v := range pattern.Pix {
+		switch v {
+		case 0:
+			black++
+		case 255:
+			white++
+		default:
+			t.Fatalf("Expected only black and white values but found %d", v)
+		}
+	}
+
+	if diff := black - white; diff > len(pattern.Pix)/20 || diff < -len(pattern.Pix)/20 {
+		t.Errorf("Expected roughly equal black and white areas but had %d black and %d white", black, white)
+	}
+	if a, b := pattern.GrayAt(60, 33).Y, pattern.GrayAt(60, 31).Y; a == b {
+		t.Errorf("Expected wedges either side of the horizontal axis to differ")
+	}
+}
+
+func TestZonePlate(t *testing.T) {
+	pattern := ZonePlate(image.Rect(0, 0, 64, 64))
+
+	if actual := pattern.GrayAt(32, 32).Y; actual < 250 {
+		t.Errorf("Expected centre to be near white but was %d", actual)
+	}
+
+	// Frequency increases outward, so adjacent pixels differ more near the
+	// edge than near the centre
+	centreDiff := absDiff(pattern.GrayAt(33, 32).Y, pattern.GrayAt(34, 32).Y)
+	edgeDiff := absDiff(pattern.GrayAt(62, 32).Y, pattern.GrayAt(63, 32).Y)
+	if edgeDiff <= centreDiff {
+		t.Errorf("Expected larger differences near the edge (%d) than near the centre (%d)", edgeDiff, centreDiff)
+	}
+}
@@ -0,0 +1,77 @@
+package convolver
+
+import (
+	"fmt"
+	"github.com/mandykoh/go-parallel"
+	"github.com/mandykoh/prism"
+	"github.com/mandykoh/prism/srgb"
+	"image"
+)
+
+// TextureLayout describes the byte layout expected by a graphics API when
+// uploading RGBA8 texture data.
+type TextureLayout struct {
+	// RowAlignment is the alignment in bytes of the start of each row, as set
+	// by GL_UNPACK_ALIGNMENT in OpenGL (1, 2, 4 or 8). Zero means rows are
+	// tightly packed.
+	RowAlignment int
+
+	// Premultiplied multiplies colour values by alpha. Multiplication is done
+	// in linear light, as expected by sRGB texture formats, which decode
+	// values before filtering and blending.
+	Premultiplied bool
+
+	// FlipVertically stores the bottom row first, matching the texture
+	// coordinate origin of OpenGL.
+	FlipVertically bool
+}
+
+// PackTexture returns the pixels of an image as sRGB encoded RGBA bytes in the
+// given layout, ready to be uploaded as a texture, along with the stride in
+// bytes between rows. When the image is an *image.NRGBA already in the
+// required layout, its pixels are returned without copying.
+func PackTexture(img image.Image, layout TextureLayout, parallelism int) (pix []byte, stride int) {
+	if a := layout.RowAlignment; a < 0 || a&(a-1) != 0 {
+		panic(fmt.Sprintf("row alignment must be a power of two but was %d", a))
+	}
+
+	nrgba := prism.ConvertImageToNRGBA(img, parallelism)
+	bounds := nrgba.Rect
+	width, height := bounds.Dx(), bounds.Dy()
+
+	stride = width * 4
+	if a := layout.RowAlignment; a > 1 {
+		stride = (stride + a - 1) / a * a
+	}
+
+	if !layout.Premultiplied && !layout.FlipVertically && nrgba.Stride == stride && nrgba.PixOffset(bounds.Min.X, bounds.Min.Y) == 0 {
+		return nrgba.Pix[:stride*height], stride
+	}
+
+	pix = make([]byte, stride*height)
+
+	parallel.RunWorkers(parallelism, func(workerNum, workerCount int) {
+		for i := workerNum; i < height; i += workerCount {
+			row := i
+			if layout.FlipVertically {
+				row = height - 1 - i
+			}
+
+			src := nrgba.Pix[nrgba.PixOffset(bounds.Min.X, bounds.Min.Y+i):][:width*4]
+			dst := pix[row*stride:][:width*4]
+
+			if !layout.Premultiplied {
+				copy(dst, src)
+				continue
+			}
+
+			for j := 0; j < len(src); j += 4 {
+				c, a := srgb.ColorFromNRGBA(nrgba.NRGBAAt(bounds.Min.X+j/4, bounds.Min.Y+i))
+				premultiplied := srgb.ColorFromLinear(c.R*a, c.G*a, c.B*a).ToNRGBA(a)
+				dst[j], dst[j+1], dst[j+2], dst[j+3] = premultiplied.R, premultiplied.G, premultiplied.B, src[j+3]
+			}
+		}
+	})
+
+	return pix, stride
+}
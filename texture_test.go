@@ -0,0 +1,84 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+)
+
+func TestPackTexture(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 128})
+	img.SetNRGBA(2, 1, color.NRGBA{R: 10, G: 20, B: 30, A: 255})
+
+	t.Run("returns tightly packed images without copying", func(t *testing.T) {
+		pix, stride := PackTexture(img, TextureLayout{RowAlignment: 4}, runtime.NumCPU())
+
+		if expected, actual := 12, stride; expected != actual {
+			t.Errorf("Expected stride to be %d but was %d", expected, actual)
+		}
+		if &pix[0] != &img.Pix[0] {
+			t.Errorf("Expected image pixels to be returned without copying")
+		}
+	})
+
+	t.Run("pads rows to the alignment", func(t *testing.T) {
+		pix, stride := PackTexture(img, TextureLayout{RowAlignment: 8}, runtime.NumCPU())
+
+		if expected, actual := 16, stride; expected != actual {
+			t.Fatalf("Expected stride to be %d but was %d", expected, actual)
+		}
+		if expected, actual := 32, len(pix); expected != actual {
+			t.Fatalf("Expected %d bytes but got %d", expected, actual)
+		}
+		if expected, actual := (color.NRGBA{R: 10, G: 20, B: 30, A: 255}), (color.NRGBA{R: pix[24], G: pix[25], B: pix[26], A: pix[27]}); expected != actual {
+			t.Errorf("Expected last pixel to be %+v but was %+v", expected, actual)
+		}
+	})
+
+	t.Run("flips rows vertically", func(t *testing.T) {
+		pix, stride := PackTexture(img, TextureLayout{FlipVertically: true}, runtime.NumCPU())
+
+		if expected, actual := uint8(10), pix[8]; expected != actual {
+			t.Errorf("Expected bottom row to be stored first but first row had %d", actual)
+		}
+		if expected, actual := uint8(128), pix[stride+3]; expected != actual {
+			t.Errorf("Expected top row to be stored last but last row had alpha %d", actual)
+		}
+	})
+
+	t.Run("premultiplies alpha in linear light", func(t *testing.T) {
+		pix, _ := PackTexture(img, TextureLayout{Premultiplied: true}, runtime.NumCPU())
+
+		// White at alpha 128 is linear 0.502, which encodes to 188
+		if expected, actual := (color.NRGBA{R: 188, G: 188, B: 188, A: 128}), (color.NRGBA{R: pix[0], G: pix[1], B: pix[2], A: pix[3]}); absDiff(expected.R, actual.R) > 1 || expected.A != actual.A {
+			t.Errorf("Expected premultiplied pixel to be %+v but was %+v", expected, actual)
+		}
+		if img.Pix[0] != 255 {
+			t.Errorf("Expected source image to be unchanged")
+		}
+	})
+
+	t.Run("packs sub-images", func(t *testing.T) {
+		sub := img.SubImage(image.Rect(1, 1, 3, 2))
+
+		pix, stride := PackTexture(sub, TextureLayout{}, runtime.NumCPU())
+
+		if expected, actual := 8, stride; expected != actual {
+			t.Fatalf("Expected stride to be %d but was %d", expected, actual)
+		}
+		if expected, actual := uint8(10), pix[4]; expected != actual {
+			t.Errorf("Expected second pixel red to be %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("panics for alignments which are not powers of two", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic")
+			}
+		}()
+		PackTexture(img, TextureLayout{RowAlignment: 3}, runtime.NumCPU())
+	})
+}
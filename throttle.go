@@ -0,0 +1,66 @@
+package convolver
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// minThrottlePause is the shortest pause taken by a throttled worker. Owed
+// pauses are accumulated until they reach this length, since the scheduler
+// can't sleep accurately for much less.
+const minThrottlePause = 2 * time.Millisecond
+
+// SetMaxCPUShare limits the fraction of a CPU each worker applying this
+// kernel may use, between 0 (exclusive) and 1, so that background filtering
+// doesn't starve latency sensitive work in the same process. Workers pause
+// between rows or tiles for long enough to keep their busy time within the
+// share, yielding to other goroutines as they go; the total CPU used is at
+// most the share multiplied by the parallelism. A share of 1 means no limit.
+func (k *Kernel) SetMaxCPUShare(share float64) {
+	if share <= 0 || share > 1 {
+		panic(fmt.Sprintf("CPU share must be greater than 0 and at most 1 but was %v", share))
+	}
+
+	k.maxCPUShare = share
+}
+
+// cpuThrottle limits the CPU time used by a single worker by pausing it in
+// proportion to the time it has been busy.
+type cpuThrottle struct {
+	share     float64
+	busySince time.Time
+	owed      time.Duration
+}
+
+// newThrottle returns a throttle for one worker applying the kernel, or nil if
+// the kernel's CPU share is not limited.
+func (k *Kernel) newThrottle() *cpuThrottle {
+	if k.maxCPUShare <= 0 || k.maxCPUShare >= 1 {
+		return nil
+	}
+
+	return &cpuThrottle{share: k.maxCPUShare, busySince: time.Now()}
+}
+
+// yield is called by a worker between units of work, pausing it if it has
+// used more than its share of CPU time. A nil throttle never pauses.
+func (t *cpuThrottle) yield() {
+	if t == nil {
+		return
+	}
+
+	now := time.Now()
+	t.owed += time.Duration(float64(now.Sub(t.busySince)) * (1/t.share - 1))
+
+	if t.owed >= minThrottlePause {
+		time.Sleep(t.owed)
+		slept := time.Since(now)
+		t.owed -= slept
+		now = now.Add(slept)
+	} else {
+		runtime.Gosched()
+	}
+
+	t.busySince = now
+}
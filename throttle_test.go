@@ -0,0 +1,77 @@
+package convolver
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestSetMaxCPUShare(t *testing.T) {
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{1, 2, 1, 2, 4, 2, 1, 2, 1})
+
+	img := randomImage(32, 32)
+
+	t.Run("produces the same result as an unthrottled kernel", func(t *testing.T) {
+		throttled := kernel
+		throttled.SetMaxCPUShare(0.5)
+
+		expected := kernel.ApplyAvg(img, runtime.NumCPU())
+		actual := throttled.ApplyAvg(img, runtime.NumCPU())
+
+		for i := range expected.Pix {
+			if expected.Pix[i] != actual.Pix[i] {
+				t.Fatalf("Expected throttled result to match at offset %d", i)
+			}
+		}
+	})
+
+	t.Run("panics for shares out of range", func(t *testing.T) {
+		for _, share := range []float64{0, -0.5, 1.5} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("Expected a panic for share %v", share)
+					}
+				}()
+				kernel.SetMaxCPUShare(share)
+			}()
+		}
+	})
+}
+
+func TestCPUThrottle(t *testing.T) {
+	t.Run("pauses in proportion to busy time", func(t *testing.T) {
+		throttle := &cpuThrottle{share: 0.25, busySince: time.Now().Add(-10 * time.Millisecond)}
+
+		start := time.Now()
+		throttle.yield()
+
+		if elapsed := time.Since(start); elapsed < 25*time.Millisecond {
+			t.Errorf("Expected a pause of at least 25ms but was %v", elapsed)
+		}
+	})
+
+	t.Run("accumulates short pauses", func(t *testing.T) {
+		throttle := &cpuThrottle{share: 0.5, busySince: time.Now()}
+
+		throttle.yield()
+
+		if throttle.owed <= 0 || throttle.owed >= minThrottlePause {
+			t.Errorf("Expected a short pause to be owed but was %v", throttle.owed)
+		}
+	})
+
+	t.Run("is disabled for unlimited kernels", func(t *testing.T) {
+		kernel := KernelWithRadius(0)
+
+		if throttle := kernel.newThrottle(); throttle != nil {
+			t.Errorf("Expected no throttle")
+		}
+
+		kernel.SetMaxCPUShare(1)
+		if throttle := kernel.newThrottle(); throttle != nil {
+			t.Errorf("Expected no throttle for a share of 1")
+		}
+	})
+}
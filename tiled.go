@@ -0,0 +1,260 @@
+package convolver
+
+import (
+	"context"
+	"fmt"
+	"github.com/mandykoh/prism"
+	"image"
+	"image/draw"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// Checkpoint records the progress of a resumable tiled application. It holds
+// the partially completed result and which of its tiles are complete, and
+// may be persisted (for example, using encoding/gob) between runs.
+type Checkpoint struct {
+	TileSize  int
+	Completed []bool
+	Result    *image.NRGBA
+}
+
+// NewCheckpoint returns an empty checkpoint for processing an image with the
+// given bounds in tiles of the given size.
+func NewCheckpoint(bounds image.Rectangle, tileSize int) *Checkpoint {
+	return &Checkpoint{
+		TileSize:  tileSize,
+		Completed: make([]bool, len(tilesCovering(bounds, tileSize))),
+		Result:    image.NewNRGBA(bounds),
+	}
+}
+
+// TileFunc receives each tile of a result as soon as it has been completed.
+// result is the whole output image, of which only the completed tiles contain
+// final values.
+type TileFunc func(tile image.Rectangle, result *image.NRGBA)
+
+// ApplyTiled applies an operation such as k.Avg to an image in square tiles of
+// the given size, calling onTile as each tile is completed so that a preview
+// can be progressively refined during long convolutions. Tiles are started in
+// row-major order. Calls to onTile are serialised, but they are made from
+// worker goroutines and should return promptly.
+func (k *Kernel) ApplyTiled(img image.Image, op OpFunc, tileSize int, onTile TileFunc, parallelism int) *image.NRGBA {
+	parallelism = k.powerMode.workers(parallelism)
+
+	input := k.newTileInput(img, parallelism)
+	result := image.NewNRGBA(img.Bounds())
+
+	tiles := tilesCovering(result.Rect, tileSize)
+	nextTile := int64(-1)
+	callbackMutex := sync.Mutex{}
+
+	runWorkers(currentMetrics(), parallelism, func(workerNum, workerCount int) {
+		throttle := k.newThrottle()
+		var buffer []uint8
+
+		for {
+			index := int(atomic.AddInt64(&nextTile, 1))
+			if index >= len(tiles) {
+				return
+			}
+
+			tile := tiles[index]
+			applyToRect(input.around(tile, &buffer), result, tile, op)
+			throttle.yield()
+
+			if onTile != nil {
+				callbackMutex.Lock()
+				onTile(tile, result)
+				callbackMutex.Unlock()
+			}
+		}
+	})
+
+	return result
+}
+
+// ApplyResumable applies an operation such as k.Avg to an image in tiles,
+// skipping any tiles already recorded as complete in the checkpoint and
+// recording each tile as it completes. If the context is cancelled, workers
+// finish their current tiles and the context's error is returned; calling
+// ApplyResumable again with the same checkpoint continues where it left off.
+// An error is also returned if any tile is somehow left incomplete, so that
+// success always means the whole result is final.
+func (k *Kernel) ApplyResumable(ctx context.Context, img image.Image, op OpFunc, checkpoint *Checkpoint, parallelism int) (*image.NRGBA, error) {
+	bounds := img.Bounds()
+
+	tiles := tilesCovering(bounds, checkpoint.TileSize)
+	if checkpoint.Result == nil || checkpoint.Result.Rect != bounds || len(checkpoint.Completed) != len(tiles) {
+		return nil, fmt.Errorf("checkpoint does not match image bounds %v with tile size %d", bounds, checkpoint.TileSize)
+	}
+
+	parallelism = k.powerMode.workers(parallelism)
+
+	input := k.newTileInput(img, parallelism)
+	nextTile := int64(-1)
+
+	runWorkers(currentMetrics(), parallelism, func(workerNum, workerCount int) {
+		throttle := k.newThrottle()
+		var buffer []uint8
+
+		for ctx.Err() == nil {
+			index := int(atomic.AddInt64(&nextTile, 1))
+			if index >= len(tiles) {
+				return
+			}
+			if checkpoint.Completed[index] {
+				continue
+			}
+
+			applyToRect(input.around(tiles[index], &buffer), checkpoint.Result, tiles[index], op)
+			checkpoint.Completed[index] = true
+			throttle.yield()
+		}
+	})
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for i, completed := range checkpoint.Completed {
+		if !completed {
+			return nil, fmt.Errorf("tile %v was not completed", tiles[i])
+		}
+	}
+
+	return checkpoint.Result, nil
+}
+
+// applyBounded applies an operation which reads pixels up to apron away to
+// the pixels of img within bounds in tiles, converting only each tile and its
+// surrounding apron to NRGBA, so that the working memory of all workers
+// together stays within the kernel's memory budget.
+func (k *Kernel) applyBounded(img image.Image, bounds image.Rectangle, apron int, op OpFunc, parallelism int) *image.NRGBA {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	result := image.NewNRGBA(bounds)
+
+	tiles := tilesCovering(bounds, k.boundedTileSize(apron, parallelism))
+	nextTile := int64(-1)
+
+	runWorkers(currentMetrics(), parallelism, func(workerNum, workerCount int) {
+		throttle := k.newThrottle()
+		var buffer []uint8
+
+		for {
+			index := int(atomic.AddInt64(&nextTile, 1))
+			if index >= len(tiles) {
+				return
+			}
+
+			tile := tiles[index]
+			input := nrgbaRegion(img, tile.Inset(-apron).Intersect(img.Bounds()), &buffer)
+
+			applyToRect(input, result, tile, op)
+			throttle.yield()
+		}
+	})
+
+	return result
+}
+
+// boundedTileSize returns the largest tile size for which each of the given
+// number of workers, taken to be at least one, can hold a tile and an apron of
+// the given width around it within the memory budget.
+func (k *Kernel) boundedTileSize(apron int, parallelism int) int {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	apronSide := int(math.Sqrt(float64(k.maxMemoryBytes / (parallelism * 4))))
+
+	if tileSize := apronSide - apron*2; tileSize > 1 {
+		return tileSize
+	}
+	return 1
+}
+
+// tileInput provides the pixels which an operation applied a tile at a time
+// reads around each tile, padded according to the kernel's edge mode. If the
+// whole input fits within the kernel's memory budget it is converted once;
+// otherwise each tile and its apron are converted only as they are needed.
+type tileInput struct {
+	img    image.Image
+	whole  *image.NRGBA
+	radius int
+}
+
+// newTileInput prepares an image for applying the kernel a tile at a time.
+func (k *Kernel) newTileInput(img image.Image, parallelism int) tileInput {
+	bounds := img.Bounds()
+	padded := k.edgeMode != EdgeClip && k.radius > 0 && !bounds.Empty()
+
+	input := tileInput{img: img, radius: k.radius}
+	if padded {
+		input.img = edgePaddedImage{img: img, rect: bounds.Inset(-k.radius), mode: k.edgeMode, fill: k.edgeFill()}
+	}
+
+	if nrgba, ok := img.(*image.NRGBA); ok && !padded {
+		input.whole = nrgba
+	} else if !k.exceedsMemoryBudget(input.img.Bounds()) {
+		input.whole = prism.ConvertImageToNRGBA(img, parallelism)
+		if padded {
+			input.whole = padNRGBA(input.whole, k.radius, k.edgeMode, k.edgeFill(), parallelism)
+		}
+	}
+
+	return input
+}
+
+// around returns the input pixels which an operation reads for the tile,
+// converting them into buffer if the input wasn't converted as a whole.
+func (t tileInput) around(tile image.Rectangle, buffer *[]uint8) *image.NRGBA {
+	if t.whole != nil {
+		return t.whole
+	}
+	return nrgbaRegion(t.img, tile.Inset(-t.radius).Intersect(t.img.Bounds()), buffer)
+}
+
+// nrgbaRegion returns the pixels of img within r converted to NRGBA and
+// stored in buffer, growing it if necessary.
+func nrgbaRegion(img image.Image, r image.Rectangle, buffer *[]uint8) *image.NRGBA {
+	size := bufferLength(r, 4)
+	if cap(*buffer) < size {
+		*buffer = make([]uint8, size)
+	}
+
+	region := &image.NRGBA{Pix: (*buffer)[:size], Stride: r.Dx() * 4, Rect: r}
+	draw.Draw(region, r, img, r.Min, draw.Src)
+
+	return region
+}
+
+func applyToRect(img, result *image.NRGBA, rect image.Rectangle, op OpFunc) {
+	for i := rect.Min.Y; i < rect.Max.Y; i++ {
+		for j := rect.Min.X; j < rect.Max.X; j++ {
+			result.SetNRGBA(j, i, op(img, j, i))
+		}
+	}
+}
+
+// tilesCovering returns the square tiles of the given size which cover the
+// bounds, in row-major order. Tiles along the right and bottom edges are
+// truncated to fit.
+func tilesCovering(bounds image.Rectangle, tileSize int) []image.Rectangle {
+	if tileSize < 1 {
+		panic("tile size must be positive")
+	}
+
+	var tiles []image.Rectangle
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += tileSize {
+		for x := bounds.Min.X; x < bounds.Max.X; x += tileSize {
+			tiles = append(tiles, image.Rect(x, y, x+tileSize, y+tileSize).Intersect(bounds))
+		}
+	}
+
+	return tiles
+}
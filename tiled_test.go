@@ -0,0 +1,274 @@
+package convolver
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+)
+
+func TestKernelApplyTiled(t *testing.T) {
+	img := randomImage(50, 37)
+
+	kernel := KernelWithRadius(2)
+	for i := 0; i < kernel.SideLength(); i++ {
+		for j := 0; j < kernel.SideLength(); j++ {
+			kernel.SetWeightUniform(j, i, 1)
+		}
+	}
+
+	t.Run("produces the same result as untiled application", func(t *testing.T) {
+		expectedImg := kernel.ApplyMax(img, runtime.NumCPU())
+		result := kernel.ApplyTiled(img, kernel.Max, 16, nil, runtime.NumCPU())
+
+		for i := range expectedImg.Pix {
+			if expectedImg.Pix[i] != result.Pix[i] {
+				t.Fatalf("Expected results to match but differ at byte %d", i)
+			}
+		}
+	})
+
+	t.Run("delivers every tile once after it is complete", func(t *testing.T) {
+		expectedImg := kernel.ApplyAvg(img, runtime.NumCPU())
+
+		var delivered []image.Rectangle
+		area := 0
+
+		kernel.ApplyTiled(img, kernel.Avg, 16, func(tile image.Rectangle, result *image.NRGBA) {
+			delivered = append(delivered, tile)
+			area += tile.Dx() * tile.Dy()
+
+			for i := tile.Min.Y; i < tile.Max.Y; i++ {
+				for j := tile.Min.X; j < tile.Max.X; j++ {
+					if expected, actual := expectedImg.NRGBAAt(j, i), result.NRGBAAt(j, i); expected != actual {
+						t.Fatalf("Expected pixel %d,%d of delivered tile to be %+v but was %+v", j, i, expected, actual)
+					}
+				}
+			}
+		}, runtime.NumCPU())
+
+		if expected, actual := 12, len(delivered); expected != actual {
+			t.Errorf("Expected %d tiles to be delivered but got %d", expected, actual)
+		}
+		if expected, actual := img.Rect.Dx()*img.Rect.Dy(), area; expected != actual {
+			t.Errorf("Expected delivered tiles to cover %d pixels but covered %d", expected, actual)
+		}
+	})
+
+	t.Run("treats parallelism below one as a single worker", func(t *testing.T) {
+		expectedImg := kernel.ApplyMax(img, runtime.NumCPU())
+
+		for _, parallelism := range []int{0, -2} {
+			result := kernel.ApplyTiled(img, kernel.Max, 16, nil, parallelism)
+
+			for i := range expectedImg.Pix {
+				if expectedImg.Pix[i] != result.Pix[i] {
+					t.Fatalf("Expected results with parallelism %d to match but differ at byte %d", parallelism, i)
+				}
+			}
+		}
+	})
+}
+
+func TestTilesCovering(t *testing.T) {
+	tiles := tilesCovering(image.Rect(5, 5, 30, 17), 10)
+
+	expected := []image.Rectangle{
+		image.Rect(5, 5, 15, 15),
+		image.Rect(15, 5, 25, 15),
+		image.Rect(25, 5, 30, 15),
+		image.Rect(5, 15, 15, 17),
+		image.Rect(15, 15, 25, 17),
+		image.Rect(25, 15, 30, 17),
+	}
+
+	if len(tiles) != len(expected) {
+		t.Fatalf("Expected %d tiles but got %d", len(expected), len(tiles))
+	}
+	for i := range expected {
+		if tiles[i] != expected[i] {
+			t.Errorf("Expected tile %d to be %v but was %v", i, expected[i], tiles[i])
+		}
+	}
+}
+
+func TestKernelApplyResumable(t *testing.T) {
+	img := randomImage(40, 40)
+
+	kernel := KernelWithRadius(1)
+	kernel.SetWeightsUniform([]float32{
+		1, 2, 1,
+		2, 4, 2,
+		1, 2, 1,
+	})
+
+	expectedImg := kernel.ApplyAvg(img, runtime.NumCPU())
+
+	t.Run("produces the same result as untiled application", func(t *testing.T) {
+		checkpoint := NewCheckpoint(img.Rect, 8)
+
+		result, err := kernel.ApplyResumable(context.Background(), img, kernel.Avg, checkpoint, runtime.NumCPU())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		for i := range expectedImg.Pix {
+			if expectedImg.Pix[i] != result.Pix[i] {
+				t.Fatalf("Expected results to match but differ at byte %d", i)
+			}
+		}
+		for i, completed := range checkpoint.Completed {
+			if !completed {
+				t.Errorf("Expected tile %d to be recorded as complete", i)
+			}
+		}
+	})
+
+	t.Run("resumes a cancelled application", func(t *testing.T) {
+		checkpoint := NewCheckpoint(img.Rect, 8)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		cancellingOp := func(img *image.NRGBA, x, y int) color.NRGBA {
+			calls++
+			if calls == 200 {
+				cancel()
+			}
+			return kernel.Avg(img, x, y)
+		}
+
+		_, err := kernel.ApplyResumable(ctx, img, cancellingOp, checkpoint, 1)
+		if err != context.Canceled {
+			t.Fatalf("Expected cancellation error but got %v", err)
+		}
+
+		completedCount := 0
+		for _, completed := range checkpoint.Completed {
+			if completed {
+				completedCount++
+			}
+		}
+		if completedCount == 0 || completedCount == len(checkpoint.Completed) {
+			t.Fatalf("Expected some but not all tiles to be complete but %d of %d were", completedCount, len(checkpoint.Completed))
+		}
+
+		resumedCalls := 0
+		countingOp := func(img *image.NRGBA, x, y int) color.NRGBA {
+			resumedCalls++
+			return kernel.Avg(img, x, y)
+		}
+
+		result, err := kernel.ApplyResumable(context.Background(), img, countingOp, checkpoint, 1)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if expected, actual := (len(checkpoint.Completed)-completedCount)*64, resumedCalls; expected != actual {
+			t.Errorf("Expected %d pixels to be processed on resumption but %d were", expected, actual)
+		}
+		for i := range expectedImg.Pix {
+			if expectedImg.Pix[i] != result.Pix[i] {
+				t.Fatalf("Expected results to match but differ at byte %d", i)
+			}
+		}
+	})
+
+	t.Run("completes every tile with parallelism below one", func(t *testing.T) {
+		for _, parallelism := range []int{0, -2} {
+			checkpoint := NewCheckpoint(img.Rect, 8)
+
+			result, err := kernel.ApplyResumable(context.Background(), img, kernel.Avg, checkpoint, parallelism)
+			if err != nil {
+				t.Fatalf("Unexpected error with parallelism %d: %v", parallelism, err)
+			}
+
+			for i := range expectedImg.Pix {
+				if expectedImg.Pix[i] != result.Pix[i] {
+					t.Fatalf("Expected results with parallelism %d to match but differ at byte %d", parallelism, i)
+				}
+			}
+		}
+	})
+
+	t.Run("rejects a checkpoint for different bounds", func(t *testing.T) {
+		checkpoint := NewCheckpoint(image.Rect(0, 0, 10, 10), 8)
+
+		if _, err := kernel.ApplyResumable(context.Background(), img, kernel.Avg, checkpoint, 1); err == nil {
+			t.Errorf("Expected an error but got none")
+		}
+	})
+}
+
+func TestKernelSetMaxMemoryBytes(t *testing.T) {
+	source := randomImage(61, 47)
+
+	img := image.NewRGBA(source.Rect)
+	for i := img.Rect.Min.Y; i < img.Rect.Max.Y; i++ {
+		for j := img.Rect.Min.X; j < img.Rect.Max.X; j++ {
+			c := source.NRGBAAt(j, i)
+			c.A = 255
+			img.Set(j, i, c)
+		}
+	}
+
+	kernel := KernelWithRadius(2)
+	for i := 0; i < kernel.SideLength(); i++ {
+		for j := 0; j < kernel.SideLength(); j++ {
+			kernel.SetWeightUniform(j, i, float32(i*j+1))
+		}
+	}
+
+	expectedImg := kernel.ApplyAvg(img, runtime.NumCPU())
+
+	t.Run("produces the same result when processing in tiles", func(t *testing.T) {
+		bounded := kernel
+		bounded.SetMaxMemoryBytes(4096)
+
+		if tileSize := bounded.boundedTileSize(bounded.radius, runtime.NumCPU()); tileSize >= img.Rect.Dx() {
+			t.Fatalf("Expected memory budget to force tiling but tile size was %d", tileSize)
+		}
+
+		result := bounded.ApplyAvg(img, runtime.NumCPU())
+
+		for i := range expectedImg.Pix {
+			if expectedImg.Pix[i] != result.Pix[i] {
+				t.Fatalf("Expected results to match but differ at byte %d", i)
+			}
+		}
+	})
+
+	t.Run("keeps each worker's tile and apron within the budget", func(t *testing.T) {
+		bounded := kernel
+
+		for _, budget := range []int{1000, 4096, 100000} {
+			for _, parallelism := range []int{1, 3, 8} {
+				bounded.SetMaxMemoryBytes(budget)
+				tileSize := bounded.boundedTileSize(bounded.radius, parallelism)
+				apronSide := tileSize + bounded.radius*2
+
+				if used := apronSide * apronSide * 4 * parallelism; used > budget && tileSize > 1 {
+					t.Errorf("Expected tile size %d with parallelism %d to fit budget %d but uses %d bytes", tileSize, parallelism, budget, used)
+				}
+			}
+		}
+	})
+	t.Run("treats parallelism below one as a single worker", func(t *testing.T) {
+		bounded := kernel
+		bounded.SetMaxMemoryBytes(4096)
+
+		for _, parallelism := range []int{0, -2} {
+			if expected, actual := bounded.boundedTileSize(bounded.radius, 1), bounded.boundedTileSize(bounded.radius, parallelism); expected != actual {
+				t.Errorf("Expected tile size with parallelism %d to be %d but was %d", parallelism, expected, actual)
+			}
+
+			result := bounded.ApplyAvg(img, parallelism)
+
+			for i := range expectedImg.Pix {
+				if expectedImg.Pix[i] != result.Pix[i] {
+					t.Fatalf("Expected results with parallelism %d to match but differ at byte %d", parallelism, i)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package convolver
+
+// ACESToneMap returns a stage which maps linear HDR values to displayable
+// values using Narkowicz's fit of the ACES filmic curve, after scaling them by
+// exposure. The curve is applied to each channel independently, giving the
+// characteristic desaturation of very bright colours.
+func ACESToneMap(exposure float32) Stage {
+	curve := func(v float32) float32 {
+		v *= exposure
+		if v <= 0 {
+			return 0
+		}
+		return clamp01((v * (2.51*v + 0.03)) / (v*(2.43*v+0.59) + 0.14))
+	}
+
+	return pointwiseStage(func(r, g, b float32) (float32, float32, float32) {
+		return curve(r), curve(g), curve(b)
+	})
+}
+
+// ReinhardToneMap returns a stage which maps linear HDR values to displayable
+// values using Reinhard's operator, after scaling them by exposure. The
+// operator is applied to luminance and colours are scaled to match, which
+// preserves hue.
+//
+// Luminance at or above whitePoint is mapped to white; a whitePoint of zero or
+// less gives the basic operator L / (1 + L), which only approaches white.
+func ReinhardToneMap(exposure, whitePoint float32) Stage {
+	return pointwiseStage(func(r, g, b float32) (float32, float32, float32) {
+		r, g, b = r*exposure, g*exposure, b*exposure
+
+		luminance := 0.2126*r + 0.7152*g + 0.0722*b
+		if luminance <= 0 {
+			return 0, 0, 0
+		}
+
+		mapped := luminance / (1 + luminance)
+		if whitePoint > 0 {
+			mapped = luminance * (1 + luminance/(whitePoint*whitePoint)) / (1 + luminance)
+		}
+
+		scale := mapped / luminance
+		return r * scale, g * scale, b * scale
+	})
+}
+
+func clamp01(v float32) float32 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
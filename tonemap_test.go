@@ -0,0 +1,76 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+)
+
+func TestACESToneMap(t *testing.T) {
+	img := NewFloatImage(image.Rect(0, 0, 3, 1))
+	img.SetRGBA(0, 0, 0, 0, 0, 1)
+	img.SetRGBA(1, 0, 0.18, 0.18, 0.18, 0.5)
+	img.SetRGBA(2, 0, 1000, 1000, 1000, 1)
+
+	result := ACESToneMap(1)(img, runtime.NumCPU())
+
+	if expected, actual := (color.NRGBA{A: 255}), result.NRGBAAt(0, 0); expected != actual {
+		t.Errorf("Expected black to stay black but was %+v", actual)
+	}
+	if mid := result.NRGBAAt(1, 0); mid.R < 100 || mid.R > 160 || mid.A != 128 {
+		t.Errorf("Expected middle grey to map to a mid tone with alpha preserved but was %+v", mid)
+	}
+	if expected, actual := (color.NRGBA{R: 255, G: 255, B: 255, A: 255}), result.NRGBAAt(2, 0); expected != actual {
+		t.Errorf("Expected very bright values to map to white but was %+v", actual)
+	}
+}
+
+func TestReinhardToneMap(t *testing.T) {
+	img := NewFloatImage(image.Rect(0, 0, 3, 1))
+	img.SetRGBA(0, 0, 1, 1, 1, 1)
+	img.SetRGBA(1, 0, 4, 4, 4, 1)
+	img.SetRGBA(2, 0, 8, 2, 0, 1)
+
+	t.Run("compresses luminance", func(t *testing.T) {
+		result := ReinhardToneMap(1, 0)(img, runtime.NumCPU())
+
+		// L / (1 + L) maps 1 to 0.5, which is 188 when sRGB encoded
+		if expected, actual := uint8(188), result.NRGBAAt(0, 0).R; expected != actual {
+			t.Errorf("Expected 1 to map to %d but was %d", expected, actual)
+		}
+		if white := result.NRGBAAt(1, 0); white.R == 255 {
+			t.Errorf("Expected basic operator not to reach white but was %+v", white)
+		}
+
+		hue := result.NRGBAAt(2, 0)
+		if !(hue.R > hue.G && hue.G > hue.B) {
+			t.Errorf("Expected channel order to be preserved but was %+v", hue)
+		}
+	})
+
+	t.Run("maps the white point to white", func(t *testing.T) {
+		result := ReinhardToneMap(1, 4)(img, runtime.NumCPU())
+
+		if expected, actual := (color.NRGBA{R: 255, G: 255, B: 255, A: 255}), result.NRGBAAt(1, 0); expected != actual {
+			t.Errorf("Expected white point to map to %+v but was %+v", expected, actual)
+		}
+	})
+
+	t.Run("applies exposure", func(t *testing.T) {
+		result := ReinhardToneMap(0.25, 0)(img, runtime.NumCPU())
+
+		if expected, actual := uint8(188), result.NRGBAAt(1, 0).R; expected != actual {
+			t.Errorf("Expected exposed value to map to %d but was %d", expected, actual)
+		}
+	})
+
+	t.Run("can begin a pipeline over HDR input", func(t *testing.T) {
+		pipeline := NewPipeline(ReinhardToneMap(1, 0))
+		result := pipeline.Apply(img, runtime.NumCPU())
+
+		if expected, actual := uint8(188), result.NRGBAAt(0, 0).R; expected != actual {
+			t.Errorf("Expected 1 to map to %d but was %d", expected, actual)
+		}
+	})
+}
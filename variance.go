@@ -0,0 +1,97 @@
+package convolver
+
+import (
+	"github.com/mandykoh/prism/srgb"
+	"image"
+	"image/color"
+	"math"
+)
+
+// ApplyVariance applies the Variance operation to an image.
+func (k *Kernel) ApplyVariance(img image.Image, parallelism int) *image.NRGBA {
+	return k.applyImage(img, k.Variance, parallelism)
+}
+
+// ApplyStdDev applies the StdDev operation to an image.
+func (k *Kernel) ApplyStdDev(img image.Image, parallelism int) *image.NRGBA {
+	return k.applyImage(img, k.StdDev, parallelism)
+}
+
+// Variance computes the weighted variance of each colour channel's linear
+// values under the kernel, giving a map of local texture or contrast which is
+// dark in flat areas and bright in detailed ones, as used by adaptive
+// sharpening and focus measures. Alpha is the weighted average of the alpha
+// under the kernel, so the result is as opaque as its surroundings.
+//
+// As with Avg, taps clipped at the edges of the image are left out. Taps with
+// zero or negative weight are ignored.
+func (k *Kernel) Variance(img *image.NRGBA, x, y int) color.NRGBA {
+	return k.localVariance(img, x, y, false)
+}
+
+// StdDev is like Variance, but computes the weighted standard deviation,
+// which is in the same units as the values themselves and so is easier to
+// compare with them or threshold.
+func (k *Kernel) StdDev(img *image.NRGBA, x, y int) color.NRGBA {
+	return k.localVariance(img, x, y, true)
+}
+
+func (k *Kernel) localVariance(img *image.NRGBA, x, y int, stdDev bool) color.NRGBA {
+	clip := k.clipToBounds(img.Rect, x, y)
+
+	var totalWeight, sum, sumSquares kernelWeight
+
+	for s := clip.Top; s < k.sideLength-clip.Bottom; s++ {
+		for t := clip.Left; t < k.sideLength-clip.Right; t++ {
+			weight := k.weights[s*k.sideLength+t]
+			c, a := srgb.ColorFromNRGBA(img.NRGBAAt(x+t-k.radius, y+s-k.radius))
+
+			if weight.R > 0 {
+				totalWeight.R += weight.R
+				sum.R += c.R * weight.R
+				sumSquares.R += c.R * c.R * weight.R
+			}
+			if weight.G > 0 {
+				totalWeight.G += weight.G
+				sum.G += c.G * weight.G
+				sumSquares.G += c.G * c.G * weight.G
+			}
+			if weight.B > 0 {
+				totalWeight.B += weight.B
+				sum.B += c.B * weight.B
+				sumSquares.B += c.B * c.B * weight.B
+			}
+			if weight.A > 0 {
+				totalWeight.A += weight.A
+				sum.A += a * weight.A
+			}
+		}
+	}
+
+	variance := func(sum, sumSquares, totalWeight float32) float32 {
+		if totalWeight <= 0 {
+			return 0
+		}
+
+		mean := sum / totalWeight
+		v := sumSquares/totalWeight - mean*mean
+		if v < 0 {
+			v = 0
+		}
+		if stdDev {
+			v = float32(math.Sqrt(float64(v)))
+		}
+		return v
+	}
+
+	result := kernelWeight{
+		R: variance(sum.R, sumSquares.R, totalWeight.R),
+		G: variance(sum.G, sumSquares.G, totalWeight.G),
+		B: variance(sum.B, sumSquares.B, totalWeight.B),
+	}
+	if totalWeight.A > 0 {
+		result.A = sum.A / totalWeight.A
+	}
+
+	return result.toNRGBA()
+}
@@ -0,0 +1,77 @@
+package convolver
+
+import (
+	"github.com/mandykoh/prism/srgb"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestVariance(t *testing.T) {
+	box := KernelWithRadius(1)
+	box.SetWeightsUniform([]float32{1, 1, 1, 1, 1, 1, 1, 1, 1})
+
+	t.Run("is zero in flat areas with alpha preserved", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+		for i := 0; i < 4; i++ {
+			for j := 0; j < 4; j++ {
+				img.SetNRGBA(j, i, color.NRGBA{R: 200, G: 100, B: 50, A: 180})
+			}
+		}
+
+		for _, apply := range []Stage{box.ApplyVariance, box.ApplyStdDev} {
+			result := apply(img, 2)
+
+			for i := 0; i < 4; i++ {
+				for j := 0; j < 4; j++ {
+					if expected, actual := (color.NRGBA{A: 180}), result.NRGBAAt(j, i); expected != actual {
+						t.Fatalf("Expected result at %d,%d to be %v but was %v", j, i, expected, actual)
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("measures the spread of values under the kernel", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 5, 5))
+		for i := 0; i < 5; i++ {
+			for j := 0; j < 5; j++ {
+				v := uint8(0)
+				if (i+j)%2 == 0 {
+					v = 255
+				}
+				img.SetNRGBA(j, i, color.NRGBA{R: v, G: v, B: v, A: 255})
+			}
+		}
+
+		variance := box.ApplyVariance(img, 2)
+		stdDev := box.ApplyStdDev(img, 2)
+
+		// Five of the nine taps around an even pixel are white.
+		p := float32(5) / 9
+		expectedVariance := srgb.ColorFromLinear(p*(1-p), p*(1-p), p*(1-p)).ToNRGBA(1)
+		if actual := variance.NRGBAAt(2, 2); expectedVariance != actual {
+			t.Errorf("Expected variance to be %v but was %v", expectedVariance, actual)
+		}
+
+		expectedStdDev := srgb.ColorFromLinear(0.4969, 0.4969, 0.4969).ToNRGBA(1)
+		if actual := stdDev.NRGBAAt(2, 2); expectedStdDev != actual {
+			t.Errorf("Expected standard deviation to be %v but was %v", expectedStdDev, actual)
+		}
+	})
+
+	t.Run("is available as registered ops", func(t *testing.T) {
+		img := randomImage(8, 8)
+
+		for name, apply := range map[string]Stage{"variance": box.ApplyVariance, "std-dev": box.ApplyStdDev} {
+			expected := apply(img, 2)
+			actual := box.ApplyOp(img, name, 2)
+
+			for i := range expected.Pix {
+				if expected.Pix[i] != actual.Pix[i] {
+					t.Fatalf("Expected registered %s to match at offset %d", name, i)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package convolver
+
+import (
+	"github.com/mandykoh/prism/linear"
+	"github.com/mandykoh/prism/srgb"
+)
+
+// vectorKernels is a table of implementations of the inner loops which are
+// worth specialising for vector instruction sets such as SSE, AVX2 or NEON.
+// Each entry processes whole rows, so that a vectorised implementation can be
+// substituted without changing the code which uses it.
+//
+// Architecture specific tables are listed in archVectorKernels, in files built
+// only for their architecture, in order of preference. The first whose
+// instructions are supported by the CPU, as reported by golang.org/x/sys/cpu,
+// is selected at initialisation, with the portable Go implementation as the
+// fallback. Building with the purego tag leaves out the assembly.
+type vectorKernels struct {
+	name string
+
+	// supported reports whether the CPU can execute this implementation.
+	supported func() bool
+
+	// accumulate adds each value of src multiplied by weight to the
+	// corresponding value of dst. src must be at least as long as dst.
+	accumulate func(dst, src []float32, weight float32)
+
+	// linearise converts a row of sRGB encoded RGBA bytes in src, laid out as
+	// in image.NRGBA, to linear float RGBA values in dst, with alpha scaled
+	// to 0.0–1.0. dst must be the same length as src.
+	linearise func(dst []float32, src []uint8)
+
+	// quantise converts a row of linear float RGBA values in src to sRGB
+	// encoded RGBA bytes in dst, clipping values to 0.0–1.0. dst must be the
+	// same length as src.
+	quantise func(dst []uint8, src []float32)
+}
+
+var genericVectorKernels = vectorKernels{
+	name:       "generic",
+	supported:  func() bool { return true },
+	accumulate: accumulateGeneric,
+	linearise:  lineariseGeneric,
+	quantise:   quantiseGeneric,
+}
+
+// vectorKernelCandidates lists the available implementations in order of
+// preference.
+var vectorKernelCandidates = append(archVectorKernels[:len(archVectorKernels):len(archVectorKernels)], genericVectorKernels)
+
+var selectedVectorKernels vectorKernels
+
+func init() {
+	selectedVectorKernels = selectVectorKernels(vectorKernelCandidates)
+}
+
+// vector returns the preferred implementation supported by the CPU.
+func vector() *vectorKernels {
+	return &selectedVectorKernels
+}
+
+func selectVectorKernels(candidates []vectorKernels) vectorKernels {
+	for _, c := range candidates {
+		if c.supported() {
+			return c
+		}
+	}
+	return genericVectorKernels
+}
+
+func accumulateGeneric(dst, src []float32, weight float32) {
+	src = src[:len(dst)]
+	for i := range dst {
+		dst[i] += src[i] * weight
+	}
+}
+
+func lineariseGeneric(dst []float32, src []uint8) {
+	dst = dst[:len(src)]
+	for i := 0; i+3 < len(src); i += 4 {
+		dst[i] = srgb.From8Bit(src[i])
+		dst[i+1] = srgb.From8Bit(src[i+1])
+		dst[i+2] = srgb.From8Bit(src[i+2])
+		dst[i+3] = float32(src[i+3]) / 255
+	}
+}
+
+func quantiseGeneric(dst []uint8, src []float32) {
+	dst = dst[:len(src)]
+	for i := 0; i+3 < len(src); i += 4 {
+		dst[i] = srgb.To8Bit(src[i])
+		dst[i+1] = srgb.To8Bit(src[i+1])
+		dst[i+2] = srgb.To8Bit(src[i+2])
+		dst[i+3] = linear.NormalisedTo8Bit(src[i+3])
+	}
+}
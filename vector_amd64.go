@@ -0,0 +1,89 @@
+//go:build !purego
+// +build !purego
+
+package convolver
+
+import (
+	"github.com/mandykoh/prism/srgb"
+	"golang.org/x/sys/cpu"
+)
+
+// archVectorKernels lists the amd64 implementations in order of preference.
+var archVectorKernels = []vectorKernels{
+	{
+		name:      "avx2",
+		supported: func() bool { return cpu.X86.HasAVX2 },
+		accumulate: func(dst, src []float32, weight float32) {
+			accumulateAVX2(dst, src[:len(dst)], weight)
+		},
+		linearise: func(dst []float32, src []uint8) {
+			dst = dst[:len(src)]
+			n := len(src) &^ 7
+			lineariseAVX2(dst[:n], src[:n], &lineariseTable)
+			lineariseGeneric(dst[n:], src[n:])
+		},
+		quantise: func(dst []uint8, src []float32) {
+			dst = dst[:len(src)]
+			n := len(src) &^ 7
+			quantiseAVX2(dst[:n], src[:n], &quantiseTable)
+			quantiseGeneric(dst[n:], src[n:])
+		},
+	},
+	{
+		name:      "sse",
+		supported: func() bool { return cpu.X86.HasSSE2 },
+		accumulate: func(dst, src []float32, weight float32) {
+			accumulateSSE(dst, src[:len(dst)], weight)
+		},
+		linearise: lineariseGeneric,
+		quantise:  quantiseGeneric,
+	},
+}
+
+// lineariseTable holds the linear value of each 8-bit sRGB encoded value,
+// followed by the normalised value of each 8-bit alpha value, so that both
+// can be looked up with a single gather.
+var lineariseTable = func() (table [512]float32) {
+	for i := 0; i < 256; i++ {
+		table[i] = srgb.From8Bit(uint8(i))
+		table[256+i] = float32(i) / 255
+	}
+	return table
+}()
+
+// quantiseTable holds the 8-bit sRGB encoding of each of the 512 levels to
+// which linear values are rounded, as used by srgb.To8Bit, followed by each
+// 8-bit alpha value, so that both can be looked up with a single gather.
+var quantiseTable = func() (table [768]uint32) {
+	for i := 0; i < 512; i++ {
+		table[i] = uint32(srgb.To8Bit(float32(i) / 511))
+	}
+	for i := 0; i < 256; i++ {
+		table[512+i] = uint32(i)
+	}
+	return table
+}()
+
+// accumulateAVX2 is accumulate using 8-wide AVX instructions. src must be the
+// same length as dst.
+//
+//go:noescape
+func accumulateAVX2(dst, src []float32, weight float32)
+
+// lineariseAVX2 is linearise for a multiple of 8 values, using AVX2 gathers
+// from lineariseTable.
+//
+//go:noescape
+func lineariseAVX2(dst []float32, src []uint8, table *[512]float32)
+
+// quantiseAVX2 is quantise for a multiple of 8 values, using AVX2 gathers
+// from quantiseTable.
+//
+//go:noescape
+func quantiseAVX2(dst []uint8, src []float32, table *[768]uint32)
+
+// accumulateSSE is accumulate using 4-wide SSE instructions. src must be the
+// same length as dst.
+//
+//go:noescape
+func accumulateSSE(dst, src []float32, weight float32)
@@ -0,0 +1,177 @@
+//go:build !purego
+// +build !purego
+
+#include "textflag.h"
+
+// func accumulateAVX2(dst, src []float32, weight float32)
+TEXT ·accumulateAVX2(SB), NOSPLIT, $0-52
+	MOVQ         dst_base+0(FP), DI
+	MOVQ         dst_len+8(FP), CX
+	MOVQ         src_base+24(FP), SI
+	VBROADCASTSS weight+48(FP), Y0
+	XORQ         AX, AX
+	MOVQ         CX, DX
+	ANDQ         $-8, DX
+
+accumulateAVX2Loop:
+	CMPQ    AX, DX
+	JAE     accumulateAVX2Tail
+	VMULPS  (SI)(AX*4), Y0, Y1
+	VADDPS  (DI)(AX*4), Y1, Y1
+	VMOVUPS Y1, (DI)(AX*4)
+	ADDQ    $8, AX
+	JMP     accumulateAVX2Loop
+
+accumulateAVX2Tail:
+	VZEROUPPER
+
+accumulateAVX2TailLoop:
+	CMPQ  AX, CX
+	JAE   accumulateAVX2Done
+	MOVSS (SI)(AX*4), X1
+	MULSS X0, X1
+	ADDSS (DI)(AX*4), X1
+	MOVSS X1, (DI)(AX*4)
+	INCQ  AX
+	JMP   accumulateAVX2TailLoop
+
+accumulateAVX2Done:
+	RET
+
+// func accumulateSSE(dst, src []float32, weight float32)
+TEXT ·accumulateSSE(SB), NOSPLIT, $0-52
+	MOVQ   dst_base+0(FP), DI
+	MOVQ   dst_len+8(FP), CX
+	MOVQ   src_base+24(FP), SI
+	MOVSS  weight+48(FP), X0
+	SHUFPS $0, X0, X0
+	XORQ   AX, AX
+	MOVQ   CX, DX
+	ANDQ   $-4, DX
+
+accumulateSSELoop:
+	CMPQ   AX, DX
+	JAE    accumulateSSETail
+	MOVUPS (SI)(AX*4), X1
+	MULPS  X0, X1
+	MOVUPS (DI)(AX*4), X2
+	ADDPS  X1, X2
+	MOVUPS X2, (DI)(AX*4)
+	ADDQ   $4, AX
+	JMP    accumulateSSELoop
+
+accumulateSSETail:
+	CMPQ  AX, CX
+	JAE   accumulateSSEDone
+	MOVSS (SI)(AX*4), X1
+	MULSS X0, X1
+	ADDSS (DI)(AX*4), X1
+	MOVSS X1, (DI)(AX*4)
+	INCQ  AX
+	JMP   accumulateSSETail
+
+accumulateSSEDone:
+	RET
+
+// Offsets into the lookup tables for the alpha value of each pixel.
+DATA lineariseOffsets<>+0(SB)/4, $0
+DATA lineariseOffsets<>+4(SB)/4, $0
+DATA lineariseOffsets<>+8(SB)/4, $0
+DATA lineariseOffsets<>+12(SB)/4, $256
+DATA lineariseOffsets<>+16(SB)/4, $0
+DATA lineariseOffsets<>+20(SB)/4, $0
+DATA lineariseOffsets<>+24(SB)/4, $0
+DATA lineariseOffsets<>+28(SB)/4, $256
+GLOBL lineariseOffsets<>(SB), RODATA|NOPTR, $32
+
+DATA quantiseOffsets<>+0(SB)/4, $0
+DATA quantiseOffsets<>+4(SB)/4, $0
+DATA quantiseOffsets<>+8(SB)/4, $0
+DATA quantiseOffsets<>+12(SB)/4, $512
+DATA quantiseOffsets<>+16(SB)/4, $0
+DATA quantiseOffsets<>+20(SB)/4, $0
+DATA quantiseOffsets<>+24(SB)/4, $0
+DATA quantiseOffsets<>+28(SB)/4, $512
+GLOBL quantiseOffsets<>(SB), RODATA|NOPTR, $32
+
+// Scales from normalised values to table levels: 511 for colour channels, as
+// in linear.NormalisedTo9Bit, and 255 for alpha.
+DATA quantiseScales<>+0(SB)/4, $511.0
+DATA quantiseScales<>+4(SB)/4, $511.0
+DATA quantiseScales<>+8(SB)/4, $511.0
+DATA quantiseScales<>+12(SB)/4, $255.0
+DATA quantiseScales<>+16(SB)/4, $511.0
+DATA quantiseScales<>+20(SB)/4, $511.0
+DATA quantiseScales<>+24(SB)/4, $511.0
+DATA quantiseScales<>+28(SB)/4, $255.0
+GLOBL quantiseScales<>(SB), RODATA|NOPTR, $32
+
+DATA quantiseHalf<>+0(SB)/4, $0.5
+GLOBL quantiseHalf<>(SB), RODATA|NOPTR, $4
+
+DATA quantiseOne<>+0(SB)/4, $1.0
+GLOBL quantiseOne<>(SB), RODATA|NOPTR, $4
+
+// func lineariseAVX2(dst []float32, src []uint8, table *[512]float32)
+TEXT ·lineariseAVX2(SB), NOSPLIT, $0-56
+	MOVQ    dst_base+0(FP), DI
+	MOVQ    src_base+24(FP), SI
+	MOVQ    src_len+32(FP), CX
+	MOVQ    table+48(FP), BX
+	VMOVDQU lineariseOffsets<>(SB), Y2
+	XORQ    AX, AX
+
+lineariseAVX2Loop:
+	CMPQ       AX, CX
+	JAE        lineariseAVX2Done
+	VPMOVZXBD  (SI)(AX*1), Y0
+	VPADDD     Y2, Y0, Y0
+	VPCMPEQD   Y3, Y3, Y3
+	VGATHERDPS Y3, (BX)(Y0*4), Y1
+	VMOVUPS    Y1, (DI)(AX*4)
+	ADDQ       $8, AX
+	JMP        lineariseAVX2Loop
+
+lineariseAVX2Done:
+	VZEROUPPER
+	RET
+
+// func quantiseAVX2(dst []uint8, src []float32, table *[768]uint32)
+TEXT ·quantiseAVX2(SB), NOSPLIT, $0-56
+	MOVQ         dst_base+0(FP), DI
+	MOVQ         src_base+24(FP), SI
+	MOVQ         src_len+32(FP), CX
+	MOVQ         table+48(FP), BX
+	VMOVUPS      quantiseScales<>(SB), Y4
+	VMOVDQU      quantiseOffsets<>(SB), Y5
+	VBROADCASTSS quantiseHalf<>(SB), Y6
+	VXORPS       Y7, Y7, Y7
+	VBROADCASTSS quantiseOne<>(SB), Y8
+	XORQ         AX, AX
+
+quantiseAVX2Loop:
+	CMPQ    AX, CX
+	JAE     quantiseAVX2Done
+	VMOVUPS (SI)(AX*4), Y0
+
+	// Clamp to 0–1, with NaN taken as 0, then scale and round to a level
+	// exactly as linear.NormalisedTo9Bit and NormalisedTo8Bit do.
+	VMAXPS     Y7, Y0, Y0
+	VMINPS     Y8, Y0, Y0
+	VMULPS     Y4, Y0, Y0
+	VADDPS     Y6, Y0, Y0
+	VCVTTPS2DQ Y0, Y0
+	VPADDD     Y5, Y0, Y0
+
+	VPCMPEQD     Y3, Y3, Y3
+	VPGATHERDD   Y3, (BX)(Y0*4), Y1
+	VEXTRACTI128 $1, Y1, X2
+	VPACKUSDW    X2, X1, X1
+	VPACKUSWB    X1, X1, X1
+	MOVQ         X1, (DI)(AX*1)
+	ADDQ         $8, AX
+	JMP          quantiseAVX2Loop
+
+quantiseAVX2Done:
+	VZEROUPPER
+	RET
@@ -0,0 +1,28 @@
+//go:build !purego
+// +build !purego
+
+package convolver
+
+import (
+	"golang.org/x/sys/cpu"
+)
+
+// archVectorKernels lists the arm64 implementations in order of preference.
+var archVectorKernels = []vectorKernels{
+	{
+		name:      "neon",
+		supported: func() bool { return cpu.ARM64.HasASIMD },
+		accumulate: func(dst, src []float32, weight float32) {
+			accumulateNEON(dst, src[:len(dst)], weight)
+		},
+		linearise: lineariseGeneric,
+		quantise:  quantiseGeneric,
+	},
+}
+
+// accumulateNEON is accumulate using 4-wide NEON instructions. Like the Go
+// compiler on arm64, it uses fused multiply-adds. src must be the same length
+// as dst.
+//
+//go:noescape
+func accumulateNEON(dst, src []float32, weight float32)
@@ -0,0 +1,35 @@
+//go:build !purego
+// +build !purego
+
+#include "textflag.h"
+
+// func accumulateNEON(dst, src []float32, weight float32)
+TEXT ·accumulateNEON(SB), NOSPLIT, $0-52
+	MOVD  dst_base+0(FP), R0
+	MOVD  dst_len+8(FP), R1
+	MOVD  src_base+24(FP), R2
+	FMOVS weight+48(FP), F0
+	VDUP  V0.S[0], V1.S4
+	AND   $~3, R1, R3
+	SUB   R3, R1, R1
+
+accumulateNEONLoop:
+	CBZ    R3, accumulateNEONTail
+	VLD1.P 16(R2), [V2.S4]
+	VLD1   (R0), [V3.S4]
+	VFMLA  V1.S4, V2.S4, V3.S4
+	VST1.P [V3.S4], 16(R0)
+	SUB    $4, R3, R3
+	B      accumulateNEONLoop
+
+accumulateNEONTail:
+	CBZ     R1, accumulateNEONDone
+	FMOVS.P 4(R2), F2
+	FMOVS   (R0), F3
+	FMADDS  F0, F3, F2, F3
+	FMOVS.P F3, 4(R0)
+	SUB     $1, R1, R1
+	B       accumulateNEONTail
+
+accumulateNEONDone:
+	RET
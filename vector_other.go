@@ -0,0 +1,8 @@
+//go:build (!amd64 && !arm64) || purego
+// +build !amd64,!arm64 purego
+
+package convolver
+
+// archVectorKernels is empty where no assembly implementations are available,
+// leaving only the portable one.
+var archVectorKernels []vectorKernels
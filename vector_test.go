@@ -0,0 +1,197 @@
+package convolver
+
+import (
+	"github.com/mandykoh/prism/srgb"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestVectorKernels(t *testing.T) {
+	t.Run("accumulate adds weighted values", func(t *testing.T) {
+		dst := []float32{1, 2, 3}
+		src := []float32{4, 5, 6, 7}
+
+		vector().accumulate(dst, src, 0.5)
+
+		for i, expected := range []float32{3, 4.5, 6} {
+			if actual := dst[i]; expected != actual {
+				t.Errorf("Expected value %d to be %v but was %v", i, expected, actual)
+			}
+		}
+	})
+
+	t.Run("linearise matches per-pixel conversion", func(t *testing.T) {
+		src := make([]uint8, 256*4)
+		for i := 0; i < 256; i++ {
+			src[i*4], src[i*4+1], src[i*4+2], src[i*4+3] = uint8(i), uint8(255-i), uint8(i/2), uint8(i)
+		}
+		dst := make([]float32, len(src))
+
+		vector().linearise(dst, src)
+
+		for i := 0; i < 256; i++ {
+			c, a := srgb.ColorFromNRGBA(color.NRGBA{R: src[i*4], G: src[i*4+1], B: src[i*4+2], A: src[i*4+3]})
+			if expected, actual := [4]float32{c.R, c.G, c.B, a}, [4]float32{dst[i*4], dst[i*4+1], dst[i*4+2], dst[i*4+3]}; expected != actual {
+				t.Fatalf("Expected pixel %d to be %v but was %v", i, expected, actual)
+			}
+		}
+	})
+
+	t.Run("quantise matches per-pixel conversion", func(t *testing.T) {
+		src := make([]float32, 300*4)
+		for i := 0; i < 300; i++ {
+			v := float32(i)/256 - 0.05
+			src[i*4], src[i*4+1], src[i*4+2], src[i*4+3] = v, 1-v, v*v, v
+		}
+		dst := make([]uint8, len(src))
+
+		vector().quantise(dst, src)
+
+		for i := 0; i < 300; i++ {
+			expected := srgb.ColorFromLinear(src[i*4], src[i*4+1], src[i*4+2]).ToNRGBA(src[i*4+3])
+			if actual := (color.NRGBA{R: dst[i*4], G: dst[i*4+1], B: dst[i*4+2], A: dst[i*4+3]}); expected != actual {
+				t.Fatalf("Expected pixel %d to be %+v but was %+v", i, expected, actual)
+			}
+		}
+	})
+
+	t.Run("every supported implementation matches the generic one", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(1478))
+
+		for _, candidate := range vectorKernelCandidates {
+			if !candidate.supported() {
+				t.Logf("Skipping %s implementation, which this CPU doesn't support", candidate.name)
+				continue
+			}
+
+			// Lengths cover whole vectors and every size of remainder.
+			for _, length := range []int{0, 1, 3, 4, 7, 8, 15, 16, 17, 33, 260} {
+				src := make([]float32, length+5)
+				expected := make([]float32, length)
+				for i := range src {
+					src[i] = rng.Float32()*4 - 2
+				}
+				for i := range expected {
+					expected[i] = rng.Float32()*4 - 2
+				}
+				actual := append([]float32(nil), expected...)
+				weight := rng.Float32()*2 - 1
+
+				genericVectorKernels.accumulate(expected, src, weight)
+				candidate.accumulate(actual, src, weight)
+
+				for i := range expected {
+					// Fused multiply-adds, as used by the Go compiler on some
+					// architectures, may differ by a rounding step.
+					if diff := math.Abs(float64(expected[i] - actual[i])); diff > 1e-6 {
+						t.Fatalf("Expected %s accumulate of length %d to give %v at %d but was %v", candidate.name, length, expected[i], i, actual[i])
+					}
+				}
+			}
+
+			for _, pixels := range []int{0, 1, 2, 3, 5, 64, 67} {
+				bytes := make([]uint8, pixels*4)
+				rng.Read(bytes)
+				expected := make([]float32, len(bytes))
+				actual := make([]float32, len(bytes))
+
+				genericVectorKernels.linearise(expected, bytes)
+				candidate.linearise(actual, bytes)
+
+				for i := range expected {
+					if expected[i] != actual[i] {
+						t.Fatalf("Expected %s linearise of %d pixels to give %v at %d but was %v", candidate.name, pixels, expected[i], i, actual[i])
+					}
+				}
+
+				floats := make([]float32, pixels*4)
+				for i := range floats {
+					floats[i] = rng.Float32()*1.2 - 0.1
+				}
+				expectedBytes := make([]uint8, len(floats))
+				actualBytes := make([]uint8, len(floats))
+
+				genericVectorKernels.quantise(expectedBytes, floats)
+				candidate.quantise(actualBytes, floats)
+
+				for i := range expectedBytes {
+					if expectedBytes[i] != actualBytes[i] {
+						t.Fatalf("Expected %s quantise of %d pixels to give %v at %d but was %v", candidate.name, pixels, expectedBytes[i], i, actualBytes[i])
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("selects the first supported candidate", func(t *testing.T) {
+		unsupported := vectorKernels{name: "unsupported", supported: func() bool { return false }}
+		preferred := vectorKernels{name: "preferred", supported: func() bool { return true }}
+
+		if expected, actual := "preferred", selectVectorKernels([]vectorKernels{unsupported, preferred, genericVectorKernels}).name; expected != actual {
+			t.Errorf("Expected %s implementation to be selected but was %s", expected, actual)
+		}
+		if expected, actual := "generic", selectVectorKernels([]vectorKernels{unsupported}).name; expected != actual {
+			t.Errorf("Expected %s implementation to be selected but was %s", expected, actual)
+		}
+	})
+}
+
+func BenchmarkVectorKernels(b *testing.B) {
+	const pixels = 1024
+
+	encoded := make([]uint8, pixels*4)
+	rand.New(rand.NewSource(1479)).Read(encoded)
+	linear := make([]float32, pixels*4)
+	genericVectorKernels.linearise(linear, encoded)
+
+	b.Run("per-pixel linearise", func(b *testing.B) {
+		b.SetBytes(int64(len(encoded)))
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < len(encoded); j += 4 {
+				c, a := srgb.ColorFromNRGBA(color.NRGBA{R: encoded[j], G: encoded[j+1], B: encoded[j+2], A: encoded[j+3]})
+				linear[j], linear[j+1], linear[j+2], linear[j+3] = c.R, c.G, c.B, a
+			}
+		}
+	})
+
+	b.Run("per-pixel quantise", func(b *testing.B) {
+		b.SetBytes(int64(len(encoded)))
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < len(linear); j += 4 {
+				c := srgb.ColorFromLinear(linear[j], linear[j+1], linear[j+2]).ToNRGBA(linear[j+3])
+				encoded[j], encoded[j+1], encoded[j+2], encoded[j+3] = c.R, c.G, c.B, c.A
+			}
+		}
+	})
+
+	for _, candidate := range vectorKernelCandidates {
+		if !candidate.supported() {
+			continue
+		}
+		candidate := candidate
+
+		b.Run(candidate.name+" linearise", func(b *testing.B) {
+			b.SetBytes(int64(len(encoded)))
+			for i := 0; i < b.N; i++ {
+				candidate.linearise(linear, encoded)
+			}
+		})
+
+		b.Run(candidate.name+" quantise", func(b *testing.B) {
+			b.SetBytes(int64(len(encoded)))
+			for i := 0; i < b.N; i++ {
+				candidate.quantise(encoded, linear)
+			}
+		})
+
+		b.Run(candidate.name+" accumulate", func(b *testing.B) {
+			dst := make([]float32, len(linear))
+			b.SetBytes(int64(len(linear) * 4))
+			for i := 0; i < b.N; i++ {
+				candidate.accumulate(dst, linear, 0.25)
+			}
+		})
+	}
+}
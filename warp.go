@@ -0,0 +1,181 @@
+package convolver
+
+import (
+	"github.com/mandykoh/prism/srgb"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Warp is a linear transform of a kernel's sampling grid. The tap at offset
+// (u, v) from the kernel's centre samples the image at offset
+// (XX*u + XY*v, YX*u + YY*v) from the pixel being computed, so that a single
+// kernel such as a Gaussian can be stretched and rotated into elliptical and
+// oriented blurs without computing new weights.
+type Warp struct {
+	XX, XY float64
+	YX, YY float64
+}
+
+// IdentityWarp leaves the sampling grid unchanged.
+var IdentityWarp = Warp{XX: 1, YY: 1}
+
+// RotationWarp returns a warp which scales the sampling grid by scaleX and
+// scaleY along the kernel's axes, then rotates it by theta radians. With y
+// increasing downwards, positive angles rotate clockwise as seen on screen.
+func RotationWarp(theta, scaleX, scaleY float64) Warp {
+	sin, cos := math.Sincos(theta)
+
+	return Warp{
+		XX: cos * scaleX, XY: -sin * scaleY,
+		YX: sin * scaleX, YY: cos * scaleY,
+	}
+}
+
+// apply returns the offset sampled by the tap at offset u, v.
+func (w Warp) apply(u, v float64) (float64, float64) {
+	return w.XX*u + w.XY*v, w.YX*u + w.YY*v
+}
+
+// ApplyAvgWarped applies the operation returned by AvgWarped to an image.
+// Edge modes other than EdgeClip pad the image far enough for every warped
+// tap, and images processed in tiles to stay within the memory budget have
+// aprons wide enough for them too.
+func (k *Kernel) ApplyAvgWarped(img image.Image, w Warp, parallelism int) *image.NRGBA {
+	op, reach := k.avgWarped(w)
+	return k.applyImageReaching(img, op, reach, parallelism)
+}
+
+// AvgWarped returns an operation like Avg, but with the kernel's sampling
+// grid transformed by w. Warped taps generally fall between pixels, so values
+// are interpolated bilinearly in linear light. Taps falling outside the image
+// are ignored and the average renormalised, as with Avg.
+func (k *Kernel) AvgWarped(w Warp) OpFunc {
+	op, _ := k.avgWarped(w)
+	return op
+}
+
+// avgWarped returns the warped average operation, along with the distance in
+// whole pixels beyond which no tap samples.
+func (k *Kernel) avgWarped(w Warp) (OpFunc, int) {
+	type warpedTap struct {
+		dx, dy float64
+		weight kernelWeight
+	}
+
+	var taps []warpedTap
+	reach := 0
+
+	for s := 0; s < k.sideLength; s++ {
+		for t := 0; t < k.sideLength; t++ {
+			weight := k.weights[s*k.sideLength+t]
+			if weight == (kernelWeight{}) {
+				continue
+			}
+
+			dx, dy := w.apply(float64(t-k.radius), float64(s-k.radius))
+			dx, dy = snapToPixel(dx), snapToPixel(dy)
+			taps = append(taps, warpedTap{dx: dx, dy: dy, weight: weight})
+
+			for _, d := range []float64{dx, dy} {
+				if r := int(math.Ceil(math.Abs(d))); r > reach {
+					reach = r
+				}
+			}
+		}
+	}
+
+	op := func(img *image.NRGBA, x, y int) color.NRGBA {
+		totalWeight := kernelWeight{}
+		sum := kernelWeight{}
+
+		for _, tap := range taps {
+			c, ok := sampleBilinearNRGBA(img, float64(x)+tap.dx, float64(y)+tap.dy)
+			if !ok {
+				continue
+			}
+
+			totalWeight.R += tap.weight.R
+			totalWeight.G += tap.weight.G
+			totalWeight.B += tap.weight.B
+			totalWeight.A += tap.weight.A
+
+			sum.R += c.R * tap.weight.R
+			sum.G += c.G * tap.weight.G
+			sum.B += c.B * tap.weight.B
+			sum.A += c.A * tap.weight.A
+		}
+
+		if totalWeight.R > 0 {
+			sum.R /= totalWeight.R
+		}
+		if totalWeight.G > 0 {
+			sum.G /= totalWeight.G
+		}
+		if totalWeight.B > 0 {
+			sum.B /= totalWeight.B
+		}
+		if totalWeight.A > 0 {
+			sum.A /= totalWeight.A
+		}
+
+		return k.averageToNRGBA(x, y, sum)
+	}
+
+	return op, reach
+}
+
+// sampleBilinearNRGBA returns the bilinearly interpolated linear colour and
+// alpha of an image at a point, or false if the point lies outside the
+// centres of the image's edge pixels.
+func sampleBilinearNRGBA(img *image.NRGBA, x, y float64) (kernelWeight, bool) {
+	floorX, floorY := math.Floor(x), math.Floor(y)
+	x0, y0 := int(floorX), int(floorY)
+	fx, fy := float32(x-floorX), float32(y-floorY)
+
+	if x0 < img.Rect.Min.X || y0 < img.Rect.Min.Y || x0 >= img.Rect.Max.X || y0 >= img.Rect.Max.Y {
+		return kernelWeight{}, false
+	}
+
+	x1, y1 := x0+1, y0+1
+	if x1 == img.Rect.Max.X {
+		if fx > 0 {
+			return kernelWeight{}, false
+		}
+		x1 = x0
+	}
+	if y1 == img.Rect.Max.Y {
+		if fy > 0 {
+			return kernelWeight{}, false
+		}
+		y1 = y0
+	}
+
+	at := func(x, y int) kernelWeight {
+		c, a := srgb.ColorFromNRGBA(img.NRGBAAt(x, y))
+		return kernelWeight{R: c.R, G: c.G, B: c.B, A: a}
+	}
+
+	lerp := func(a, b kernelWeight, t float32) kernelWeight {
+		return kernelWeight{
+			R: a.R + (b.R-a.R)*t,
+			G: a.G + (b.G-a.G)*t,
+			B: a.B + (b.B-a.B)*t,
+			A: a.A + (b.A-a.A)*t,
+		}
+	}
+
+	top := lerp(at(x0, y0), at(x1, y0), fx)
+	bottom := lerp(at(x0, y1), at(x1, y1), fx)
+
+	return lerp(top, bottom, fy), true
+}
+
+// snapToPixel rounds offsets within rounding error of a whole pixel, such as
+// those from a rotation by a right angle, so that they sample exactly.
+func snapToPixel(v float64) float64 {
+	if r := math.Round(v); math.Abs(v-r) < 1e-9 {
+		return r
+	}
+	return v
+}
@@ -0,0 +1,107 @@
+package convolver
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"runtime"
+	"testing"
+)
+
+func TestApplyAvgWarped(t *testing.T) {
+	img := randomImage(30, 20)
+
+	checkClose := func(t *testing.T, expected, actual *image.NRGBA, region image.Rectangle) {
+		t.Helper()
+
+		for i := region.Min.Y; i < region.Max.Y; i++ {
+			for j := region.Min.X; j < region.Max.X; j++ {
+				e, a := expected.NRGBAAt(j, i), actual.NRGBAAt(j, i)
+				if absDiff(e.R, a.R) > 1 || absDiff(e.G, a.G) > 1 || absDiff(e.B, a.B) > 1 || absDiff(e.A, a.A) > 1 {
+					t.Fatalf("Expected %v but was %v at %d, %d", e, a, j, i)
+				}
+			}
+		}
+	}
+
+	tapKernel := func(radius, x, y int) Kernel {
+		kernel := KernelWithRadius(radius)
+		kernel.SetWeightUniform(x+radius, y+radius, 1)
+		return kernel
+	}
+
+	t.Run("matches ApplyAvg with the identity warp", func(t *testing.T) {
+		kernel := GaussianKernel(1.5)
+
+		checkClose(t, kernel.ApplyAvg(img, runtime.NumCPU()), kernel.ApplyAvgWarped(img, IdentityWarp, runtime.NumCPU()), img.Rect)
+	})
+
+	t.Run("rotates the sampling grid", func(t *testing.T) {
+		left := tapKernel(1, -1, 0)
+		up := tapKernel(1, 0, -1)
+
+		checkClose(t, up.ApplyAvg(img, runtime.NumCPU()), left.ApplyAvgWarped(img, RotationWarp(math.Pi/2, 1, 1), runtime.NumCPU()), img.Rect)
+	})
+
+	t.Run("stretches the sampling grid", func(t *testing.T) {
+		left := tapKernel(1, -1, 0)
+		farLeft := tapKernel(2, -2, 0)
+
+		checkClose(t, farLeft.ApplyAvg(img, runtime.NumCPU()), left.ApplyAvgWarped(img, RotationWarp(0, 2, 1), runtime.NumCPU()), img.Rect)
+	})
+
+	t.Run("interpolates between pixels", func(t *testing.T) {
+		left := tapKernel(1, -1, 0)
+
+		pair := KernelWithRadius(1)
+		pair.SetWeightsUniform([]float32{
+			0, 0, 0,
+			1, 1, 0,
+			0, 0, 0,
+		})
+
+		checkClose(t, pair.ApplyAvg(img, runtime.NumCPU()), left.ApplyAvgWarped(img, RotationWarp(0, 0.5, 1), runtime.NumCPU()), image.Rect(1, 0, 30, 20))
+	})
+
+	t.Run("pads far enough for the warped kernel with edge modes", func(t *testing.T) {
+		flat := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+		for i := 0; i < len(flat.Pix); i += 4 {
+			flat.Pix[i], flat.Pix[i+1], flat.Pix[i+2], flat.Pix[i+3] = 200, 100, 50, 255
+		}
+
+		kernel := GaussianKernel(1)
+		kernel.SetEdgeMode(EdgeZero)
+		result := kernel.ApplyAvgWarped(flat, RotationWarp(0.3, 3, 1), runtime.NumCPU())
+
+		if expected, actual := (color.NRGBA{R: 200, G: 100, B: 50, A: 255}), result.NRGBAAt(20, 10); absDiff(expected.A, actual.A) > 1 {
+			t.Errorf("Expected interior to be close to %v but was %v", expected, actual)
+		}
+
+		// Taps stretched beyond the kernel's own radius see transparent black
+		// rather than being clipped
+		if actual := result.NRGBAAt(0, 10).A; actual > 200 {
+			t.Errorf("Expected edge alpha to fade but was %d", actual)
+		}
+	})
+
+	t.Run("gives the same results within a memory budget", func(t *testing.T) {
+		rgba := image.NewRGBA(img.Rect)
+		draw.Draw(rgba, img.Rect, img, img.Rect.Min, draw.Src)
+
+		for _, mode := range []EdgeMode{EdgeClip, EdgeZero, EdgeMirror} {
+			kernel := GaussianKernel(1)
+			kernel.SetEdgeMode(mode)
+			expected := kernel.ApplyAvgWarped(rgba, RotationWarp(0, 2, 2), 3)
+
+			kernel.SetMaxMemoryBytes(1024)
+			actual := kernel.ApplyAvgWarped(rgba, RotationWarp(0, 2, 2), 3)
+
+			for i := range expected.Pix {
+				if expected.Pix[i] != actual.Pix[i] {
+					t.Fatalf("Expected memory bounded result with edge mode %v to match at offset %d", mode, i)
+				}
+			}
+		}
+	})
+}